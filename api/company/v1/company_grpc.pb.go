@@ -0,0 +1,571 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: api/company/v1/company.proto
+
+package companyv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CompanyService_GetCompany_FullMethodName     = "/xm.company.v1.CompanyService/GetCompany"
+	CompanyService_ListCompanies_FullMethodName  = "/xm.company.v1.CompanyService/ListCompanies"
+	CompanyService_CreateCompany_FullMethodName  = "/xm.company.v1.CompanyService/CreateCompany"
+	CompanyService_UpdateCompany_FullMethodName  = "/xm.company.v1.CompanyService/UpdateCompany"
+	CompanyService_DeleteCompany_FullMethodName  = "/xm.company.v1.CompanyService/DeleteCompany"
+	CompanyService_ListChildren_FullMethodName   = "/xm.company.v1.CompanyService/ListChildren"
+	CompanyService_ListAncestors_FullMethodName  = "/xm.company.v1.CompanyService/ListAncestors"
+	CompanyService_ListSubtree_FullMethodName    = "/xm.company.v1.CompanyService/ListSubtree"
+	CompanyService_AddTags_FullMethodName        = "/xm.company.v1.CompanyService/AddTags"
+	CompanyService_RemoveTags_FullMethodName     = "/xm.company.v1.CompanyService/RemoveTags"
+	CompanyService_ListTags_FullMethodName       = "/xm.company.v1.CompanyService/ListTags"
+	CompanyService_WatchCompanies_FullMethodName = "/xm.company.v1.CompanyService/WatchCompanies"
+)
+
+// CompanyServiceClient is the client API for CompanyService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CompanyServiceClient interface {
+	GetCompany(ctx context.Context, in *GetCompanyRequest, opts ...grpc.CallOption) (*Company, error)
+	ListCompanies(ctx context.Context, in *ListCompaniesRequest, opts ...grpc.CallOption) (*ListCompaniesResponse, error)
+	CreateCompany(ctx context.Context, in *CreateCompanyRequest, opts ...grpc.CallOption) (*Company, error)
+	UpdateCompany(ctx context.Context, in *UpdateCompanyRequest, opts ...grpc.CallOption) (*Company, error)
+	// DeleteCompany soft deletes the company, handling its subsidiaries as
+	// the request says.
+	DeleteCompany(ctx context.Context, in *DeleteCompanyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListChildren lists the subsidiaries of the company, ordered by name.
+	ListChildren(ctx context.Context, in *ListHierarchyRequest, opts ...grpc.CallOption) (*ListHierarchyResponse, error)
+	// ListAncestors lists the parent of the company, its parent and so on up
+	// to the top of the group.
+	ListAncestors(ctx context.Context, in *ListHierarchyRequest, opts ...grpc.CallOption) (*ListHierarchyResponse, error)
+	// ListSubtree lists the company followed by every subsidiary below it,
+	// level by level.
+	ListSubtree(ctx context.Context, in *ListHierarchyRequest, opts ...grpc.CallOption) (*ListHierarchyResponse, error)
+	// AddTags tags the company, which keeps the tags it already has.
+	AddTags(ctx context.Context, in *AddTagsRequest, opts ...grpc.CallOption) (*Company, error)
+	// RemoveTags removes the tags from the company, ignoring those it does not
+	// have.
+	RemoveTags(ctx context.Context, in *RemoveTagsRequest, opts ...grpc.CallOption) (*Company, error)
+	// ListTags lists the tags of the companies, with the number of companies
+	// that have each, ordered by name.
+	ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResponse, error)
+	// WatchCompanies streams the changes made to the companies from now on.
+	WatchCompanies(ctx context.Context, in *WatchCompaniesRequest, opts ...grpc.CallOption) (CompanyService_WatchCompaniesClient, error)
+}
+
+type companyServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCompanyServiceClient(cc grpc.ClientConnInterface) CompanyServiceClient {
+	return &companyServiceClient{cc}
+}
+
+func (c *companyServiceClient) GetCompany(ctx context.Context, in *GetCompanyRequest, opts ...grpc.CallOption) (*Company, error) {
+	out := new(Company)
+	err := c.cc.Invoke(ctx, CompanyService_GetCompany_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) ListCompanies(ctx context.Context, in *ListCompaniesRequest, opts ...grpc.CallOption) (*ListCompaniesResponse, error) {
+	out := new(ListCompaniesResponse)
+	err := c.cc.Invoke(ctx, CompanyService_ListCompanies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) CreateCompany(ctx context.Context, in *CreateCompanyRequest, opts ...grpc.CallOption) (*Company, error) {
+	out := new(Company)
+	err := c.cc.Invoke(ctx, CompanyService_CreateCompany_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) UpdateCompany(ctx context.Context, in *UpdateCompanyRequest, opts ...grpc.CallOption) (*Company, error) {
+	out := new(Company)
+	err := c.cc.Invoke(ctx, CompanyService_UpdateCompany_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) DeleteCompany(ctx context.Context, in *DeleteCompanyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, CompanyService_DeleteCompany_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) ListChildren(ctx context.Context, in *ListHierarchyRequest, opts ...grpc.CallOption) (*ListHierarchyResponse, error) {
+	out := new(ListHierarchyResponse)
+	err := c.cc.Invoke(ctx, CompanyService_ListChildren_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) ListAncestors(ctx context.Context, in *ListHierarchyRequest, opts ...grpc.CallOption) (*ListHierarchyResponse, error) {
+	out := new(ListHierarchyResponse)
+	err := c.cc.Invoke(ctx, CompanyService_ListAncestors_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) ListSubtree(ctx context.Context, in *ListHierarchyRequest, opts ...grpc.CallOption) (*ListHierarchyResponse, error) {
+	out := new(ListHierarchyResponse)
+	err := c.cc.Invoke(ctx, CompanyService_ListSubtree_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) AddTags(ctx context.Context, in *AddTagsRequest, opts ...grpc.CallOption) (*Company, error) {
+	out := new(Company)
+	err := c.cc.Invoke(ctx, CompanyService_AddTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) RemoveTags(ctx context.Context, in *RemoveTagsRequest, opts ...grpc.CallOption) (*Company, error) {
+	out := new(Company)
+	err := c.cc.Invoke(ctx, CompanyService_RemoveTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) ListTags(ctx context.Context, in *ListTagsRequest, opts ...grpc.CallOption) (*ListTagsResponse, error) {
+	out := new(ListTagsResponse)
+	err := c.cc.Invoke(ctx, CompanyService_ListTags_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *companyServiceClient) WatchCompanies(ctx context.Context, in *WatchCompaniesRequest, opts ...grpc.CallOption) (CompanyService_WatchCompaniesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompanyService_ServiceDesc.Streams[0], CompanyService_WatchCompanies_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &companyServiceWatchCompaniesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CompanyService_WatchCompaniesClient interface {
+	Recv() (*CompanyEvent, error)
+	grpc.ClientStream
+}
+
+type companyServiceWatchCompaniesClient struct {
+	grpc.ClientStream
+}
+
+func (x *companyServiceWatchCompaniesClient) Recv() (*CompanyEvent, error) {
+	m := new(CompanyEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CompanyServiceServer is the server API for CompanyService service.
+// All implementations must embed UnimplementedCompanyServiceServer
+// for forward compatibility
+type CompanyServiceServer interface {
+	GetCompany(context.Context, *GetCompanyRequest) (*Company, error)
+	ListCompanies(context.Context, *ListCompaniesRequest) (*ListCompaniesResponse, error)
+	CreateCompany(context.Context, *CreateCompanyRequest) (*Company, error)
+	UpdateCompany(context.Context, *UpdateCompanyRequest) (*Company, error)
+	// DeleteCompany soft deletes the company, handling its subsidiaries as
+	// the request says.
+	DeleteCompany(context.Context, *DeleteCompanyRequest) (*emptypb.Empty, error)
+	// ListChildren lists the subsidiaries of the company, ordered by name.
+	ListChildren(context.Context, *ListHierarchyRequest) (*ListHierarchyResponse, error)
+	// ListAncestors lists the parent of the company, its parent and so on up
+	// to the top of the group.
+	ListAncestors(context.Context, *ListHierarchyRequest) (*ListHierarchyResponse, error)
+	// ListSubtree lists the company followed by every subsidiary below it,
+	// level by level.
+	ListSubtree(context.Context, *ListHierarchyRequest) (*ListHierarchyResponse, error)
+	// AddTags tags the company, which keeps the tags it already has.
+	AddTags(context.Context, *AddTagsRequest) (*Company, error)
+	// RemoveTags removes the tags from the company, ignoring those it does not
+	// have.
+	RemoveTags(context.Context, *RemoveTagsRequest) (*Company, error)
+	// ListTags lists the tags of the companies, with the number of companies
+	// that have each, ordered by name.
+	ListTags(context.Context, *ListTagsRequest) (*ListTagsResponse, error)
+	// WatchCompanies streams the changes made to the companies from now on.
+	WatchCompanies(*WatchCompaniesRequest, CompanyService_WatchCompaniesServer) error
+	mustEmbedUnimplementedCompanyServiceServer()
+}
+
+// UnimplementedCompanyServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCompanyServiceServer struct {
+}
+
+func (UnimplementedCompanyServiceServer) GetCompany(context.Context, *GetCompanyRequest) (*Company, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCompany not implemented")
+}
+func (UnimplementedCompanyServiceServer) ListCompanies(context.Context, *ListCompaniesRequest) (*ListCompaniesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCompanies not implemented")
+}
+func (UnimplementedCompanyServiceServer) CreateCompany(context.Context, *CreateCompanyRequest) (*Company, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCompany not implemented")
+}
+func (UnimplementedCompanyServiceServer) UpdateCompany(context.Context, *UpdateCompanyRequest) (*Company, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCompany not implemented")
+}
+func (UnimplementedCompanyServiceServer) DeleteCompany(context.Context, *DeleteCompanyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCompany not implemented")
+}
+func (UnimplementedCompanyServiceServer) ListChildren(context.Context, *ListHierarchyRequest) (*ListHierarchyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChildren not implemented")
+}
+func (UnimplementedCompanyServiceServer) ListAncestors(context.Context, *ListHierarchyRequest) (*ListHierarchyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAncestors not implemented")
+}
+func (UnimplementedCompanyServiceServer) ListSubtree(context.Context, *ListHierarchyRequest) (*ListHierarchyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSubtree not implemented")
+}
+func (UnimplementedCompanyServiceServer) AddTags(context.Context, *AddTagsRequest) (*Company, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddTags not implemented")
+}
+func (UnimplementedCompanyServiceServer) RemoveTags(context.Context, *RemoveTagsRequest) (*Company, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveTags not implemented")
+}
+func (UnimplementedCompanyServiceServer) ListTags(context.Context, *ListTagsRequest) (*ListTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTags not implemented")
+}
+func (UnimplementedCompanyServiceServer) WatchCompanies(*WatchCompaniesRequest, CompanyService_WatchCompaniesServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchCompanies not implemented")
+}
+func (UnimplementedCompanyServiceServer) mustEmbedUnimplementedCompanyServiceServer() {}
+
+// UnsafeCompanyServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CompanyServiceServer will
+// result in compilation errors.
+type UnsafeCompanyServiceServer interface {
+	mustEmbedUnimplementedCompanyServiceServer()
+}
+
+func RegisterCompanyServiceServer(s grpc.ServiceRegistrar, srv CompanyServiceServer) {
+	s.RegisterService(&CompanyService_ServiceDesc, srv)
+}
+
+func _CompanyService_GetCompany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCompanyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).GetCompany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_GetCompany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).GetCompany(ctx, req.(*GetCompanyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_ListCompanies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCompaniesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).ListCompanies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_ListCompanies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).ListCompanies(ctx, req.(*ListCompaniesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_CreateCompany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCompanyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).CreateCompany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_CreateCompany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).CreateCompany(ctx, req.(*CreateCompanyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_UpdateCompany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCompanyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).UpdateCompany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_UpdateCompany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).UpdateCompany(ctx, req.(*UpdateCompanyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_DeleteCompany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCompanyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).DeleteCompany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_DeleteCompany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).DeleteCompany(ctx, req.(*DeleteCompanyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_ListChildren_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHierarchyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).ListChildren(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_ListChildren_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).ListChildren(ctx, req.(*ListHierarchyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_ListAncestors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHierarchyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).ListAncestors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_ListAncestors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).ListAncestors(ctx, req.(*ListHierarchyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_ListSubtree_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHierarchyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).ListSubtree(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_ListSubtree_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).ListSubtree(ctx, req.(*ListHierarchyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_AddTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).AddTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_AddTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).AddTags(ctx, req.(*AddTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_RemoveTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).RemoveTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_RemoveTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).RemoveTags(ctx, req.(*RemoveTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_ListTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTagsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompanyServiceServer).ListTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CompanyService_ListTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompanyServiceServer).ListTags(ctx, req.(*ListTagsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompanyService_WatchCompanies_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchCompaniesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CompanyServiceServer).WatchCompanies(m, &companyServiceWatchCompaniesServer{stream})
+}
+
+type CompanyService_WatchCompaniesServer interface {
+	Send(*CompanyEvent) error
+	grpc.ServerStream
+}
+
+type companyServiceWatchCompaniesServer struct {
+	grpc.ServerStream
+}
+
+func (x *companyServiceWatchCompaniesServer) Send(m *CompanyEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CompanyService_ServiceDesc is the grpc.ServiceDesc for CompanyService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CompanyService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "xm.company.v1.CompanyService",
+	HandlerType: (*CompanyServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCompany",
+			Handler:    _CompanyService_GetCompany_Handler,
+		},
+		{
+			MethodName: "ListCompanies",
+			Handler:    _CompanyService_ListCompanies_Handler,
+		},
+		{
+			MethodName: "CreateCompany",
+			Handler:    _CompanyService_CreateCompany_Handler,
+		},
+		{
+			MethodName: "UpdateCompany",
+			Handler:    _CompanyService_UpdateCompany_Handler,
+		},
+		{
+			MethodName: "DeleteCompany",
+			Handler:    _CompanyService_DeleteCompany_Handler,
+		},
+		{
+			MethodName: "ListChildren",
+			Handler:    _CompanyService_ListChildren_Handler,
+		},
+		{
+			MethodName: "ListAncestors",
+			Handler:    _CompanyService_ListAncestors_Handler,
+		},
+		{
+			MethodName: "ListSubtree",
+			Handler:    _CompanyService_ListSubtree_Handler,
+		},
+		{
+			MethodName: "AddTags",
+			Handler:    _CompanyService_AddTags_Handler,
+		},
+		{
+			MethodName: "RemoveTags",
+			Handler:    _CompanyService_RemoveTags_Handler,
+		},
+		{
+			MethodName: "ListTags",
+			Handler:    _CompanyService_ListTags_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchCompanies",
+			Handler:       _CompanyService_WatchCompanies_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/company/v1/company.proto",
+}
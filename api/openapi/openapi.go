@@ -0,0 +1,29 @@
+// Package openapi embeds the OpenAPI specification of the REST API, which is
+// maintained by hand in openapi.yaml alongside routes.go.
+package openapi
+
+import (
+	_ "embed"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+// Load parses the specification and checks that it is valid.
+func Load() (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	err = doc.Validate(loader.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
@@ -1,17 +1,32 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/api/openapi"
+	"github.com/petrostrak/xm-companies/db"
+	"github.com/petrostrak/xm-companies/internal/adapters/cache"
+	"github.com/petrostrak/xm-companies/internal/adapters/graph"
 	"github.com/petrostrak/xm-companies/internal/adapters/handlers"
 	"github.com/petrostrak/xm-companies/internal/adapters/kafka/producer"
+	"github.com/petrostrak/xm-companies/internal/adapters/ratelimit"
 	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/adapters/rpc"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
 	"github.com/petrostrak/xm-companies/internal/core/services"
 	"github.com/petrostrak/xm-companies/utils"
+	"google.golang.org/grpc"
 )
 
 type Application struct {
@@ -19,6 +34,21 @@ type Application struct {
 	Config              *utils.Config
 	CompanyService      *services.CompanyService
 	CompanyHandler      *handlers.CompanyHandler
+	JobService          *services.JobService
+	JobHandler          *handlers.JobHandler
+	StreamHandler       *handlers.StreamHandler
+	WebhookService      *services.WebhookService
+	WebhookHandler      *handlers.WebhookHandler
+	AttributeHandler    *handlers.AttributeHandler
+	GraphQLHandler      *graph.Handler
+	DocsHandler         *handlers.DocsHandler
+	OpenAPIValidation   func(http.Handler) http.Handler
+	ReadYourWrites      func(http.Handler) http.Handler
+	IdempotencyService  *services.IdempotencyService
+	RateLimitService    *services.RateLimitService
+	CompanyCache        *cache.CompanyRepository
+	Broadcaster         *services.Broadcaster
+	GRPCServer          *grpc.Server
 	Routes              *chi.Mux
 	AuthenticationToken *jwtauth.JWTAuth
 	KafkaProducer       *kafka.Producer
@@ -30,24 +60,340 @@ func (app *Application) New() error {
 		return err
 	}
 
-	store := repository.NewPostgresRepository()
-	companyService := services.NewCompanyService(store.CompanyRepository)
-	companyHandler := handlers.NewCompanyHandler(*companyService)
+	prod, err := producer.GetNewProducer()
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+
+	repos, err := newRepositories(config, logger)
+	if err != nil {
+		return err
+	}
+
+	companyRepo := repos.companies
+	companyCache, err := newCompanyCache(config)
+	if err != nil {
+		return err
+	}
+	if companyCache != nil {
+		app.CompanyCache = cache.NewCompanyRepository(companyRepo, companyCache)
+		companyRepo = app.CompanyCache
+		expvar.Publish("company_cache", expvar.Func(func() any {
+			return app.CompanyCache.Stats()
+		}))
+	}
+
+	if config.StreamSource != "local" && config.StreamSource != "kafka" {
+		return fmt.Errorf("unknown stream source %q", config.StreamSource)
+	}
+
+	webhookService := services.NewWebhookService(repos.webhooks, services.WebhookConfig{
+		Concurrency:          config.WebhookConcurrency,
+		PollInterval:         config.WebhookPollInterval,
+		Timeout:              config.WebhookTimeout,
+		MaxAttempts:          config.WebhookMaxAttempts,
+		Backoff:              config.WebhookBackoff,
+		MaxBackoff:           config.WebhookMaxBackoff,
+		DisableAfter:         config.WebhookDisableAfter,
+		AllowPrivateNetworks: config.WebhookAllowPrivateNetworks,
+	}, logger)
+
+	// Deliveries are queued where the change is made rather than by every
+	// replica that hears of it, so that each one is sent once.
+	companyProducer := services.NewWebhookProducer(webhookService, producer.NewCompanyProducer(prod))
+	broadcaster := services.NewBroadcaster(companyProducer, services.BroadcastConfig{
+		History: config.StreamHistory,
+		Remote:  config.StreamSource == "kafka",
+	})
+	companyService := services.NewCompanyService(companyRepo, repos.definitions, broadcaster, services.CompanyConfig{
+		ReadTimeout:  config.DBReadTimeout,
+		WriteTimeout: config.DBWriteTimeout,
+		BulkTimeout:  config.DBBulkTimeout,
+	})
+	detailsService := services.NewCompanyDetailsService(companyRepo, repos.addresses, repos.contacts, services.CompanyConfig{
+		ReadTimeout:  config.DBReadTimeout,
+		WriteTimeout: config.DBWriteTimeout,
+	})
+	companyHandler := handlers.NewCompanyHandler(*companyService, detailsService)
+	attributeService := services.NewAttributeService(repos.definitions, services.CompanyConfig{
+		ReadTimeout:  config.DBReadTimeout,
+		WriteTimeout: config.DBWriteTimeout,
+	})
 	tokenAuth := jwtauth.New("HS256", []byte("xm-companies"), nil)
 
-	prod, err := producer.GetNewProducer()
+	jobService := services.NewJobService(repos.jobs, services.JobConfig{
+		Dir:          config.JobsDir,
+		Concurrency:  config.JobsConcurrency,
+		PollInterval: config.JobsPollInterval,
+		MaxAttempts:  config.JobsMaxAttempts,
+		StaleAfter:   config.JobsStaleAfter,
+	}, logger)
+	jobService.Register(handlers.ImportJob, companyHandler.RunImportJob)
+	jobService.Register(handlers.ExportJob, companyHandler.RunExportJob)
+
+	schema, err := graph.NewSchema(companyService)
+	if err != nil {
+		return err
+	}
+
+	rateLimitService, err := newRateLimitService(config)
+	if err != nil {
+		return err
+	}
+
+	doc, err := openapi.Load()
 	if err != nil {
 		return err
 	}
 
-	app.Logger = log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	docsHandler, err := handlers.NewDocsHandler(doc)
+	if err != nil {
+		return err
+	}
+
+	if config.ReadYourWritesWindow > 0 {
+		app.ReadYourWrites = handlers.ReadYourWrites(config.ReadYourWritesWindow, tokenAuth)
+	}
+
+	if config.OpenAPIValidate {
+		app.OpenAPIValidation, err = handlers.OpenAPIValidation(doc)
+		if err != nil {
+			return err
+		}
+	}
+
+	app.Logger = logger
 	app.Config = config
 	app.CompanyService = companyService
 	app.CompanyHandler = companyHandler
-	app.Routes = app.routes().(*chi.Mux)
+	app.JobService = jobService
+	app.JobHandler = handlers.NewJobHandler(jobService)
+	app.StreamHandler = handlers.NewStreamHandler(broadcaster)
+	app.WebhookService = webhookService
+	app.WebhookHandler = handlers.NewWebhookHandler(webhookService)
+	app.AttributeHandler = handlers.NewAttributeHandler(attributeService)
+	app.GraphQLHandler = graph.NewHandler(schema, graph.Limits{
+		MaxDepth:      config.GraphQLMaxDepth,
+		MaxComplexity: config.GraphQLMaxComplexity,
+	})
+	app.DocsHandler = docsHandler
+	app.IdempotencyService = services.NewIdempotencyService(repos.idempotency, services.IdempotencyConfig{
+		TTL:     config.IdempotencyTTL,
+		Lease:   config.IdempotencyLease,
+		Timeout: config.DBWriteTimeout,
+	})
+	app.RateLimitService = rateLimitService
+	app.Broadcaster = broadcaster
 	app.AuthenticationToken = tokenAuth
+	app.GRPCServer = rpc.NewServer(rpc.NewCompanyServer(companyService, broadcaster), tokenAuth)
 	app.KafkaProducer = prod
+	app.Routes = app.routes().(*chi.Mux)
+
+	return nil
+
+}
+
+func postgresConfig(config *utils.Config) repository.PostgresConfig {
+	var replicas []string
+	for _, dsn := range strings.Split(config.PostgresReplicas, ",") {
+		if dsn = strings.TrimSpace(dsn); dsn != "" {
+			replicas = append(replicas, dsn)
+		}
+	}
 
+	return repository.PostgresConfig{
+		Host:            config.PostgresHost,
+		Port:            config.PostgresPort,
+		User:            config.PostgresUser,
+		Password:        config.PostgresPassword,
+		DBName:          config.PostgresDBName,
+		SSLMode:         config.PostgresSSLMode,
+		MaxOpenConns:    config.PostgresMaxOpenConns,
+		MaxIdleConns:    config.PostgresMaxIdleConns,
+		ConnMaxIdleTime: config.PostgresConnMaxIdleTime,
+		ConnMaxLifetime: config.PostgresConnMaxLifetime,
+		ConnectTimeout:  config.PostgresConnectTimeout,
+		ConnectRetries:  config.PostgresConnectRetries,
+		ConnectBackoff:  config.PostgresConnectBackoff,
+		Replicas:        replicas,
+
+		ReplicaHealthInterval: config.PostgresReplicaHealthInterval,
+	}
+}
+
+// migrateOnStart applies the pending migrations when DB_AUTO_MIGRATE is set,
+// and refuses to start on a schema this version of the service does not know.
+func migrateOnStart(config *utils.Config, store *repository.PostgresRepository, logger *log.Logger) error {
+	migrations, err := repository.LoadMigrations(db.Migrations())
+	if err != nil {
+		return err
+	}
+	migrator := repository.NewMigrator(store.CompanyRepository.DB, migrations, logger)
+
+	ctx := context.Background()
+	if config.DBAutoMigrate {
+		err = migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = migrator.Check(ctx)
+	if err != nil {
+		return err
+	}
+
+	version, _, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version < migrator.Latest() {
+		logger.Printf("the database schema is at version %d of %d, run the pending migrations with migrate up", version, migrator.Latest())
+	}
 	return nil
+}
+
+// repositories are where the services keep their data, all in the store
+// selected by COMPANY_STORE.
+type repositories struct {
+	companies   ports.CompanyRepository
+	addresses   ports.AddressRepository
+	contacts    ports.ContactRepository
+	definitions ports.AttributeDefinitionRepository
+	jobs        ports.JobRepository
+	webhooks    ports.WebhookRepository
+	idempotency ports.IdempotencyRepository
+}
+
+// newRepositories opens the store selected by COMPANY_STORE. Postgres is only
+// connected to, and its schema checked, when it is the selected store.
+func newRepositories(config *utils.Config, logger *log.Logger) (*repositories, error) {
+	switch config.CompanyStore {
+	case "", "postgres":
+		store, err := repository.NewPostgresRepository(postgresConfig(config))
+		if err != nil {
+			return nil, err
+		}
 
+		err = migrateOnStart(config, store, logger)
+		if err != nil {
+			return nil, err
+		}
+		if replicas := store.CompanyRepository.Replicas(); replicas != nil {
+			expvar.Publish("postgres_replicas", expvar.Func(func() any {
+				return map[string]int{"healthy": replicas.Healthy(), "total": replicas.Len()}
+			}))
+		}
+
+		return &repositories{
+			companies:   store.CompanyRepository,
+			addresses:   store.AddressRepository,
+			contacts:    store.ContactRepository,
+			definitions: store.AttributeDefinitionRepository,
+			jobs:        store.JobRepository,
+			webhooks:    store.WebhookRepository,
+			idempotency: store.IdempotencyRepository,
+		}, nil
+	case "sqlite":
+		repo, err := repository.NewSQLiteCompanyRepository(config.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return &repositories{
+			companies:   repo,
+			addresses:   &repository.AddressRepository{DB: repo.DB},
+			contacts:    &repository.ContactRepository{DB: repo.DB},
+			definitions: &repository.AttributeDefinitionRepository{DB: repo.DB},
+			jobs:        &repository.SQLiteJobRepository{DB: repo.DB},
+			webhooks:    &repository.SQLiteWebhookRepository{DB: repo.DB},
+			idempotency: &repository.SQLiteIdempotencyRepository{DB: repo.DB},
+		}, nil
+	case "memory":
+		repo := repository.NewMemoryCompanyRepository()
+
+		return &repositories{
+			companies:   repo,
+			addresses:   repository.NewMemoryAddressRepository(repo),
+			contacts:    repository.NewMemoryContactRepository(repo),
+			definitions: repository.NewMemoryAttributeDefinitionRepository(),
+			jobs:        repository.NewMemoryJobRepository(),
+			webhooks:    repository.NewMemoryWebhookRepository(),
+			idempotency: repository.NewMemoryIdempotencyRepository(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown company store %q", config.CompanyStore)
+	}
+}
+
+func newCompanyCache(config *utils.Config) (ports.CompanyCache, error) {
+	switch config.CacheStore {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		return cache.NewMemoryCache(config.CacheSize, config.CacheTTL), nil
+	case "redis":
+		return cache.NewRedisCacheFromURL(config.RedisURL, config.CacheTTL)
+	default:
+		return nil, fmt.Errorf("unknown cache store %q", config.CacheStore)
+	}
+}
+
+func newRateLimitService(config *utils.Config) (*services.RateLimitService, error) {
+	defaultLimit, err := domain.ParseRateLimit(config.RateLimitDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	routeLimits, err := domain.ParseRouteRateLimits(config.RateLimitRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	var store ports.RateLimitStore
+	switch config.RateLimitStore {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "redis":
+		store, err = ratelimit.NewRedisStoreFromURL(config.RedisURL)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", config.RateLimitStore)
+	}
+
+	return services.NewRateLimitService(store, domain.RateLimits{
+		Default: defaultLimit,
+		Routes:  routeLimits,
+	}), nil
+}
+
+// purge periodically removes the companies that have been soft deleted for
+// longer than the configured retention period and the expired idempotency
+// keys.
+func (app *Application) purge(ctx context.Context) {
+	ticker := time.NewTicker(app.Config.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := app.CompanyService.PurgeDeleted(ctx, app.Config.PurgeRetention)
+			if err != nil {
+				utils.LogError(err)
+			} else if purged > 0 {
+				app.Logger.Printf("purged %d deleted companies", purged)
+			}
+
+			_, err = app.IdempotencyService.PurgeExpired(ctx)
+			if err != nil {
+				utils.LogError(err)
+			}
+		}
+	}
 }
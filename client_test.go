@@ -0,0 +1,716 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/api/openapi"
+	"github.com/petrostrak/xm-companies/internal/adapters/graph"
+	"github.com/petrostrak/xm-companies/internal/adapters/handlers"
+	"github.com/petrostrak/xm-companies/internal/adapters/ratelimit"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/pkg/client"
+)
+
+type discardProducer struct{}
+
+func (discardProducer) ProduceCompany(company *domain.Company, method string) error {
+	return nil
+}
+
+// newTestServer serves the routes of the application, validated against the
+// specification, with repositories kept in memory.
+func newTestServer(t *testing.T) (*httptest.Server, *jwtauth.JWTAuth) {
+	logger := log.New(io.Discard, "", 0)
+
+	companyRepo := repository.NewMemoryCompanyRepository()
+	definitionRepo := repository.NewMemoryAttributeDefinitionRepository()
+	companyService := services.NewCompanyService(companyRepo, definitionRepo, discardProducer{}, services.CompanyConfig{})
+	detailsService := services.NewCompanyDetailsService(companyRepo, repository.NewMemoryAddressRepository(companyRepo), repository.NewMemoryContactRepository(companyRepo), services.CompanyConfig{})
+	companyHandler := handlers.NewCompanyHandler(*companyService, detailsService)
+
+	jobService := services.NewJobService(repository.NewMemoryJobRepository(), services.JobConfig{
+		Dir:          t.TempDir(),
+		Concurrency:  1,
+		PollInterval: 10 * time.Millisecond,
+		MaxAttempts:  1,
+		StaleAfter:   time.Minute,
+	}, logger)
+	jobService.Register(handlers.ImportJob, companyHandler.RunImportJob)
+	jobService.Register(handlers.ExportJob, companyHandler.RunExportJob)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go jobService.Run(ctx)
+
+	webhookService := services.NewWebhookService(repository.NewMemoryWebhookRepository(), services.WebhookConfig{}, logger)
+
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("invalid specification: %s", err)
+	}
+	docsHandler, err := handlers.NewDocsHandler(doc)
+	if err != nil {
+		t.Fatalf("error creating docs handler: %s", err)
+	}
+	validation, err := handlers.OpenAPIValidation(doc)
+	if err != nil {
+		t.Fatalf("error creating validation: %s", err)
+	}
+
+	app := Application{
+		Logger:              logger,
+		CompanyService:      companyService,
+		CompanyHandler:      companyHandler,
+		JobService:          jobService,
+		JobHandler:          handlers.NewJobHandler(jobService),
+		StreamHandler:       &handlers.StreamHandler{},
+		WebhookService:      webhookService,
+		WebhookHandler:      handlers.NewWebhookHandler(webhookService),
+		AttributeHandler:    handlers.NewAttributeHandler(services.NewAttributeService(definitionRepo, services.CompanyConfig{})),
+		GraphQLHandler:      &graph.Handler{},
+		DocsHandler:         docsHandler,
+		OpenAPIValidation:   validation,
+		IdempotencyService:  services.NewIdempotencyService(repository.NewMemoryIdempotencyRepository(), services.IdempotencyConfig{TTL: time.Hour}),
+		RateLimitService:    services.NewRateLimitService(ratelimit.NewMemoryStore(), domain.RateLimits{}),
+		AuthenticationToken: jwtauth.New("HS256", []byte("xm-companies"), nil),
+	}
+
+	srv := httptest.NewServer(app.routes())
+	t.Cleanup(srv.Close)
+
+	return srv, app.AuthenticationToken
+}
+
+func newTestClient(t *testing.T, url string, auth *jwtauth.JWTAuth, claims map[string]any) *client.Client {
+	var options []client.Option
+	if claims != nil {
+		_, token, err := auth.Encode(claims)
+		if err != nil {
+			t.Fatalf("error encoding token: %s", err)
+		}
+		options = append(options, client.WithToken(token))
+	}
+
+	c, err := client.New(url, options...)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	return c
+}
+
+func TestClientCompanies(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester", "role": "admin"})
+	anonymous := newTestClient(t, srv.URL, auth, nil)
+	ctx := context.Background()
+
+	created, err := c.CreateCompany(ctx, client.CompanyInput{Name: "Alpha", NumberOfEmployees: 10, Type: client.Cooperative})
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+	if created.ID == uuid.Nil || created.CreatedBy != "tester" || created.Type != client.Cooperative {
+		t.Errorf("expected a cooperative created by tester but got %+v", created)
+	}
+
+	_, err = c.CreateCompany(ctx, client.CompanyInput{Name: "Alpha"})
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected a conflict but got %v", err)
+	}
+
+	_, err = c.CreateCompany(ctx, client.CompanyInput{Name: ""})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrValidation) || apiErr.Fields["name"] != "must be provided" {
+		t.Errorf("expected the validation errors of the name but got %v", err)
+	}
+
+	_, err = anonymous.CreateCompany(ctx, client.CompanyInput{Name: "Beta"})
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected an anonymous create to be unauthorized but got %v", err)
+	}
+
+	got, err := anonymous.GetCompany(ctx, created.ID)
+	if err != nil || got.Name != "Alpha" || got.Type != client.Cooperative {
+		t.Errorf("expected the created company but got %+v, %v", got, err)
+	}
+
+	registered := true
+	updated, err := c.UpdateCompany(ctx, created.ID, client.CompanyUpdate{Registered: &registered})
+	if err != nil || !updated.Registered || updated.NumberOfEmployees != 10 {
+		t.Errorf("expected only registered to change but got %+v, %v", updated, err)
+	}
+
+	err = c.DeleteCompany(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("error deleting company: %s", err)
+	}
+
+	_, err = c.GetCompany(ctx, created.ID)
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected the deleted company not to be found but got %v", err)
+	}
+
+	restored, err := c.RestoreCompany(ctx, created.ID)
+	if err != nil || restored.ID != created.ID {
+		t.Errorf("expected the company to be restored but got %+v, %v", restored, err)
+	}
+
+	err = newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"}).PurgeCompany(ctx, created.ID)
+	if !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("expected a purge without the admin role to be forbidden but got %v", err)
+	}
+
+	err = c.PurgeCompany(ctx, created.ID)
+	if err != nil {
+		t.Errorf("error purging company: %s", err)
+	}
+}
+
+func TestClientPatchCompany(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	company, err := c.CreateCompany(ctx, client.CompanyInput{Name: "Patched", Description: "A broker", NumberOfEmployees: 10, Type: client.Cooperative})
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	patched, err := c.MergePatchCompany(ctx, company.ID, map[string]any{"description": nil, "number_of_employees": 20})
+	if err != nil || patched.Description != "" || patched.NumberOfEmployees != 20 || patched.Name != "Patched" || patched.Type != client.Cooperative {
+		t.Errorf("expected the description to be cleared and the employees changed but got %+v, %v", patched, err)
+	}
+
+	patched, err = c.JSONPatchCompany(ctx, company.ID,
+		client.PatchOperation{Op: "test", Path: "/number_of_employees", Value: 20},
+		client.PatchOperation{Op: "replace", Path: "/registered", Value: true},
+		client.PatchOperation{Op: "copy", From: "/name", Path: "/description"},
+	)
+	if err != nil || !patched.Registered || patched.Description != "Patched" {
+		t.Errorf("expected the company to be registered and described by its name but got %+v, %v", patched, err)
+	}
+
+	_, err = c.JSONPatchCompany(ctx, company.ID,
+		client.PatchOperation{Op: "replace", Path: "/registered", Value: false},
+		client.PatchOperation{Op: "test", Path: "/number_of_employees", Value: 10},
+	)
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected a failed test to conflict but got %v", err)
+	}
+	got, err := c.GetCompany(ctx, company.ID)
+	if err != nil || !got.Registered {
+		t.Errorf("expected a failed test to leave the company unchanged but got %+v, %v", got, err)
+	}
+
+	var apiErr *client.Error
+	_, err = c.JSONPatchCompany(ctx, company.ID,
+		client.PatchOperation{Op: "remove", Path: "/type"},
+		client.PatchOperation{Op: "replace", Path: "/created_by", Value: "someone"},
+	)
+	if !errors.As(err, &apiErr) || apiErr.Fields["type"] != "must be provided" || apiErr.Fields["created_by"] != "cannot be changed" {
+		t.Errorf("expected the validation errors of the patch but got %v", err)
+	}
+
+	_, err = c.JSONPatchCompany(ctx, company.ID, client.PatchOperation{Op: "remove", Path: "/parent_id/id"})
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected a missing path to conflict but got %v", err)
+	}
+
+	_, err = c.MergePatchCompany(ctx, company.ID, []string{"name"})
+	if !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("expected a merge patch that is not an object to be rejected but got %v", err)
+	}
+
+	_, token, err := auth.Encode(map[string]any{"sub": "tester"})
+	if err != nil {
+		t.Fatalf("error encoding token: %s", err)
+	}
+	req, _ := http.NewRequest(http.MethodPatch, srv.URL+"/companies/"+company.ID.String(), strings.NewReader("name=XM"))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error sending request: %s", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnsupportedMediaType || !strings.Contains(res.Header.Get("Accept-Patch"), "application/json-patch+json") {
+		t.Errorf("expected an unsupported media type with the accepted patches but got %d, %q", res.StatusCode, res.Header.Get("Accept-Patch"))
+	}
+}
+
+func TestClientCompanyDetails(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	company, err := c.CreateCompany(ctx, client.CompanyInput{Name: "Detailed"})
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	address, err := c.CreateAddress(ctx, company.ID, client.AddressInput{Label: "HQ", Line1: "1 Main Street", City: "Athens", CountryCode: "gr"})
+	if err != nil {
+		t.Fatalf("error creating address: %s", err)
+	}
+	if address.ID == uuid.Nil || address.CompanyID != company.ID || address.CountryCode != "GR" {
+		t.Errorf("expected an address of the company in GR but got %+v", address)
+	}
+
+	_, err = c.CreateAddress(ctx, company.ID, client.AddressInput{Line1: "2 Side Street", City: "Nowhere", CountryCode: "XX"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrValidation) || apiErr.Fields["country_code"] == "" {
+		t.Errorf("expected the validation error of the country code but got %v", err)
+	}
+
+	_, err = c.CreateAddress(ctx, uuid.New(), client.AddressInput{Line1: "3 High Street", City: "London", CountryCode: "GB"})
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected the address of an unknown company not to be found but got %v", err)
+	}
+
+	city := "Thessaloniki"
+	updated, err := c.UpdateAddress(ctx, company.ID, address.ID, client.AddressUpdate{City: &city})
+	if err != nil || updated.City != city || updated.Line1 != "1 Main Street" {
+		t.Errorf("expected only the city to change but got %+v, %v", updated, err)
+	}
+
+	contact, err := c.CreateContact(ctx, company.ID, client.ContactInput{Name: "Jane Doe", Email: "jane@example.com", Phone: "+30 210 1234567"})
+	if err != nil {
+		t.Fatalf("error creating contact: %s", err)
+	}
+
+	_, err = c.CreateContact(ctx, company.ID, client.ContactInput{Name: "John Doe", Email: "john"})
+	if !errors.As(err, &apiErr) || apiErr.Fields["email"] == "" {
+		t.Errorf("expected the validation error of the email but got %v", err)
+	}
+
+	got, err := c.GetCompany(ctx, company.ID)
+	if err != nil || got.Addresses != nil || got.Contacts != nil {
+		t.Errorf("expected no details without include but got %+v, %v", got, err)
+	}
+
+	got, err = c.GetCompanyIncluding(ctx, company.ID, client.IncludeAddresses, client.IncludeContacts)
+	if err != nil || len(got.Addresses) != 1 || got.Addresses[0].City != city || len(got.Contacts) != 1 || got.Contacts[0].ID != contact.ID {
+		t.Errorf("expected the company with its address and contact but got %+v, %v", got, err)
+	}
+
+	page, err := c.ListCompanies(ctx, client.ListOptions{Include: []string{client.IncludeContacts}})
+	if err != nil || len(page.Companies) != 1 || len(page.Companies[0].Contacts) != 1 || page.Companies[0].Addresses != nil {
+		t.Errorf("expected the companies with their contacts only but got %+v, %v", page, err)
+	}
+
+	_, err = c.GetCompanyIncluding(ctx, company.ID, "owners")
+	if !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("expected an unknown include to be a bad request but got %v", err)
+	}
+
+	err = c.DeleteContact(ctx, company.ID, contact.ID)
+	if err != nil {
+		t.Errorf("error deleting contact: %s", err)
+	}
+	_, err = c.GetContact(ctx, company.ID, contact.ID)
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected the deleted contact not to be found but got %v", err)
+	}
+
+	err = c.DeleteCompany(ctx, company.ID)
+	if err != nil {
+		t.Fatalf("error deleting company: %s", err)
+	}
+	_, err = c.ListAddresses(ctx, company.ID)
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected the addresses of a deleted company not to be found but got %v", err)
+	}
+}
+
+func TestClientAttributes(t *testing.T) {
+	srv, auth := newTestServer(t)
+	admin := newTestClient(t, srv.URL, auth, map[string]any{"sub": "admin", "role": "admin"})
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	_, err := c.CreateAttribute(ctx, client.AttributeDefinitionInput{Name: "industry", Schema: json.RawMessage(`{"type":"string"}`)})
+	if !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("expected defining an attribute without the admin role to be forbidden but got %v", err)
+	}
+
+	for _, input := range []client.AttributeDefinitionInput{
+		{Name: "industry", Description: "The sector of the company", Schema: json.RawMessage(`{"type":"string","enum":["fintech","retail"]}`)},
+		{Name: "founded", Schema: json.RawMessage(`{"type":"integer","minimum":1800}`)},
+		{Name: "listed", Schema: json.RawMessage(`{"type":"boolean"}`)},
+	} {
+		definition, err := admin.CreateAttribute(ctx, input)
+		if err != nil || definition.Name != input.Name || definition.CreatedBy != "admin" {
+			t.Fatalf("expected the %s attribute to be defined but got %+v, %v", input.Name, definition, err)
+		}
+	}
+
+	_, err = admin.CreateAttribute(ctx, client.AttributeDefinitionInput{Name: "listed", Schema: json.RawMessage(`{"type":"boolean"}`)})
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected a duplicate definition to conflict but got %v", err)
+	}
+
+	var apiErr *client.Error
+	_, err = admin.CreateAttribute(ctx, client.AttributeDefinitionInput{Name: "size", Schema: json.RawMessage(`{"type":"text"}`)})
+	if !errors.As(err, &apiErr) || apiErr.Fields["schema"] == "" {
+		t.Errorf("expected the validation error of the schema but got %v", err)
+	}
+
+	definitions, err := c.ListAttributes(ctx)
+	if err != nil || len(definitions) != 3 || definitions[0].Name != "founded" {
+		t.Errorf("expected the definitions ordered by name but got %+v, %v", definitions, err)
+	}
+
+	fintech, err := c.CreateCompany(ctx, client.CompanyInput{Name: "Fintech", Attributes: map[string]any{"industry": "fintech", "founded": 2010, "listed": true}})
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+	if fintech.Attributes["industry"] != "fintech" || fintech.Attributes["founded"] != 2010.0 {
+		t.Errorf("expected the attributes of the company but got %+v", fintech.Attributes)
+	}
+	retail, err := c.CreateCompany(ctx, client.CompanyInput{Name: "Retail", Attributes: map[string]any{"industry": "retail", "listed": true}})
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	_, err = c.CreateCompany(ctx, client.CompanyInput{Name: "Mining", Attributes: map[string]any{"industry": "mining", "size": "large"}})
+	if !errors.As(err, &apiErr) || apiErr.Fields["attributes.industry"] == "" || apiErr.Fields["attributes.size"] != "is not defined" {
+		t.Errorf("expected the validation errors of the attributes but got %v", err)
+	}
+
+	updated, err := c.UpdateCompany(ctx, fintech.ID, client.CompanyUpdate{Attributes: map[string]any{"listed": nil, "founded": 2012}})
+	if err != nil || updated.Attributes["founded"] != 2012.0 || updated.Attributes["industry"] != "fintech" || updated.Attributes["listed"] != nil {
+		t.Errorf("expected the attributes to be merged but got %+v, %v", updated, err)
+	}
+
+	_, err = c.UpdateCompany(ctx, retail.ID, client.CompanyUpdate{Attributes: map[string]any{"founded": 1700}})
+	if !errors.As(err, &apiErr) || apiErr.Fields["attributes.founded"] == "" {
+		t.Errorf("expected the validation error of the updated attribute but got %v", err)
+	}
+
+	testCases := []struct {
+		attributes map[string]any
+		expected   []string
+	}{
+		{map[string]any{"industry": "fintech"}, []string{"Fintech"}},
+		{map[string]any{"listed": true}, []string{"Retail"}},
+		{map[string]any{"founded": 2012, "industry": "fintech"}, []string{"Fintech"}},
+		{map[string]any{"founded": "2012"}, nil},
+	}
+	for _, tt := range testCases {
+		page, err := c.ListCompanies(ctx, client.ListOptions{CompanyFilter: client.CompanyFilter{Attributes: tt.attributes}})
+		if err != nil {
+			t.Fatalf("%v: error listing companies: %s", tt.attributes, err)
+		}
+		var names []string
+		for _, company := range page.Companies {
+			names = append(names, company.Name)
+		}
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("%v: expected %v but got %v", tt.attributes, tt.expected, names)
+		}
+	}
+
+	description := "The market the company sells to"
+	definition, err := admin.UpdateAttribute(ctx, "industry", client.AttributeDefinitionUpdate{Description: &description})
+	if err != nil || definition.Description != description || !strings.Contains(string(definition.Schema), "fintech") {
+		t.Errorf("expected only the description to change but got %+v, %v", definition, err)
+	}
+
+	err = admin.DeleteAttribute(ctx, "listed")
+	if err != nil {
+		t.Fatalf("error deleting attribute: %s", err)
+	}
+	_, err = c.GetAttribute(ctx, "listed")
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected the deleted definition not to be found but got %v", err)
+	}
+
+	got, err := c.GetCompany(ctx, retail.ID)
+	if err != nil || got.Attributes["listed"] != true {
+		t.Errorf("expected the company to keep the value of the deleted attribute but got %+v, %v", got, err)
+	}
+}
+
+func TestClientTags(t *testing.T) {
+	srv, auth := newTestServer(t)
+	anonymous := newTestClient(t, srv.URL, auth, nil)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	companies := make(map[string]*client.Company)
+	for _, name := range []string{"Broker", "Bank", "Retail"} {
+		company, err := c.CreateCompany(ctx, client.CompanyInput{Name: name})
+		if err != nil {
+			t.Fatalf("error creating company: %s", err)
+		}
+		if company.Tags == nil || len(company.Tags) != 0 {
+			t.Errorf("expected a new company to have no tags but got %v", company.Tags)
+		}
+		companies[name] = company
+	}
+
+	_, err := anonymous.AddTags(ctx, companies["Broker"].ID, "region:emea")
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected tagging anonymously to be unauthorized but got %v", err)
+	}
+
+	broker, err := c.AddTags(ctx, companies["Broker"].ID, "Tier:1", "region:emea")
+	if err != nil || !reflect.DeepEqual(broker.Tags, []string{"region:emea", "tier:1"}) {
+		t.Errorf("expected the tags in lower case and sorted but got %+v, %v", broker, err)
+	}
+	_, err = c.AddTags(ctx, companies["Bank"].ID, "region:apac", "tier:1")
+	if err != nil {
+		t.Fatalf("error tagging company: %s", err)
+	}
+	_, err = c.AddTags(ctx, companies["Retail"].ID, "region:emea")
+	if err != nil {
+		t.Fatalf("error tagging company: %s", err)
+	}
+
+	var apiErr *client.Error
+	_, err = c.AddTags(ctx, companies["Retail"].ID, "region/emea")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Fields["tags"] == "" {
+		t.Errorf("expected the validation error of the tags but got %v", err)
+	}
+
+	testCases := []struct {
+		filter   client.CompanyFilter
+		expected []string
+	}{
+		{client.CompanyFilter{Tags: []string{"tier:1"}}, []string{"Bank", "Broker"}},
+		{client.CompanyFilter{Tags: []string{"tier:1", "region:emea"}}, []string{"Broker"}},
+		{client.CompanyFilter{AnyTags: []string{"region:apac", "region:emea"}}, []string{"Bank", "Broker", "Retail"}},
+		{client.CompanyFilter{Tags: []string{"region:emea"}, AnyTags: []string{"tier:1", "tier:2"}}, []string{"Broker"}},
+		{client.CompanyFilter{Tags: []string{"tier:2"}}, nil},
+	}
+	for _, tt := range testCases {
+		page, err := c.ListCompanies(ctx, client.ListOptions{CompanyFilter: tt.filter})
+		if err != nil {
+			t.Fatalf("%+v: error listing companies: %s", tt.filter, err)
+		}
+		var names []string
+		for _, company := range page.Companies {
+			names = append(names, company.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.expected) {
+			t.Errorf("%+v: expected %v but got %v", tt.filter, tt.expected, names)
+		}
+	}
+
+	broker, err = c.RemoveTag(ctx, companies["Broker"].ID, "tier:1")
+	if err != nil || !reflect.DeepEqual(broker.Tags, []string{"region:emea"}) {
+		t.Errorf("expected only the region to be left but got %+v, %v", broker, err)
+	}
+	_, err = c.RemoveTag(ctx, uuid.New(), "tier:1")
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected untagging an unknown company not to be found but got %v", err)
+	}
+
+	tags, err := anonymous.ListTags(ctx)
+	expected := []client.Tag{{Name: "region:apac", Count: 1}, {Name: "region:emea", Count: 2}, {Name: "tier:1", Count: 1}}
+	if err != nil || !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected %+v but got %+v, %v", expected, tags, err)
+	}
+}
+
+func TestClientIterateCompanies(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	inputs := make([]client.CompanyInput, 7)
+	for i := range inputs {
+		inputs[i] = client.CompanyInput{Name: string(rune('A'+i)) + " company", Registered: i%2 == 0}
+	}
+	_, err := c.CreateCompanies(ctx, inputs, client.Atomic)
+	if err != nil {
+		t.Fatalf("error creating companies: %s", err)
+	}
+
+	registered := true
+	testCases := []struct {
+		name          string
+		opts          client.ListOptions
+		expectedNames string
+	}{
+		{"one page", client.ListOptions{}, "A B C D E F G"},
+		{"pages of 3", client.ListOptions{Limit: 3}, "A B C D E F G"},
+		{"pages of 1", client.ListOptions{Limit: 1}, "A B C D E F G"},
+		{"filtered", client.ListOptions{Limit: 2, CompanyFilter: client.CompanyFilter{Registered: &registered}}, "A C E G"},
+		{"after a cursor", client.ListOptions{After: "RCBjb21wYW55"}, "E F G"},
+	}
+
+	for _, tt := range testCases {
+		var names []string
+		it := c.IterateCompanies(tt.opts)
+		for it.Next(ctx) {
+			names = append(names, strings.TrimSuffix(it.Company().Name, " company"))
+		}
+
+		if it.Err() != nil || strings.Join(names, " ") != tt.expectedNames {
+			t.Errorf("%s: expected %s but got %v, %v", tt.name, tt.expectedNames, names, it.Err())
+		}
+	}
+
+	page, err := c.ListCompanies(ctx, client.ListOptions{Limit: 5})
+	if err != nil || len(page.Companies) != 5 || page.NextCursor == "" {
+		t.Errorf("expected a first page of 5 with a cursor but got %+v, %v", page, err)
+	}
+
+	it := c.IterateCompanies(client.ListOptions{Limit: 5000})
+	if it.Next(ctx) || !errors.Is(it.Err(), client.ErrBadRequest) {
+		t.Errorf("expected an invalid limit to stop the iteration but got %v", it.Err())
+	}
+}
+
+func TestClientBatches(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	results, err := c.CreateCompanies(ctx, []client.CompanyInput{{Name: "Alpha"}, {Name: "Beta"}}, client.Atomic)
+	if err != nil || len(results) != 2 || results[1].Company == nil || results[1].Company.Name != "Beta" {
+		t.Fatalf("expected both companies to be created but got %+v, %v", results, err)
+	}
+
+	_, err = c.CreateCompanies(ctx, []client.CompanyInput{{Name: "Gamma"}, {Name: ""}}, client.Atomic)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrValidation) || len(apiErr.Results) != 2 {
+		t.Fatalf("expected the atomic batch to fail with its results but got %v", err)
+	}
+	if apiErr.Results[0].Status != 424 || apiErr.Results[1].Err.Fields["name"] != "must be provided" {
+		t.Errorf("expected the second item to be at fault but got %+v", apiErr.Results)
+	}
+
+	name := "Delta"
+	results, err = c.UpdateCompanies(ctx, []client.CompanyBatchUpdate{
+		{ID: results[0].Company.ID, CompanyUpdate: client.CompanyUpdate{Name: &name}},
+		{ID: uuid.New()},
+	}, client.BestEffort)
+	if err != nil || results[0].Company == nil || results[0].Company.Name != "Delta" || !errors.Is(results[1].Err, client.ErrNotFound) {
+		t.Errorf("expected the first update to succeed and the second not to be found but got %+v, %v", results, err)
+	}
+
+	results, err = c.DeleteCompanies(ctx, []uuid.UUID{results[0].Company.ID}, "")
+	if err != nil || results[0].Err != nil {
+		t.Errorf("expected the company to be deleted but got %+v, %v", results, err)
+	}
+}
+
+func TestClientImportExport(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	ctx := context.Background()
+
+	summary, err := c.ImportCompanies(ctx, client.CSV, strings.NewReader("name,type\nAlpha,1\n,2\nBeta,2\n"))
+	if err != nil || summary.Imported != 2 || summary.Failed != 1 || summary.Errors[0].Row != 3 || summary.Errors[0].Fields["name"] == "" {
+		t.Errorf("expected 2 imported companies and the error of row 3 but got %+v, %v", summary, err)
+	}
+
+	summary, err = c.ImportCompanies(ctx, client.NDJSON, strings.NewReader(`{"name": ""}`))
+	if !errors.Is(err, client.ErrValidation) || summary == nil || summary.Failed != 1 {
+		t.Errorf("expected an import of invalid rows to fail with its summary but got %+v, %v", summary, err)
+	}
+
+	nonProfit := client.NonProfit
+	export, err := c.ExportCompanies(ctx, client.NDJSON, client.CompanyFilter{Type: &nonProfit})
+	if err != nil {
+		t.Fatalf("error exporting companies: %s", err)
+	}
+	data, _ := io.ReadAll(export)
+	export.Close()
+	if !strings.Contains(string(data), `"name":"Alpha"`) || strings.Contains(string(data), "Beta") {
+		t.Errorf("expected the export of the non profit companies but got %s", data)
+	}
+
+	job, err := c.CreateExportJob(ctx, client.CSV, client.CompanyFilter{})
+	if err != nil {
+		t.Fatalf("error creating export job: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	job, err = c.WaitJob(ctx, job.ID, 10*time.Millisecond)
+	if err != nil || job.Status != client.JobSucceeded || job.Progress != 2 {
+		t.Fatalf("expected the export job to succeed but got %+v, %v", job, err)
+	}
+
+	result, err := c.JobResult(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("error getting job result: %s", err)
+	}
+	data, _ = io.ReadAll(result)
+	result.Close()
+	if !strings.HasPrefix(string(data), "id,name,") || strings.Count(string(data), "\n") != 3 {
+		t.Errorf("expected a CSV export of 2 companies but got %s", data)
+	}
+
+	_, err = c.CancelJob(ctx, job.ID)
+	if !errors.Is(err, client.ErrConflict) {
+		t.Errorf("expected cancelling a finished job to conflict but got %v", err)
+	}
+}
+
+func TestClientWebhooks(t *testing.T) {
+	srv, auth := newTestServer(t)
+	c := newTestClient(t, srv.URL, auth, map[string]any{"sub": "tester"})
+	other := newTestClient(t, srv.URL, auth, map[string]any{"sub": "other"})
+	ctx := context.Background()
+
+	_, _, err := c.CreateWebhook(ctx, client.WebhookInput{URL: "ftp://example.com"})
+	if !errors.Is(err, client.ErrValidation) {
+		t.Errorf("expected an invalid URL to be rejected but got %v", err)
+	}
+
+	webhook, secret, err := c.CreateWebhook(ctx, client.WebhookInput{URL: "https://example.com/hooks", Events: []string{client.WebhookCompanyCreated}})
+	if err != nil || secret == "" || !webhook.Active {
+		t.Fatalf("expected an active webhook with a secret but got %+v, %v", webhook, err)
+	}
+
+	webhooks, err := other.ListWebhooks(ctx)
+	if err != nil || len(webhooks) != 0 {
+		t.Errorf("expected no webhook of another subject but got %v, %v", webhooks, err)
+	}
+
+	_, err = other.GetWebhook(ctx, webhook.ID)
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected the webhook of another subject not to be found but got %v", err)
+	}
+
+	active := false
+	webhook, err = c.UpdateWebhook(ctx, webhook.ID, client.WebhookUpdate{Active: &active})
+	if err != nil || webhook.Active || len(webhook.Events) != 1 {
+		t.Errorf("expected only active to change but got %+v, %v", webhook, err)
+	}
+
+	deliveries, err := c.WebhookDeliveries(ctx, webhook.ID, 10)
+	if err != nil || len(deliveries) != 0 {
+		t.Errorf("expected no deliveries but got %v, %v", deliveries, err)
+	}
+
+	err = c.DeleteWebhook(ctx, webhook.ID)
+	if err != nil {
+		t.Errorf("error deleting webhook: %s", err)
+	}
+
+	webhooks, err = c.ListWebhooks(ctx)
+	if err != nil || len(webhooks) != 0 {
+		t.Errorf("expected the webhook to be deleted but got %v, %v", webhooks, err)
+	}
+}
@@ -0,0 +1,21 @@
+// Package db embeds the SQL migrations of the database schema, which the
+// service applies itself through repository.Migrator.
+package db
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migration/*.sql
+var migrations embed.FS
+
+// Migrations returns the migration files, named
+// {version}_{name}.{up|down}.sql.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrations, "migration")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
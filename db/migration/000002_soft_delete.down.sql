@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "companies_deleted_at_idx";
+DROP INDEX IF EXISTS "companies_name_active_idx";
+DELETE FROM "companies" WHERE "deleted_at" IS NOT NULL;
+ALTER TABLE "companies" ADD CONSTRAINT "companies_name_key" UNIQUE ("name");
+
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "deleted_by";
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "deleted_at";
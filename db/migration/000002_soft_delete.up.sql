@@ -0,0 +1,6 @@
+ALTER TABLE "companies" ADD COLUMN "deleted_at" timestamptz NULL;
+ALTER TABLE "companies" ADD COLUMN "deleted_by" varchar(255) NULL;
+
+ALTER TABLE "companies" DROP CONSTRAINT IF EXISTS "companies_name_key";
+CREATE UNIQUE INDEX "companies_name_active_idx" ON "companies" ("name") WHERE "deleted_at" IS NULL;
+CREATE INDEX "companies_deleted_at_idx" ON "companies" ("deleted_at") WHERE "deleted_at" IS NOT NULL;
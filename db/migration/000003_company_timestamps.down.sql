@@ -0,0 +1,4 @@
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "updated_by";
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "created_by";
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "updated_at";
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "created_at";
@@ -0,0 +1,4 @@
+ALTER TABLE "companies" ADD COLUMN "created_at" timestamptz NOT NULL DEFAULT now();
+ALTER TABLE "companies" ADD COLUMN "updated_at" timestamptz NOT NULL DEFAULT now();
+ALTER TABLE "companies" ADD COLUMN "created_by" varchar(255) NOT NULL DEFAULT '';
+ALTER TABLE "companies" ADD COLUMN "updated_by" varchar(255) NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+CREATE TABLE "jobs" (
+  "id" uuid DEFAULT gen_random_uuid(),
+  "type" varchar(50) NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'queued',
+  "params" jsonb NOT NULL DEFAULT '{}',
+  "progress" bigint NOT NULL DEFAULT 0,
+  "result_type" varchar(255) NOT NULL DEFAULT '',
+  "error" text NOT NULL DEFAULT '',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "created_by" varchar(255) NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  "started_at" timestamptz NULL,
+  "finished_at" timestamptz NULL,
+  "heartbeat_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX "jobs_pending_idx" ON "jobs" ("created_at") WHERE "status" IN ('queued', 'running', 'failed');
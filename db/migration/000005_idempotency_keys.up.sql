@@ -0,0 +1,13 @@
+CREATE TABLE "idempotency_keys" (
+  "key" varchar(255) NOT NULL,
+  "subject" varchar(255) NOT NULL DEFAULT '',
+  "request_hash" bytea NOT NULL,
+  "status" integer NOT NULL DEFAULT 0,
+  "header" jsonb NOT NULL DEFAULT '{}',
+  "body" bytea NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "expires_at" timestamptz NOT NULL,
+  PRIMARY KEY ("subject", "key")
+);
+
+CREATE INDEX "idempotency_keys_expires_at_idx" ON "idempotency_keys" ("expires_at");
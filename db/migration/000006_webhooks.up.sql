@@ -0,0 +1,33 @@
+CREATE TABLE "webhooks" (
+  "id" uuid DEFAULT gen_random_uuid(),
+  "url" varchar(2048) NOT NULL,
+  "secret" varchar(255) NOT NULL,
+  "events" text[] NOT NULL DEFAULT '{}',
+  "active" boolean NOT NULL DEFAULT true,
+  "consecutive_failures" integer NOT NULL DEFAULT 0,
+  "disabled_at" timestamptz NULL,
+  "created_by" varchar(255) NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE "webhook_deliveries" (
+  "id" uuid DEFAULT gen_random_uuid(),
+  "webhook_id" uuid NOT NULL REFERENCES "webhooks" ("id") ON DELETE CASCADE,
+  "event_id" uuid NOT NULL,
+  "event" varchar(50) NOT NULL,
+  "payload" bytea NOT NULL,
+  "status" varchar(20) NOT NULL DEFAULT 'pending',
+  "attempts" integer NOT NULL DEFAULT 0,
+  "response_status" integer NOT NULL DEFAULT 0,
+  "error" text NOT NULL DEFAULT '',
+  "next_attempt_at" timestamptz NOT NULL DEFAULT now(),
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  "delivered_at" timestamptz NULL,
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX "webhook_deliveries_due_idx" ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+CREATE INDEX "webhook_deliveries_webhook_idx" ON "webhook_deliveries" ("webhook_id", "created_at");
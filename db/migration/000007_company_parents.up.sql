@@ -0,0 +1,3 @@
+ALTER TABLE "companies" ADD COLUMN "parent_id" uuid NULL REFERENCES "companies" ("id") ON DELETE SET NULL;
+ALTER TABLE "companies" ADD CONSTRAINT "companies_parent_id_check" CHECK ("parent_id" <> "id");
+CREATE INDEX "companies_parent_id_idx" ON "companies" ("parent_id") WHERE "parent_id" IS NOT NULL;
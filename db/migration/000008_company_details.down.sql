@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "company_contacts";
+DROP TABLE IF EXISTS "company_addresses";
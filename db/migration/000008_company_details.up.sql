@@ -0,0 +1,29 @@
+CREATE TABLE "company_addresses" (
+  "id" uuid DEFAULT gen_random_uuid(),
+  "company_id" uuid NOT NULL REFERENCES "companies" ("id") ON DELETE CASCADE,
+  "label" varchar(50) NOT NULL DEFAULT '',
+  "line1" varchar(200) NOT NULL,
+  "line2" varchar(200) NOT NULL DEFAULT '',
+  "city" varchar(100) NOT NULL,
+  "region" varchar(100) NOT NULL DEFAULT '',
+  "postal_code" varchar(20) NOT NULL DEFAULT '',
+  "country_code" char(2) NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("id")
+);
+
+CREATE TABLE "company_contacts" (
+  "id" uuid DEFAULT gen_random_uuid(),
+  "company_id" uuid NOT NULL REFERENCES "companies" ("id") ON DELETE CASCADE,
+  "name" varchar(100) NOT NULL,
+  "email" varchar(254) NOT NULL DEFAULT '',
+  "phone" varchar(30) NOT NULL DEFAULT '',
+  "role" varchar(100) NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("id")
+);
+
+CREATE INDEX "company_addresses_company_idx" ON "company_addresses" ("company_id", "created_at");
+CREATE INDEX "company_contacts_company_idx" ON "company_contacts" ("company_id", "created_at");
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "attribute_definitions";
+ALTER TABLE "companies" DROP COLUMN IF EXISTS "attributes";
@@ -0,0 +1,13 @@
+ALTER TABLE "companies" ADD COLUMN "attributes" jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE "companies" ADD CONSTRAINT "companies_attributes_check" CHECK (jsonb_typeof("attributes") = 'object');
+CREATE INDEX "companies_attributes_idx" ON "companies" USING gin ("attributes" jsonb_path_ops);
+
+CREATE TABLE "attribute_definitions" (
+  "name" varchar(63),
+  "description" varchar(500) NOT NULL DEFAULT '',
+  "schema" jsonb NOT NULL,
+  "created_by" varchar(255) NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  "updated_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("name")
+);
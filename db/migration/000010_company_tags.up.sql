@@ -0,0 +1,15 @@
+CREATE TABLE "tags" (
+  "name" varchar(50) COLLATE "C",
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("name")
+);
+
+CREATE TABLE "company_tags" (
+  "company_id" uuid NOT NULL REFERENCES "companies" ("id") ON DELETE CASCADE,
+  "tag" varchar(50) COLLATE "C" NOT NULL REFERENCES "tags" ("name") ON DELETE CASCADE,
+  "created_by" varchar(255) NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY ("company_id", "tag")
+);
+
+CREATE INDEX "company_tags_tag_idx" ON "company_tags" ("tag");
@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+	"github.com/redis/go-redis/v9"
+)
+
+var errNotFound = errors.New("not found")
+
+// fakeRepository counts the reads that reach it, and those that were not
+// pinned to the primary. Transactions write straight through and only report
+// the error of fn.
+type fakeRepository struct {
+	companies   map[uuid.UUID]domain.Company
+	gets        int
+	replicaGets int
+}
+
+func (f *fakeRepository) Create(ctx context.Context, company *domain.Company) error {
+	company.ID = uuid.New()
+	f.companies[company.ID] = *company
+	return nil
+}
+
+func (f *fakeRepository) Update(ctx context.Context, company *domain.Company) error {
+	if _, ok := f.companies[company.ID]; !ok {
+		return errNotFound
+	}
+	f.companies[company.ID] = *company
+	return nil
+}
+
+func (f *fakeRepository) Delete(ctx context.Context, id uuid.UUID, deletedBy string) error {
+	if _, ok := f.companies[id]; !ok {
+		return errNotFound
+	}
+	delete(f.companies, id)
+	return nil
+}
+
+func (f *fakeRepository) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	return nil, errNotFound
+}
+
+func (f *fakeRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	return f.Delete(ctx, id, "")
+}
+
+func (f *fakeRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	f.gets++
+	if !repository.UsesPrimary(ctx) {
+		f.replicaGets++
+	}
+	company, ok := f.companies[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &company, nil
+}
+
+func (f *fakeRepository) InTx(ctx context.Context, fn func(ports.CompanyRepository) error) error {
+	return fn(f)
+}
+
+func (f *fakeRepository) Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	return nil, nil
+}
+
+func (f *fakeRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	return nil
+}
+
+func (f *fakeRepository) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	return nil, nil
+}
+
+func (f *fakeRepository) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return nil, nil
+}
+
+func (f *fakeRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return nil, nil
+}
+
+func (f *fakeRepository) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return nil, nil
+}
+
+func (f *fakeRepository) UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	return nil, errNotFound
+}
+
+func (f *fakeRepository) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	return nil, nil
+}
+
+func Test_MemoryCache(t *testing.T) {
+	c := NewMemoryCache(2, time.Hour)
+
+	alpha := &domain.Company{ID: uuid.New(), Name: "Alpha"}
+	beta := &domain.Company{ID: uuid.New(), Name: "Beta"}
+	gamma := &domain.Company{ID: uuid.New(), Name: "Gamma"}
+
+	_ = c.Set(alpha)
+	_ = c.Set(beta)
+
+	// Reading alpha makes beta the least recently used company.
+	cached, _, _ := c.Get(alpha.ID)
+	cached.Name = "Changed"
+	_ = c.Set(gamma)
+
+	// Neither the companies that are set nor those that are got share their
+	// parent, attributes and tags with the cache.
+	parentID := uuid.New()
+	deltaParentID := parentID
+	delta := &domain.Company{ID: uuid.New(), Name: "Delta", ParentID: &deltaParentID, Attributes: domain.Attributes{"industry": "fintech"}, Tags: domain.Tags{"tier:1"}}
+	shared := NewMemoryCache(1, time.Hour)
+	_ = shared.Set(delta)
+	*delta.ParentID = uuid.New()
+	delta.Attributes["industry"] = "retail"
+	delta.Tags[0] = "tier:2"
+
+	cached, _, _ = shared.Get(delta.ID)
+	if *cached.ParentID != parentID || cached.Attributes["industry"] != "fintech" || cached.Tags[0] != "tier:1" {
+		t.Errorf("expected the cached company to keep its parent, attributes and tags but got %+v", cached)
+	}
+	*cached.ParentID = uuid.New()
+	cached.Attributes["industry"] = "retail"
+	cached.Tags[0] = "tier:2"
+
+	cached, _, _ = shared.Get(delta.ID)
+	if *cached.ParentID != parentID || cached.Attributes["industry"] != "fintech" || cached.Tags[0] != "tier:1" {
+		t.Errorf("expected changing a got company to leave the cache unchanged but got %+v", cached)
+	}
+
+	if _, ok, _ := c.Get(beta.ID); ok {
+		t.Errorf("expected the least recently used company to be evicted")
+	}
+
+	cached, ok, _ := c.Get(alpha.ID)
+	if !ok || cached.Name != "Alpha" {
+		t.Errorf("expected Alpha to stay cached unchanged but got %v", cached)
+	}
+
+	if c.Len() != 2 {
+		t.Errorf("expected 2 cached companies but got %d", c.Len())
+	}
+
+	expiring := NewMemoryCache(2, 10*time.Millisecond)
+	_ = expiring.Set(alpha)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok, _ := expiring.Get(alpha.ID); ok {
+		t.Errorf("expected the company to expire")
+	}
+}
+
+func Test_RedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := NewRedisCache(redis.NewClient(&redis.Options{Addr: server.Addr()}), time.Minute)
+	defer c.Close()
+
+	company := &domain.Company{ID: uuid.New(), Name: "Alpha", Type: domain.Cooperative}
+	_ = c.Set(company)
+
+	cached, ok, err := c.Get(company.ID)
+	if err != nil || !ok || cached.Name != "Alpha" || cached.Type != domain.Cooperative {
+		t.Errorf("expected Alpha to be cached but got %v, %t, %v", cached, ok, err)
+	}
+
+	if ttl := server.TTL(key(company.ID)); ttl != time.Minute {
+		t.Errorf("expected the company to be cached for a minute but got %s", ttl)
+	}
+
+	_ = c.Delete(company.ID)
+	if _, ok, _ := c.Get(company.ID); ok {
+		t.Errorf("expected the company to be deleted")
+	}
+}
+
+func Test_CompanyRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepository{companies: make(map[uuid.UUID]domain.Company)}
+	cached := NewCompanyRepository(repo, NewMemoryCache(10, time.Hour))
+
+	company := &domain.Company{Name: "Alpha"}
+	_ = cached.Create(ctx, company)
+
+	for i := 0; i < 3; i++ {
+		_, _ = cached.Get(ctx, company.ID)
+	}
+
+	if repo.gets != 1 || repo.replicaGets != 0 {
+		t.Errorf("expected a single read from the primary but got %d, %d of them from a replica", repo.gets, repo.replicaGets)
+	}
+
+	company.Name = "Beta"
+	_ = cached.Update(ctx, company)
+
+	got, _ := cached.Get(ctx, company.ID)
+	if got.Name != "Beta" {
+		t.Errorf("expected update to invalidate the company but got %s", got.Name)
+	}
+
+	// Changes made in a transaction are invalidated when it commits, and
+	// reads in a transaction bypass the cache.
+	err := cached.InTx(ctx, func(tx ports.CompanyRepository) error {
+		company.Name = "Gamma"
+		_ = tx.Update(ctx, company)
+
+		got, _ := tx.Get(ctx, company.ID)
+		if got.Name != "Gamma" {
+			t.Errorf("expected the transaction to read its own change but got %s", got.Name)
+		}
+
+		got, _ = cached.Get(ctx, company.ID)
+		if got.Name != "Beta" {
+			t.Errorf("expected the change to stay cached until commit but got %s", got.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error in transaction: %s", err)
+	}
+
+	got, _ = cached.Get(ctx, company.ID)
+	if got.Name != "Gamma" {
+		t.Errorf("expected the commit to invalidate the company but got %s", got.Name)
+	}
+
+	// Another replica changing the company is only seen once invalidated.
+	repo.companies[company.ID] = domain.Company{ID: company.ID, Name: "Delta"}
+	got, _ = cached.Get(ctx, company.ID)
+	if got.Name != "Gamma" {
+		t.Errorf("expected the cached company but got %s", got.Name)
+	}
+
+	cached.Invalidate(company.ID)
+	got, _ = cached.Get(ctx, company.ID)
+	if got.Name != "Delta" {
+		t.Errorf("expected the invalidated company to be read again but got %s", got.Name)
+	}
+
+	_ = cached.Delete(ctx, company.ID, "admin")
+	if _, err := cached.Get(ctx, company.ID); err != errNotFound {
+		t.Errorf("expected deleted company to be gone but got %v", err)
+	}
+
+	expected := Stats{Hits: 4, Misses: 5, Invalidations: 4}
+	if stats := cached.Stats(); stats != expected {
+		t.Errorf("expected stats %+v but got %+v", expected, stats)
+	}
+}
@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+type entry struct {
+	company domain.Company
+	expires time.Time
+}
+
+// copyCompany returns a copy of the company that shares none of its parent,
+// attributes and tags, so that changing one does not change the other.
+func copyCompany(company *domain.Company) domain.Company {
+	copied := *company
+	if company.ParentID != nil {
+		parentID := *company.ParentID
+		copied.ParentID = &parentID
+	}
+	copied.Attributes = company.Attributes.Clone()
+	if company.Tags != nil {
+		copied.Tags = append(domain.Tags{}, company.Tags...)
+	}
+	return copied
+}
+
+// MemoryCache keeps up to size companies in memory for ttl each, evicting the
+// least recently used company when it is full.
+type MemoryCache struct {
+	size int
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	items map[uuid.UUID]*list.Element
+	order *list.List
+}
+
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[uuid.UUID]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(id uuid.UUID) (*domain.Company, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[id]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := elem.Value.(*entry)
+	if !time.Now().Before(e.expires) {
+		c.remove(elem)
+		return nil, false, nil
+	}
+
+	c.order.MoveToFront(elem)
+
+	// Callers get a copy so that changing it does not change the cache.
+	company := copyCompany(&e.company)
+	return &company, true, nil
+}
+
+func (c *MemoryCache) Set(company *domain.Company) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{company: copyCompany(company), expires: time.Now().Add(c.ttl)}
+
+	if elem, ok := c.items[company.ID]; ok {
+		elem.Value = e
+		c.order.MoveToFront(elem)
+		return nil
+	}
+
+	c.items[company.ID] = c.order.PushFront(e)
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+
+	return nil
+}
+
+func (c *MemoryCache) Delete(id uuid.UUID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[id]; ok {
+		c.remove(elem)
+	}
+	return nil
+}
+
+// Len returns the number of cached companies, including the expired ones that
+// have not been evicted yet.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *MemoryCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry).company.ID)
+}
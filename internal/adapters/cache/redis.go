@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCache keeps the companies in Redis for ttl each, shared by every
+// replica. Redis evicts them according to its own maxmemory policy.
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewRedisCache(client *redis.Client, ttl time.Duration) *RedisCache {
+	return &RedisCache{client, ttl}
+}
+
+// NewRedisCacheFromURL connects to the server at a URL such as
+// "redis://localhost:6379/0".
+func NewRedisCacheFromURL(url string, ttl time.Duration) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedisCache(redis.NewClient(opts), ttl), nil
+}
+
+func (c *RedisCache) Get(id uuid.UUID) (*domain.Company, bool, error) {
+	data, err := c.client.Get(context.Background(), key(id)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	var company domain.Company
+	err = json.Unmarshal(data, &company)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &company, true, nil
+}
+
+func (c *RedisCache) Set(company *domain.Company) error {
+	data, err := json.Marshal(company)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Set(context.Background(), key(company.ID), data, c.ttl).Err()
+}
+
+func (c *RedisCache) Delete(id uuid.UUID) error {
+	return c.client.Del(context.Background(), key(id)).Err()
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+func key(id uuid.UUID) string {
+	return "company:" + id.String()
+}
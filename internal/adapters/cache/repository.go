@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// Stats counts how the cache has been used since the repository was created.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+	Errors        uint64 `json:"errors"`
+}
+
+type stats struct {
+	hits, misses, invalidations, errors atomic.Uint64
+}
+
+// CompanyRepository is a read-through cache in front of another repository.
+// Get is served from the cache when it can, while every change made through
+// the repository invalidates the changed company. Changes made by other
+// replicas are invalidated through Invalidate, and in the meantime the ttl of
+// the cache bounds how stale a company can be.
+type CompanyRepository struct {
+	repo  ports.CompanyRepository
+	cache ports.CompanyCache
+	stats *stats
+
+	// changed collects the companies changed in a transaction, which are
+	// invalidated once it commits. It is nil outside of transactions.
+	changed *[]uuid.UUID
+}
+
+func NewCompanyRepository(repo ports.CompanyRepository, cache ports.CompanyCache) *CompanyRepository {
+	return &CompanyRepository{repo: repo, cache: cache, stats: &stats{}}
+}
+
+// Stats returns the hits and misses of Get and the invalidations so far.
+func (a *CompanyRepository) Stats() Stats {
+	return Stats{
+		Hits:          a.stats.hits.Load(),
+		Misses:        a.stats.misses.Load(),
+		Invalidations: a.stats.invalidations.Load(),
+		Errors:        a.stats.errors.Load(),
+	}
+}
+
+// Invalidate removes the company from the cache.
+func (a *CompanyRepository) Invalidate(id uuid.UUID) {
+	a.stats.invalidations.Add(1)
+
+	err := a.cache.Delete(id)
+	if err != nil {
+		a.stats.errors.Add(1)
+		utils.LogError(err)
+	}
+}
+
+func (a *CompanyRepository) changes(id uuid.UUID) {
+	if a.changed != nil {
+		*a.changed = append(*a.changed, id)
+		return
+	}
+	a.Invalidate(id)
+}
+
+func (a *CompanyRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	// Transactions read their own changes, which must not be cached before
+	// they commit.
+	if a.changed != nil {
+		return a.repo.Get(ctx, id)
+	}
+
+	company, ok, err := a.cache.Get(id)
+	if err != nil {
+		a.stats.errors.Add(1)
+		utils.LogError(err)
+	}
+	if ok {
+		a.stats.hits.Add(1)
+		return company, nil
+	}
+	a.stats.misses.Add(1)
+
+	// The company is read from the primary, since a lagging replica would
+	// fill the cache with a company that outlives its next invalidation.
+	company, err = a.repo.Get(repository.WithPrimary(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.cache.Set(company)
+	if err != nil {
+		a.stats.errors.Add(1)
+		utils.LogError(err)
+	}
+
+	return company, nil
+}
+
+func (a *CompanyRepository) Create(ctx context.Context, company *domain.Company) error {
+	return a.repo.Create(ctx, company)
+}
+
+func (a *CompanyRepository) Update(ctx context.Context, company *domain.Company) error {
+	err := a.repo.Update(ctx, company)
+	if err != nil {
+		return err
+	}
+	a.changes(company.ID)
+	return nil
+}
+
+func (a *CompanyRepository) Delete(ctx context.Context, id uuid.UUID, deletedBy string) error {
+	err := a.repo.Delete(ctx, id, deletedBy)
+	if err != nil {
+		return err
+	}
+	a.changes(id)
+	return nil
+}
+
+func (a *CompanyRepository) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	company, err := a.repo.Restore(ctx, id, restoredBy)
+	if err != nil {
+		return nil, err
+	}
+	a.changes(id)
+	return company, nil
+}
+
+func (a *CompanyRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	err := a.repo.Purge(ctx, id)
+	if err != nil {
+		return err
+	}
+	a.changes(id)
+	return nil
+}
+
+// PurgeDeleted needs no invalidation, since soft deleted companies were
+// invalidated when they were deleted.
+func (a *CompanyRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return a.repo.PurgeDeleted(ctx, before)
+}
+
+func (a *CompanyRepository) InTx(ctx context.Context, fn func(ports.CompanyRepository) error) error {
+	if a.changed != nil {
+		return fn(a)
+	}
+
+	var changed []uuid.UUID
+	err := a.repo.InTx(ctx, func(tx ports.CompanyRepository) error {
+		return fn(&CompanyRepository{repo: tx, cache: a.cache, stats: a.stats, changed: &changed})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range changed {
+		a.Invalidate(id)
+	}
+	return nil
+}
+
+func (a *CompanyRepository) Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	return a.repo.Import(ctx, next)
+}
+
+func (a *CompanyRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	return a.repo.Export(ctx, filter, fn)
+}
+
+func (a *CompanyRepository) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	return a.repo.List(ctx, filter, after, limit)
+}
+
+func (a *CompanyRepository) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.repo.Children(ctx, id)
+}
+
+func (a *CompanyRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.repo.Ancestors(ctx, id)
+}
+
+func (a *CompanyRepository) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.repo.Subtree(ctx, id)
+}
+
+func (a *CompanyRepository) UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	company, err := a.repo.UpdateTags(ctx, id, add, remove, updatedBy)
+	if err != nil {
+		return nil, err
+	}
+	a.changes(id)
+	return company, nil
+}
+
+func (a *CompanyRepository) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	return a.repo.Tags(ctx)
+}
@@ -0,0 +1,50 @@
+package graph
+
+import (
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// The codes of the errors, reported in their extensions.
+const (
+	codeBadRequest      = "BAD_REQUEST"
+	codeBadUserInput    = "BAD_USER_INPUT"
+	codeUnauthenticated = "UNAUTHENTICATED"
+	codeNotFound        = "NOT_FOUND"
+	codeConflict        = "CONFLICT"
+	codeTooComplex      = "QUERY_TOO_COMPLEX"
+	codeInternal        = "INTERNAL_SERVER_ERROR"
+)
+
+// Error is an error reported to GraphQL clients with a code and, for failed
+// validations, the reasons each field was rejected in its extensions.
+type Error struct {
+	Message string
+	Code    string
+	Fields  map[string]string
+}
+
+var _ gqlerrors.ExtendedError = (*Error)(nil)
+
+func newError(code, message string) *Error {
+	return &Error{Message: message, Code: code}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Extensions() map[string]any {
+	extensions := map[string]any{"code": e.Code}
+	if len(e.Fields) > 0 {
+		extensions["fields"] = e.Fields
+	}
+	return extensions
+}
+
+// formatted returns the error the way GraphQL reports the errors of a request
+// that could not be executed.
+func (e *Error) formatted() gqlerrors.FormattedError {
+	formatted := gqlerrors.FormatError(e)
+	formatted.Extensions = e.Extensions()
+	return formatted
+}
@@ -0,0 +1,408 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+type discardProducer struct{}
+
+func (discardProducer) ProduceCompany(company *domain.Company, method string) error {
+	return nil
+}
+
+type response struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+type testServer struct {
+	t       *testing.T
+	handler http.Handler
+	token   string
+}
+
+func newTestServer(t *testing.T, limits Limits) *testServer {
+	definitions := repository.NewMemoryAttributeDefinitionRepository()
+	for _, definition := range []*domain.AttributeDefinition{
+		{Name: "industry", Schema: json.RawMessage(`{"type":"string","enum":["fintech","retail"]}`)},
+		{Name: "founded", Schema: json.RawMessage(`{"type":"integer"}`)},
+	} {
+		err := definitions.Create(context.Background(), definition)
+		if err != nil {
+			t.Fatalf("error creating definition: %s", err)
+		}
+	}
+	service := services.NewCompanyService(repository.NewMemoryCompanyRepository(), definitions, discardProducer{}, services.CompanyConfig{})
+	schema, err := NewSchema(service)
+	if err != nil {
+		t.Fatalf("error building schema: %s", err)
+	}
+
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, err := auth.Encode(map[string]interface{}{"sub": "tester"})
+	if err != nil {
+		t.Fatalf("error encoding token: %s", err)
+	}
+
+	return &testServer{t, jwtauth.Verifier(auth)(NewHandler(schema, limits)), token}
+}
+
+// post sends the query with POST, authenticated unless token is empty.
+func (s *testServer) post(query string, variables map[string]any, token string) (int, response) {
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.do(req)
+}
+
+func (s *testServer) get(query string) (int, response) {
+	return s.do(httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(query), nil))
+}
+
+func (s *testServer) do(req *http.Request) (int, response) {
+	rr := httptest.NewRecorder()
+	s.handler.ServeHTTP(rr, req)
+
+	var res response
+	err := json.Unmarshal(rr.Body.Bytes(), &res)
+	if err != nil {
+		s.t.Fatalf("error decoding response %q: %s", rr.Body.String(), err)
+	}
+	return rr.Code, res
+}
+
+func (s *testServer) create(name, companyType string) string {
+	status, res := s.post(`mutation($name: String!) {
+		createCompany(input: {name: $name, type: `+companyType+`}) { id }
+	}`, map[string]any{"name": name}, s.token)
+	if status != http.StatusOK || len(res.Errors) > 0 {
+		s.t.Fatalf("error creating company: %d %v", status, res.Errors)
+	}
+
+	var created struct{ ID string }
+	_ = json.Unmarshal(res.Data["createCompany"], &created)
+	return created.ID
+}
+
+func Test_Mutations(t *testing.T) {
+	s := newTestServer(t, Limits{MaxDepth: 10, MaxComplexity: 1000})
+
+	status, res := s.post(`mutation {
+		createCompany(input: {name: "Alpha", numberOfEmployees: 3, type: COOPERATIVE}) {
+			id name numberOfEmployees type createdBy
+		}
+	}`, nil, s.token)
+	if status != http.StatusOK || len(res.Errors) > 0 {
+		t.Fatalf("error creating company: %d %v", status, res.Errors)
+	}
+
+	var created struct {
+		ID                string
+		Name              string
+		NumberOfEmployees int
+		Type              string
+		CreatedBy         string
+	}
+	_ = json.Unmarshal(res.Data["createCompany"], &created)
+	if created.Name != "Alpha" || created.NumberOfEmployees != 3 || created.Type != "COOPERATIVE" || created.CreatedBy != "tester" {
+		t.Errorf("expected Alpha created by tester but got %+v", created)
+	}
+
+	status, res = s.post(`mutation($id: ID!) {
+		updateCompany(id: $id, input: {registered: true}) { name registered updatedBy }
+	}`, map[string]any{"id": created.ID}, s.token)
+	if status != http.StatusOK || string(res.Data["updateCompany"]) != `{"name":"Alpha","registered":true,"updatedBy":"tester"}` {
+		t.Errorf("expected only registered to be updated but got %s %v", res.Data["updateCompany"], res.Errors)
+	}
+
+	_, res = s.post(`mutation {
+		createCompany(input: {name: "A name that is far too long", type: UNKNOWN}) { id }
+	}`, nil, s.token)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput || res.Errors[0].Extensions["fields"] == nil {
+		t.Errorf("expected a validation error with the invalid fields but got %v", res.Errors)
+	}
+
+	status, res = s.post(`mutation { deleteCompany(id: "`+created.ID+`") }`, nil, "")
+	if status != http.StatusUnauthorized || res.Errors[0].Extensions["code"] != codeUnauthenticated {
+		t.Errorf("expected status code %d without a token but got %d %v", http.StatusUnauthorized, status, res.Errors)
+	}
+
+	status, _ = s.get(`mutation { deleteCompany(id: "` + created.ID + `") }`)
+	if status != http.StatusMethodNotAllowed {
+		t.Errorf("expected status code %d for a mutation sent with GET but got %d", http.StatusMethodNotAllowed, status)
+	}
+
+	status, res = s.post(`mutation { deleteCompany(id: "`+created.ID+`") }`, nil, s.token)
+	if status != http.StatusOK || string(res.Data["deleteCompany"]) != `"`+created.ID+`"` {
+		t.Errorf("expected the id of the deleted company but got %s %v", res.Data["deleteCompany"], res.Errors)
+	}
+
+	_, res = s.post(`mutation { deleteCompany(id: "`+created.ID+`") }`, nil, s.token)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeNotFound {
+		t.Errorf("expected a not found error but got %v", res.Errors)
+	}
+}
+
+func Test_Subsidiaries(t *testing.T) {
+	s := newTestServer(t, Limits{MaxDepth: 10, MaxComplexity: 1000})
+	parent := s.create("Alpha", "COOPERATIVE")
+
+	status, res := s.post(`mutation($parent: ID!) {
+		createCompany(input: {name: "Beta", type: COOPERATIVE, parentId: $parent}) { id parentId }
+	}`, map[string]any{"parent": parent}, s.token)
+	var created struct {
+		ID       string
+		ParentID *string
+	}
+	_ = json.Unmarshal(res.Data["createCompany"], &created)
+	if status != http.StatusOK || created.ParentID == nil || *created.ParentID != parent {
+		t.Fatalf("expected Beta to be a subsidiary of Alpha but got %s %v", res.Data["createCompany"], res.Errors)
+	}
+
+	_, res = s.post(`mutation($id: ID!) {
+		updateCompany(id: $id, input: {parentId: "nope"}) { id }
+	}`, map[string]any{"id": created.ID}, s.token)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput || res.Errors[0].Extensions["fields"] == nil {
+		t.Errorf("expected a validation error of the parent but got %v", res.Errors)
+	}
+
+	_, res = s.post(`mutation($id: ID!) {
+		updateCompany(id: $id, input: {parentId: ""}) { parentId }
+	}`, map[string]any{"id": created.ID}, s.token)
+	if string(res.Data["updateCompany"]) != `{"parentId":null}` {
+		t.Errorf("expected an empty parentId to detach the company but got %s %v", res.Data["updateCompany"], res.Errors)
+	}
+
+	status, res = s.post(`mutation($id: ID!) { deleteCompany(id: $id, subsidiaries: DETACH) }`, map[string]any{"id": parent}, s.token)
+	if status != http.StatusOK || len(res.Errors) > 0 {
+		t.Errorf("expected the company to be deleted but got %d %v", status, res.Errors)
+	}
+}
+
+func Test_Queries(t *testing.T) {
+	s := newTestServer(t, Limits{MaxDepth: 10, MaxComplexity: 1000})
+
+	alpha := s.create("Alpha", "COOPERATIVE")
+	s.create("Beta", "NON_PROFIT")
+	s.create("Alphabet", "NON_PROFIT")
+
+	status, res := s.get(`{ company(id: "` + alpha + `") { name type } }`)
+	if status != http.StatusOK || string(res.Data["company"]) != `{"name":"Alpha","type":"COOPERATIVE"}` {
+		t.Errorf("expected to get Alpha without a token but got %d %s %v", status, res.Data["company"], res.Errors)
+	}
+
+	_, res = s.get(`{ company(id: "` + uuid.NewString() + `") { name } }`)
+	if string(res.Data["company"]) != "null" || len(res.Errors) > 0 {
+		t.Errorf("expected null for an unknown company but got %s %v", res.Data["company"], res.Errors)
+	}
+
+	var names []string
+	var pages int
+	variables := map[string]any{"first": 2}
+	for {
+		_, res := s.post(`query($first: Int, $after: String) {
+			companies(first: $first, after: $after) { items { name } nextCursor }
+		}`, variables, "")
+		if len(res.Errors) > 0 {
+			t.Fatalf("error listing companies: %v", res.Errors)
+		}
+		pages++
+
+		var page struct {
+			Items      []struct{ Name string }
+			NextCursor *string
+		}
+		_ = json.Unmarshal(res.Data["companies"], &page)
+		for _, item := range page.Items {
+			names = append(names, item.Name)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		variables["after"] = *page.NextCursor
+	}
+
+	if pages != 2 || strings.Join(names, ",") != "Alpha,Alphabet,Beta" {
+		t.Errorf("expected Alpha, Alphabet and Beta in 2 pages but got %v in %d", names, pages)
+	}
+
+	_, res = s.get(`{ companies(type: NON_PROFIT) { items { name } } }`)
+	if string(res.Data["companies"]) != `{"items":[{"name":"Alphabet"},{"name":"Beta"}]}` {
+		t.Errorf("expected the non profit companies but got %s %v", res.Data["companies"], res.Errors)
+	}
+
+	_, res = s.get(`{ searchCompanies(name: "alpha") { items { name } } }`)
+	if string(res.Data["searchCompanies"]) != `{"items":[{"name":"Alpha"},{"name":"Alphabet"}]}` {
+		t.Errorf("expected the companies named like alpha but got %s %v", res.Data["searchCompanies"], res.Errors)
+	}
+
+	_, res = s.get(`{ companies(first: 0) { items { name } } }`)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput {
+		t.Errorf("expected an error for an empty page but got %v", res.Errors)
+	}
+
+	status, res = s.get(`{ companies { items { unknown } } }`)
+	if status != http.StatusBadRequest || len(res.Errors) != 1 || res.Data != nil {
+		t.Errorf("expected status code %d for an invalid query but got %d %v", http.StatusBadRequest, status, res.Errors)
+	}
+}
+
+func Test_Attributes(t *testing.T) {
+	s := newTestServer(t, Limits{MaxDepth: 10, MaxComplexity: 1000})
+
+	_, res := s.post(`mutation {
+		createCompany(input: {name: "Alpha", type: COOPERATIVE, attributes: {industry: "mining"}}) { id }
+	}`, nil, s.token)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput || res.Errors[0].Extensions["fields"] == nil {
+		t.Errorf("expected a validation error of the attributes but got %v", res.Errors)
+	}
+
+	status, res := s.post(`mutation {
+		createCompany(input: {name: "Alpha", type: COOPERATIVE, attributes: {industry: "fintech", founded: 1999}}) { id attributes }
+	}`, nil, s.token)
+	var created struct {
+		ID         string
+		Attributes map[string]any
+	}
+	_ = json.Unmarshal(res.Data["createCompany"], &created)
+	if status != http.StatusOK || created.Attributes["industry"] != "fintech" || created.Attributes["founded"] != 1999.0 {
+		t.Fatalf("expected Alpha with its attributes but got %s %v", res.Data["createCompany"], res.Errors)
+	}
+	s.create("Beta", "COOPERATIVE")
+
+	// Attributes set to null in the variables are removed.
+	_, res = s.post(`mutation($id: ID!, $attributes: JSONObject) {
+		updateCompany(id: $id, input: {attributes: $attributes}) { attributes }
+	}`, map[string]any{"id": created.ID, "attributes": map[string]any{"industry": "retail", "founded": nil}}, s.token)
+	if string(res.Data["updateCompany"]) != `{"attributes":{"industry":"retail"}}` {
+		t.Errorf("expected the attributes to be merged but got %s %v", res.Data["updateCompany"], res.Errors)
+	}
+
+	_, res = s.get(`{ companies(attributes: {industry: "retail"}) { items { name attributes } } }`)
+	if string(res.Data["companies"]) != `{"items":[{"attributes":{"industry":"retail"},"name":"Alpha"}]}` {
+		t.Errorf("expected the companies in retail but got %s %v", res.Data["companies"], res.Errors)
+	}
+
+	_, res = s.get(`{ companies { items { name attributes } } }`)
+	if string(res.Data["companies"]) != `{"items":[{"attributes":{"industry":"retail"},"name":"Alpha"},{"attributes":{},"name":"Beta"}]}` {
+		t.Errorf("expected an empty object for the attributes of Beta but got %s %v", res.Data["companies"], res.Errors)
+	}
+
+	_, res = s.get(`{ companies(attributes: {industry: ["retail"]}) { items { name } } }`)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput {
+		t.Errorf("expected an error for a list filter but got %v", res.Errors)
+	}
+}
+
+func Test_Tags(t *testing.T) {
+	s := newTestServer(t, Limits{MaxDepth: 10, MaxComplexity: 1000})
+	alpha := s.create("Alpha", "COOPERATIVE")
+	beta := s.create("Beta", "COOPERATIVE")
+
+	status, res := s.post(`mutation($id: ID!) { addTags(id: $id, tags: ["tier:1"]) { id } }`, map[string]any{"id": alpha}, "")
+	if status != http.StatusUnauthorized {
+		t.Errorf("expected status code %d without a token but got %d %v", http.StatusUnauthorized, status, res.Errors)
+	}
+
+	_, res = s.post(`mutation($id: ID!) {
+		addTags(id: $id, tags: ["Tier:1", "region:emea"]) { tags updatedBy }
+	}`, map[string]any{"id": alpha}, s.token)
+	if string(res.Data["addTags"]) != `{"tags":["region:emea","tier:1"],"updatedBy":"tester"}` {
+		t.Errorf("expected Alpha to be tagged by tester but got %s %v", res.Data["addTags"], res.Errors)
+	}
+	_, res = s.post(`mutation($id: ID!) { addTags(id: $id, tags: ["tier:1"]) { id } }`, map[string]any{"id": beta}, s.token)
+	if len(res.Errors) > 0 {
+		t.Fatalf("error tagging Beta: %v", res.Errors)
+	}
+
+	_, res = s.post(`mutation($id: ID!) { addTags(id: $id, tags: ["not a tag"]) { id } }`, map[string]any{"id": beta}, s.token)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput || res.Errors[0].Extensions["fields"] == nil {
+		t.Errorf("expected a validation error of the tags but got %v", res.Errors)
+	}
+
+	_, res = s.get(`{ companies(tag: ["tier:1", "REGION:EMEA"]) { items { name } } }`)
+	if string(res.Data["companies"]) != `{"items":[{"name":"Alpha"}]}` {
+		t.Errorf("expected only Alpha to have both tags but got %s %v", res.Data["companies"], res.Errors)
+	}
+
+	_, res = s.get(`{ companies(tagAny: ["tier:1", "region:emea"]) { items { name tags } } }`)
+	if string(res.Data["companies"]) != `{"items":[{"name":"Alpha","tags":["region:emea","tier:1"]},{"name":"Beta","tags":["tier:1"]}]}` {
+		t.Errorf("expected both companies to have either tag but got %s %v", res.Data["companies"], res.Errors)
+	}
+
+	_, res = s.get(`{ companies(tag: ["not a tag"]) { items { name } } }`)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeBadUserInput {
+		t.Errorf("expected an error for an invalid tag filter but got %v", res.Errors)
+	}
+
+	_, res = s.post(`mutation($id: ID!) {
+		removeTags(id: $id, tags: ["region:emea", "unknown"]) { tags }
+	}`, map[string]any{"id": alpha}, s.token)
+	if string(res.Data["removeTags"]) != `{"tags":["tier:1"]}` {
+		t.Errorf("expected Alpha to keep tier:1 only but got %s %v", res.Data["removeTags"], res.Errors)
+	}
+
+	_, res = s.get(`{ tags { name count } }`)
+	if string(res.Data["tags"]) != `[{"count":2,"name":"tier:1"}]` {
+		t.Errorf("expected tier:1 on both companies but got %s %v", res.Data["tags"], res.Errors)
+	}
+}
+
+func Test_Limits(t *testing.T) {
+	s := newTestServer(t, Limits{MaxDepth: 3, MaxComplexity: 50})
+
+	testCases := []struct {
+		name           string
+		query          string
+		variables      map[string]any
+		expectedStatus int
+	}{
+		{"within limits", `{ companies(first: 10) { items { name } } }`, nil, http.StatusOK},
+		{"at the maximum depth", `{ companies(first: 1) { items { name } nextCursor } __typename }`, nil, http.StatusOK},
+		{"too complex", `{ companies(first: 30) { items { name } } }`, nil, http.StatusBadRequest},
+		{"too complex by default", `{ companies { items { id name } } }`, nil, http.StatusBadRequest},
+		{"too complex by variable", `query($n: Int) { companies(first: $n) { items { name } } }`, map[string]any{"n": 30}, http.StatusBadRequest},
+		{"too complex by default variable", `query($n: Int = 30) { companies(first: $n) { items { name } } }`, nil, http.StatusBadRequest},
+		{"too complex by fragment", `{ companies(first: 10) { ...page } } fragment page on CompanyPage { items { id name type description } }`, nil, http.StatusBadRequest},
+		{"introspection", `{ __schema { types { name fields { name type { name ofType { name } } } } } }`, nil, http.StatusOK},
+	}
+
+	for _, tt := range testCases {
+		status, res := s.post(tt.query, tt.variables, "")
+		if status != tt.expectedStatus {
+			t.Errorf("%s: expected status code %d but got %d %v", tt.name, tt.expectedStatus, status, res.Errors)
+		}
+		if status == http.StatusBadRequest && (len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != codeTooComplex) {
+			t.Errorf("%s: expected a complexity error but got %v", tt.name, res.Errors)
+		}
+	}
+
+	status, res := s.post(`{ a: companies(first: 1) { items { name } } b: company(id: "`+uuid.NewString()+`") { name } }`, nil, "")
+	if status != http.StatusOK {
+		t.Errorf("expected status code %d but got %d %v", http.StatusOK, status, res.Errors)
+	}
+
+	deep := Limits{MaxDepth: 2, MaxComplexity: 1000}
+	s = newTestServer(t, deep)
+	status, res = s.post(`{ companies(first: 1) { items { name } } }`, nil, "")
+	if status != http.StatusBadRequest || res.Errors[0].Extensions["code"] != codeTooComplex {
+		t.Errorf("expected status code %d for a query too deep but got %d %v", http.StatusBadRequest, status, res.Errors)
+	}
+}
@@ -0,0 +1,174 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// Handler serves GraphQL requests over HTTP. Queries may be sent with GET or
+// POST, mutations only with POST and a valid JWT, like the REST write routes.
+type Handler struct {
+	schema graphql.Schema
+	limits Limits
+}
+
+func NewHandler(schema graphql.Schema, limits Limits) *Handler {
+	return &Handler{schema: schema, limits: limits}
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// ServeHTTP executes the request. Requests that cannot be executed at all are
+// answered with an error status and only errors, while requests that were
+// executed are answered with 200 even if some of their fields failed.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			err := json.Unmarshal([]byte(variables), &req.Variables)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, newError(codeBadRequest, "variables must be a JSON object"))
+				return
+			}
+		}
+	case http.MethodPost:
+		err := utils.ReadJSON(w, r, &req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, newError(codeBadRequest, err.Error()))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, newError(codeBadRequest, "GraphQL requests must be sent with GET or POST"))
+		return
+	}
+
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, newError(codeBadRequest, "query must be provided"))
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	validation := graphql.ValidateDocument(&h.schema, doc, nil)
+	if !validation.IsValid {
+		writeJSON(w, http.StatusBadRequest, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	op, fragments, err := operation(doc, req.OperationName)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, newError(codeBadRequest, err.Error()))
+		return
+	}
+
+	if op.Operation != ast.OperationTypeQuery {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, newError(codeBadRequest, "mutations must be sent with POST"))
+			return
+		}
+
+		// The same check as jwtauth.Authenticator, which cannot protect the
+		// endpoint as a whole since queries are public.
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err != nil || token == nil {
+			writeError(w, http.StatusUnauthorized, newError(codeUnauthenticated, "mutations require a valid JWT"))
+			return
+		}
+	}
+
+	depth, complexity := measure(op, fragments, req.Variables)
+	if depth > h.limits.MaxDepth {
+		writeError(w, http.StatusBadRequest, newError(codeTooComplex,
+			fmt.Sprintf("query has a depth of %d, more than the maximum of %d", depth, h.limits.MaxDepth)))
+		return
+	}
+	if complexity > h.limits.MaxComplexity {
+		writeError(w, http.StatusBadRequest, newError(codeTooComplex,
+			fmt.Sprintf("query has a complexity of %d, more than the maximum of %d", complexity, h.limits.MaxComplexity)))
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       r.Context(),
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// operation returns the operation of the document to execute, along with the
+// fragments it may spread.
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, map[string]*ast.FragmentDefinition, error) {
+	var op *ast.OperationDefinition
+	var operations int
+	fragments := make(map[string]*ast.FragmentDefinition)
+
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.OperationDefinition:
+			operations++
+			if name == "" || (definition.Name != nil && definition.Name.Value == name) {
+				op = definition
+			}
+		case *ast.FragmentDefinition:
+			fragments[definition.Name.Value] = definition
+		}
+	}
+
+	switch {
+	case name == "" && operations > 1:
+		return nil, nil, fmt.Errorf("operationName must be provided for documents with %d operations", operations)
+	case op == nil:
+		return nil, nil, fmt.Errorf("unknown operation named %q", name)
+	}
+	return op, fragments, nil
+}
+
+func writeError(w http.ResponseWriter, status int, err *Error) {
+	writeJSON(w, status, &graphql.Result{Errors: []gqlerrors.FormattedError{err.formatted()}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, result *graphql.Result) {
+	env := utils.Envelope{"data": result.Data}
+	if len(result.Errors) > 0 {
+		env["errors"] = result.Errors
+	}
+	// Requests that were not executed have no data at all.
+	if status != http.StatusOK {
+		delete(env, "data")
+	}
+
+	err := utils.WriteJSON(w, status, env, nil)
+	if err != nil {
+		utils.LogError(err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
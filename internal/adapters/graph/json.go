@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// jsonObject is a JSON object, such as the custom attributes of a company.
+// Numbers are float64, the way encoding/json decodes them.
+var jsonObject = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSONObject",
+	Description: "A JSON object.",
+	Serialize: func(value any) any {
+		switch value := value.(type) {
+		case domain.Attributes:
+			if value == nil {
+				return map[string]any{}
+			}
+			return map[string]any(value)
+		case map[string]any:
+			return value
+		}
+		return nil
+	},
+	ParseValue: func(value any) any {
+		if object, ok := value.(map[string]any); ok {
+			return object
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) any {
+		if _, ok := valueAST.(*ast.ObjectValue); !ok {
+			return nil
+		}
+		return parseLiteral(valueAST)
+	},
+})
+
+// parseLiteral returns the JSON value of a GraphQL literal. Enum values are
+// read as strings.
+func parseLiteral(valueAST ast.Value) any {
+	switch valueAST := valueAST.(type) {
+	case *ast.ObjectValue:
+		object := make(map[string]any, len(valueAST.Fields))
+		for _, field := range valueAST.Fields {
+			object[field.Name.Value] = parseLiteral(field.Value)
+		}
+		return object
+	case *ast.ListValue:
+		list := make([]any, 0, len(valueAST.Values))
+		for _, value := range valueAST.Values {
+			list = append(list, parseLiteral(value))
+		}
+		return list
+	case *ast.IntValue:
+		n, _ := strconv.ParseFloat(valueAST.Value, 64)
+		return n
+	case *ast.FloatValue:
+		n, _ := strconv.ParseFloat(valueAST.Value, 64)
+		return n
+	case *ast.StringValue:
+		return valueAST.Value
+	case *ast.EnumValue:
+		return valueAST.Value
+	case *ast.BooleanValue:
+		return valueAST.Value
+	}
+	return nil
+}
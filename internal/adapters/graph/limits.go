@@ -0,0 +1,107 @@
+package graph
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bound the size of the queries that are executed, so that a single
+// request cannot fetch arbitrarily many companies.
+type Limits struct {
+	// MaxDepth is the deepest nesting of fields allowed, counting the fields
+	// of the operation as depth 1.
+	MaxDepth int
+	// MaxComplexity is the highest cost allowed. Each field costs 1, and the
+	// fields selected below a paginated field cost as many times as the
+	// companies it asks for.
+	MaxComplexity int
+}
+
+// measure returns the depth and complexity of the operation. Introspection
+// fields are not measured.
+func measure(op *ast.OperationDefinition, fragments map[string]*ast.FragmentDefinition, variables map[string]any) (depth, complexity int) {
+	m := &measurer{fragments: fragments, variables: variables, defaults: make(map[string]ast.Value)}
+	for _, definition := range op.VariableDefinitions {
+		if definition.DefaultValue != nil {
+			m.defaults[definition.Variable.Name.Value] = definition.DefaultValue
+		}
+	}
+	return m.selectionSet(op.SelectionSet, 1)
+}
+
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	defaults  map[string]ast.Value
+}
+
+// selectionSet returns the depth and complexity of the selections found at
+// the given depth.
+func (m *measurer) selectionSet(set *ast.SelectionSet, depth int) (maxDepth, complexity int) {
+	if set == nil {
+		return depth - 1, 0
+	}
+
+	maxDepth = depth - 1
+	add := func(d, c int) {
+		if d > maxDepth {
+			maxDepth = d
+		}
+		complexity += c
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			d, c := m.selectionSet(selection.SelectionSet, depth+1)
+			add(d, 1+c*m.multiplier(selection))
+		case *ast.InlineFragment:
+			add(m.selectionSet(selection.SelectionSet, depth))
+		case *ast.FragmentSpread:
+			// Validation has already rejected spreads of unknown fragments
+			// and fragment cycles.
+			if fragment, ok := m.fragments[selection.Name.Value]; ok {
+				add(m.selectionSet(fragment.SelectionSet, depth))
+			}
+		}
+	}
+
+	return maxDepth, complexity
+}
+
+// multiplier returns how many companies a field asks for with its first
+// argument, or 1 for the fields that are not paginated.
+func (m *measurer) multiplier(field *ast.Field) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+
+		value := arg.Value
+		if variable, ok := value.(*ast.Variable); ok {
+			// Variables decoded from JSON are numbers of type float64.
+			if n, ok := m.variables[variable.Name.Value].(float64); ok && n > 0 {
+				return int(n)
+			}
+			value = m.defaults[variable.Name.Value]
+		}
+
+		if value, ok := value.(*ast.IntValue); ok {
+			n, err := strconv.Atoi(value.Value)
+			if err == nil && n > 0 {
+				return n
+			}
+		}
+		return 1
+	}
+
+	if field.Name.Value == "companies" || field.Name.Value == "searchCompanies" {
+		return defaultFirst
+	}
+	return 1
+}
@@ -0,0 +1,549 @@
+package graph
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const (
+	defaultFirst = 20
+	maxFirst     = 100
+)
+
+var companyType = graphql.NewEnum(graphql.EnumConfig{
+	Name: "CompanyType",
+	Values: graphql.EnumValueConfigMap{
+		"CORPORATIONS":        &graphql.EnumValueConfig{Value: domain.Corporations},
+		"NON_PROFIT":          &graphql.EnumValueConfig{Value: domain.NonProfit},
+		"COOPERATIVE":         &graphql.EnumValueConfig{Value: domain.Cooperative},
+		"SOLE_PROPRIETORSHIP": &graphql.EnumValueConfig{Value: domain.SoleProprietorship},
+		"UNKNOWN":             &graphql.EnumValueConfig{Value: domain.Unknown},
+	},
+})
+
+// The fields of Company are resolved from the fields of domain.Company with
+// the same name, ignoring case.
+var company = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Company",
+	Fields: graphql.Fields{
+		"id":                &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"name":              &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"description":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"numberOfEmployees": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		"registered":        &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"type":              &graphql.Field{Type: graphql.NewNonNull(companyType)},
+		"parentId":          &graphql.Field{Type: graphql.ID},
+		"attributes": &graphql.Field{
+			Type:        graphql.NewNonNull(jsonObject),
+			Description: "The custom attributes of the company.",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				company, _ := p.Source.(*domain.Company)
+				if company == nil || company.Attributes == nil {
+					return map[string]any{}, nil
+				}
+				return company.Attributes, nil
+			},
+		},
+		"tags": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Description: "The tags of the company, sorted, which are changed with addTags and removeTags.",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				company, _ := p.Source.(*domain.Company)
+				if company == nil || company.Tags == nil {
+					return []string{}, nil
+				}
+				return []string(company.Tags), nil
+			},
+		},
+		"createdAt": &graphql.Field{Type: graphql.DateTime},
+		"updatedAt": &graphql.Field{Type: graphql.DateTime},
+		"createdBy": &graphql.Field{Type: graphql.String},
+		"updatedBy": &graphql.Field{Type: graphql.String},
+	},
+})
+
+var tag = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Tag",
+	Fields: graphql.Fields{
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"count": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.Int),
+			Description: "The number of companies that have the tag.",
+		},
+	},
+})
+
+var companyPage = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CompanyPage",
+	Fields: graphql.Fields{
+		"items": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(company)))},
+		// nextCursor is null on the last page.
+		"nextCursor": &graphql.Field{Type: graphql.String},
+	},
+})
+
+// page is the source of a CompanyPage.
+type page struct {
+	Items      []*domain.Company
+	NextCursor *string
+}
+
+var createCompanyInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CreateCompanyInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":              &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+		"description":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"numberOfEmployees": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"registered":        &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"type":              &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(companyType)},
+		"parentId":          &graphql.InputObjectFieldConfig{Type: graphql.ID},
+		"attributes":        &graphql.InputObjectFieldConfig{Type: jsonObject},
+	},
+})
+
+// UpdateCompanyInput changes only the fields it is given.
+var updateCompanyInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UpdateCompanyInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":              &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"description":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"numberOfEmployees": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		"registered":        &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		"type":              &graphql.InputObjectFieldConfig{Type: companyType},
+		// The parser does not accept null literals, so an empty parentId
+		// stands for none.
+		"parentId": &graphql.InputObjectFieldConfig{
+			Type:        graphql.ID,
+			Description: "The id of the parent company, or an empty string to detach the company from its parent.",
+		},
+		// Null literals are not accepted either, so the attributes to
+		// remove are set to null in variables.
+		"attributes": &graphql.InputObjectFieldConfig{
+			Type:        jsonObject,
+			Description: "A JSON merge patch of the attributes: those set to null are removed, the others are added or replaced.",
+		},
+	},
+})
+
+var subsidiaryPolicy = graphql.NewEnum(graphql.EnumConfig{
+	Name:        "SubsidiaryPolicy",
+	Description: "What happens to the subsidiaries of a deleted company.",
+	Values: graphql.EnumValueConfigMap{
+		"RESTRICT": &graphql.EnumValueConfig{
+			Value:       domain.RestrictSubsidiaries,
+			Description: "The company is not deleted while it has subsidiaries.",
+		},
+		"DETACH": &graphql.EnumValueConfig{
+			Value:       domain.DetachSubsidiaries,
+			Description: "The subsidiaries are moved to the top of their own groups.",
+		},
+		"CASCADE": &graphql.EnumValueConfig{
+			Value:       domain.CascadeSubsidiaries,
+			Description: "The subsidiaries are deleted along with the company.",
+		},
+	},
+})
+
+// pageArgs are the arguments of the paginated queries. Cursors hold the name
+// of the last company of the previous page.
+var pageArgs = graphql.FieldConfigArgument{
+	"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultFirst},
+	"after": &graphql.ArgumentConfig{Type: graphql.String},
+}
+
+// NewSchema returns the GraphQL schema of the companies, resolved by the
+// company service.
+func NewSchema(service *services.CompanyService) (graphql.Schema, error) {
+	r := &resolver{service}
+
+	listArgs := graphql.FieldConfigArgument{
+		"type":       &graphql.ArgumentConfig{Type: companyType},
+		"registered": &graphql.ArgumentConfig{Type: graphql.Boolean},
+		"attributes": &graphql.ArgumentConfig{
+			Type:        jsonObject,
+			Description: "The values the attributes of the companies must hold, which are strings, numbers or booleans.",
+		},
+		"tag": &graphql.ArgumentConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "Tags the companies must all have.",
+		},
+		"tagAny": &graphql.ArgumentConfig{
+			Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+			Description: "Tags the companies must have at least one of.",
+		},
+	}
+	searchArgs := graphql.FieldConfigArgument{
+		"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+	}
+	for name, arg := range pageArgs {
+		listArgs[name] = arg
+		searchArgs[name] = arg
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"company": &graphql.Field{
+				Type:        company,
+				Description: "The company with the given id, or null if there is none.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.company,
+			},
+			"companies": &graphql.Field{
+				Type:        graphql.NewNonNull(companyPage),
+				Description: "The companies ordered by name.",
+				Args:        listArgs,
+				Resolve:     r.companies,
+			},
+			"searchCompanies": &graphql.Field{
+				Type:        graphql.NewNonNull(companyPage),
+				Description: "The companies whose name contains the given name, ignoring case, ordered by name.",
+				Args:        searchArgs,
+				Resolve:     r.companies,
+			},
+			"tags": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(tag))),
+				Description: "The tags of the companies that are not deleted, ordered by name.",
+				Resolve:     r.tags,
+			},
+		},
+	})
+
+	tagsArgs := graphql.FieldConfigArgument{
+		"id":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+		"tags": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+	}
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createCompany": &graphql.Field{
+				Type: graphql.NewNonNull(company),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(createCompanyInput)},
+				},
+				Resolve: r.createCompany,
+			},
+			"updateCompany": &graphql.Field{
+				Type: graphql.NewNonNull(company),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(updateCompanyInput)},
+				},
+				Resolve: r.updateCompany,
+			},
+			"deleteCompany": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.ID),
+				Description: "Soft deletes the company and returns its id.",
+				Args: graphql.FieldConfigArgument{
+					"id":           &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"subsidiaries": &graphql.ArgumentConfig{Type: subsidiaryPolicy, DefaultValue: domain.RestrictSubsidiaries},
+				},
+				Resolve: r.deleteCompany,
+			},
+			"addTags": &graphql.Field{
+				Type:        graphql.NewNonNull(company),
+				Description: "Tags the company, which keeps the tags it already has.",
+				Args:        tagsArgs,
+				Resolve:     r.addTags,
+			},
+			"removeTags": &graphql.Field{
+				Type:        graphql.NewNonNull(company),
+				Description: "Removes the tags from the company, ignoring those it does not have.",
+				Args:        tagsArgs,
+				Resolve:     r.removeTags,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+type resolver struct {
+	service *services.CompanyService
+}
+
+func (r *resolver) company(p graphql.ResolveParams) (any, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	company, err := r.service.Get(p.Context, id)
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return company, nil
+}
+
+// companies resolves both the listing and the search of companies, which
+// differ only in their filters.
+func (r *resolver) companies(p graphql.ResolveParams) (any, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxFirst {
+		return nil, newError(codeBadUserInput, fmt.Sprintf("first must be between 1 and %d", maxFirst))
+	}
+
+	cursor, _ := p.Args["after"].(string)
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, newError(codeBadUserInput, "after is not a valid cursor")
+	}
+
+	var filter domain.CompanyFilter
+	filter.Name, _ = p.Args["name"].(string)
+	if t, ok := p.Args["type"].(domain.CompanyType); ok {
+		filter.Type = &t
+	}
+	if registered, ok := p.Args["registered"].(bool); ok {
+		filter.Registered = &registered
+	}
+	if attributes, ok := p.Args["attributes"].(map[string]any); ok {
+		filter.Attributes, err = parseAttributeFilters(attributes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	filter.Tags, err = parseTagFilter(p.Args["tag"], "tag")
+	if err != nil {
+		return nil, err
+	}
+	filter.AnyTags, err = parseTagFilter(p.Args["tagAny"], "tagAny")
+	if err != nil {
+		return nil, err
+	}
+
+	// One more company than asked for tells whether there is another page.
+	companies, err := r.service.List(p.Context, filter, string(after), first+1)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+
+	result := page{Items: companies}
+	if len(companies) > first {
+		result.Items = companies[:first]
+		next := base64.RawURLEncoding.EncodeToString([]byte(companies[first-1].Name))
+		result.NextCursor = &next
+	}
+	return result, nil
+}
+
+func (r *resolver) createCompany(p graphql.ResolveParams) (any, error) {
+	input, _ := p.Args["input"].(map[string]any)
+
+	company := &domain.Company{CreatedBy: subject(p)}
+	err := applyInput(company, input)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.service.Create(p.Context, company)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return company, nil
+}
+
+func (r *resolver) updateCompany(p graphql.ResolveParams) (any, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	company, err := r.service.Get(p.Context, id)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+
+	input, _ := p.Args["input"].(map[string]any)
+	err = applyInput(company, input)
+	if err != nil {
+		return nil, err
+	}
+	company.UpdatedBy = subject(p)
+
+	err = r.service.Update(p.Context, company)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return company, nil
+}
+
+func (r *resolver) deleteCompany(p graphql.ResolveParams) (any, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	subsidiaries, _ := p.Args["subsidiaries"].(domain.SubsidiaryPolicy)
+	err = r.service.Delete(p.Context, id, subject(p), subsidiaries)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return id, nil
+}
+
+func (r *resolver) addTags(p graphql.ResolveParams) (any, error) {
+	return r.updateTags(p, r.service.AddTags)
+}
+
+func (r *resolver) removeTags(p graphql.ResolveParams) (any, error) {
+	return r.updateTags(p, r.service.RemoveTags)
+}
+
+func (r *resolver) updateTags(p graphql.ResolveParams, update func(context.Context, uuid.UUID, []string, string) (*domain.Company, error)) (any, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	company, err := update(p.Context, id, stringList(p.Args["tags"]), subject(p))
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return company, nil
+}
+
+func (r *resolver) tags(p graphql.ResolveParams) (any, error) {
+	tags, err := r.service.Tags(p.Context)
+	if err != nil {
+		return nil, resolveError(err)
+	}
+	return tags, nil
+}
+
+// applyInput sets the fields of the company that are present in the input,
+// leaving the others untouched.
+func applyInput(company *domain.Company, input map[string]any) error {
+	if name, ok := input["name"].(string); ok {
+		company.Name = name
+	}
+	if description, ok := input["description"].(string); ok {
+		company.Description = description
+	}
+	if employees, ok := input["numberOfEmployees"].(int); ok {
+		company.NumberOfEmployees = employees
+	}
+	if registered, ok := input["registered"].(bool); ok {
+		company.Registered = registered
+	}
+	if t, ok := input["type"].(domain.CompanyType); ok {
+		company.Type = t
+	}
+	if parent, ok := input["parentId"].(string); ok {
+		company.ParentID = nil
+		if parent != "" {
+			id, err := uuid.Parse(parent)
+			if err != nil {
+				return &Error{
+					Message: "parentId must be a valid UUID",
+					Code:    codeBadUserInput,
+					Fields:  map[string]string{"parent_id": "must be a valid UUID"},
+				}
+			}
+			company.ParentID = &id
+		}
+	}
+	if attributes, ok := input["attributes"].(map[string]any); ok {
+		company.Attributes, _ = domain.MergePatch(company.Attributes, attributes).(map[string]any)
+	}
+	return nil
+}
+
+// parseAttributeFilters checks the attribute filters of a listing like the
+// REST API does.
+func parseAttributeFilters(attributes map[string]any) (map[string]any, error) {
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	for name, value := range attributes {
+		if !domain.ValidAttributeName(name) {
+			return nil, newError(codeBadUserInput, fmt.Sprintf("attributes filter %q is not a valid attribute name", name))
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return nil, newError(codeBadUserInput, fmt.Sprintf("attributes filter %q must be a string, a number or a boolean", name))
+		}
+	}
+	return attributes, nil
+}
+
+// parseTagFilter normalizes the tags of a filter of a listing, rejecting
+// invalid tags like the REST API.
+func parseTagFilter(value any, arg string) ([]string, error) {
+	var tags []string
+	for _, tag := range stringList(value) {
+		tag = domain.NormalizeTag(tag)
+		if tag == "" {
+			continue
+		}
+		if !domain.ValidTag(tag) {
+			return nil, newError(codeBadUserInput, fmt.Sprintf("%s must be tags but got %q", arg, tag))
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// stringList returns the strings of a list argument.
+func stringList(value any) []string {
+	values, _ := value.([]any)
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func parseID(value any) (uuid.UUID, error) {
+	s, _ := value.(string)
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, newError(codeBadUserInput, "id must be a valid UUID")
+	}
+	return id, nil
+}
+
+// subject returns the subject of the JWT of the request, if any.
+func subject(p graphql.ResolveParams) string {
+	_, claims, _ := jwtauth.FromContext(p.Context)
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// resolveError maps the errors of the company service to GraphQL errors, the
+// way the REST handlers map them to HTTP statuses.
+func resolveError(err error) error {
+	var validationErrs domain.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		return &Error{Message: validationErrs.Error(), Code: codeBadUserInput, Fields: validationErrs}
+	case errors.Is(err, repository.ErrParentNotFound), errors.Is(err, repository.ErrHierarchyCycle):
+		return &Error{Message: err.Error(), Code: codeBadUserInput, Fields: map[string]string{"parent_id": err.Error()}}
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return newError(codeNotFound, "the requested company could not be found")
+	case errors.Is(err, repository.ErrDuplicateName), errors.Is(err, repository.ErrHasSubsidiaries):
+		return newError(codeConflict, err.Error())
+	default:
+		utils.LogError(err)
+		return newError(codeInternal, "the server encountered a problem and could not process your request")
+	}
+}
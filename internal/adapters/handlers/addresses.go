@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// ListAddresses returns the addresses of the company, oldest first.
+func (a *CompanyHandler) ListAddresses(w http.ResponseWriter, r *http.Request) {
+	addresses, err := a.details.ListAddresses(r.Context(), utils.ReadIDParam(r))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"addresses": addresses}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *CompanyHandler) GetAddress(w http.ResponseWriter, r *http.Request) {
+	address, err := a.details.GetAddress(r.Context(), utils.ReadIDParam(r), utils.ReadUUIDParam(r, "address_id"))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"address": address}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// CreateAddress adds an address to the company. Country codes are accepted in
+// either case and stored in upper case.
+func (a *CompanyHandler) CreateAddress(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Label       string `json:"label"`
+		Line1       string `json:"line1"`
+		Line2       string `json:"line2"`
+		City        string `json:"city"`
+		Region      string `json:"region"`
+		PostalCode  string `json:"postal_code"`
+		CountryCode string `json:"country_code"`
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	address := &domain.Address{
+		CompanyID:   utils.ReadIDParam(r),
+		Label:       input.Label,
+		Line1:       input.Line1,
+		Line2:       input.Line2,
+		City:        input.City,
+		Region:      input.Region,
+		PostalCode:  input.PostalCode,
+		CountryCode: strings.ToUpper(input.CountryCode),
+	}
+
+	err = a.details.CreateAddress(r.Context(), address)
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/companies/%s/addresses/%s", address.CompanyID, address.ID))
+
+	err = utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"address": address}, headers)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// UpdateAddress changes the fields of the address that are present in the
+// request.
+func (a *CompanyHandler) UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	address, err := a.details.GetAddress(r.Context(), utils.ReadIDParam(r), utils.ReadUUIDParam(r, "address_id"))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	var input struct {
+		Label       *string `json:"label"`
+		Line1       *string `json:"line1"`
+		Line2       *string `json:"line2"`
+		City        *string `json:"city"`
+		Region      *string `json:"region"`
+		PostalCode  *string `json:"postal_code"`
+		CountryCode *string `json:"country_code"`
+	}
+
+	err = utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+	if input.Label != nil {
+		address.Label = *input.Label
+	}
+	if input.Line1 != nil {
+		address.Line1 = *input.Line1
+	}
+	if input.Line2 != nil {
+		address.Line2 = *input.Line2
+	}
+	if input.City != nil {
+		address.City = *input.City
+	}
+	if input.Region != nil {
+		address.Region = *input.Region
+	}
+	if input.PostalCode != nil {
+		address.PostalCode = *input.PostalCode
+	}
+	if input.CountryCode != nil {
+		address.CountryCode = strings.ToUpper(*input.CountryCode)
+	}
+
+	err = a.details.UpdateAddress(r.Context(), address)
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"address": address}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *CompanyHandler) DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	err := a.details.DeleteAddress(r.Context(), utils.ReadIDParam(r), utils.ReadUUIDParam(r, "address_id"))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Address successfully deleted"}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// writeDetailsError responds with the error of reading or changing an address
+// or a contact.
+func writeDetailsError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs domain.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		utils.FailedValidationResponse(w, r, validationErrs)
+	case errors.Is(err, repository.ErrRecordNotFound):
+		utils.NotFoundResponse(w, r)
+	default:
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
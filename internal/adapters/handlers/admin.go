@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// RequireAdmin rejects with 403 the requests whose JWT does not carry the
+// admin role.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !utils.IsAdmin(r) {
+			utils.ForbiddenResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+var (
+	ErrInvalidAttributes      = errors.New("attributes must be an object or null")
+	ErrInvalidAttributeFilter = errors.New("attributes filters must be of the form attributes[name]=value, where value is a string, a number or a boolean")
+)
+
+// attributesInput is the attributes of an update, a JSON merge patch (RFC
+// 7386) of the attributes of the company: attributes set to null are
+// removed, the others are added or replaced, and null removes them all.
+type attributesInput struct {
+	Set   bool
+	Patch map[string]any
+}
+
+func (p *attributesInput) UnmarshalJSON(data []byte) error {
+	var patch any
+	err := json.Unmarshal(data, &patch)
+	if err != nil {
+		return err
+	}
+
+	switch patch := patch.(type) {
+	case nil:
+	case map[string]any:
+		p.Patch = patch
+	default:
+		return ErrInvalidAttributes
+	}
+	p.Set = true
+	return nil
+}
+
+func (p attributesInput) apply(company *domain.Company) {
+	if !p.Set {
+		return
+	}
+	if p.Patch == nil {
+		company.Attributes = nil
+		return
+	}
+	company.Attributes, _ = domain.MergePatch(company.Attributes, p.Patch).(map[string]any)
+}
+
+// parseAttributeFilters returns the attributes filters of the query string,
+// given as attributes[name]=value. Values are decoded as JSON when they can
+// be, so that numbers and booleans match the attributes of the same type,
+// and are strings otherwise: a string of digits is written in quotes.
+func parseAttributeFilters(qs url.Values) (map[string]any, error) {
+	var filters map[string]any
+	for key, values := range qs {
+		name, ok := strings.CutPrefix(key, "attributes[")
+		if !ok {
+			continue
+		}
+		name, ok = strings.CutSuffix(name, "]")
+		if !ok || !domain.ValidAttributeName(name) {
+			return nil, ErrInvalidAttributeFilter
+		}
+
+		var value any
+		err := json.Unmarshal([]byte(values[0]), &value)
+		if err != nil {
+			value = values[0]
+		}
+		switch value.(type) {
+		case string, float64, bool:
+		default:
+			return nil, ErrInvalidAttributeFilter
+		}
+
+		if filters == nil {
+			filters = make(map[string]any)
+		}
+		filters[name] = value
+	}
+	return filters, nil
+}
+
+// AttributeHandler manages the definitions of the custom attributes of
+// companies.
+type AttributeHandler struct {
+	service *services.AttributeService
+}
+
+func NewAttributeHandler(attributeService *services.AttributeService) *AttributeHandler {
+	return &AttributeHandler{attributeService}
+}
+
+// ListAttributes returns every attribute definition, ordered by name.
+func (a *AttributeHandler) ListAttributes(w http.ResponseWriter, r *http.Request) {
+	definitions, err := a.service.List(r.Context())
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"attributes": definitions}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *AttributeHandler) GetAttribute(w http.ResponseWriter, r *http.Request) {
+	definition, err := a.service.Get(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"attribute": definition}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// CreateAttribute defines a custom attribute, whose values must then match
+// its schema.
+func (a *AttributeHandler) CreateAttribute(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Schema      json.RawMessage `json:"schema"`
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	definition := &domain.AttributeDefinition{
+		Name:        input.Name,
+		Description: input.Description,
+		Schema:      input.Schema,
+		CreatedBy:   utils.ReadSubject(r),
+	}
+
+	err = a.service.Create(r.Context(), definition)
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/attributes/%s", definition.Name))
+
+	err = utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"attribute": definition}, headers)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// UpdateAttribute changes the description or the schema of an attribute
+// definition. The values companies already have are not checked against the
+// new schema until the companies are next changed.
+func (a *AttributeHandler) UpdateAttribute(w http.ResponseWriter, r *http.Request) {
+	definition, err := a.service.Get(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	var input struct {
+		Description *string         `json:"description"`
+		Schema      json.RawMessage `json:"schema"`
+	}
+
+	err = utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Description != nil {
+		definition.Description = *input.Description
+	}
+	if input.Schema != nil {
+		definition.Schema = input.Schema
+	}
+
+	err = a.service.Update(r.Context(), definition)
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"attribute": definition}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// DeleteAttribute removes an attribute definition. Companies keep the values
+// they have for it, but cannot be changed until these are removed.
+func (a *AttributeHandler) DeleteAttribute(w http.ResponseWriter, r *http.Request) {
+	err := a.service.Delete(r.Context(), chi.URLParam(r, "name"))
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *AttributeHandler) errorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs domain.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		utils.FailedValidationResponse(w, r, validationErrs)
+	case errors.Is(err, repository.ErrRecordNotFound):
+		utils.NotFoundResponse(w, r)
+	case errors.Is(err, repository.ErrDuplicateAttribute):
+		utils.ConflictResponse(w, r, err)
+	default:
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const maxBatchSize = 5000
+
+var (
+	ErrEmptyBatch    = errors.New("batch must contain at least one item")
+	ErrBatchTooLarge = fmt.Errorf("batch must not contain more than %d items", maxBatchSize)
+	ErrInvalidMode   = errors.New(`mode must be either "atomic" or "best-effort"`)
+)
+
+type batchResult struct {
+	Index   int             `json:"index"`
+	Status  int             `json:"status"`
+	ID      *uuid.UUID      `json:"id,omitempty"`
+	Company *domain.Company `json:"company,omitempty"`
+	Error   any             `json:"error,omitempty"`
+}
+
+func (a *CompanyHandler) CreateCompanies(w http.ResponseWriter, r *http.Request) {
+	atomic, err := readBatchMode(r)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var input []struct {
+		Name              string             `json:"name"`
+		Description       string             `json:"description"`
+		NumberOfEmployees int                `json:"number_of_employees"`
+		Registered        bool               `json:"registered"`
+		Type              domain.CompanyType `json:"type"`
+		ParentID          *uuid.UUID         `json:"parent_id"`
+		Attributes        domain.Attributes  `json:"attributes"`
+	}
+
+	err = utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err = checkBatchSize(len(input))
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	createdBy := utils.ReadSubject(r)
+	companies := make([]*domain.Company, len(input))
+	for i, in := range input {
+		companies[i] = &domain.Company{
+			Name:              in.Name,
+			Description:       in.Description,
+			NumberOfEmployees: in.NumberOfEmployees,
+			Registered:        in.Registered,
+			Type:              in.Type,
+			ParentID:          in.ParentID,
+			Attributes:        in.Attributes,
+			CreatedBy:         createdBy,
+		}
+	}
+
+	errs := a.service.CreateBatch(r.Context(), companies, atomic)
+
+	results := make([]batchResult, len(errs))
+	for i, err := range errs {
+		results[i] = newBatchResult(i, err, http.StatusCreated)
+		if err == nil {
+			results[i].Company = companies[i]
+		}
+	}
+
+	writeBatchResponse(w, r, results, atomic, http.StatusCreated)
+}
+
+func (a *CompanyHandler) UpdateCompanies(w http.ResponseWriter, r *http.Request) {
+	atomic, err := readBatchMode(r)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var input []struct {
+		ID                uuid.UUID           `json:"id"`
+		Name              *string             `json:"name"`
+		Description       *string             `json:"description"`
+		NumberOfEmployees *int                `json:"number_of_employees"`
+		Registered        *bool               `json:"registered"`
+		Type              *domain.CompanyType `json:"type"`
+		ParentID          parentInput         `json:"parent_id"`
+		Attributes        attributesInput     `json:"attributes"`
+	}
+
+	err = utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err = checkBatchSize(len(input))
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	updates := make([]services.CompanyUpdate, len(input))
+	for i := range input {
+		in := input[i]
+		updates[i] = services.CompanyUpdate{
+			ID: in.ID,
+			Apply: func(company *domain.Company) {
+				if in.Name != nil {
+					company.Name = *in.Name
+				}
+				if in.Description != nil {
+					company.Description = *in.Description
+				}
+				if in.NumberOfEmployees != nil {
+					company.NumberOfEmployees = *in.NumberOfEmployees
+				}
+				if in.Registered != nil {
+					company.Registered = *in.Registered
+				}
+				if in.Type != nil {
+					company.Type = *in.Type
+				}
+				in.ParentID.apply(company)
+				in.Attributes.apply(company)
+			},
+		}
+	}
+
+	companies, errs := a.service.UpdateBatch(r.Context(), updates, utils.ReadSubject(r), atomic)
+
+	results := make([]batchResult, len(errs))
+	for i, err := range errs {
+		results[i] = newBatchResult(i, err, http.StatusOK)
+		if err == nil {
+			results[i].Company = companies[i]
+		} else {
+			results[i].ID = &input[i].ID
+		}
+	}
+
+	writeBatchResponse(w, r, results, atomic, http.StatusOK)
+}
+
+func (a *CompanyHandler) DeleteCompanies(w http.ResponseWriter, r *http.Request) {
+	atomic, err := readBatchMode(r)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var ids []uuid.UUID
+
+	err = utils.ReadJSON(w, r, &ids)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	err = checkBatchSize(len(ids))
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	errs := a.service.DeleteBatch(r.Context(), ids, utils.ReadSubject(r), atomic)
+
+	results := make([]batchResult, len(errs))
+	for i, err := range errs {
+		results[i] = newBatchResult(i, err, http.StatusOK)
+		results[i].ID = &ids[i]
+	}
+
+	writeBatchResponse(w, r, results, atomic, http.StatusOK)
+}
+
+// readBatchMode reports whether the batch must be applied atomically, which is
+// the default, or item by item.
+func readBatchMode(r *http.Request) (bool, error) {
+	switch r.URL.Query().Get("mode") {
+	case "", "atomic":
+		return true, nil
+	case "best-effort":
+		return false, nil
+	default:
+		return false, ErrInvalidMode
+	}
+}
+
+func checkBatchSize(n int) error {
+	switch {
+	case n == 0:
+		return ErrEmptyBatch
+	case n > maxBatchSize:
+		return ErrBatchTooLarge
+	default:
+		return nil
+	}
+}
+
+func newBatchResult(index int, err error, success int) batchResult {
+	result := batchResult{Index: index, Status: success}
+	if err == nil {
+		return result
+	}
+
+	var validationErrs domain.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		result.Status = http.StatusUnprocessableEntity
+		result.Error = validationErrs
+	case errors.Is(err, repository.ErrParentNotFound), errors.Is(err, repository.ErrHierarchyCycle):
+		result.Status = http.StatusUnprocessableEntity
+		result.Error = domain.ValidationErrors{"parent_id": err.Error()}
+	case errors.Is(err, repository.ErrRecordNotFound):
+		result.Status = http.StatusNotFound
+		result.Error = "the requested resource could not be found"
+	case errors.Is(err, repository.ErrDuplicateName), errors.Is(err, repository.ErrHasSubsidiaries):
+		result.Status = http.StatusConflict
+		result.Error = err.Error()
+	case errors.Is(err, services.ErrBatchAborted):
+		result.Status = http.StatusFailedDependency
+		result.Error = err.Error()
+	case errors.Is(err, context.Canceled):
+		result.Status = utils.StatusClientClosedRequest
+		result.Error = "the request was cancelled before this item could be processed"
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Status = http.StatusGatewayTimeout
+		result.Error = "the request took too long to process this item"
+	default:
+		utils.LogError(err)
+		result.Status = http.StatusInternalServerError
+		result.Error = "the server encountered a problem and could not process this item"
+	}
+	return result
+}
+
+// writeBatchResponse responds with success when every item succeeded. A failed
+// atomic batch responds with the status of the item that caused the failure
+// and a best-effort batch with failed items responds with 207.
+func writeBatchResponse(w http.ResponseWriter, r *http.Request, results []batchResult, atomic bool, success int) {
+	status := success
+	for _, result := range results {
+		if result.Status == success || result.Status == http.StatusFailedDependency {
+			continue
+		}
+		status = result.Status
+		if !atomic {
+			status = http.StatusMultiStatus
+		}
+		break
+	}
+
+	err := utils.WriteJSON(w, status, utils.Envelope{"results": results}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/petrostrak/xm-companies/internal/adapters/repository"
@@ -14,28 +16,40 @@ import (
 
 const (
 	URL = "http://localhost:8082/topics/%s/partitions/%d"
+
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+var (
+	ErrInvalidLimit  = fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+	ErrInvalidCursor = errors.New("after must be a cursor returned as next_cursor")
 )
 
 type CompanyHandler struct {
 	service services.CompanyService
+	details *services.CompanyDetailsService
 }
 
-func NewCompanyHandler(companyService services.CompanyService) *CompanyHandler {
-	return &CompanyHandler{companyService}
+func NewCompanyHandler(companyService services.CompanyService, details *services.CompanyDetailsService) *CompanyHandler {
+	return &CompanyHandler{companyService, details}
 }
 
 func (a *CompanyHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name              string `json:"name"`
-		Description       string `json:"description"`
-		NumberOfEmployees int    `json:"number_of_employees"`
-		Registered        bool   `json:"registered"`
-		Type              int    `json:"type"`
+		Name              string             `json:"name"`
+		Description       string             `json:"description"`
+		NumberOfEmployees int                `json:"number_of_employees"`
+		Registered        bool               `json:"registered"`
+		Type              domain.CompanyType `json:"type"`
+		ParentID          *uuid.UUID         `json:"parent_id"`
+		Attributes        domain.Attributes  `json:"attributes"`
 	}
 
 	err := utils.ReadJSON(w, r, &input)
 	if err != nil {
 		utils.BadRequestResponse(w, r, err)
+		return
 	}
 
 	company := &domain.Company{
@@ -43,12 +57,25 @@ func (a *CompanyHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
 		Description:       input.Description,
 		NumberOfEmployees: input.NumberOfEmployees,
 		Registered:        input.Registered,
-		Type:              domain.CompanyType(input.Type),
+		Type:              input.Type,
+		ParentID:          input.ParentID,
+		Attributes:        input.Attributes,
+		CreatedBy:         utils.ReadSubject(r),
 	}
 
-	err = a.service.Create(company)
+	err = a.service.Create(r.Context(), company)
 	if err != nil {
-		utils.ServerErrorResponse(w, r, err)
+		var validationErrs domain.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrs):
+			utils.FailedValidationResponse(w, r, validationErrs)
+		case errors.Is(err, repository.ErrParentNotFound), errors.Is(err, repository.ErrHierarchyCycle):
+			utils.FailedValidationResponse(w, r, map[string]string{"parent_id": err.Error()})
+		case errors.Is(err, repository.ErrDuplicateName):
+			utils.ConflictResponse(w, r, err)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -61,10 +88,18 @@ func (a *CompanyHandler) CreateCompany(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetCompany returns the company, along with its addresses and contacts when
+// they are listed in include.
 func (a *CompanyHandler) GetCompany(w http.ResponseWriter, r *http.Request) {
 	id := utils.ReadIDParam(r)
 
-	company, err := a.service.Get(id)
+	inc, err := parseInclude(r.URL.Query())
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	company, err := a.service.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
@@ -75,31 +110,108 @@ func (a *CompanyHandler) GetCompany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var comp struct {
-		ID                uuid.UUID `json:"id"`
-		Name              string    `json:"name"`
-		Description       string    `json:"description"`
-		NumberOfEmployees int       `json:"number_of_employees"`
-		Registered        bool      `json:"registered"`
-		Type              string    `json:"type"`
+	var payload any = company
+	if inc.any() {
+		views, err := a.withDetails(r.Context(), []*domain.Company{company}, inc)
+		if err != nil {
+			utils.ServerErrorResponse(w, r, err)
+			return
+		}
+		payload = views[0]
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"Company": payload}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ListCompanies returns a page of the companies matching the name, type,
+// registered and attributes filters, ordered by name, along with the details listed in
+// include. The cursor of the next page, if there is one, is returned as
+// next_cursor, to be passed as after.
+func (a *CompanyHandler) ListCompanies(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	filter, err := parseCompanyFilter(qs)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	inc, err := parseInclude(qs)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	limit := defaultListLimit
+	if value := qs.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxListLimit {
+			utils.BadRequestResponse(w, r, ErrInvalidLimit)
+			return
+		}
+		limit = n
+	}
+
+	// Cursors hold the name of the last company of the previous page.
+	after, err := base64.RawURLEncoding.DecodeString(qs.Get("after"))
+	if err != nil {
+		utils.BadRequestResponse(w, r, ErrInvalidCursor)
+		return
+	}
+
+	// One more company than asked for tells whether there is another page.
+	companies, err := a.service.List(r.Context(), filter, string(after), limit+1)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	env := utils.Envelope{}
+	if len(companies) > limit {
+		companies = companies[:limit]
+		env["next_cursor"] = base64.RawURLEncoding.EncodeToString([]byte(companies[limit-1].Name))
+	}
+	if companies == nil {
+		companies = []*domain.Company{}
+	}
+	env["companies"] = companies
+	if inc.any() {
+		env["companies"], err = a.withDetails(r.Context(), companies, inc)
+		if err != nil {
+			utils.ServerErrorResponse(w, r, err)
+			return
+		}
 	}
-	comp.ID = company.ID
-	comp.Name = company.Name
-	comp.Description = company.Description
-	comp.NumberOfEmployees = company.NumberOfEmployees
-	comp.Registered = company.Registered
-	comp.Type = company.Type.ToString()
 
-	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"Company": comp}, nil)
+	err = utils.WriteJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		utils.ServerErrorResponse(w, r, err)
 	}
 }
 
+// UpdateCompany changes the company with the fields of a JSON body that are
+// present, a JSON merge patch or a JSON Patch of the company, depending on the
+// Content-Type of the body. Other media types are rejected with 415.
 func (a *CompanyHandler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
 	id := utils.ReadIDParam(r)
 
-	company, err := a.service.Get(id)
+	read, ok := patchReader(r)
+	if !ok {
+		w.Header().Set("Accept-Patch", acceptPatch)
+		utils.UnsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	patch, err := read(w, r)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	company, err := a.service.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
@@ -110,64 +222,96 @@ func (a *CompanyHandler) UpdateCompany(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var input struct {
-		Name              *string `json:"name"`
-		Description       *string `json:"description"`
-		NumberOfEmployees *int    `json:"number_of_employees"`
-		Registered        *bool   `json:"registered"`
-		Type              *int    `json:"type"`
+	err = patch(company)
+	if err == nil {
+		company.UpdatedBy = utils.ReadSubject(r)
+		err = a.service.Update(r.Context(), company)
 	}
-
-	err = utils.ReadJSON(w, r, &input)
 	if err != nil {
-		utils.BadRequestResponse(w, r, err)
-	}
-	if input.Name != nil {
-		company.Name = *input.Name
-	}
-	if input.Description != nil {
-		company.Description = *input.Description
+		var validationErrs domain.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrs):
+			utils.FailedValidationResponse(w, r, validationErrs)
+		case errors.Is(err, domain.ErrInvalidPatch):
+			utils.BadRequestResponse(w, r, err)
+		case errors.Is(err, domain.ErrPatchConflict):
+			utils.ConflictResponse(w, r, err)
+		case errors.Is(err, repository.ErrParentNotFound), errors.Is(err, repository.ErrHierarchyCycle):
+			utils.FailedValidationResponse(w, r, map[string]string{"parent_id": err.Error()})
+		case errors.Is(err, repository.ErrDuplicateName):
+			utils.ConflictResponse(w, r, err)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
 	}
-	if input.NumberOfEmployees != nil {
-		company.NumberOfEmployees = *input.NumberOfEmployees
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"Company": company}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
 	}
-	if input.Registered != nil {
-		company.Registered = *input.Registered
+}
+
+func (a *CompanyHandler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
+	id := utils.ReadIDParam(r)
+
+	purge := r.URL.Query().Get("purge") == "true"
+	if purge && !utils.IsAdmin(r) {
+		utils.ForbiddenResponse(w, r)
+		return
 	}
-	if input.Type != nil {
-		company.Type = domain.CompanyType(*input.Type)
+
+	subsidiaries, err := domain.ParseSubsidiaryPolicy(r.URL.Query().Get("subsidiaries"))
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
 	}
 
-	err = a.service.Update(company)
+	if purge {
+		err = a.service.Purge(r.Context(), id)
+	} else {
+		err = a.service.Delete(r.Context(), id, utils.ReadSubject(r), subsidiaries)
+	}
 	if err != nil {
-		utils.ServerErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			utils.NotFoundResponse(w, r)
+		case errors.Is(err, repository.ErrHasSubsidiaries):
+			utils.ConflictResponse(w, r, err)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"Company": company}, nil)
+	message := "Company successfully deleted"
+	if purge {
+		message = "Company successfully purged"
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": message}, nil)
 	if err != nil {
 		utils.ServerErrorResponse(w, r, err)
 	}
 }
 
-func (a *CompanyHandler) DeleteCompany(w http.ResponseWriter, r *http.Request) {
+func (a *CompanyHandler) RestoreCompany(w http.ResponseWriter, r *http.Request) {
 	id := utils.ReadIDParam(r)
 
-	err := a.service.Delete(id)
+	company, err := a.service.Restore(r.Context(), id, utils.ReadSubject(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
 			utils.NotFoundResponse(w, r)
+		case errors.Is(err, repository.ErrDuplicateName), errors.Is(err, repository.ErrParentNotFound):
+			utils.ConflictResponse(w, r, err)
 		default:
 			utils.ServerErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	var company domain.Company
-	company.ID = id
-
-	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Company successfully deleted"}, nil)
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"Company": company}, nil)
 	if err != nil {
 		utils.ServerErrorResponse(w, r, err)
 	}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// ListContacts returns the contacts of the company, oldest first.
+func (a *CompanyHandler) ListContacts(w http.ResponseWriter, r *http.Request) {
+	contacts, err := a.details.ListContacts(r.Context(), utils.ReadIDParam(r))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"contacts": contacts}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *CompanyHandler) GetContact(w http.ResponseWriter, r *http.Request) {
+	contact, err := a.details.GetContact(r.Context(), utils.ReadIDParam(r), utils.ReadUUIDParam(r, "contact_id"))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"contact": contact}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *CompanyHandler) CreateContact(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Phone string `json:"phone"`
+		Role  string `json:"role"`
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	contact := &domain.Contact{
+		CompanyID: utils.ReadIDParam(r),
+		Name:      input.Name,
+		Email:     input.Email,
+		Phone:     input.Phone,
+		Role:      input.Role,
+	}
+
+	err = a.details.CreateContact(r.Context(), contact)
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/companies/%s/contacts/%s", contact.CompanyID, contact.ID))
+
+	err = utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"contact": contact}, headers)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// UpdateContact changes the fields of the contact that are present in the
+// request.
+func (a *CompanyHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
+	contact, err := a.details.GetContact(r.Context(), utils.ReadIDParam(r), utils.ReadUUIDParam(r, "contact_id"))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	var input struct {
+		Name  *string `json:"name"`
+		Email *string `json:"email"`
+		Phone *string `json:"phone"`
+		Role  *string `json:"role"`
+	}
+
+	err = utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+	if input.Name != nil {
+		contact.Name = *input.Name
+	}
+	if input.Email != nil {
+		contact.Email = *input.Email
+	}
+	if input.Phone != nil {
+		contact.Phone = *input.Phone
+	}
+	if input.Role != nil {
+		contact.Role = *input.Role
+	}
+
+	err = a.details.UpdateContact(r.Context(), contact)
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"contact": contact}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *CompanyHandler) DeleteContact(w http.ResponseWriter, r *http.Request) {
+	err := a.details.DeleteContact(r.Context(), utils.ReadIDParam(r), utils.ReadUUIDParam(r, "contact_id"))
+	if err != nil {
+		writeDetailsError(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"message": "Contact successfully deleted"}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
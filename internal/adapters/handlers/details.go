@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+var ErrInvalidInclude = errors.New(`include must be a comma separated list of "addresses" and "contacts"`)
+
+// include is the details of companies to return along with them.
+type include struct {
+	addresses bool
+	contacts  bool
+}
+
+func (i include) any() bool {
+	return i.addresses || i.contacts
+}
+
+func parseInclude(qs url.Values) (include, error) {
+	var inc include
+	for _, value := range qs["include"] {
+		for _, name := range strings.Split(value, ",") {
+			switch strings.TrimSpace(name) {
+			case "addresses":
+				inc.addresses = true
+			case "contacts":
+				inc.contacts = true
+			default:
+				return inc, ErrInvalidInclude
+			}
+		}
+	}
+	return inc, nil
+}
+
+// companyView is a company along with the details asked for with include,
+// which are left out rather than empty when they were not asked for.
+type companyView struct {
+	*domain.Company
+	Addresses *[]*domain.Address `json:"addresses,omitempty"`
+	Contacts  *[]*domain.Contact `json:"contacts,omitempty"`
+}
+
+// withDetails returns the companies along with the details asked for, which
+// are read for all of them at once.
+func (a *CompanyHandler) withDetails(ctx context.Context, companies []*domain.Company, inc include) ([]*companyView, error) {
+	ids := make([]uuid.UUID, len(companies))
+	for i, company := range companies {
+		ids[i] = company.ID
+	}
+
+	var addresses map[uuid.UUID][]*domain.Address
+	if inc.addresses {
+		var err error
+		addresses, err = a.details.AddressesOf(ctx, ids...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var contacts map[uuid.UUID][]*domain.Contact
+	if inc.contacts {
+		var err error
+		contacts, err = a.details.ContactsOf(ctx, ids...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	views := make([]*companyView, len(companies))
+	for i, company := range companies {
+		views[i] = &companyView{Company: company}
+		if inc.addresses {
+			companyAddresses := addresses[company.ID]
+			views[i].Addresses = &companyAddresses
+		}
+		if inc.contacts {
+			companyContacts := contacts[company.ID]
+			views[i].Contacts = &companyContacts
+		}
+	}
+	return views, nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/petrostrak/xm-companies/utils"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerUI is the Swagger UI page, which loads its assets from /docs/ and
+// the specification from /openapi.json.
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8">
+    <title>XM Companies API</title>
+    <link rel="stylesheet" type="text/css" href="/docs/swagger-ui.css" />
+    <link rel="icon" type="image/png" href="/docs/favicon-32x32.png" sizes="32x32" />
+  </head>
+  <body>
+    <div id="swagger-ui"></div>
+    <script src="/docs/swagger-ui-bundle.js" charset="UTF-8"></script>
+    <script src="/docs/swagger-ui-standalone-preset.js" charset="UTF-8"></script>
+    <script>
+      window.onload = function () {
+        window.ui = SwaggerUIBundle({
+          url: "/openapi.json",
+          dom_id: "#swagger-ui",
+          presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+          layout: "StandaloneLayout",
+        });
+      };
+    </script>
+  </body>
+</html>
+`
+
+// DocsHandler serves the OpenAPI specification and a Swagger UI to browse it,
+// both embedded in the binary.
+type DocsHandler struct {
+	spec   []byte
+	assets http.Handler
+}
+
+func NewDocsHandler(doc *openapi3.T) (*DocsHandler, error) {
+	spec, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DocsHandler{
+		spec:   spec,
+		assets: http.StripPrefix("/docs/", http.FileServer(http.FS(swaggerFiles.FS))),
+	}, nil
+}
+
+func (a *DocsHandler) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write(a.spec)
+	if err != nil {
+		utils.LogError(err)
+	}
+}
+
+func (a *DocsHandler) SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := w.Write([]byte(swaggerUI))
+	if err != nil {
+		utils.LogError(err)
+	}
+}
+
+// Assets serves the files of Swagger UI, except for its index page, which
+// would load the example specification of Swagger UI rather than ours.
+func (a *DocsHandler) Assets(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/docs/") {
+	case "", "index.html":
+		a.SwaggerUI(w, r)
+	default:
+		a.assets.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// exportFlushInterval is the number of companies written between flushes of
+// an export to the client.
+const exportFlushInterval = 100
+
+// exportErrorTrailer is the trailer that reports an export cut short after
+// its first companies were sent, when the status can no longer tell.
+const exportErrorTrailer = "X-Export-Error"
+
+var (
+	ErrInvalidFormat     = errors.New(`format must be either "csv" or "ndjson"`)
+	ErrInvalidType       = errors.New("type must be a valid company type")
+	ErrInvalidRegistered = errors.New("registered must be a boolean")
+)
+
+var exportColumns = []string{
+	"id", "name", "description", "number_of_employees", "registered", "type",
+	"parent_id", "attributes", "tags", "created_at", "updated_at", "created_by", "updated_by",
+}
+
+// companyEncoder writes companies to an export stream.
+type companyEncoder interface {
+	Encode(*domain.Company) error
+	Flush() error
+}
+
+func (a *CompanyHandler) ExportCompanies(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseCompanyFilter(r.URL.Query())
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	var enc companyEncoder
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="companies.csv"`)
+		enc = &csvEncoder{writer: csv.NewWriter(w)}
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="companies.ndjson"`)
+		enc = &ndjsonEncoder{encoder: json.NewEncoder(w)}
+	default:
+		utils.BadRequestResponse(w, r, ErrInvalidFormat)
+		return
+	}
+
+	w.Header().Set("Trailer", exportErrorTrailer)
+
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	err = a.service.Export(r.Context(), filter, func(company *domain.Company) error {
+		err := enc.Encode(company)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushInterval == 0 {
+			err = enc.Flush()
+			if err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// Once the first companies have been sent the status can no longer be
+		// changed, so the export is cut short and reported in the trailer.
+		if written == 0 {
+			w.Header().Del("Content-Disposition")
+			w.Header().Del("Trailer")
+			utils.ServerErrorResponse(w, r, err)
+			return
+		}
+		failExport(w, err)
+		return
+	}
+
+	err = enc.Flush()
+	if err != nil {
+		failExport(w, err)
+	}
+}
+
+// failExport logs the error that cut an export short and reports it to the
+// client in the trailer.
+func failExport(w http.ResponseWriter, err error) {
+	utils.LogError(err)
+	w.Header().Set(exportErrorTrailer, "the export could not be completed")
+}
+
+// RunExportJob is the job runner for asynchronous exports. The job's params
+// hold the format and the filters of the export.
+func (a *CompanyHandler) RunExportJob(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error) {
+	qs := make(url.Values, len(job.Params))
+	for key, value := range job.Params {
+		qs.Set(key, value)
+	}
+
+	filter, err := parseCompanyFilter(qs)
+	if err != nil {
+		return "", err
+	}
+
+	var enc companyEncoder
+	var resultType string
+	switch qs.Get("format") {
+	case "", "csv":
+		enc, resultType = &csvEncoder{writer: csv.NewWriter(output)}, "text/csv"
+	case "ndjson":
+		enc, resultType = &ndjsonEncoder{encoder: json.NewEncoder(output)}, "application/x-ndjson"
+	default:
+		return "", ErrInvalidFormat
+	}
+
+	var written int64
+	err = a.service.Export(ctx, filter, func(company *domain.Company) error {
+		err := enc.Encode(company)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushInterval == 0 {
+			return progress(written)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	err = enc.Flush()
+	if err != nil {
+		return "", err
+	}
+
+	return resultType, progress(written)
+}
+
+// parseCompanyFilter reads the optional name, type, registered and attributes
+// filters from a query string.
+func parseCompanyFilter(qs url.Values) (domain.CompanyFilter, error) {
+	filter := domain.CompanyFilter{Name: qs.Get("name")}
+
+	if value := qs.Get("type"); value != "" {
+		companyType, err := domain.ParseCompanyType(value)
+		if err != nil {
+			return filter, ErrInvalidType
+		}
+		filter.Type = &companyType
+	}
+
+	if value := qs.Get("registered"); value != "" {
+		registered, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, ErrInvalidRegistered
+		}
+		filter.Registered = &registered
+	}
+
+	attributes, err := parseAttributeFilters(qs)
+	if err != nil {
+		return filter, err
+	}
+	filter.Attributes = attributes
+
+	filter.Tags, err = parseTagFilter(qs, "tag")
+	if err != nil {
+		return filter, err
+	}
+	filter.AnyTags, err = parseTagFilter(qs, "tag_any")
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// csvEncoder writes the header row along with the first company, or on flush
+// when there are no companies at all.
+type csvEncoder struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.writer.Write(exportColumns)
+}
+
+func (e *csvEncoder) Encode(company *domain.Company) error {
+	err := e.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	var parentID string
+	if company.ParentID != nil {
+		parentID = company.ParentID.String()
+	}
+
+	attributes, err := company.Attributes.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	return e.writer.Write([]string{
+		company.ID.String(),
+		company.Name,
+		company.Description,
+		strconv.Itoa(company.NumberOfEmployees),
+		strconv.FormatBool(company.Registered),
+		company.Type.String(),
+		parentID,
+		string(attributes),
+		strings.Join(company.Tags, ","),
+		company.CreatedAt.Format(time.RFC3339Nano),
+		company.UpdatedAt.Format(time.RFC3339Nano),
+		company.CreatedBy,
+		company.UpdatedBy,
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	err := e.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(company *domain.Company) error {
+	return e.encoder.Encode(company)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
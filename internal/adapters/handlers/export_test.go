@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+// failingExportRepository exports its companies and then fails.
+type failingExportRepository struct {
+	*repository.MemoryCompanyRepository
+}
+
+func (f failingExportRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	err := f.MemoryCompanyRepository.Export(ctx, filter, fn)
+	if err != nil {
+		return err
+	}
+	return errors.New("connection reset")
+}
+
+func Test_ExportErrorTrailer(t *testing.T) {
+	repo := failingExportRepository{repository.NewMemoryCompanyRepository()}
+	handler := NewCompanyHandler(*services.NewCompanyService(repo, nil, discardProducer{}, services.CompanyConfig{}), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/companies/export?format=ndjson", nil)
+	rr := httptest.NewRecorder()
+	handler.ExportCompanies(rr, req)
+
+	res := rr.Result()
+	if res.StatusCode != http.StatusInternalServerError || res.Header.Get("Trailer") != "" {
+		t.Errorf("expected status code %d without a trailer for an empty export but got %d %v", http.StatusInternalServerError, res.StatusCode, res.Header)
+	}
+
+	err := repo.Create(context.Background(), &domain.Company{Name: "Alpha", Type: domain.Corporations})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ExportCompanies(rr, req)
+
+	res = rr.Result()
+	if res.StatusCode != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"Alpha"`) {
+		t.Errorf("expected Alpha to be sent with status code %d but got %d %q", http.StatusOK, res.StatusCode, rr.Body.String())
+	}
+	if res.Trailer.Get(exportErrorTrailer) == "" {
+		t.Errorf("expected the failure to be reported in the %s trailer but got %v", exportErrorTrailer, res.Trailer)
+	}
+}
@@ -1,10 +1,13 @@
-// go:build integration
+//go:build integration
+
 package handlers
 
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
@@ -16,7 +19,10 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/ory/dockertest"
 	"github.com/ory/dockertest/docker"
+	"github.com/petrostrak/xm-companies/api/openapi"
+	"github.com/petrostrak/xm-companies/db"
 	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
 	"github.com/petrostrak/xm-companies/internal/core/services"
 )
 
@@ -78,11 +84,15 @@ func TestMain(m *testing.M) {
 	}
 
 	testRepo = repository.PostgresRepository{
-		CompanyRepository: &repository.CompanyRepository{DB: testDB},
+		CompanyRepository:             &repository.CompanyRepository{DB: testDB},
+		AddressRepository:             &repository.AddressRepository{DB: testDB},
+		ContactRepository:             &repository.ContactRepository{DB: testDB},
+		AttributeDefinitionRepository: &repository.AttributeDefinitionRepository{DB: testDB},
 	}
 
-	companyService = services.NewCompanyService(testRepo.CompanyRepository)
-	companyHandler = NewCompanyHandler(*companyService)
+	companyService = services.NewCompanyService(testRepo.CompanyRepository, testRepo.AttributeDefinitionRepository, nopProducer{}, services.CompanyConfig{})
+	detailsService := services.NewCompanyDetailsService(testRepo.CompanyRepository, testRepo.AddressRepository, testRepo.ContactRepository, services.CompanyConfig{})
+	companyHandler = NewCompanyHandler(*companyService, detailsService)
 
 	code := m.Run()
 
@@ -93,14 +103,30 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+type nopProducer struct{}
+
+func (nopProducer) ProduceCompany(*domain.Company, string) error { return nil }
+
+// createTables applies the migrations and seeds the test company.
 func createTables() error {
-	tableSQL, err := os.ReadFile("./testdata/init_schema.sql")
+	migrations, err := repository.LoadMigrations(db.Migrations())
+	if err != nil {
+		return err
+	}
+
+	err = repository.NewMigrator(testDB, migrations, log.New(io.Discard, "", 0)).Up(context.Background())
 	if err != nil {
 		fmt.Println(err)
 		return err
 	}
 
-	_, err = testDB.Exec(string(tableSQL))
+	seedSQL, err := os.ReadFile("./testdata/seed.sql")
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	_, err = testDB.Exec(string(seedSQL))
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -132,12 +158,14 @@ func Test_Handlers(t *testing.T) {
 				"name": "Petros Inc.",
 				"number_of_employees": 50,
 				"registered": false,
-				"type": 3
+				"type": "sole_proprietorship"
 			  }`,
 			"",
 			companyHandler.CreateCompany,
 			http.StatusCreated,
 		},
+		{"createCompany-invalid type", "POST", `{"name": "Typeless Inc.", "type": "partnership"}`, "", companyHandler.CreateCompany, http.StatusBadRequest},
+		{"createCompany-out of range type", "POST", `{"name": "Typeless Inc.", "type": 9}`, "", companyHandler.CreateCompany, http.StatusBadRequest},
 		{"getCompany", "GET", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.GetCompany, http.StatusOK},
 		{"getCompany-Invalid", "", "", "121f03cd-ce8c-447d-8747-fb8cb7aa3a52", companyHandler.GetCompany, http.StatusMethodNotAllowed},
 		{
@@ -166,17 +194,62 @@ func Test_Handlers(t *testing.T) {
 			  }`,
 			"0e6c0248-a659-41d0-b860-795df3a53f44",
 			companyHandler.UpdateCompany,
-			http.StatusInternalServerError,
+			http.StatusConflict,
 		},
 		{"deleteCompany", "DELETE", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.DeleteCompany, http.StatusOK},
+		{"getCompany-deleted", "GET", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.GetCompany, http.StatusMethodNotAllowed},
+		{"deleteCompany-deleted", "DELETE", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.DeleteCompany, http.StatusMethodNotAllowed},
+		{"restoreCompany", "POST", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.RestoreCompany, http.StatusOK},
+		{"restoreCompany-not deleted", "POST", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.RestoreCompany, http.StatusMethodNotAllowed},
+		{"getCompany-restored", "GET", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.GetCompany, http.StatusOK},
+		{"purgeCompany-not admin", "DELETE", "", "0e6c0248-a659-41d0-b860-795df3a53f44", companyHandler.DeleteCompany, http.StatusForbidden},
+		{
+			"createCompanies",
+			"POST",
+			`[
+				{"name": "Batch One", "number_of_employees": 1, "registered": true, "type": 0},
+				{"name": "Batch Two", "number_of_employees": 2, "registered": false, "type": 2}
+			]`,
+			"",
+			companyHandler.CreateCompanies,
+			http.StatusCreated,
+		},
+		{
+			"createCompanies-invalid",
+			"POST",
+			`[
+				{"name": "Batch Three", "number_of_employees": 3, "registered": true, "type": 0},
+				{"name": "", "number_of_employees": 4, "registered": true, "type": 0}
+			]`,
+			"",
+			companyHandler.CreateCompanies,
+			http.StatusUnprocessableEntity,
+		},
+		{
+			"createCompanies-duplicate",
+			"POST",
+			`[
+				{"name": "Batch Four", "number_of_employees": 4, "registered": true, "type": 0},
+				{"name": "Batch One", "number_of_employees": 1, "registered": true, "type": 0}
+			]`,
+			"",
+			companyHandler.CreateCompanies,
+			http.StatusConflict,
+		},
+		{"deleteCompanies-empty", "DELETE", `[]`, "", companyHandler.DeleteCompanies, http.StatusBadRequest},
 	}
 
 	for _, tt := range testCases {
+		target := "/"
+		if strings.Contains(tt.name, "purge") {
+			target = "/?purge=true"
+		}
+
 		var req *http.Request
 		if tt.json == "" {
-			req, _ = http.NewRequest(tt.method, "/", nil)
+			req, _ = http.NewRequest(tt.method, target, nil)
 		} else {
-			req, _ = http.NewRequest(tt.method, "/", strings.NewReader(tt.json))
+			req, _ = http.NewRequest(tt.method, target, strings.NewReader(tt.json))
 		}
 
 		if tt.paramID != "" {
@@ -194,3 +267,134 @@ func Test_Handlers(t *testing.T) {
 		}
 	}
 }
+
+// Test_HandlersOpenAPI runs the company handlers behind the validation of the
+// OpenAPI specification, which fails any request whose response it does not
+// document.
+func Test_HandlersOpenAPI(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("invalid specification: %s", err)
+	}
+
+	validate, err := OpenAPIValidation(doc)
+	if err != nil {
+		t.Fatalf("error creating middleware: %s", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(validate)
+	r.Get("/companies", companyHandler.ListCompanies)
+	r.Post("/companies", companyHandler.CreateCompany)
+	r.Get("/companies/{id}", companyHandler.GetCompany)
+	r.Patch("/companies/{id}", companyHandler.UpdateCompany)
+	r.Delete("/companies/{id}", companyHandler.DeleteCompany)
+	r.Post("/companies/{id}/restore", companyHandler.RestoreCompany)
+	r.Get("/companies/{id}/children", companyHandler.GetChildren)
+	r.Get("/companies/{id}/ancestors", companyHandler.GetAncestors)
+	r.Get("/companies/{id}/subtree", companyHandler.GetSubtree)
+	r.Get("/companies/{id}/addresses", companyHandler.ListAddresses)
+	r.Post("/companies/{id}/addresses", companyHandler.CreateAddress)
+	r.Get("/companies/{id}/contacts", companyHandler.ListContacts)
+	r.Post("/companies/{id}/contacts", companyHandler.CreateContact)
+	r.Post("/companies/import", companyHandler.ImportCompanies)
+	r.Get("/companies/export", companyHandler.ExportCompanies)
+	r.Post("/companies:batch", companyHandler.CreateCompanies)
+	r.Delete("/companies:batch", companyHandler.DeleteCompanies)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	do := func(method, path, contentType, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error sending %s %s: %s", method, path, err)
+		}
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(data)
+	}
+
+	status, body := do("POST", "/companies", "application/json", `{"name": "OpenAPI", "number_of_employees": 5, "type": 2}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, status, body)
+	}
+
+	var created struct {
+		Company domain.Company `json:"Company"`
+	}
+	_ = json.Unmarshal([]byte(body), &created)
+	path := "/companies/" + created.Company.ID.String()
+
+	status, body = do("POST", "/companies", "application/json", `{"name": "OpenAPI Kid", "parent_id": "`+created.Company.ID.String()+`"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("expected status %d but got %d: %s", http.StatusCreated, status, body)
+	}
+
+	var kid struct {
+		Company domain.Company `json:"Company"`
+	}
+	_ = json.Unmarshal([]byte(body), &kid)
+	kidPath := "/companies/" + kid.Company.ID.String()
+
+	testCases := []struct {
+		name           string
+		method         string
+		path           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{"getCompany", "GET", path, "", "", http.StatusOK},
+		{"listCompanies", "GET", "/companies?name=OpenAPI&limit=1", "", "", http.StatusOK},
+		{"listCompanies-invalid limit", "GET", "/companies?limit=0", "", "", http.StatusBadRequest},
+		{"updateCompany", "PATCH", path, "application/json", `{"registered": true}`, http.StatusOK},
+		{"updateCompany-invalid", "PATCH", path, "application/json", `{"name": ""}`, http.StatusUnprocessableEntity},
+		{"updateCompany-merge patch", "PATCH", path, "application/merge-patch+json", `{"description": null, "attributes": {"unused": null}}`, http.StatusOK},
+		{"updateCompany-json patch", "PATCH", path, "application/json-patch+json", `[{"op": "test", "path": "/registered", "value": true}, {"op": "replace", "path": "/number_of_employees", "value": 6}]`, http.StatusOK},
+		{"updateCompany-failed test", "PATCH", path, "application/json-patch+json", `[{"op": "test", "path": "/registered", "value": false}]`, http.StatusConflict},
+		{"updateCompany-read only", "PATCH", path, "application/json-patch+json", `[{"op": "replace", "path": "/id", "value": "121f03cd-ce8c-447d-8747-fb8cb7aa3a52"}]`, http.StatusUnprocessableEntity},
+		{"updateCompany-unsupported media type", "PATCH", path, "text/plain", `registered=true`, http.StatusUnsupportedMediaType},
+		{"getChildren", "GET", path + "/children", "", "", http.StatusOK},
+		{"getAncestors", "GET", kidPath + "/ancestors", "", "", http.StatusOK},
+		{"getSubtree", "GET", path + "/subtree", "", "", http.StatusOK},
+		{"createAddress", "POST", path + "/addresses", "application/json", `{"line1": "1 Main Street", "city": "Athens", "country_code": "gr"}`, http.StatusCreated},
+		{"createAddress-invalid country", "POST", path + "/addresses", "application/json", `{"line1": "1 Main Street", "city": "Athens", "country_code": "XX"}`, http.StatusUnprocessableEntity},
+		{"createAddress-unknown company", "POST", "/companies/121f03cd-ce8c-447d-8747-fb8cb7aa3a52/addresses", "application/json", `{"line1": "1 Main Street", "city": "Athens", "country_code": "GR"}`, http.StatusMethodNotAllowed},
+		{"listAddresses", "GET", path + "/addresses", "", "", http.StatusOK},
+		{"createContact", "POST", path + "/contacts", "application/json", `{"name": "Jane Doe", "email": "jane@example.com"}`, http.StatusCreated},
+		{"createContact-invalid phone", "POST", path + "/contacts", "application/json", `{"name": "Jane Doe", "phone": "12"}`, http.StatusUnprocessableEntity},
+		{"listContacts", "GET", path + "/contacts", "", "", http.StatusOK},
+		{"getCompany-include", "GET", path + "?include=addresses,contacts", "", "", http.StatusOK},
+		{"getCompany-invalid include", "GET", path + "?include=owners", "", "", http.StatusBadRequest},
+		{"listCompanies-include", "GET", "/companies?name=OpenAPI&include=addresses", "", "", http.StatusOK},
+		{"getChildren-unknown", "GET", "/companies/121f03cd-ce8c-447d-8747-fb8cb7aa3a52/children", "", "", http.StatusMethodNotAllowed},
+		{"updateCompany-cycle", "PATCH", path, "application/json", `{"parent_id": "` + kid.Company.ID.String() + `"}`, http.StatusUnprocessableEntity},
+		{"updateCompany-unknown parent", "PATCH", path, "application/json", `{"parent_id": "121f03cd-ce8c-447d-8747-fb8cb7aa3a52"}`, http.StatusUnprocessableEntity},
+		{"deleteCompany-subsidiaries", "DELETE", path, "", "", http.StatusConflict},
+		{"deleteCompany-invalid subsidiaries", "DELETE", path + "?subsidiaries=orphan", "", "", http.StatusBadRequest},
+		{"updateCompany-detach", "PATCH", kidPath, "application/json", `{"parent_id": null}`, http.StatusOK},
+		{"deleteCompany", "DELETE", path, "", "", http.StatusOK},
+		{"getCompany-deleted", "GET", path, "", "", http.StatusMethodNotAllowed},
+		{"restoreCompany", "POST", path + "/restore", "", "", http.StatusOK},
+		{"purgeCompany-not admin", "DELETE", path + "?purge=true", "", "", http.StatusForbidden},
+		{"importCompanies", "POST", "/companies/import", "application/x-ndjson", `{"name": "OpenAPI NDJSON"}`, http.StatusCreated},
+		{"importCompanies-partial", "POST", "/companies/import", "text/csv", "name,type\nOpenAPI CSV,1\n,1\n", http.StatusMultiStatus},
+		{"exportCompanies", "GET", "/companies/export?format=ndjson&name=OpenAPI", "", "", http.StatusOK},
+		{"exportCompanies-invalid", "GET", "/companies/export?format=xml", "", "", http.StatusBadRequest},
+		{"createCompanies", "POST", "/companies:batch", "application/json", `[{"name": "OpenAPI Batch"}]`, http.StatusCreated},
+		{"createCompanies-duplicate", "POST", "/companies:batch", "application/json", `[{"name": "OpenAPI Batch"}]`, http.StatusConflict},
+		{"deleteCompanies-best effort", "DELETE", "/companies:batch?mode=best-effort", "application/json", `["121f03cd-ce8c-447d-8747-fb8cb7aa3a52"]`, http.StatusMultiStatus},
+	}
+
+	for _, tt := range testCases {
+		status, body := do(tt.method, tt.path, tt.contentType, tt.body)
+		if status != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedStatus, status, body)
+		}
+	}
+}
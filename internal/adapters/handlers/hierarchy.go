@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// parentInput is the parent_id of an update, which is left unchanged when it
+// is absent and detaches the company from its parent when it is null.
+type parentInput struct {
+	Set bool
+	ID  *uuid.UUID
+}
+
+func (p *parentInput) UnmarshalJSON(data []byte) error {
+	p.Set = true
+	return json.Unmarshal(data, &p.ID)
+}
+
+func (p parentInput) apply(company *domain.Company) {
+	if p.Set {
+		company.ParentID = p.ID
+	}
+}
+
+// GetChildren returns the subsidiaries of the company, ordered by name.
+func (a *CompanyHandler) GetChildren(w http.ResponseWriter, r *http.Request) {
+	a.writeHierarchy(w, r, a.service.Children)
+}
+
+// GetAncestors returns the parent of the company, its parent and so on up to
+// the top of the group.
+func (a *CompanyHandler) GetAncestors(w http.ResponseWriter, r *http.Request) {
+	a.writeHierarchy(w, r, a.service.Ancestors)
+}
+
+// GetSubtree returns the company followed by every subsidiary below it, level
+// by level.
+func (a *CompanyHandler) GetSubtree(w http.ResponseWriter, r *http.Request) {
+	a.writeHierarchy(w, r, a.service.Subtree)
+}
+
+func (a *CompanyHandler) writeHierarchy(w http.ResponseWriter, r *http.Request, read func(context.Context, uuid.UUID) ([]*domain.Company, error)) {
+	id := utils.ReadIDParam(r)
+
+	companies, err := read(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			utils.NotFoundResponse(w, r)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"companies": companies}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
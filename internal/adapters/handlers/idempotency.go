@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	// Bodies are hashed up to the size of the largest import, but only the
+	// first megabyte is kept in memory while the request is processed.
+	maxIdempotentBodyBytes   = maxImportBytes
+	maxIdempotentMemoryBytes = 1048576 // one megabyte
+)
+
+var (
+	ErrIdempotencyKeyTooLong = fmt.Errorf("Idempotency-Key must not be more than %d characters long", maxIdempotencyKeyLength)
+	ErrIdempotentBodyTooBig  = fmt.Errorf("body must not be larger than %d bytes", maxIdempotentBodyBytes)
+)
+
+// replayedHeaders are the response headers stored along with the body of an
+// idempotent request.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Idempotency makes mutating requests that carry an Idempotency-Key header
+// safe to retry. The first response to a key is stored and replayed for every
+// retry of the same request, while reusing the key for a different request is
+// rejected with 422. Keys are scoped to the subject of the request's JWT.
+func Idempotency(service *services.IdempotencyService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				utils.BadRequestResponse(w, r, ErrIdempotencyKeyTooLong)
+				return
+			}
+
+			hash := sha256.New()
+			// The media type is part of the request: the same body means
+			// something else as JSON and as a merge patch.
+			fmt.Fprintf(hash, "%s %s\n%s\n", r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type"))
+
+			body, cleanup, err := spoolBody(r.Body, hash)
+			if err != nil {
+				var pathErr *fs.PathError
+				switch {
+				case errors.As(err, &pathErr):
+					utils.ServerErrorResponse(w, r, err)
+				default:
+					utils.BadRequestResponse(w, r, err)
+				}
+				return
+			}
+			defer cleanup()
+			r.Body = io.NopCloser(body)
+
+			subject := utils.ReadSubject(r)
+
+			stored, err := service.Begin(r.Context(), subject, key, hash.Sum(nil))
+			if err != nil {
+				switch {
+				case errors.Is(err, services.ErrIdempotencyKeyReused):
+					utils.ErrorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+				case errors.Is(err, services.ErrIdempotencyKeyInProgress):
+					utils.ConflictResponse(w, r, err)
+				default:
+					utils.ServerErrorResponse(w, r, err)
+				}
+				return
+			}
+
+			if stored != nil {
+				for name, values := range stored.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				_, err = w.Write(stored.Body)
+				if err != nil {
+					utils.LogError(err)
+				}
+				return
+			}
+
+			var buf bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+
+			release := service.Hold(subject, key, utils.LogError)
+			completed := false
+			defer func() {
+				release()
+
+				// Unless the response was stored, the key is freed so that the
+				// request can be retried. The request's own context may be done
+				// by then, e.g. when the client went away, so it is not used.
+				if !completed {
+					if err := service.Abandon(context.Background(), subject, key); err != nil {
+						utils.LogError(err)
+					}
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// Server errors are not stored so that the request can be retried.
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			header := make(http.Header)
+			for _, name := range replayedHeaders {
+				if value := ww.Header().Get(name); value != "" {
+					header.Set(name, value)
+				}
+			}
+
+			err = service.Complete(context.Background(), subject, key, status, header, buf.Bytes())
+			if err != nil {
+				utils.LogError(err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// spoolBody copies the body into hash and returns a copy of it to be read
+// again, kept in memory up to a megabyte and in a temporary file beyond that,
+// along with a function that removes the file.
+func spoolBody(body io.Reader, hash io.Writer) (io.Reader, func(), error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.TeeReader(io.LimitReader(body, maxIdempotentMemoryBytes+1), hash))
+	if err != nil {
+		return nil, nil, err
+	}
+	if n <= maxIdempotentMemoryBytes {
+		return &buf, func() {}, nil
+	}
+
+	file, err := os.CreateTemp("", "xm-companies-idempotent-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	_, err = buf.WriteTo(file)
+	if err == nil {
+		var m int64
+		m, err = io.Copy(file, io.TeeReader(io.LimitReader(body, maxIdempotentBodyBytes+1-n), hash))
+		n += m
+	}
+	if err == nil && n > maxIdempotentBodyBytes {
+		err = ErrIdempotentBodyTooBig
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return file, cleanup, nil
+}
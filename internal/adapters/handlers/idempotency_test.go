@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+type fakeIdempotencyRepository struct {
+	mu       sync.Mutex
+	requests map[string]*domain.IdempotentRequest
+}
+
+func (f *fakeIdempotencyRepository) Reserve(ctx context.Context, req *domain.IdempotentRequest) (*domain.IdempotentRequest, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := req.Subject + "/" + req.Key
+	if stored, ok := f.requests[id]; ok && stored.ExpiresAt.After(time.Now()) {
+		takeOver := stored.Status == 0 && stored.LockedUntil.Before(time.Now()) && bytes.Equal(stored.RequestHash, req.RequestHash)
+		if !takeOver {
+			return stored, nil
+		}
+	}
+	f.requests[id] = req
+	return nil, nil
+}
+
+func (f *fakeIdempotencyRepository) Extend(ctx context.Context, subject, key string, lockedUntil time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if stored, ok := f.requests[subject+"/"+key]; ok && stored.Status == 0 {
+		stored.LockedUntil = lockedUntil
+	}
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Complete(ctx context.Context, req *domain.IdempotentRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.requests[req.Subject+"/"+req.Key]
+	stored.Status, stored.Header, stored.Body = req.Status, req.Header, req.Body
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Release(ctx context.Context, subject, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.requests, subject+"/"+key)
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func Test_Idempotency(t *testing.T) {
+	repo := &fakeIdempotencyRepository{requests: make(map[string]*domain.IdempotentRequest)}
+	service := services.NewIdempotencyService(repo, services.IdempotencyConfig{TTL: time.Hour})
+
+	calls := 0
+	status := http.StatusCreated
+	handler := Idempotency(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Location", fmt.Sprintf("/companies/%d", calls))
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"call": %d}`, calls)
+	}))
+
+	testCases := []struct {
+		name           string
+		key            string
+		body           string
+		expectedStatus int
+		expectedBody   string
+		expectedCalls  int
+	}{
+		{"no key", "", `{"name": "Alpha"}`, http.StatusCreated, `{"call": 1}`, 1},
+		{"first", "abc", `{"name": "Alpha"}`, http.StatusCreated, `{"call": 2}`, 2},
+		{"retry", "abc", `{"name": "Alpha"}`, http.StatusCreated, `{"call": 2}`, 2},
+		{"different body", "abc", `{"name": "Beta"}`, http.StatusUnprocessableEntity, "", 2},
+		{"other key", "def", `{"name": "Alpha"}`, http.StatusCreated, `{"call": 3}`, 3},
+		{"too long", strings.Repeat("k", 256), `{}`, http.StatusBadRequest, "", 3},
+	}
+
+	for _, tt := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/companies/", strings.NewReader(tt.body))
+		if tt.key != "" {
+			req.Header.Set("Idempotency-Key", tt.key)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedStatus, rr.Code)
+		}
+
+		if tt.expectedBody != "" && rr.Body.String() != tt.expectedBody {
+			t.Errorf("%s: expected body %s but got %s", tt.name, tt.expectedBody, rr.Body.String())
+		}
+
+		if calls != tt.expectedCalls {
+			t.Errorf("%s: expected the handler to have been called %d times but got %d", tt.name, tt.expectedCalls, calls)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/companies/", strings.NewReader(`{"name": "Alpha"}`))
+	req.Header.Set("Idempotency-Key", "abc")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Header().Get("Location") != "/companies/2" || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected the stored headers to be replayed but got %v", rr.Header())
+	}
+
+	// Server errors are not stored, so a retry runs the handler again.
+	status = http.StatusInternalServerError
+	for i := 0; i < 2; i++ {
+		req = httptest.NewRequest(http.MethodPost, "/companies/", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "ghi")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if calls != 5 {
+		t.Errorf("expected failed requests to be retried but the handler was called %d times", calls)
+	}
+}
+
+func Test_IdempotencyLease(t *testing.T) {
+	repo := &fakeIdempotencyRepository{requests: make(map[string]*domain.IdempotentRequest)}
+	service := services.NewIdempotencyService(repo, services.IdempotencyConfig{TTL: time.Hour, Lease: time.Minute})
+
+	var reserved domain.IdempotentRequest
+	crash := true
+	calls := 0
+	handler := Idempotency(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if crash {
+			reserved = *repo.requests["/abc"]
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	post := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/companies/", strings.NewReader(`{"name": "Alpha"}`))
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// The key is left reserved, as it is when the process dies while
+	// processing the request.
+	post()
+	repo.requests["/abc"] = &reserved
+	crash = false
+
+	if code := post(); code != http.StatusConflict || calls != 1 {
+		t.Errorf("expected a retry within the lease to get %d without being processed but got %d after %d calls", http.StatusConflict, code, calls)
+	}
+
+	reserved.LockedUntil = time.Now().Add(-time.Second)
+	if code := post(); code != http.StatusCreated || calls != 2 {
+		t.Errorf("expected a retry after the lease to take the key over but got %d after %d calls", code, calls)
+	}
+
+	if code := post(); code != http.StatusCreated || calls != 2 {
+		t.Errorf("expected the response of the retry to be replayed but got %d after %d calls", code, calls)
+	}
+}
+
+func Test_IdempotencyLargeBody(t *testing.T) {
+	repo := &fakeIdempotencyRepository{requests: make(map[string]*domain.IdempotentRequest)}
+	service := services.NewIdempotencyService(repo, services.IdempotencyConfig{TTL: time.Hour})
+
+	calls := 0
+	handler := Idempotency(service)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%d", len(body))
+	}))
+
+	// Bodies beyond a megabyte are spooled to disk rather than rejected.
+	body := strings.Repeat("a", 3*maxIdempotentMemoryBytes)
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedCalls  int
+	}{
+		{"first", body, http.StatusOK, 1},
+		{"retry", body, http.StatusOK, 1},
+		{"different body", body + "b", http.StatusUnprocessableEntity, 1},
+	}
+
+	for _, tt := range testCases {
+		req := httptest.NewRequest(http.MethodPost, "/companies/import", strings.NewReader(tt.body))
+		req.Header.Set("Idempotency-Key", "abc")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedStatus, rr.Code)
+		}
+
+		if rr.Code == http.StatusOK && rr.Body.String() != fmt.Sprint(len(body)) {
+			t.Errorf("%s: expected the handler to read the whole body but it read %s bytes", tt.name, rr.Body.String())
+		}
+
+		if calls != tt.expectedCalls {
+			t.Errorf("%s: expected the handler to have been called %d times but got %d", tt.name, tt.expectedCalls, calls)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const (
+	maxImportBytes         = 100 << 20 // 100 megabytes
+	importReadTimeout      = 10 * time.Minute
+	importProgressInterval = 100
+	maxNDJSONLineBytes     = 1 << 20 // 1 megabyte
+)
+
+var (
+	ErrMissingNameColumn    = errors.New(`csv header must contain a "name" column`)
+	ErrUnsupportedMediaType = errors.New(`media type must be either "text/csv" or "application/x-ndjson"`)
+	// ErrLineTooLong aborts an NDJSON import, since the lines after a line
+	// that is too long cannot be told apart.
+	ErrLineTooLong = fmt.Errorf("ndjson lines must not be larger than %d bytes", maxNDJSONLineBytes)
+)
+
+// importColumns are the columns an import maps onto a company. The read-only
+// columns of an export are accepted as well so that exports can be imported
+// back, but they are ignored. Parents are ignored too, since they refer to
+// the ids of the exported companies rather than those of the imported ones,
+// and so are attributes and tags, which are set on companies one at a time.
+var (
+	importColumns  = []string{"name", "description", "number_of_employees", "registered", "type"}
+	ignoredColumns = []string{"id", "parent_id", "attributes", "tags", "created_at", "updated_at", "created_by", "updated_by"}
+)
+
+type importRowError struct {
+	Row   int `json:"row"`
+	Error any `json:"error"`
+}
+
+func (a *CompanyHandler) ImportCompanies(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		utils.UnsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	extendReadDeadline(w)
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	src, err := newImportSource(mediaType, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnsupportedMediaType):
+			utils.UnsupportedMediaTypeResponse(w, r)
+		default:
+			utils.BadRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	result, err := a.service.Import(r.Context(), src, utils.ReadSubject(r))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			utils.BadRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesErr.Limit))
+		case errors.As(err, &parseErr), errors.Is(err, ErrLineTooLong):
+			utils.BadRequestResponse(w, r, err)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	status, env := importSummary(result)
+
+	err = utils.WriteJSON(w, status, env, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// RunImportJob is the job runner for asynchronous imports. The job's input is
+// the uploaded body and its result the same summary a synchronous import
+// responds with.
+func (a *CompanyHandler) RunImportJob(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error) {
+	src, err := newImportSource(job.Params["content_type"], input)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := a.service.Import(ctx, &progressSource{ImportSource: src, progress: progress}, job.CreatedBy)
+	if err != nil {
+		return "", err
+	}
+
+	_, env := importSummary(result)
+
+	err = json.NewEncoder(output).Encode(env)
+	if err != nil {
+		return "", err
+	}
+
+	return "application/json", nil
+}
+
+func newImportSource(mediaType string, r io.Reader) (services.ImportSource, error) {
+	switch mediaType {
+	case "text/csv":
+		return newCSVSource(r)
+	case "application/x-ndjson", "application/ndjson":
+		return newNDJSONSource(r), nil
+	default:
+		return nil, ErrUnsupportedMediaType
+	}
+}
+
+// importSummary also logs the failure to produce the imported companies,
+// which does not fail the import since they are committed.
+func importSummary(result *services.ImportResult) (int, utils.Envelope) {
+	if result.PublishErr != nil {
+		utils.LogError(fmt.Errorf("producing imported companies: %w", result.PublishErr))
+	}
+
+	rowErrors := make([]importRowError, len(result.Errors))
+	for i, rowErr := range result.Errors {
+		rowErrors[i] = importRowError{Row: rowErr.Row, Error: rowErr.Err.Error()}
+
+		var validationErrs domain.ValidationErrors
+		if errors.As(rowErr.Err, &validationErrs) {
+			rowErrors[i].Error = validationErrs
+		}
+	}
+
+	status := http.StatusCreated
+	switch {
+	case len(rowErrors) > 0 && len(result.Created) > 0:
+		status = http.StatusMultiStatus
+	case len(rowErrors) > 0:
+		status = http.StatusUnprocessableEntity
+	}
+
+	return status, utils.Envelope{
+		"imported": len(result.Created),
+		"failed":   len(rowErrors),
+		"errors":   rowErrors,
+	}
+}
+
+// progressSource reports the progress of an import every
+// importProgressInterval rows.
+type progressSource struct {
+	services.ImportSource
+	progress func(int64) error
+	rows     int64
+}
+
+func (s *progressSource) Next() (*domain.Company, error) {
+	company, err := s.ImportSource.Next()
+	if errors.Is(err, io.EOF) {
+		if perr := s.progress(s.rows); perr != nil {
+			return nil, perr
+		}
+		return nil, err
+	}
+
+	s.rows++
+	if s.rows%importProgressInterval == 0 {
+		if perr := s.progress(s.rows); perr != nil {
+			return nil, perr
+		}
+	}
+
+	return company, err
+}
+
+// csvSource reads companies from a CSV document whose first row names the
+// columns. Rows are numbered as in a spreadsheet, the header being row 1.
+type csvSource struct {
+	reader  *csv.Reader
+	columns []string
+	row     int
+}
+
+func newCSVSource(r io.Reader) (*csvSource, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, utils.ErrEmptyBody
+		}
+		return nil, err
+	}
+
+	columns := make([]string, len(header))
+	hasName := false
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !contains(importColumns, column) && !contains(ignoredColumns, column) {
+			return nil, fmt.Errorf("csv header contains unknown column %q", column)
+		}
+		if column == "name" {
+			hasName = true
+		}
+		columns[i] = column
+	}
+
+	if !hasName {
+		return nil, ErrMissingNameColumn
+	}
+
+	return &csvSource{reader: reader, columns: columns, row: 1}, nil
+}
+
+func (s *csvSource) Next() (*domain.Company, error) {
+	record, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+	s.row++
+	if err != nil {
+		if errors.Is(err, csv.ErrFieldCount) {
+			return nil, &services.RowError{Row: s.row, Err: err}
+		}
+		return nil, err
+	}
+
+	var company domain.Company
+	errs := domain.ValidationErrors{}
+
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+
+		switch s.columns[i] {
+		case "name":
+			company.Name = value
+		case "description":
+			company.Description = value
+		case "number_of_employees":
+			company.NumberOfEmployees, err = strconv.Atoi(value)
+			if err != nil {
+				errs["number_of_employees"] = "must be an integer"
+			}
+		case "registered":
+			company.Registered, err = strconv.ParseBool(value)
+			if err != nil {
+				errs["registered"] = "must be a boolean"
+			}
+		case "type":
+			company.Type, err = domain.ParseCompanyType(value)
+			if err != nil {
+				errs["type"] = "must be a valid company type"
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, &services.RowError{Row: s.row, Err: errs}
+	}
+
+	return &company, nil
+}
+
+func (s *csvSource) Row() int {
+	return s.row
+}
+
+// ndjsonSource reads companies from newline delimited JSON, one company per
+// line. Blank lines are skipped.
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+func newNDJSONSource(r io.Reader) *ndjsonSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineBytes)
+
+	return &ndjsonSource{scanner: scanner}
+}
+
+func (s *ndjsonSource) Next() (*domain.Company, error) {
+	for s.scanner.Scan() {
+		s.row++
+
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var input struct {
+			Name              string             `json:"name"`
+			Description       string             `json:"description"`
+			NumberOfEmployees int                `json:"number_of_employees"`
+			Registered        bool               `json:"registered"`
+			Type              domain.CompanyType `json:"type"`
+		}
+
+		err := json.Unmarshal(line, &input)
+		if errors.Is(err, domain.ErrInvalidCompanyType) {
+			return nil, &services.RowError{Row: s.row, Err: domain.ValidationErrors{"type": "must be a valid company type"}}
+		}
+		if err != nil {
+			return nil, &services.RowError{Row: s.row, Err: utils.ErrBadJSON}
+		}
+
+		return &domain.Company{
+			Name:              input.Name,
+			Description:       input.Description,
+			NumberOfEmployees: input.NumberOfEmployees,
+			Registered:        input.Registered,
+			Type:              input.Type,
+		}, nil
+	}
+
+	err := s.scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return nil, fmt.Errorf("line %d: %w", s.row+1, ErrLineTooLong)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *ndjsonSource) Row() int {
+	return s.row
+}
+
+// extendReadDeadline gives large uploads more time than the server's
+// ReadTimeout, which is sized for regular requests.
+func extendReadDeadline(w http.ResponseWriter) {
+	err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(importReadTimeout))
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		utils.LogError(err)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+func Test_CSVSource(t *testing.T) {
+	doc := `Name,number_of_employees,registered,type,id
+Alpha,10,true,1,ignored
+Beta,ten,true,1,ignored
+Gamma,3
+Delta,0,false,cooperative,ignored
+Epsilon,1,true,partnership,ignored
+`
+
+	src, err := newCSVSource(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("could not read csv header: %s", err)
+	}
+
+	var companies []*domain.Company
+	var rowErrs []*services.RowError
+
+	for {
+		company, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErr *services.RowError
+		switch {
+		case errors.As(err, &rowErr):
+			rowErrs = append(rowErrs, rowErr)
+		case err != nil:
+			t.Fatalf("unexpected error: %s", err)
+		default:
+			companies = append(companies, company)
+		}
+	}
+
+	if len(companies) != 2 || companies[0].Name != "Alpha" || companies[1].Name != "Delta" {
+		t.Errorf("expected Alpha and Delta to be read but got %v", companies)
+	}
+
+	if companies[0].NumberOfEmployees != 10 || !companies[0].Registered || companies[0].Type != domain.NonProfit {
+		t.Errorf("columns were not mapped onto the company: %+v", companies[0])
+	}
+
+	if companies[1].Type != domain.Cooperative {
+		t.Errorf("expected the type slug to be parsed but got %v", companies[1].Type)
+	}
+
+	if len(rowErrs) != 3 || rowErrs[0].Row != 3 || rowErrs[1].Row != 4 || rowErrs[2].Row != 6 {
+		t.Errorf("expected errors for rows 3, 4 and 6 but got %v", rowErrs)
+	}
+}
+
+func Test_CSVSourceHeader(t *testing.T) {
+	testCases := []struct {
+		name string
+		doc  string
+	}{
+		{"empty", ""},
+		{"unknown column", "name,revenue\n"},
+		{"missing name", "description,type\n"},
+	}
+
+	for _, tt := range testCases {
+		_, err := newCSVSource(strings.NewReader(tt.doc))
+		if err == nil {
+			t.Errorf("%s: expected an error but got none", tt.name)
+		}
+	}
+}
+
+func Test_NDJSONSource(t *testing.T) {
+	doc := `{"name": "Alpha", "number_of_employees": 10, "registered": true, "type": 1}
+
+{"name": "Beta",
+{"name": "Gamma", "type": "cooperative"}
+{"name": "Delta", "type": "partnership"}
+`
+
+	src := newNDJSONSource(strings.NewReader(doc))
+
+	company, err := src.Next()
+	if err != nil || company.Name != "Alpha" || company.NumberOfEmployees != 10 {
+		t.Errorf("expected Alpha to be read but got %+v, %v", company, err)
+	}
+
+	var rowErr *services.RowError
+	_, err = src.Next()
+	if !errors.As(err, &rowErr) || rowErr.Row != 3 {
+		t.Errorf("expected an error for row 3 but got %v", err)
+	}
+
+	company, err = src.Next()
+	if err != nil || company.Name != "Gamma" || company.Type != domain.Cooperative || src.Row() != 4 {
+		t.Errorf("expected Gamma to be read from row 4 but got %+v from row %d, %v", company, src.Row(), err)
+	}
+
+	var validationErrs domain.ValidationErrors
+	_, err = src.Next()
+	if !errors.As(err, &rowErr) || rowErr.Row != 5 || !errors.As(rowErr.Err, &validationErrs) || validationErrs["type"] == "" {
+		t.Errorf("expected a type error for row 5 but got %v", err)
+	}
+
+	_, err = src.Next()
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF but got %v", err)
+	}
+}
+
+func Test_NDJSONSourceLineTooLong(t *testing.T) {
+	doc := `{"name": "Alpha"}
+{"name": "` + strings.Repeat("a", maxNDJSONLineBytes) + `"}
+{"name": "Beta"}
+`
+
+	src := newNDJSONSource(strings.NewReader(doc))
+
+	company, err := src.Next()
+	if err != nil || company.Name != "Alpha" {
+		t.Errorf("expected Alpha to be read but got %+v, %v", company, err)
+	}
+
+	_, err = src.Next()
+	if !errors.Is(err, ErrLineTooLong) || !strings.HasPrefix(err.Error(), "line 2: ") {
+		t.Errorf("expected line 2 to be too long but got %v", err)
+	}
+}
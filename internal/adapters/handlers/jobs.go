@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// The types of the jobs whose runners are provided by CompanyHandler.
+const (
+	ImportJob = "import"
+	ExportJob = "export"
+)
+
+var (
+	ErrJobFinished = errors.New("the job has already finished")
+)
+
+type JobHandler struct {
+	service *services.JobService
+}
+
+func NewJobHandler(jobService *services.JobService) *JobHandler {
+	return &JobHandler{jobService}
+}
+
+// CreateImportJob queues the import of the uploaded CSV or NDJSON body.
+func (a *JobHandler) CreateImportJob(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !contains([]string{"text/csv", "application/x-ndjson", "application/ndjson"}, mediaType) {
+		utils.UnsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	extendReadDeadline(w)
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	params := map[string]string{"content_type": mediaType}
+	a.enqueue(w, r, ImportJob, params, body)
+}
+
+// CreateExportJob queues an export with the format and filters of the query
+// string.
+func (a *JobHandler) CreateExportJob(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	_, err := parseCompanyFilter(qs)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	switch qs.Get("format") {
+	case "", "csv", "ndjson":
+	default:
+		utils.BadRequestResponse(w, r, ErrInvalidFormat)
+		return
+	}
+
+	params := make(map[string]string)
+	for key, values := range qs {
+		if !contains([]string{"format", "name", "type", "registered", "tag", "tag_any"}, key) && !strings.HasPrefix(key, "attributes[") {
+			continue
+		}
+		// Repeated tags are kept as the comma separated list their filters
+		// accept as well.
+		value := values[0]
+		if key == "tag" || key == "tag_any" {
+			value = strings.Join(values, ",")
+		}
+		if value != "" {
+			params[key] = value
+		}
+	}
+
+	a.enqueue(w, r, ExportJob, params, nil)
+}
+
+func (a *JobHandler) enqueue(w http.ResponseWriter, r *http.Request, jobType string, params map[string]string, input io.Reader) {
+	// A job without a subject could never be read back by its creator.
+	subject := utils.ReadSubject(r)
+	if subject == "" {
+		utils.ForbiddenResponse(w, r)
+		return
+	}
+
+	job, err := a.service.Enqueue(r.Context(), jobType, params, input, subject)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			utils.BadRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesErr.Limit))
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/jobs/%s", job.ID))
+
+	err = utils.WriteJSON(w, http.StatusAccepted, jobEnvelope(job), headers)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.readJob(w, r)
+	if !ok {
+		return
+	}
+
+	err := utils.WriteJSON(w, http.StatusOK, jobEnvelope(job), nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *JobHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.readJob(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := a.service.Cancel(r.Context(), job.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			utils.NotFoundResponse(w, r)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if job.Status != domain.JobCancelled {
+		utils.ConflictResponse(w, r, ErrJobFinished)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, jobEnvelope(job), nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// GetJobResult streams the result of a job that succeeded.
+func (a *JobHandler) GetJobResult(w http.ResponseWriter, r *http.Request) {
+	job, ok := a.readJob(w, r)
+	if !ok {
+		return
+	}
+
+	job, result, err := a.service.Result(r.Context(), job.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			utils.NotFoundResponse(w, r)
+		case errors.Is(err, services.ErrJobNotFinished):
+			utils.ConflictResponse(w, r, err)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer result.Close()
+
+	w.Header().Set("Content-Type", job.ResultType)
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, result)
+	if err != nil {
+		utils.LogError(err)
+	}
+}
+
+// readJob returns the job of the request. Jobs of other subjects are only
+// visible to admins and are reported as not found to everyone else.
+func (a *JobHandler) readJob(w http.ResponseWriter, r *http.Request) (*domain.Job, bool) {
+	job, err := a.service.Get(r.Context(), utils.ReadIDParam(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			utils.NotFoundResponse(w, r)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !utils.IsOwner(r, job.CreatedBy) && !utils.IsAdmin(r) {
+		utils.NotFoundResponse(w, r)
+		return nil, false
+	}
+
+	return job, true
+}
+
+func jobEnvelope(job *domain.Job) utils.Envelope {
+	env := utils.Envelope{"job": job}
+	if job.Status == domain.JobSucceeded {
+		env["result_url"] = fmt.Sprintf("/jobs/%s/result", job.ID)
+	}
+	return env
+}
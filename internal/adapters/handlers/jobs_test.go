@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+func Test_JobOwnership(t *testing.T) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, alice, _ := auth.Encode(map[string]interface{}{"sub": "alice"})
+	_, bob, _ := auth.Encode(map[string]interface{}{"sub": "bob"})
+	_, admin, _ := auth.Encode(map[string]interface{}{"sub": "carol", "role": "admin"})
+	_, anonymous, _ := auth.Encode(map[string]interface{}{})
+
+	service := services.NewJobService(repository.NewMemoryJobRepository(), services.JobConfig{Dir: t.TempDir()}, log.New(io.Discard, "", 0))
+	service.Register(ExportJob, func(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error) {
+		return "text/csv", nil
+	})
+
+	job, err := service.Enqueue(context.Background(), ExportJob, nil, nil, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan, err := service.Enqueue(context.Background(), ExportJob, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewJobHandler(service)
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(auth))
+	r.Use(jwtauth.Authenticator)
+	r.Post("/exports", handler.CreateExportJob)
+	r.Get("/jobs/{id}", handler.GetJob)
+	r.Get("/jobs/{id}/result", handler.GetJobResult)
+	r.Post("/jobs/{id}/cancel", handler.CancelJob)
+
+	testCases := []struct {
+		name           string
+		method         string
+		url            string
+		token          string
+		expectedStatus int
+	}{
+		{"job of another subject", "GET", "/jobs/" + job.ID.String(), bob, http.StatusMethodNotAllowed},
+		{"result of another subject", "GET", "/jobs/" + job.ID.String() + "/result", bob, http.StatusMethodNotAllowed},
+		{"cancel by another subject", "POST", "/jobs/" + job.ID.String() + "/cancel", bob, http.StatusMethodNotAllowed},
+		{"job of the subject", "GET", "/jobs/" + job.ID.String(), alice, http.StatusOK},
+		{"job read by an admin", "GET", "/jobs/" + job.ID.String(), admin, http.StatusOK},
+		{"job without a subject", "GET", "/jobs/" + orphan.ID.String(), anonymous, http.StatusMethodNotAllowed},
+		{"export without a subject", "POST", "/exports", anonymous, http.StatusForbidden},
+		{"cancel by the subject", "POST", "/jobs/" + job.ID.String() + "/cancel", alice, http.StatusOK},
+	}
+
+	for _, tt := range testCases {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedStatus, rr.Code)
+		}
+	}
+
+	job, err = service.Get(context.Background(), job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != domain.JobCancelled {
+		t.Errorf("expected the job to be cancelled by its subject only but got %s", job.Status)
+	}
+}
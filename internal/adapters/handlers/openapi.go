@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+func init() {
+	// Anything the handlers can parse is a valid uuid.
+	openapi3.DefineStringFormatCallback("uuid", func(value string) error {
+		_, err := uuid.Parse(value)
+		return err
+	})
+
+	// The imports and exports are validated as opaque strings: the handlers
+	// report the errors of each row, which the default CSV decoder would turn
+	// into a failure of the whole body.
+	openapi3filter.RegisterBodyDecoder("text/csv", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/ndjson", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.FileBodyDecoder)
+
+	// Merge patches are JSON, which the library only knows for JSON Patch.
+	openapi3filter.RegisterBodyDecoder("application/merge-patch+json", openapi3filter.RegisteredBodyDecoder("application/json"))
+}
+
+// OpenAPIValidation returns a middleware that checks the requests and the
+// responses of the routes of the specification against it. Invalid requests
+// are rejected with 400 and responses that do not match are replaced with a
+// 500 naming the mismatch, so that the specification and the handlers cannot
+// drift apart unnoticed in tests. It buffers every response but the streams,
+// so it is meant for tests and development rather than production.
+func OpenAPIValidation(doc *openapi3.T) (func(http.Handler) http.Handler, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &openapi3filter.Options{
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+		IncludeResponseStatus: true,
+		SkipSettingDefaults:   true,
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route, params, err := router.FindRoute(r)
+			if err != nil {
+				// Routes missing from the specification are left to the
+				// router, which knows best how to respond.
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: params,
+				Route:      route,
+				Options:    options,
+			}
+
+			if !unsupportedMediaType(route.Operation, r) {
+				err = openapi3filter.ValidateRequest(r.Context(), input)
+				if err != nil {
+					utils.BadRequestResponse(w, r, err)
+					return
+				}
+			}
+
+			if streams(route.Operation) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			res := &bufferedResponse{header: make(http.Header)}
+			next.ServeHTTP(res, r)
+			if res.status == 0 {
+				res.status = http.StatusOK
+			}
+
+			err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: input,
+				Status:                 res.status,
+				Header:                 res.header,
+				Body:                   io.NopCloser(bytes.NewReader(res.body.Bytes())),
+				Options:                options,
+			})
+			if err != nil {
+				err = fmt.Errorf("%s %s responded with %d, which does not match the specification: %w", r.Method, r.URL.Path, res.status, err)
+				utils.LogError(err)
+				utils.ErrorResponse(w, r, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			for name, values := range res.header {
+				w.Header()[name] = values
+			}
+			w.WriteHeader(res.status)
+			if res.body.Len() == 0 {
+				return
+			}
+
+			_, err = w.Write(res.body.Bytes())
+			if err != nil {
+				utils.LogError(err)
+			}
+		})
+	}, nil
+}
+
+// unsupportedMediaType reports whether the request has a body of a media type
+// that the operation does not take but responds to with 415. Such requests are
+// left to the handler, so that its 415 is the response clients get.
+func unsupportedMediaType(op *openapi3.Operation, r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" || op.RequestBody == nil || op.RequestBody.Value == nil || op.Responses.Get(http.StatusUnsupportedMediaType) == nil {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err != nil || op.RequestBody.Value.Content.Get(mediaType) == nil
+}
+
+// streams reports whether the operation responds with a stream of events or a
+// WebSocket, whose responses cannot be buffered.
+func streams(op *openapi3.Operation) bool {
+	if op.Responses.Get(http.StatusSwitchingProtocols) != nil {
+		return true
+	}
+	for _, res := range op.Responses {
+		if res.Value != nil && res.Value.Content.Get("text/event-stream") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse holds a response until it has been validated.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
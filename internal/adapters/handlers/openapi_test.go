@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/petrostrak/xm-companies/api/openapi"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const companyEnvelope = `{"Company": {
+	"id": "0e6c0248-a659-41d0-b860-795df3a53f44",
+	"name": "XM",
+	"description": "",
+	"number_of_employees": 10,
+	"registered": true,
+	"type": %s,
+	"created_at": "2023-01-02T15:04:05Z",
+	"updated_at": "2023-01-02T15:04:05Z",
+	"created_by": "tester",
+	"updated_by": ""
+}}`
+
+func Test_OpenAPIValidation(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("invalid specification: %s", err)
+	}
+
+	validate, err := OpenAPIValidation(doc)
+	if err != nil {
+		t.Fatalf("error creating middleware: %s", err)
+	}
+
+	docs, err := NewDocsHandler(doc)
+	if err != nil {
+		t.Fatalf("error creating docs handler: %s", err)
+	}
+
+	var status int
+	var body string
+	var called bool
+	respond := func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+
+	r := chi.NewRouter()
+	r.Use(validate)
+	r.Get("/companies/{id}", respond)
+	r.Post("/companies", respond)
+	r.Patch("/companies/{id}", respond)
+	r.Get("/openapi.json", docs.OpenAPI)
+	r.Get("/docs", docs.SwaggerUI)
+
+	testCases := []struct {
+		name           string
+		method         string
+		target         string
+		contentType    string
+		request        string
+		status         int
+		body           string
+		expectedStatus int
+		expectedCalled bool
+	}{
+		{"valid", "GET", "/companies/0e6c0248-a659-41d0-b860-795df3a53f44", "", "", 200, strings.Replace(companyEnvelope, "%s", `"non_profit"`, 1), 200, true},
+		{"response not matching", "GET", "/companies/0e6c0248-a659-41d0-b860-795df3a53f44", "", "", 200, strings.Replace(companyEnvelope, "%s", "1", 1), 500, true},
+		{"undocumented status", "GET", "/companies/0e6c0248-a659-41d0-b860-795df3a53f44", "", "", 418, `{"error": "teapot"}`, 500, true},
+		{"invalid path parameter", "GET", "/companies/42", "", "", 200, "", 400, false},
+		{"invalid body", "POST", "/companies", "application/json", `{"name": 1}`, 201, "", 400, false},
+		{"missing body", "POST", "/companies", "application/json", "", 201, "", 400, false},
+		{"merge patch", "PATCH", "/companies/0e6c0248-a659-41d0-b860-795df3a53f44", "application/merge-patch+json", `{"description": null}`, 200, strings.Replace(companyEnvelope, "%s", `"non_profit"`, 1), 200, true},
+		{"invalid json patch", "PATCH", "/companies/0e6c0248-a659-41d0-b860-795df3a53f44", "application/json-patch+json", `[{"op": "erase", "path": "/name"}]`, 200, "", 400, false},
+		{"unsupported media type", "PATCH", "/companies/0e6c0248-a659-41d0-b860-795df3a53f44", "text/plain", "name=XM", 415, `{"error": "the request body has an unsupported media type"}`, 415, true},
+		{"undocumented route", "GET", "/unknown", "", "", 200, "", 404, false},
+		{"specification", "GET", "/openapi.json", "", "", 0, "", 200, false},
+		{"swagger ui", "GET", "/docs", "", "", 0, "", 200, false},
+	}
+
+	for _, tt := range testCases {
+		status, body, called = tt.status, tt.body, false
+
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.request))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d: %s", tt.name, tt.expectedStatus, rr.Code, rr.Body)
+		}
+		if called != tt.expectedCalled {
+			t.Errorf("%s: expected the handler to be called to be %t but got %t", tt.name, tt.expectedCalled, called)
+		}
+	}
+}
+
+func Test_OpenAPIValidationError(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("invalid specification: %s", err)
+	}
+
+	validate, err := OpenAPIValidation(doc)
+	if err != nil {
+		t.Fatalf("error creating middleware: %s", err)
+	}
+
+	// Validation errors are reported with the same envelope as every other
+	// error, so that they match the specification themselves.
+	handler := validate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.FailedValidationResponse(w, r, map[string]string{"name": "must be provided"})
+	}))
+
+	req := httptest.NewRequest("POST", "/companies", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "must be provided") {
+		t.Errorf("expected the validation errors of the handler but got %d: %s", rr.Code, rr.Body)
+	}
+}
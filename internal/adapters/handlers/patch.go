@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+var ErrInvalidMergePatch = errors.New("merge patch must be an object")
+
+// companyPatch changes a company the way the body of an update asks.
+type companyPatch func(*domain.Company) error
+
+// patchMediaTypes are the media types of the bodies of an update, with the
+// functions that read them.
+var patchMediaTypes = map[string]func(http.ResponseWriter, *http.Request) (companyPatch, error){
+	"application/json":             readCompanyUpdate,
+	"application/merge-patch+json": readMergePatch,
+	"application/json-patch+json":  readJSONPatch,
+}
+
+// acceptPatch is the Accept-Patch header (RFC 5789) of the updates rejected
+// for their media type.
+const acceptPatch = "application/json, application/merge-patch+json, application/json-patch+json"
+
+// patchReader returns the function that reads the body of the update, by its
+// media type. Bodies without a Content-Type are read as JSON.
+func patchReader(r *http.Request) (func(http.ResponseWriter, *http.Request) (companyPatch, error), bool) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return readCompanyUpdate, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	read, ok := patchMediaTypes[mediaType]
+	return read, ok
+}
+
+// readCompanyUpdate reads the fields to change of a JSON body. The fields that
+// are absent are left unchanged, as are those that are null but parent_id and
+// attributes.
+func readCompanyUpdate(w http.ResponseWriter, r *http.Request) (companyPatch, error) {
+	var input struct {
+		Name              *string             `json:"name"`
+		Description       *string             `json:"description"`
+		NumberOfEmployees *int                `json:"number_of_employees"`
+		Registered        *bool               `json:"registered"`
+		Type              *domain.CompanyType `json:"type"`
+		ParentID          parentInput         `json:"parent_id"`
+		Attributes        attributesInput     `json:"attributes"`
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(company *domain.Company) error {
+		if input.Name != nil {
+			company.Name = *input.Name
+		}
+		if input.Description != nil {
+			company.Description = *input.Description
+		}
+		if input.NumberOfEmployees != nil {
+			company.NumberOfEmployees = *input.NumberOfEmployees
+		}
+		if input.Registered != nil {
+			company.Registered = *input.Registered
+		}
+		if input.Type != nil {
+			company.Type = *input.Type
+		}
+		input.ParentID.apply(company)
+		input.Attributes.apply(company)
+		return nil
+	}, nil
+}
+
+// readMergePatch reads a JSON merge patch (RFC 7386) of the company, whose
+// null members clear the fields.
+func readMergePatch(w http.ResponseWriter, r *http.Request) (companyPatch, error) {
+	var patch any
+	err := utils.ReadJSON(w, r, &patch)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := patch.(map[string]any); !ok {
+		return nil, ErrInvalidMergePatch
+	}
+
+	return func(company *domain.Company) error {
+		return domain.PatchCompany(company, func(doc any) (any, error) {
+			return domain.MergePatch(doc, patch), nil
+		})
+	}, nil
+}
+
+// readJSONPatch reads a JSON Patch (RFC 6902) of the company, which is only
+// applied when every operation, tests included, succeeds.
+func readJSONPatch(w http.ResponseWriter, r *http.Request) (companyPatch, error) {
+	var patch domain.JSONPatch
+	err := utils.ReadJSON(w, r, &patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(company *domain.Company) error {
+		return domain.PatchCompany(company, patch.Apply)
+	}, nil
+}
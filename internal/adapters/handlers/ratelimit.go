@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+// RateLimit limits the requests every client makes to each route. Clients
+// are told about their limit through the RateLimit-* headers and requests
+// over it are rejected with 429 and a Retry-After header.
+//
+// Clients are identified by the subject of a valid JWT and otherwise by their
+// IP address, so the middleware must come after middleware.RealIP. Headers
+// that are not verified, such as X-API-Key, never identify a client, since
+// clients could otherwise get a new bucket by changing them. When the store fails requests are let through.
+func RateLimit(service *services.RateLimitService, auth *jwtauth.JWTAuth) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := service.Take(r.Method, routePattern(r), rateLimitClient(r, auth))
+			if err != nil {
+				utils.LogError(fmt.Errorf("rate limiting: %w", err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if res == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(res.Reset))
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%s", res.Limit.Requests, seconds(res.Limit.Period)))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", seconds(res.RetryAfter))
+				utils.RateLimitExceededResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// routePattern returns the pattern of the route that will serve the request,
+// or an empty string when there is none. The middleware runs before chi has
+// routed the request, so the router is asked to match it ahead of time.
+func routePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return ""
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	tctx := chi.NewRouteContext()
+	if !rctx.Routes.Match(tctx, r.Method, path) {
+		return ""
+	}
+
+	return tctx.RoutePattern()
+}
+
+func rateLimitClient(r *http.Request, auth *jwtauth.JWTAuth) string {
+	if token, err := jwtauth.VerifyRequest(auth, r, jwtauth.TokenFromHeader); err == nil && token.Subject() != "" {
+		return "sub:" + token.Subject()
+	}
+
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip
+}
+
+// seconds rounds d up to whole seconds, as the headers require.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
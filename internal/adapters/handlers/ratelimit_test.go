@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/ratelimit"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+func Test_RateLimit(t *testing.T) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := auth.Encode(map[string]interface{}{"sub": "alice"})
+
+	service := services.NewRateLimitService(ratelimit.NewMemoryStore(), domain.RateLimits{
+		Default: domain.RateLimit{Requests: 2, Period: time.Hour},
+		Routes: map[string]domain.RateLimit{
+			"GET /companies/{id}": {Requests: 1, Period: time.Minute},
+			"/health":             {},
+		},
+	})
+
+	r := chi.NewRouter()
+	r.Use(RateLimit(service, auth))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.Get("/companies/{id}", ok)
+	r.Post("/companies/{id}/restore", ok)
+	r.Get("/health", ok)
+
+	testCases := []struct {
+		name              string
+		method            string
+		url               string
+		remoteAddr        string
+		header            map[string]string
+		expectedStatus    int
+		expectedRemaining string
+		expectedRetry     string
+	}{
+		{"route limit", "GET", "/companies/1", "10.0.0.1:1234", nil, http.StatusOK, "0", ""},
+		{"route limit per route", "GET", "/companies/2", "10.0.0.1:1234", nil, http.StatusTooManyRequests, "0", "60"},
+		{"route limit per client", "GET", "/companies/1", "10.0.0.2:1234", nil, http.StatusOK, "0", ""},
+		{"default limit", "POST", "/companies/1/restore", "10.0.0.1:1234", nil, http.StatusOK, "1", ""},
+		{"default limit shared", "POST", "/unknown", "10.0.0.1:1234", nil, http.StatusNotFound, "0", ""},
+		{"default limit exhausted", "POST", "/companies/1/restore", "10.0.0.1:1234", nil, http.StatusTooManyRequests, "0", "1800"},
+		{"by subject", "POST", "/companies/1/restore", "10.0.0.1:1234", map[string]string{"Authorization": "Bearer " + token}, http.StatusOK, "1", ""},
+		{"by subject from elsewhere", "POST", "/companies/1/restore", "10.0.0.3:1234", map[string]string{"Authorization": "Bearer " + token}, http.StatusOK, "0", ""},
+		{"invalid token", "POST", "/companies/1/restore", "10.0.0.1:1234", map[string]string{"Authorization": "Bearer invalid"}, http.StatusTooManyRequests, "0", "1800"},
+		{"api key", "POST", "/companies/1/restore", "10.0.0.4:1234", map[string]string{"X-API-Key": "key-1"}, http.StatusOK, "1", ""},
+		{"another api key", "POST", "/companies/1/restore", "10.0.0.4:1234", map[string]string{"X-API-Key": "key-2"}, http.StatusOK, "0", ""},
+		{"api keys share the bucket of their address", "POST", "/companies/1/restore", "10.0.0.4:1234", map[string]string{"X-API-Key": "key-3"}, http.StatusTooManyRequests, "0", "1800"},
+		{"unlimited", "GET", "/health", "10.0.0.1:1234", nil, http.StatusOK, "", ""},
+	}
+
+	for _, tt := range testCases {
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		req.RemoteAddr = tt.remoteAddr
+		for key, value := range tt.header {
+			req.Header.Set(key, value)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedStatus, rr.Code)
+		}
+
+		if remaining := rr.Header().Get("RateLimit-Remaining"); remaining != tt.expectedRemaining {
+			t.Errorf("%s: expected RateLimit-Remaining %q but got %q", tt.name, tt.expectedRemaining, remaining)
+		}
+
+		if retry := rr.Header().Get("Retry-After"); retry != tt.expectedRetry {
+			t.Errorf("%s: expected Retry-After %q but got %q", tt.name, tt.expectedRetry, retry)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+)
+
+// ReadYourWrites serves the reads of a client from the primary database
+// rather than a replica for window after the client last changed something,
+// so that a lagging replica does not hide its own writes from it. Clients are
+// identified like by RateLimit, and successful requests other than GET, HEAD
+// and OPTIONS count as writes. Writes are remembered by each instance of the
+// service on its own.
+func ReadYourWrites(window time.Duration, auth *jwtauth.JWTAuth) func(http.Handler) http.Handler {
+	writes := &recentWrites{window: window, at: make(map[string]time.Time)}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client := rateLimitClient(r, auth)
+			if writes.recent(client) {
+				r = r.WithContext(repository.WithPrimary(r.Context()))
+			}
+
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			if ww.Status() < http.StatusBadRequest {
+				writes.record(client)
+			}
+		})
+	}
+}
+
+// recentWrites remembers when each client last wrote, for as long as it is
+// within the window.
+type recentWrites struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	at     map[string]time.Time
+	pruned time.Time
+}
+
+func (rw *recentWrites) recent(client string) bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	at, ok := rw.at[client]
+	return ok && time.Since(at) < rw.window
+}
+
+func (rw *recentWrites) record(client string) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	now := time.Now()
+	rw.at[client] = now
+
+	if now.Sub(rw.pruned) < rw.window {
+		return
+	}
+	for client, at := range rw.at {
+		if now.Sub(at) >= rw.window {
+			delete(rw.at, client)
+		}
+	}
+	rw.pruned = now
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+)
+
+func Test_ReadYourWrites(t *testing.T) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, token, _ := auth.Encode(map[string]interface{}{"sub": "alice"})
+
+	window := 50 * time.Millisecond
+	r := chi.NewRouter()
+	r.Use(ReadYourWrites(window, auth))
+
+	var primary bool
+	r.Get("/companies/{id}", func(w http.ResponseWriter, r *http.Request) {
+		primary = repository.UsesPrimary(r.Context())
+	})
+	r.Post("/companies", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	r.Post("/companies:batch", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	testCases := []struct {
+		name            string
+		method          string
+		url             string
+		remoteAddr      string
+		header          map[string]string
+		wait            time.Duration
+		expectedPrimary bool
+	}{
+		{"read before writing", "GET", "/companies/1", "10.0.0.1:1234", nil, 0, false},
+		{"write", "POST", "/companies", "10.0.0.1:1234", nil, 0, false},
+		{"read own write", "GET", "/companies/1", "10.0.0.1:1234", nil, 0, true},
+		{"read of another client", "GET", "/companies/1", "10.0.0.2:1234", nil, 0, false},
+		{"failed write", "POST", "/companies:batch", "10.0.0.2:1234", nil, 0, false},
+		{"read after failed write", "GET", "/companies/1", "10.0.0.2:1234", nil, 0, false},
+		{"write by subject", "POST", "/companies", "10.0.0.3:1234", map[string]string{"Authorization": "Bearer " + token}, 0, false},
+		{"read by subject from elsewhere", "GET", "/companies/1", "10.0.0.4:1234", map[string]string{"Authorization": "Bearer " + token}, 0, true},
+		{"read after window", "GET", "/companies/1", "10.0.0.1:1234", nil, window, false},
+	}
+
+	for _, tt := range testCases {
+		time.Sleep(tt.wait)
+
+		req := httptest.NewRequest(tt.method, tt.url, nil)
+		req.RemoteAddr = tt.remoteAddr
+		for key, value := range tt.header {
+			req.Header.Set(key, value)
+		}
+
+		primary = false
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		if primary != tt.expectedPrimary {
+			t.Errorf("%s: expected reads pinned to the primary to be %t but got %t", tt.name, tt.expectedPrimary, primary)
+		}
+	}
+}
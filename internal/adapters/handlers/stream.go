@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const (
+	// streamBuffer is how many events a stream may fall behind by before it
+	// is ended.
+	streamBuffer = 64
+	// streamKeepAlive is how often idle streams are written to, so that
+	// proxies do not time them out.
+	streamKeepAlive = 15 * time.Second
+)
+
+type StreamHandler struct {
+	events   *services.Broadcaster
+	upgrader websocket.Upgrader
+}
+
+func NewStreamHandler(events *services.Broadcaster) *StreamHandler {
+	return &StreamHandler{events: events}
+}
+
+// streamEvent is the payload of the events sent to the streams.
+type streamEvent struct {
+	ID      string          `json:"id,omitempty"`
+	Event   string          `json:"event"`
+	Company *domain.Company `json:"company,omitempty"`
+}
+
+// resetEvent tells the client that events may have been missed since the one
+// it saw last, so it should fetch the companies it follows again.
+var resetEvent = streamEvent{Event: "reset"}
+
+func newStreamEvent(event domain.CompanyEvent) streamEvent {
+	company := event.Company
+	return streamEvent{ID: event.ID, Event: event.Kind(), Company: &company}
+}
+
+// streamFilter selects the events of the companies with the given ids and
+// types. Deletions carry no type and pass the type filter.
+type streamFilter struct {
+	ids   map[uuid.UUID]bool
+	types map[domain.CompanyType]bool
+}
+
+func readStreamFilter(r *http.Request) (*streamFilter, error) {
+	filter := &streamFilter{ids: make(map[uuid.UUID]bool), types: make(map[domain.CompanyType]bool)}
+	query := r.URL.Query()
+
+	for _, value := range splitValues(query["id"]) {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("id %q is not a valid UUID", value)
+		}
+		filter.ids[id] = true
+	}
+
+	for _, value := range splitValues(query["type"]) {
+		t, err := domain.ParseCompanyType(value)
+		if err != nil {
+			return nil, fmt.Errorf("type %q is not a valid company type", value)
+		}
+		filter.types[t] = true
+	}
+
+	return filter, nil
+}
+
+// splitValues accepts both repeated and comma-separated query values.
+func splitValues(values []string) []string {
+	var split []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				split = append(split, v)
+			}
+		}
+	}
+	return split
+}
+
+func (f *streamFilter) match(event domain.CompanyEvent) bool {
+	if len(f.ids) > 0 && !f.ids[event.Company.ID] {
+		return false
+	}
+	if len(f.types) > 0 && event.Method != http.MethodDelete && !f.types[event.Company.Type] {
+		return false
+	}
+	return true
+}
+
+// lastEventID returns the ID of the event the client saw last, sent by
+// EventSource on reconnection or in the query string by clients that cannot
+// set headers.
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return r.URL.Query().Get("last_event_id")
+}
+
+// Stream pushes the changes of the companies as server-sent events until the
+// client goes away. Streams that fall behind are ended, and clients resume
+// them after the last event they saw with Last-Event-ID.
+func (a *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	filter, err := readStreamFilter(r)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.ServerErrorResponse(w, r, fmt.Errorf("streaming is not supported by %T", w))
+		return
+	}
+
+	events, unsubscribe, resumed := a.events.SubscribeAfter(lastEventID(r), streamBuffer)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if !resumed {
+		writeServerSentEvent(w, resetEvent)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			writeServerSentEvent(w, newStreamEvent(event))
+			flusher.Flush()
+		}
+	}
+}
+
+func writeServerSentEvent(w http.ResponseWriter, event streamEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		utils.LogError(err)
+		return
+	}
+
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data)
+}
+
+// WebSocket pushes the same events as Stream as JSON messages over a
+// WebSocket, resuming after the event named by the last_event_id parameter.
+// Streams that fall behind are closed with a try-again-later status.
+func (a *StreamHandler) WebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := readStreamFilter(r)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	conn, err := a.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded with an error.
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe, resumed := a.events.SubscribeAfter(lastEventID(r), streamBuffer)
+	defer unsubscribe()
+
+	// Reading is only needed to handle the control messages of the client and
+	// to learn that it has gone away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if !resumed {
+		err = conn.WriteJSON(resetEvent)
+		if err != nil {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamKeepAlive))
+		case event, ok := <-events:
+			if !ok {
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "the stream fell behind the changes")
+				_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			err = conn.WriteJSON(newStreamEvent(event))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
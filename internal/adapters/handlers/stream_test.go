@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+type discardProducer struct{}
+
+func (discardProducer) ProduceCompany(company *domain.Company, method string) error {
+	return nil
+}
+
+// readServerSentEvent returns the fields of the next event of the stream,
+// skipping comments.
+func readServerSentEvent(t *testing.T, stream *bufio.Scanner) map[string]string {
+	fields := make(map[string]string)
+	for stream.Scan() {
+		line := stream.Text()
+		switch {
+		case line == "":
+			if len(fields) > 0 {
+				return fields
+			}
+		case strings.HasPrefix(line, ":"):
+		default:
+			name, value, _ := strings.Cut(line, ": ")
+			fields[name] = value
+		}
+	}
+	t.Fatalf("stream ended: %v", stream.Err())
+	return nil
+}
+
+func Test_Stream(t *testing.T) {
+	events := services.NewBroadcaster(discardProducer{}, services.BroadcastConfig{History: 10})
+	srv := httptest.NewServer(http.HandlerFunc(NewStreamHandler(events).Stream))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The stream is subscribed to once its response header has been received.
+	subscribe := func(query, lastID string) (*http.Response, *bufio.Scanner) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+query, nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("error subscribing: %s", err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res, bufio.NewScanner(res.Body)
+	}
+
+	published, unsubscribe := events.Subscribe(10)
+	defer unsubscribe()
+
+	charity := &domain.Company{ID: uuid.New(), Name: "Charity", Type: domain.NonProfit}
+	_ = events.ProduceCompany(charity, http.MethodPost)
+	created := <-published
+
+	res, stream := subscribe("?type=1", "")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream but got %d %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	_ = events.ProduceCompany(&domain.Company{ID: uuid.New(), Name: "Coop", Type: domain.Cooperative}, http.MethodPost)
+	charity.Description = "Helps"
+	_ = events.ProduceCompany(charity, http.MethodPatch)
+	_ = events.ProduceCompany(&domain.Company{ID: charity.ID}, http.MethodDelete)
+
+	event := readServerSentEvent(t, stream)
+	if event["event"] != "updated" || event["id"] == "" || !strings.Contains(event["data"], `"description":"Helps"`) {
+		t.Errorf("expected only the update of Charity to pass the type filter but got %v", event)
+	}
+
+	event = readServerSentEvent(t, stream)
+	if event["event"] != "deleted" {
+		t.Errorf("expected the deletion of Charity to pass the type filter but got %v", event)
+	}
+
+	_, stream = subscribe("?id="+charity.ID.String(), created.ID)
+	event = readServerSentEvent(t, stream)
+	if event["event"] != "updated" {
+		t.Errorf("expected the update of Charity to be resumed but got %v", event)
+	}
+
+	_, stream = subscribe("", "unknown-1")
+	event = readServerSentEvent(t, stream)
+	if event["event"] != "reset" {
+		t.Errorf("expected a reset when resuming after an unknown event but got %v", event)
+	}
+
+	res, _ = subscribe("?type=9", "")
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status code %d for an invalid type but got %d", http.StatusBadRequest, res.StatusCode)
+	}
+}
+
+func Test_WebSocket(t *testing.T) {
+	events := services.NewBroadcaster(discardProducer{}, services.BroadcastConfig{History: 10})
+	srv := httptest.NewServer(http.HandlerFunc(NewStreamHandler(events).WebSocket))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	charity := &domain.Company{ID: uuid.New(), Name: "Charity", Type: domain.NonProfit}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?id="+charity.ID.String()+"&last_event_id=unknown-1", nil)
+	if err != nil {
+		t.Fatalf("error dialing: %s", err)
+	}
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var reset streamEvent
+	err = conn.ReadJSON(&reset)
+	if err != nil || reset.Event != "reset" {
+		t.Fatalf("expected a reset when resuming after an unknown event but got %v, %v", reset, err)
+	}
+
+	_ = events.ProduceCompany(&domain.Company{ID: uuid.New(), Name: "Coop"}, http.MethodPost)
+	_ = events.ProduceCompany(charity, http.MethodPost)
+
+	var created streamEvent
+	err = conn.ReadJSON(&created)
+	if err != nil || created.Event != "created" || created.ID == "" || created.Company == nil || created.Company.Name != "Charity" {
+		t.Errorf("expected only the creation of Charity to pass the id filter but got %v, %v", created, err)
+	}
+
+	_, res, err := websocket.DefaultDialer.Dial(url+"?id=invalid", nil)
+	if err == nil || res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status code %d for an invalid id but got %v", http.StatusBadRequest, err)
+	}
+}
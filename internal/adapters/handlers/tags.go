@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+var ErrInvalidTagFilter = errors.New("tag and tag_any must be tags, repeated or as a comma separated list")
+
+// parseTagFilter returns the normalized tags of the query string parameter,
+// which may be repeated or hold a comma separated list.
+func parseTagFilter(qs url.Values, key string) ([]string, error) {
+	var tags []string
+	for _, value := range qs[key] {
+		for _, tag := range strings.Split(value, ",") {
+			tag = domain.NormalizeTag(tag)
+			if tag == "" {
+				continue
+			}
+			if !domain.ValidTag(tag) {
+				return nil, ErrInvalidTagFilter
+			}
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// ListTags returns the tags of the companies, with the number of companies
+// that have each, ordered by name.
+func (a *CompanyHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := a.service.Tags(r.Context())
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"tags": tags}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// AddTags tags the company, which keeps the tags it already has.
+func (a *CompanyHandler) AddTags(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Tags []string `json:"tags"`
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	company, err := a.service.AddTags(r.Context(), utils.ReadIDParam(r), input.Tags, utils.ReadSubject(r))
+	a.tagsResponse(w, r, company, err)
+}
+
+// RemoveTag removes a tag from the company, which may not have it.
+func (a *CompanyHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	company, err := a.service.RemoveTags(r.Context(), utils.ReadIDParam(r), []string{chi.URLParam(r, "tag")}, utils.ReadSubject(r))
+	a.tagsResponse(w, r, company, err)
+}
+
+func (a *CompanyHandler) tagsResponse(w http.ResponseWriter, r *http.Request, company *domain.Company, err error) {
+	if err != nil {
+		var validationErrs domain.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrs):
+			utils.FailedValidationResponse(w, r, validationErrs)
+		case errors.Is(err, repository.ErrRecordNotFound):
+			utils.NotFoundResponse(w, r)
+		default:
+			utils.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"Company": company}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
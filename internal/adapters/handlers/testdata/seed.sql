@@ -0,0 +1,2 @@
+INSERT INTO companies (id, name, description, number_of_employees, registered, type)
+		VALUES ('0e6c0248-a659-41d0-b860-795df3a53f44', 'Petros Trak Inc', 'A small family firm', 4, true, 3);
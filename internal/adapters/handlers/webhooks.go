@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService}
+}
+
+// CreateWebhook subscribes a URL to company events. The secret that signs the
+// deliveries is only ever returned here.
+func (a *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	// A webhook without a subject could never be managed by its creator.
+	subject := utils.ReadSubject(r)
+	if subject == "" {
+		utils.ForbiddenResponse(w, r)
+		return
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := &domain.Webhook{
+		URL:       input.URL,
+		Events:    input.Events,
+		Active:    true,
+		CreatedBy: subject,
+	}
+
+	err = a.service.Create(r.Context(), webhook)
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/webhooks/%s", webhook.ID))
+
+	err = utils.WriteJSON(w, http.StatusCreated, utils.Envelope{"webhook": webhook, "secret": webhook.Secret}, headers)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// ListWebhooks lists the webhooks of the caller, or every webhook for admins.
+func (a *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	// An empty subject lists every webhook, so it is only ever used for
+	// admins.
+	createdBy := utils.ReadSubject(r)
+	switch {
+	case utils.IsAdmin(r):
+		createdBy = ""
+	case createdBy == "":
+		utils.ForbiddenResponse(w, r)
+		return
+	}
+
+	webhooks, err := a.service.List(r.Context(), createdBy)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"webhooks": webhooks}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := utils.WriteJSON(w, http.StatusOK, utils.Envelope{"webhook": webhook}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// UpdateWebhook changes the URL, the events or the activation of a webhook.
+// Activating a webhook that was disabled resumes its pending deliveries.
+func (a *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		URL    *string   `json:"url"`
+		Events *[]string `json:"events"`
+		Active *bool     `json:"active"`
+	}
+
+	err := utils.ReadJSON(w, r, &input)
+	if err != nil {
+		utils.BadRequestResponse(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.Events != nil {
+		webhook.Events = *input.Events
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	err = a.service.Update(r.Context(), webhook)
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"webhook": webhook}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+func (a *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.service.Delete(r.Context(), webhook.ID)
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries returns the log of the latest deliveries to a
+// webhook, newest first, up to the limit parameter.
+func (a *WebhookHandler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := defaultDeliveriesLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			utils.BadRequestResponse(w, r, fmt.Errorf("limit must be between 1 and %d", maxDeliveriesLimit))
+			return
+		}
+		limit = n
+	}
+
+	webhook, ok := a.readWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := a.service.Deliveries(r.Context(), webhook.ID, limit)
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return
+	}
+
+	err = utils.WriteJSON(w, http.StatusOK, utils.Envelope{"deliveries": deliveries}, nil)
+	if err != nil {
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
+
+// readWebhook returns the webhook of the id parameter if it belongs to the
+// caller or the caller is an admin, and responds with 404 otherwise.
+func (a *WebhookHandler) readWebhook(w http.ResponseWriter, r *http.Request) (*domain.Webhook, bool) {
+	webhook, err := a.service.Get(r.Context(), utils.ReadIDParam(r))
+	if err != nil {
+		a.errorResponse(w, r, err)
+		return nil, false
+	}
+
+	if !utils.IsOwner(r, webhook.CreatedBy) && !utils.IsAdmin(r) {
+		utils.NotFoundResponse(w, r)
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+func (a *WebhookHandler) errorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs domain.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		utils.FailedValidationResponse(w, r, validationErrs)
+	case errors.Is(err, repository.ErrRecordNotFound):
+		utils.NotFoundResponse(w, r)
+	default:
+		utils.ServerErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+)
+
+func Test_WebhookOwnership(t *testing.T) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	_, alice, _ := auth.Encode(map[string]interface{}{"sub": "alice"})
+	_, bob, _ := auth.Encode(map[string]interface{}{"sub": "bob"})
+	_, admin, _ := auth.Encode(map[string]interface{}{"sub": "carol", "role": "admin"})
+	_, anonymous, _ := auth.Encode(map[string]interface{}{})
+
+	repo := repository.NewMemoryWebhookRepository()
+	service := services.NewWebhookService(repo, services.WebhookConfig{}, log.New(io.Discard, "", 0))
+
+	webhook := &domain.Webhook{URL: "https://example.com/hooks", Active: true, CreatedBy: "alice"}
+	err := repo.Create(context.Background(), webhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := &domain.Webhook{URL: "https://example.com/orphan", Active: true}
+	err = repo.Create(context.Background(), orphan)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewWebhookHandler(service)
+	r := chi.NewRouter()
+	r.Use(jwtauth.Verifier(auth))
+	r.Use(jwtauth.Authenticator)
+	r.Post("/webhooks", handler.CreateWebhook)
+	r.Get("/webhooks", handler.ListWebhooks)
+	r.Get("/webhooks/{id}", handler.GetWebhook)
+	r.Delete("/webhooks/{id}", handler.DeleteWebhook)
+
+	testCases := []struct {
+		name           string
+		method         string
+		url            string
+		token          string
+		expectedStatus int
+	}{
+		{"webhook of another subject", "GET", "/webhooks/" + webhook.ID.String(), bob, http.StatusMethodNotAllowed},
+		{"delete by another subject", "DELETE", "/webhooks/" + webhook.ID.String(), bob, http.StatusMethodNotAllowed},
+		{"webhook without a subject", "GET", "/webhooks/" + orphan.ID.String(), anonymous, http.StatusMethodNotAllowed},
+		{"list without a subject", "GET", "/webhooks", anonymous, http.StatusForbidden},
+		{"create without a subject", "POST", "/webhooks", anonymous, http.StatusForbidden},
+		{"webhook of the subject", "GET", "/webhooks/" + webhook.ID.String(), alice, http.StatusOK},
+		{"webhook read by an admin", "GET", "/webhooks/" + orphan.ID.String(), admin, http.StatusOK},
+	}
+
+	for _, tt := range testCases {
+		req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(`{"url":"https://example.com/hooks"}`))
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedStatus, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	companyBroker = "localhost:9092"
+	companyTopic  = "producer.company"
+)
+
+// ConsumeCompanyInvalidations calls invalidate with the id of every company
+// announced on the company topic from now on, until ctx is done. It reads
+// every partition of the topic directly rather than through a consumer
+// group, so that each replica sees every event without leaving groups behind
+// on the brokers. Partitions added to the topic are only read after a
+// restart.
+func ConsumeCompanyInvalidations(ctx context.Context, invalidate func(uuid.UUID)) error {
+	return consumeCompanyTopic(ctx, func(message kafka.Message) {
+		// Events are keyed by the id of their company.
+		id, err := uuid.ParseBytes(message.Key)
+		if err != nil {
+			return
+		}
+
+		invalidate(id)
+	})
+}
+
+// ConsumeCompanyEvents calls publish with every event announced on the
+// company topic from now on, until ctx is done, like
+// ConsumeCompanyInvalidations.
+func ConsumeCompanyEvents(ctx context.Context, publish func(domain.CompanyEvent)) error {
+	return consumeCompanyTopic(ctx, func(message kafka.Message) {
+		event := domain.CompanyEvent{}
+		for _, header := range message.Headers {
+			if header.Key == "Method" {
+				event.Method = string(header.Value)
+			}
+		}
+
+		err := json.Unmarshal(message.Value, &event.Company)
+		if err != nil || event.Method == "" {
+			return
+		}
+
+		publish(event)
+	})
+}
+
+// consumeCompanyTopic calls handle with every message produced to the company
+// topic from now on, one at a time, until ctx is done or a partition cannot
+// be read.
+func consumeCompanyTopic(ctx context.Context, handle func(kafka.Message)) error {
+	dialer := &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+	}
+
+	partitions, err := dialer.LookupPartitions(ctx, "tcp", companyBroker, companyTopic)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	readers := make([]*kafka.Reader, 0, len(partitions))
+	for _, partition := range partitions {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   []string{companyBroker},
+			Topic:     companyTopic,
+			Partition: partition.ID,
+			MaxWait:   100 * time.Millisecond,
+			Dialer:    dialer,
+		})
+		defer reader.Close()
+
+		err = reader.SetOffset(kafka.LastOffset)
+		if err != nil {
+			return err
+		}
+		readers = append(readers, reader)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(chan error, len(readers))
+
+	for _, reader := range readers {
+		wg.Add(1)
+		go func(reader *kafka.Reader) {
+			defer wg.Done()
+
+			for {
+				message, err := reader.ReadMessage(ctx)
+				if err != nil {
+					if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+						errs <- err
+					}
+					// A partition that cannot be read stops the others.
+					cancel()
+					return
+				}
+
+				mu.Lock()
+				handle(message)
+				mu.Unlock()
+			}
+		}(reader)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	return <-errs
+}
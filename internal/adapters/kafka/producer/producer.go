@@ -52,6 +52,19 @@ func produce(key []byte, value []byte, method string, topic string, producer *ka
 	close(deliveryChan)
 }
 
+// CompanyProducer publishes company events to kafka.
+type CompanyProducer struct {
+	producer *kafka.Producer
+}
+
+func NewCompanyProducer(prod *kafka.Producer) *CompanyProducer {
+	return &CompanyProducer{prod}
+}
+
+func (p *CompanyProducer) ProduceCompany(company *domain.Company, method string) error {
+	return ProduceCompany(p.producer, company, method)
+}
+
 func ProduceCompany(prod *kafka.Producer, company *domain.Company, method string) error {
 	companyTopic := "producer.company"
 
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// refill returns the tokens of a bucket that held tokens at last once it has
+// been refilled until now. Buckets refill continuously at Requests tokens per
+// Period and hold at most Requests tokens.
+func refill(limit domain.RateLimit, tokens float64, last, now time.Time) float64 {
+	elapsed := now.Sub(last)
+	if elapsed <= 0 {
+		return tokens
+	}
+
+	tokens += float64(limit.Requests) * float64(elapsed) / float64(limit.Period)
+	return math.Min(tokens, float64(limit.Requests))
+}
+
+// result describes a bucket that holds tokens after a request was, or was
+// not, allowed.
+func result(limit domain.RateLimit, tokens float64, allowed bool) domain.RateLimitResult {
+	perToken := float64(limit.Period) / float64(limit.Requests)
+
+	res := domain.RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) * perToken),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+
+	return res
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+	"github.com/redis/go-redis/v9"
+)
+
+func Test_MemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func Test_RedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	store := NewRedisStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	defer store.Close()
+
+	testStore(t, store)
+
+	if ttl := server.TTL("client"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("expected the bucket to expire once it is full but its ttl is %s", ttl)
+	}
+}
+
+func testStore(t *testing.T, store ports.RateLimitStore) {
+	limit := domain.RateLimit{Requests: 3, Period: time.Minute}
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name              string
+		key               string
+		elapsed           time.Duration
+		expectedAllowed   bool
+		expectedRemaining int
+		expectedRetry     time.Duration
+	}{
+		{"first", "client", 0, true, 2, 0},
+		{"second", "client", 0, true, 1, 0},
+		{"third", "client", 0, true, 0, 0},
+		{"exhausted", "client", 0, false, 0, 20 * time.Second},
+		{"partly refilled", "client", 10 * time.Second, false, 0, 10 * time.Second},
+		{"refilled", "client", 20 * time.Second, true, 0, 0},
+		{"other client", "other", 20 * time.Second, true, 2, 0},
+		{"full again", "client", 2 * time.Minute, true, 2, 0},
+	}
+
+	for _, tt := range testCases {
+		res, err := store.Take(tt.key, limit, start.Add(tt.elapsed))
+		if err != nil {
+			t.Fatalf("%s: error taking from bucket: %s", tt.name, err)
+		}
+
+		if res.Allowed != tt.expectedAllowed {
+			t.Errorf("%s: expected allowed to be %t but got %t", tt.name, tt.expectedAllowed, res.Allowed)
+		}
+
+		if res.Remaining != tt.expectedRemaining {
+			t.Errorf("%s: expected %d remaining but got %d", tt.name, tt.expectedRemaining, res.Remaining)
+		}
+
+		if res.RetryAfter.Round(time.Second) != tt.expectedRetry {
+			t.Errorf("%s: expected retry after %s but got %s", tt.name, tt.expectedRetry, res.RetryAfter)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from the bucket in KEYS[1] atomically. Its
+// arguments are the requests and the period in milliseconds of the limit and
+// the current time in milliseconds, and it returns whether a token was taken
+// along with the tokens left. The bucket expires once it would be full.
+var takeScript = redis.NewScript(`
+local requests = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1])
+local last = tonumber(state[2])
+if tokens == nil or last == nil then
+	tokens = requests
+	last = now
+end
+
+if now > last then
+	tokens = math.min(requests, tokens + requests * (now - last) / period)
+	last = now
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", last)
+redis.call("PEXPIRE", KEYS[1], math.ceil((requests - tokens) * period / requests) + 1)
+
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps the buckets in Redis, or any server speaking its protocol,
+// so that the replicas sharing it enforce the limits together.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client}
+}
+
+// NewRedisStoreFromURL connects to the server at a URL such as
+// "redis://localhost:6379/0".
+func NewRedisStoreFromURL(url string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRedisStore(redis.NewClient(opts)), nil
+}
+
+func (s *RedisStore) Take(key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	values, err := takeScript.Run(context.Background(), s.client, []string{key},
+		limit.Requests, limit.Period.Milliseconds(), now.UnixMilli()).Slice()
+	if err != nil {
+		return domain.RateLimitResult{}, err
+	}
+
+	if len(values) != 2 {
+		return domain.RateLimitResult{}, fmt.Errorf("unexpected rate limit script reply %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	left, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(left, 64)
+	if err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("unexpected rate limit script reply %v", values)
+	}
+
+	return result(limit, tokens, allowed == 1), nil
+}
+
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// AddressRepository stores the addresses of companies in Postgres or SQLite,
+// which share its queries.
+type AddressRepository struct {
+	DB *sql.DB
+}
+
+const addressColumns = `id, company_id, label, line1, line2, city, region, postal_code, country_code,
+		created_at, updated_at`
+
+func scanAddress(row rowScanner, address *domain.Address) error {
+	return row.Scan(
+		&address.ID,
+		&address.CompanyID,
+		&address.Label,
+		&address.Line1,
+		&address.Line2,
+		&address.City,
+		&address.Region,
+		&address.PostalCode,
+		&address.CountryCode,
+		&address.CreatedAt,
+		&address.UpdatedAt,
+	)
+}
+
+// Create stores the address. It returns ErrRecordNotFound when the company of
+// the address does not exist.
+func (a *AddressRepository) Create(ctx context.Context, address *domain.Address) error {
+	query := `
+		INSERT INTO company_addresses (id, company_id, label, line1, line2, city, region, postal_code,
+			country_code, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)
+		RETURNING ` + addressColumns
+
+	args := []any{
+		uuid.New(),
+		address.CompanyID,
+		address.Label,
+		address.Line1,
+		address.Line2,
+		address.City,
+		address.Region,
+		address.PostalCode,
+		address.CountryCode,
+		time.Now().UTC(),
+	}
+
+	err := scanAddress(a.DB.QueryRowContext(ctx, query, args...), address)
+	if isForeignKeyViolation(err) {
+		return ErrRecordNotFound
+	}
+	return contextErr(ctx, err)
+}
+
+func (a *AddressRepository) Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Address, error) {
+	query := `
+		SELECT ` + addressColumns + `
+		FROM company_addresses
+		WHERE id = $1 AND company_id = $2`
+
+	var address domain.Address
+
+	err := scanAddress(a.DB.QueryRowContext(ctx, query, id, companyID), &address)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &address, nil
+}
+
+func (a *AddressRepository) Update(ctx context.Context, address *domain.Address) error {
+	query := `
+		UPDATE company_addresses
+		SET label = $1, line1 = $2, line2 = $3, city = $4, region = $5, postal_code = $6,
+			country_code = $7, updated_at = $8
+		WHERE id = $9 AND company_id = $10
+		RETURNING ` + addressColumns
+
+	args := []any{
+		address.Label,
+		address.Line1,
+		address.Line2,
+		address.City,
+		address.Region,
+		address.PostalCode,
+		address.CountryCode,
+		time.Now().UTC(),
+		address.ID,
+		address.CompanyID,
+	}
+
+	err := scanAddress(a.DB.QueryRowContext(ctx, query, args...), address)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return contextErr(ctx, err)
+		}
+	}
+	return nil
+}
+
+func (a *AddressRepository) Delete(ctx context.Context, companyID, id uuid.UUID) error {
+	query := `
+		DELETE FROM company_addresses
+		WHERE id = $1 AND company_id = $2`
+
+	result, err := a.DB.ExecContext(ctx, query, id, companyID)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// List returns the addresses of the companies, oldest first.
+func (a *AddressRepository) List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Address, error) {
+	addresses := []*domain.Address{}
+	if len(companyIDs) == 0 {
+		return addresses, nil
+	}
+
+	query := `
+		SELECT ` + addressColumns + `
+		FROM company_addresses
+		WHERE company_id IN (` + placeholders(len(companyIDs)) + `)
+		ORDER BY created_at, id`
+
+	rows, err := a.DB.QueryContext(ctx, query, idArgs(companyIDs)...)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address domain.Address
+		err = scanAddress(rows, &address)
+		if err != nil {
+			return nil, contextErr(ctx, err)
+		}
+		addresses = append(addresses, &address)
+	}
+	return addresses, contextErr(ctx, rows.Err())
+}
+
+// placeholders returns the placeholders $1 to $n, separated by commas.
+func placeholders(n int) string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return strings.Join(ps, ", ")
+}
+
+func idArgs(ids []uuid.UUID) []any {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
+
+// isForeignKeyViolation reports whether err is a violation of a foreign key in
+// Postgres or SQLite.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// AttributeDefinitionRepository stores the definitions of the custom
+// attributes of companies in Postgres or SQLite, which share its queries.
+type AttributeDefinitionRepository struct {
+	DB *sql.DB
+}
+
+const attributeDefinitionColumns = `name, description, schema, created_by, created_at, updated_at`
+
+func scanAttributeDefinition(row rowScanner, definition *domain.AttributeDefinition) error {
+	return row.Scan(
+		&definition.Name,
+		&definition.Description,
+		(*[]byte)(&definition.Schema),
+		&definition.CreatedBy,
+		&definition.CreatedAt,
+		&definition.UpdatedAt,
+	)
+}
+
+// Create stores the definition. It returns ErrDuplicateAttribute when a
+// definition with the same name exists.
+func (d *AttributeDefinitionRepository) Create(ctx context.Context, definition *domain.AttributeDefinition) error {
+	query := `
+		INSERT INTO attribute_definitions (name, description, schema, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		RETURNING ` + attributeDefinitionColumns
+
+	args := []any{
+		definition.Name,
+		definition.Description,
+		string(definition.Schema),
+		definition.CreatedBy,
+		time.Now().UTC(),
+	}
+
+	err := scanAttributeDefinition(d.DB.QueryRowContext(ctx, query, args...), definition)
+	if isUniqueViolation(err) || isSQLiteUniqueViolation(err) {
+		return ErrDuplicateAttribute
+	}
+	return contextErr(ctx, err)
+}
+
+func (d *AttributeDefinitionRepository) Get(ctx context.Context, name string) (*domain.AttributeDefinition, error) {
+	query := `
+		SELECT ` + attributeDefinitionColumns + `
+		FROM attribute_definitions
+		WHERE name = $1`
+
+	var definition domain.AttributeDefinition
+
+	err := scanAttributeDefinition(d.DB.QueryRowContext(ctx, query, name), &definition)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &definition, nil
+}
+
+// Update changes the description and schema of the definition.
+func (d *AttributeDefinitionRepository) Update(ctx context.Context, definition *domain.AttributeDefinition) error {
+	query := `
+		UPDATE attribute_definitions
+		SET description = $1, schema = $2, updated_at = $3
+		WHERE name = $4
+		RETURNING ` + attributeDefinitionColumns
+
+	args := []any{
+		definition.Description,
+		string(definition.Schema),
+		time.Now().UTC(),
+		definition.Name,
+	}
+
+	err := scanAttributeDefinition(d.DB.QueryRowContext(ctx, query, args...), definition)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return contextErr(ctx, err)
+		}
+	}
+	return nil
+}
+
+func (d *AttributeDefinitionRepository) Delete(ctx context.Context, name string) error {
+	query := `
+		DELETE FROM attribute_definitions
+		WHERE name = $1`
+
+	result, err := d.DB.ExecContext(ctx, query, name)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// List returns every definition, ordered by name.
+func (d *AttributeDefinitionRepository) List(ctx context.Context) ([]*domain.AttributeDefinition, error) {
+	query := `
+		SELECT ` + attributeDefinitionColumns + `
+		FROM attribute_definitions
+		ORDER BY name`
+
+	rows, err := d.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	defer rows.Close()
+
+	definitions := []*domain.AttributeDefinition{}
+	for rows.Next() {
+		var definition domain.AttributeDefinition
+		err = scanAttributeDefinition(rows, &definition)
+		if err != nil {
+			return nil, contextErr(ctx, err)
+		}
+		definitions = append(definitions, &definition)
+	}
+	return definitions, contextErr(ctx, rows.Err())
+}
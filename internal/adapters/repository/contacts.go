@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// ContactRepository stores the contacts of companies in Postgres or SQLite,
+// which share its queries.
+type ContactRepository struct {
+	DB *sql.DB
+}
+
+const contactColumns = `id, company_id, name, email, phone, role, created_at, updated_at`
+
+func scanContact(row rowScanner, contact *domain.Contact) error {
+	return row.Scan(
+		&contact.ID,
+		&contact.CompanyID,
+		&contact.Name,
+		&contact.Email,
+		&contact.Phone,
+		&contact.Role,
+		&contact.CreatedAt,
+		&contact.UpdatedAt,
+	)
+}
+
+// Create stores the contact. It returns ErrRecordNotFound when the company of
+// the contact does not exist.
+func (c *ContactRepository) Create(ctx context.Context, contact *domain.Contact) error {
+	query := `
+		INSERT INTO company_contacts (id, company_id, name, email, phone, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING ` + contactColumns
+
+	args := []any{
+		uuid.New(),
+		contact.CompanyID,
+		contact.Name,
+		contact.Email,
+		contact.Phone,
+		contact.Role,
+		time.Now().UTC(),
+	}
+
+	err := scanContact(c.DB.QueryRowContext(ctx, query, args...), contact)
+	if isForeignKeyViolation(err) {
+		return ErrRecordNotFound
+	}
+	return contextErr(ctx, err)
+}
+
+func (c *ContactRepository) Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Contact, error) {
+	query := `
+		SELECT ` + contactColumns + `
+		FROM company_contacts
+		WHERE id = $1 AND company_id = $2`
+
+	var contact domain.Contact
+
+	err := scanContact(c.DB.QueryRowContext(ctx, query, id, companyID), &contact)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &contact, nil
+}
+
+func (c *ContactRepository) Update(ctx context.Context, contact *domain.Contact) error {
+	query := `
+		UPDATE company_contacts
+		SET name = $1, email = $2, phone = $3, role = $4, updated_at = $5
+		WHERE id = $6 AND company_id = $7
+		RETURNING ` + contactColumns
+
+	args := []any{
+		contact.Name,
+		contact.Email,
+		contact.Phone,
+		contact.Role,
+		time.Now().UTC(),
+		contact.ID,
+		contact.CompanyID,
+	}
+
+	err := scanContact(c.DB.QueryRowContext(ctx, query, args...), contact)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return contextErr(ctx, err)
+		}
+	}
+	return nil
+}
+
+func (c *ContactRepository) Delete(ctx context.Context, companyID, id uuid.UUID) error {
+	query := `
+		DELETE FROM company_contacts
+		WHERE id = $1 AND company_id = $2`
+
+	result, err := c.DB.ExecContext(ctx, query, id, companyID)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// List returns the contacts of the companies, oldest first.
+func (c *ContactRepository) List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Contact, error) {
+	contacts := []*domain.Contact{}
+	if len(companyIDs) == 0 {
+		return contacts, nil
+	}
+
+	query := `
+		SELECT ` + contactColumns + `
+		FROM company_contacts
+		WHERE company_id IN (` + placeholders(len(companyIDs)) + `)
+		ORDER BY created_at, id`
+
+	rows, err := c.DB.QueryContext(ctx, query, idArgs(companyIDs)...)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var contact domain.Contact
+		err = scanContact(rows, &contact)
+		if err != nil {
+			return nil, contextErr(ctx, err)
+		}
+		contacts = append(contacts, &contact)
+	}
+	return contacts, contextErr(ctx, rows.Err())
+}
@@ -0,0 +1,1199 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// testCompanyRepositoryContract checks the behaviour every company repository
+// must share. It only touches companies named "Contract ...", and purges them
+// when done, so that it can run against a database other tests also use.
+func testCompanyRepositoryContract(t *testing.T, repo ports.CompanyRepository) {
+	ctx := context.Background()
+
+	create := func(t *testing.T, company *domain.Company) *domain.Company {
+		t.Helper()
+		err := repo.Create(ctx, company)
+		if err != nil {
+			t.Fatalf("error creating company %q: %s", company.Name, err)
+		}
+		t.Cleanup(func() { _ = repo.Purge(ctx, company.ID) })
+		return company
+	}
+
+	names := func(t *testing.T, filter domain.CompanyFilter, after string, limit int) []string {
+		t.Helper()
+		companies, err := repo.List(ctx, filter, after, limit)
+		if err != nil {
+			t.Fatalf("list returned an error: %s", err)
+		}
+		var names []string
+		for _, company := range companies {
+			names = append(names, company.Name)
+		}
+		return names
+	}
+
+	t.Run("create and get", func(t *testing.T) {
+		company := create(t, &domain.Company{
+			Name:              "Contract Get",
+			Description:       "A small family firm",
+			NumberOfEmployees: 4,
+			Registered:        true,
+			Type:              domain.SoleProprietorship,
+			CreatedBy:         "tester",
+		})
+
+		if company.ID == uuid.Nil || company.CreatedAt.IsZero() || !company.UpdatedAt.Equal(company.CreatedAt) {
+			t.Errorf("expected id, created_at and updated_at to be set on create but got %+v", company)
+		}
+		if company.UpdatedBy != "tester" {
+			t.Errorf("expected updated_by to be 'tester' but got %q", company.UpdatedBy)
+		}
+
+		found, err := repo.Get(ctx, company.ID)
+		if err != nil {
+			t.Fatalf("error getting company: %s", err)
+		}
+		if found.Name != company.Name || found.Description != company.Description ||
+			found.NumberOfEmployees != 4 || !found.Registered || found.Type != domain.SoleProprietorship ||
+			!found.CreatedAt.Equal(company.CreatedAt) || found.CreatedBy != "tester" {
+			t.Errorf("expected to get %+v but got %+v", company, found)
+		}
+
+		_, err = repo.Get(ctx, uuid.New())
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected getting an unknown company to return %v but got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("unique names", func(t *testing.T) {
+		create(t, &domain.Company{Name: "Contract Dup A"})
+		other := create(t, &domain.Company{Name: "Contract Dup B"})
+
+		err := repo.Create(ctx, &domain.Company{Name: "Contract Dup A"})
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("expected creating a taken name to return %v but got %v", ErrDuplicateName, err)
+		}
+
+		other.Name = "Contract Dup A"
+		err = repo.Update(ctx, other)
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("expected renaming to a taken name to return %v but got %v", ErrDuplicateName, err)
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		company := create(t, &domain.Company{Name: "Contract Upd", Type: domain.Corporations, CreatedBy: "tester"})
+		createdAt := company.CreatedAt
+
+		company.Name = "Contract Upd 2"
+		company.NumberOfEmployees = 6
+		company.Type = domain.Cooperative
+		company.UpdatedBy = "editor"
+		err := repo.Update(ctx, company)
+		if err != nil {
+			t.Fatalf("error updating company: %s", err)
+		}
+		if company.UpdatedAt.Before(createdAt) || company.UpdatedBy != "editor" || company.CreatedBy != "tester" {
+			t.Errorf("expected updated_at to move forward and updated_by to be 'editor' but got %+v", company)
+		}
+
+		found, err := repo.Get(ctx, company.ID)
+		if err != nil {
+			t.Fatalf("error getting company: %s", err)
+		}
+		if found.Name != "Contract Upd 2" || found.NumberOfEmployees != 6 || found.Type != domain.Cooperative {
+			t.Errorf("expected the update to be stored but got %+v", found)
+		}
+
+		err = repo.Update(ctx, &domain.Company{ID: uuid.New(), Name: "Contract Upd 3"})
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected updating an unknown company to return %v but got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		company := create(t, &domain.Company{Name: "Contract Del"})
+
+		err := repo.Delete(ctx, company.ID, "tester")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+
+		_, err = repo.Get(ctx, company.ID)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected getting a deleted company to return %v but got %v", ErrRecordNotFound, err)
+		}
+		err = repo.Delete(ctx, company.ID, "tester")
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected deleting a deleted company to return %v but got %v", ErrRecordNotFound, err)
+		}
+		err = repo.Update(ctx, company)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected updating a deleted company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		// The name of a deleted company is free to take, which blocks its
+		// restoration until it is released again.
+		taken := create(t, &domain.Company{Name: "Contract Del"})
+		_, err = repo.Restore(ctx, company.ID, "restorer")
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Errorf("expected restoring a company whose name is taken to return %v but got %v", ErrDuplicateName, err)
+		}
+
+		err = repo.Purge(ctx, taken.ID)
+		if err != nil {
+			t.Fatalf("error purging company: %s", err)
+		}
+
+		restored, err := repo.Restore(ctx, company.ID, "restorer")
+		if err != nil {
+			t.Fatalf("error restoring company: %s", err)
+		}
+		if restored.ID != company.ID || restored.Name != "Contract Del" || restored.UpdatedBy != "restorer" {
+			t.Errorf("expected to restore %+v but got %+v", company, restored)
+		}
+
+		_, err = repo.Get(ctx, company.ID)
+		if err != nil {
+			t.Errorf("error getting restored company: %s", err)
+		}
+		_, err = repo.Restore(ctx, company.ID, "restorer")
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected restoring an active company to return %v but got %v", ErrRecordNotFound, err)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		company := create(t, &domain.Company{Name: "Contract Purge"})
+
+		err := repo.Purge(ctx, company.ID)
+		if err != nil {
+			t.Fatalf("error purging company: %s", err)
+		}
+		err = repo.Purge(ctx, company.ID)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected purging a purged company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		deleted := create(t, &domain.Company{Name: "Contract Purge"})
+		err = repo.Delete(ctx, deleted.ID, "tester")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+
+		_, err = repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("error purging deleted companies: %s", err)
+		}
+		_, err = repo.Restore(ctx, deleted.ID, "restorer")
+		if err != nil {
+			t.Fatalf("expected a company deleted within the retention period to be kept but got %v", err)
+		}
+
+		err = repo.Delete(ctx, deleted.ID, "tester")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+		purged, err := repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatalf("error purging deleted companies: %s", err)
+		}
+		if purged < 1 {
+			t.Errorf("expected the deleted company to be purged but got %d purged", purged)
+		}
+		_, err = repo.Restore(ctx, deleted.ID, "restorer")
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected the purged company to be gone but got %v", err)
+		}
+	})
+
+	t.Run("hierarchy", func(t *testing.T) {
+		hierarchy := func(t *testing.T, read func(context.Context, uuid.UUID) ([]*domain.Company, error), id uuid.UUID) []string {
+			t.Helper()
+			companies, err := read(ctx, id)
+			if err != nil {
+				t.Fatalf("hierarchy returned an error: %s", err)
+			}
+			var names []string
+			for _, company := range companies {
+				names = append(names, company.Name)
+			}
+			return names
+		}
+
+		unknown := uuid.New()
+		err := repo.Create(ctx, &domain.Company{Name: "Contract Orphan", ParentID: &unknown})
+		if !errors.Is(err, ErrParentNotFound) {
+			t.Errorf("expected creating a company with an unknown parent to return %v but got %v", ErrParentNotFound, err)
+		}
+
+		// Companies are created parents first, so that the cleanups purge
+		// them subsidiaries first.
+		root := create(t, &domain.Company{Name: "Contract Root"})
+		kidB := create(t, &domain.Company{Name: "Contract Kid B", ParentID: &root.ID})
+		kidA := create(t, &domain.Company{Name: "Contract Kid A", ParentID: &root.ID})
+		grand := create(t, &domain.Company{Name: "Contract Grand", ParentID: &kidB.ID})
+
+		found, err := repo.Get(ctx, grand.ID)
+		if err != nil {
+			t.Fatalf("error getting company: %s", err)
+		}
+		if found.ParentID == nil || *found.ParentID != kidB.ID {
+			t.Errorf("expected the parent to be stored but got %v", found.ParentID)
+		}
+
+		if got := hierarchy(t, repo.Children, root.ID); !reflect.DeepEqual(got, []string{"Contract Kid A", "Contract Kid B"}) {
+			t.Errorf("expected the children ordered by name but got %v", got)
+		}
+		if got := hierarchy(t, repo.Children, grand.ID); len(got) != 0 {
+			t.Errorf("expected a company without subsidiaries to have no children but got %v", got)
+		}
+		if got := hierarchy(t, repo.Ancestors, grand.ID); !reflect.DeepEqual(got, []string{"Contract Kid B", "Contract Root"}) {
+			t.Errorf("expected the ancestors from the parent up but got %v", got)
+		}
+		if got := hierarchy(t, repo.Subtree, root.ID); !reflect.DeepEqual(got, []string{"Contract Root", "Contract Kid A", "Contract Kid B", "Contract Grand"}) {
+			t.Errorf("expected the subtree level by level but got %v", got)
+		}
+		_, err = repo.Children(ctx, uuid.New())
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected the children of an unknown company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		root.ParentID = &grand.ID
+		err = repo.Update(ctx, root)
+		if !errors.Is(err, ErrHierarchyCycle) {
+			t.Errorf("expected making a company a subsidiary of its subsidiary to return %v but got %v", ErrHierarchyCycle, err)
+		}
+		root.ParentID = nil
+
+		err = repo.Delete(ctx, kidB.ID, "tester")
+		if !errors.Is(err, ErrHasSubsidiaries) {
+			t.Errorf("expected deleting a company with subsidiaries to return %v but got %v", ErrHasSubsidiaries, err)
+		}
+		err = repo.Purge(ctx, kidB.ID)
+		if !errors.Is(err, ErrHasSubsidiaries) {
+			t.Errorf("expected purging a company with subsidiaries to return %v but got %v", ErrHasSubsidiaries, err)
+		}
+
+		grand.ParentID = &kidA.ID
+		err = repo.Update(ctx, grand)
+		if err != nil {
+			t.Fatalf("error moving company: %s", err)
+		}
+		err = repo.Delete(ctx, kidB.ID, "tester")
+		if err != nil {
+			t.Fatalf("expected a company without subsidiaries to be deleted but got %v", err)
+		}
+
+		grand.ParentID = &kidB.ID
+		err = repo.Update(ctx, grand)
+		if !errors.Is(err, ErrParentNotFound) {
+			t.Errorf("expected moving a company under a deleted one to return %v but got %v", ErrParentNotFound, err)
+		}
+		grand.ParentID = &kidA.ID
+
+		// A company cannot be restored under a deleted parent, and loses
+		// its parent once the parent is purged.
+		err = repo.Delete(ctx, grand.ID, "tester")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+		err = repo.Delete(ctx, kidA.ID, "tester")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+		_, err = repo.Restore(ctx, grand.ID, "restorer")
+		if !errors.Is(err, ErrParentNotFound) {
+			t.Errorf("expected restoring a company whose parent is deleted to return %v but got %v", ErrParentNotFound, err)
+		}
+
+		err = repo.Purge(ctx, kidA.ID)
+		if err != nil {
+			t.Fatalf("error purging company: %s", err)
+		}
+		restored, err := repo.Restore(ctx, grand.ID, "restorer")
+		if err != nil {
+			t.Fatalf("expected a company whose parent is purged to be restored but got %v", err)
+		}
+		if restored.ParentID != nil {
+			t.Errorf("expected the company to lose its purged parent but got %v", restored.ParentID)
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		errRollback := errors.New("rollback")
+		err := repo.InTx(ctx, func(tx ports.CompanyRepository) error {
+			err := tx.Create(ctx, &domain.Company{Name: "Contract Tx A"})
+			if err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Errorf("expected the transaction to return %v but got %v", errRollback, err)
+		}
+		if got := names(t, domain.CompanyFilter{Name: "contract tx"}, "", 10); len(got) != 0 {
+			t.Errorf("expected a rolled back transaction to create nothing but got %v", got)
+		}
+
+		var created *domain.Company
+		err = repo.InTx(ctx, func(tx ports.CompanyRepository) error {
+			created = &domain.Company{Name: "Contract Tx B"}
+			return tx.Create(ctx, created)
+		})
+		if err != nil {
+			t.Fatalf("transaction returned an error: %s", err)
+		}
+		t.Cleanup(func() { _ = repo.Purge(ctx, created.ID) })
+
+		if got := names(t, domain.CompanyFilter{Name: "contract tx"}, "", 10); len(got) != 1 || got[0] != "Contract Tx B" {
+			t.Errorf("expected a committed transaction to create 'Contract Tx B' but got %v", got)
+		}
+	})
+
+	t.Run("import", func(t *testing.T) {
+		create(t, &domain.Company{Name: "Contract Imp C"})
+
+		rows := []*domain.Company{
+			{Name: "Contract Imp B", NumberOfEmployees: 1, CreatedBy: "importer"},
+			{Name: "Contract Imp A", NumberOfEmployees: 2, CreatedBy: "importer"},
+			{Name: "Contract Imp B", NumberOfEmployees: 3, CreatedBy: "importer"},
+			{Name: "Contract Imp C", NumberOfEmployees: 4, CreatedBy: "importer"},
+		}
+		i := 0
+		created, err := repo.Import(ctx, func() (*domain.Company, error) {
+			if i == len(rows) {
+				return nil, io.EOF
+			}
+			i++
+			return rows[i-1], nil
+		})
+		if err != nil {
+			t.Fatalf("import returned an error: %s", err)
+		}
+		for _, company := range created {
+			id := company.ID
+			t.Cleanup(func() { _ = repo.Purge(ctx, id) })
+		}
+
+		if len(created) != 2 || created[0].Name != "Contract Imp A" || created[1].Name != "Contract Imp B" {
+			t.Fatalf("expected 'Contract Imp A' and 'Contract Imp B' to be imported but got %v", created)
+		}
+		if created[1].NumberOfEmployees != 1 || created[1].UpdatedBy != "importer" || created[1].ID == uuid.Nil {
+			t.Errorf("expected the first of the duplicate rows to be imported but got %+v", created[1])
+		}
+
+		errRead := errors.New("read")
+		_, err = repo.Import(ctx, func() (*domain.Company, error) {
+			if i == len(rows) {
+				return nil, errRead
+			}
+			i = len(rows)
+			return &domain.Company{Name: "Contract Imp D"}, nil
+		})
+		if !errors.Is(err, errRead) {
+			t.Errorf("expected the import to return %v but got %v", errRead, err)
+		}
+		if got := names(t, domain.CompanyFilter{Name: "contract imp"}, "", 10); len(got) != 3 {
+			t.Errorf("expected a failed import to create nothing but got %v", got)
+		}
+	})
+
+	t.Run("list and export", func(t *testing.T) {
+		create(t, &domain.Company{Name: "Contract Beta", Type: domain.NonProfit, Registered: true})
+		create(t, &domain.Company{Name: "Contract Alpha", Type: domain.NonProfit})
+		create(t, &domain.Company{Name: "Contract Gamma", Type: domain.Cooperative, Registered: true})
+		deleted := create(t, &domain.Company{Name: "Contract Delta", Type: domain.NonProfit})
+		err := repo.Delete(ctx, deleted.ID, "tester")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+
+		all := domain.CompanyFilter{Name: "CONTRACT"}
+		if got := names(t, all, "", 2); len(got) != 2 || got[0] != "Contract Alpha" || got[1] != "Contract Beta" {
+			t.Errorf("expected the first page to hold 'Contract Alpha' and 'Contract Beta' but got %v", got)
+		}
+		if got := names(t, all, "Contract Beta", 2); len(got) != 1 || got[0] != "Contract Gamma" {
+			t.Errorf("expected the second page to hold 'Contract Gamma' but got %v", got)
+		}
+
+		nonProfit := domain.NonProfit
+		registered := true
+		if got := names(t, domain.CompanyFilter{Name: "contract", Type: &nonProfit}, "", 10); len(got) != 2 {
+			t.Errorf("expected 2 non profit companies but got %v", got)
+		}
+		if got := names(t, domain.CompanyFilter{Name: "contract", Registered: &registered}, "", 10); len(got) != 2 {
+			t.Errorf("expected 2 registered companies but got %v", got)
+		}
+
+		var exported []string
+		err = repo.Export(ctx, domain.CompanyFilter{Name: "contract", Type: &nonProfit, Registered: &registered}, func(company *domain.Company) error {
+			exported = append(exported, company.Name)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("export returned an error: %s", err)
+		}
+		if len(exported) != 1 || exported[0] != "Contract Beta" {
+			t.Errorf("expected only 'Contract Beta' to be exported but got %v", exported)
+		}
+
+		errWrite := errors.New("write")
+		err = repo.Export(ctx, all, func(*domain.Company) error { return errWrite })
+		if !errors.Is(err, errWrite) {
+			t.Errorf("expected the export to return %v but got %v", errWrite, err)
+		}
+	})
+
+	t.Run("name wildcards", func(t *testing.T) {
+		for _, name := range []string{"Contract 100%", "Contract 1000", "Contract a_b", "Contract axb", `Contract a\b`} {
+			create(t, &domain.Company{Name: name})
+		}
+
+		testCases := []struct {
+			name     string
+			expected []string
+		}{
+			{"%", []string{"Contract 100%"}},
+			{"_", []string{"Contract a_b"}},
+			{"0%", []string{"Contract 100%"}},
+			{"a_b", []string{"Contract a_b"}},
+			{`\`, []string{`Contract a\b`}},
+			{`a\b`, []string{`Contract a\b`}},
+		}
+
+		for _, tt := range testCases {
+			if got := names(t, domain.CompanyFilter{Name: tt.name}, "", 10); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected %q to match %v literally but got %v", tt.name, tt.expected, got)
+			}
+		}
+	})
+
+	t.Run("attributes", func(t *testing.T) {
+		fintech := create(t, &domain.Company{Name: "Contract Attr A", Attributes: domain.Attributes{
+			"industry": "fintech",
+			"founded":  2010,
+			"listed":   true,
+			"tags":     []any{"payments"},
+		}})
+		create(t, &domain.Company{Name: "Contract Attr B", Attributes: domain.Attributes{"industry": "retail", "founded": "2010"}})
+		create(t, &domain.Company{Name: "Contract Attr C"})
+
+		found, err := repo.Get(ctx, fintech.ID)
+		if err != nil {
+			t.Fatalf("error getting company: %s", err)
+		}
+		expected := domain.Attributes{"industry": "fintech", "founded": float64(2010), "listed": true, "tags": []any{"payments"}}
+		if !reflect.DeepEqual(found.Attributes, expected) {
+			t.Errorf("expected the attributes %v but got %v", expected, found.Attributes)
+		}
+
+		for _, tt := range []struct {
+			filter   map[string]any
+			expected []string
+		}{
+			{map[string]any{"industry": "fintech"}, []string{"Contract Attr A"}},
+			{map[string]any{"founded": float64(2010)}, []string{"Contract Attr A"}},
+			{map[string]any{"founded": "2010"}, []string{"Contract Attr B"}},
+			{map[string]any{"listed": true, "industry": "fintech"}, []string{"Contract Attr A"}},
+			{map[string]any{"listed": false}, nil},
+			{map[string]any{"missing": "x"}, nil},
+			{nil, []string{"Contract Attr A", "Contract Attr B", "Contract Attr C"}},
+		} {
+			got := names(t, domain.CompanyFilter{Name: "contract attr", Attributes: tt.filter}, "", 10)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("%v: expected %v but got %v", tt.filter, tt.expected, got)
+			}
+		}
+
+		found.Attributes = domain.Attributes{"industry": "banking"}
+		err = repo.Update(ctx, found)
+		if err != nil {
+			t.Fatalf("error updating company: %s", err)
+		}
+		found, err = repo.Get(ctx, fintech.ID)
+		if err != nil {
+			t.Fatalf("error getting company: %s", err)
+		}
+		if !reflect.DeepEqual(found.Attributes, domain.Attributes{"industry": "banking"}) {
+			t.Errorf("expected the attributes to be replaced but got %v", found.Attributes)
+		}
+
+		found.Attributes["industry"] = "changed"
+		again, err := repo.Get(ctx, fintech.ID)
+		if err != nil {
+			t.Fatalf("error getting company: %s", err)
+		}
+		if again.Attributes["industry"] != "banking" {
+			t.Errorf("expected the stored attributes to be left alone but got %v", again.Attributes)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		emea := create(t, &domain.Company{Name: "Contract Tag A", Tags: domain.Tags{"ignored"}})
+		apac := create(t, &domain.Company{Name: "Contract Tag B"})
+		create(t, &domain.Company{Name: "Contract Tag C"})
+
+		if emea.Tags != nil {
+			t.Errorf("expected a new company to have no tags but got %v", emea.Tags)
+		}
+
+		tagged, err := repo.UpdateTags(ctx, emea.ID, []string{"contract:tier-1", "contract:emea"}, nil, "tagger")
+		if err != nil {
+			t.Fatalf("error tagging company: %s", err)
+		}
+		if !reflect.DeepEqual(tagged.Tags, domain.Tags{"contract:emea", "contract:tier-1"}) || tagged.UpdatedBy != "tagger" {
+			t.Errorf("expected the company tagged by tagger with its tags sorted but got %+v", tagged)
+		}
+		_, err = repo.UpdateTags(ctx, apac.ID, []string{"contract:apac", "contract:tier-1"}, nil, "tagger")
+		if err != nil {
+			t.Fatalf("error tagging company: %s", err)
+		}
+
+		tagged, err = repo.UpdateTags(ctx, emea.ID, []string{"contract:emea"}, []string{"contract:tier-1", "contract:none"}, "tagger")
+		if err != nil || !reflect.DeepEqual(tagged.Tags, domain.Tags{"contract:emea"}) {
+			t.Errorf("expected tagging again and removing missing tags to be ignored but got %+v, %v", tagged, err)
+		}
+		found, err := repo.Get(ctx, emea.ID)
+		if err != nil || !reflect.DeepEqual(found.Tags, domain.Tags{"contract:emea"}) {
+			t.Errorf("expected the tags of the stored company but got %+v, %v", found, err)
+		}
+
+		found.Tags[0] = "changed"
+		found.Description = "Updated"
+		err = repo.Update(ctx, found)
+		if err != nil || !reflect.DeepEqual(found.Tags, domain.Tags{"contract:emea"}) {
+			t.Errorf("expected an update to leave the tags alone but got %v, %v", found.Tags, err)
+		}
+
+		for _, tt := range []struct {
+			filter   domain.CompanyFilter
+			expected []string
+		}{
+			{domain.CompanyFilter{Tags: []string{"contract:emea"}}, []string{"Contract Tag A"}},
+			{domain.CompanyFilter{Tags: []string{"contract:apac", "contract:tier-1"}}, []string{"Contract Tag B"}},
+			{domain.CompanyFilter{Tags: []string{"contract:emea", "contract:tier-1"}}, nil},
+			{domain.CompanyFilter{AnyTags: []string{"contract:emea", "contract:apac"}}, []string{"Contract Tag A", "Contract Tag B"}},
+			{domain.CompanyFilter{Tags: []string{"contract:tier-1"}, AnyTags: []string{"contract:emea", "contract:apac"}}, []string{"Contract Tag B"}},
+			{domain.CompanyFilter{AnyTags: []string{"contract:none"}}, nil},
+			{domain.CompanyFilter{}, []string{"Contract Tag A", "Contract Tag B", "Contract Tag C"}},
+		} {
+			tt.filter.Name = "contract tag"
+			got := names(t, tt.filter, "", 10)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("%+v: expected %v but got %v", tt.filter, tt.expected, got)
+			}
+		}
+
+		counts := func() map[string]int64 {
+			t.Helper()
+			tags, err := repo.Tags(ctx)
+			if err != nil {
+				t.Fatalf("error listing tags: %s", err)
+			}
+			counts := make(map[string]int64)
+			for i, tag := range tags {
+				if i > 0 && tags[i-1].Name >= tag.Name {
+					t.Errorf("expected the tags ordered by name but got %s after %s", tag.Name, tags[i-1].Name)
+				}
+				if strings.HasPrefix(tag.Name, "contract:") {
+					counts[tag.Name] = tag.Count
+				}
+			}
+			return counts
+		}
+		expected := map[string]int64{"contract:apac": 1, "contract:emea": 1, "contract:tier-1": 1}
+		if got := counts(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected the counts %v but got %v", expected, got)
+		}
+
+		err = repo.Delete(ctx, apac.ID, "")
+		if err != nil {
+			t.Fatalf("error deleting company: %s", err)
+		}
+		expected = map[string]int64{"contract:emea": 1}
+		if got := counts(); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected the tags of deleted companies not to be counted but got %v", got)
+		}
+
+		_, err = repo.UpdateTags(ctx, apac.ID, []string{"contract:emea"}, nil, "tagger")
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected tagging a deleted company to return %v but got %v", ErrRecordNotFound, err)
+		}
+		restored, err := repo.Restore(ctx, apac.ID, "restorer")
+		if err != nil || !reflect.DeepEqual(restored.Tags, domain.Tags{"contract:apac", "contract:tier-1"}) {
+			t.Errorf("expected a restored company to have its tags back but got %+v, %v", restored, err)
+		}
+	})
+
+	t.Run("concurrent creates", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				company := &domain.Company{Name: "Contract Race"}
+				err := repo.Create(ctx, company)
+				if err == nil {
+					t.Cleanup(func() { _ = repo.Purge(ctx, company.ID) })
+				}
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, ErrDuplicateName):
+				t.Errorf("expected concurrent creates to return %v but got %v", ErrDuplicateName, err)
+			}
+		}
+		if created != 1 {
+			t.Errorf("expected exactly one of the concurrent creates to succeed but %d did", created)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := repo.List(cancelled, domain.CompanyFilter{}, "", 10)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected listing with a cancelled context to return %v but got %v", context.Canceled, err)
+		}
+		err = repo.Create(cancelled, &domain.Company{Name: "Contract Ctx"})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected creating with a cancelled context to return %v but got %v", context.Canceled, err)
+		}
+	})
+}
+
+func Test_MemoryCompanyRepository(t *testing.T) {
+	testCompanyRepositoryContract(t, NewMemoryCompanyRepository())
+}
+
+func Test_SQLiteCompanyRepository(t *testing.T) {
+	repo, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer repo.Close()
+
+	testCompanyRepositoryContract(t, repo)
+}
+
+// testCompanyDetailsContract checks the behaviour every address and contact
+// repository must share, against the repository of their companies.
+func testCompanyDetailsContract(t *testing.T, companies ports.CompanyRepository, addresses ports.AddressRepository, contacts ports.ContactRepository) {
+	ctx := context.Background()
+
+	create := func(t *testing.T, name string) *domain.Company {
+		t.Helper()
+		company := &domain.Company{Name: name}
+		err := companies.Create(ctx, company)
+		if err != nil {
+			t.Fatalf("error creating company %q: %s", name, err)
+		}
+		t.Cleanup(func() { _ = companies.Purge(ctx, company.ID) })
+		return company
+	}
+
+	t.Run("addresses", func(t *testing.T) {
+		first := create(t, "Details First")
+		second := create(t, "Details Second")
+
+		home := &domain.Address{CompanyID: first.ID, Label: "HQ", Line1: "1 Main Street", City: "Athens", CountryCode: "GR"}
+		err := addresses.Create(ctx, home)
+		if err != nil {
+			t.Fatalf("error creating address: %s", err)
+		}
+		if home.ID == uuid.Nil || home.CreatedAt.IsZero() || !home.UpdatedAt.Equal(home.CreatedAt) {
+			t.Errorf("expected id, created_at and updated_at to be set on create but got %+v", home)
+		}
+
+		branch := &domain.Address{CompanyID: first.ID, Line1: "2 Side Street", City: "Patras", CountryCode: "GR"}
+		abroad := &domain.Address{CompanyID: second.ID, Line1: "3 High Street", City: "London", CountryCode: "GB"}
+		for _, address := range []*domain.Address{branch, abroad} {
+			err = addresses.Create(ctx, address)
+			if err != nil {
+				t.Fatalf("error creating address: %s", err)
+			}
+		}
+
+		err = addresses.Create(ctx, &domain.Address{CompanyID: uuid.New(), Line1: "Nowhere", City: "Nowhere", CountryCode: "GR"})
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected creating an address of an unknown company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		found, err := addresses.Get(ctx, first.ID, home.ID)
+		if err != nil || found.Label != "HQ" || found.City != "Athens" || !found.CreatedAt.Equal(home.CreatedAt) {
+			t.Errorf("expected to get %+v but got %+v, %v", home, found, err)
+		}
+		_, err = addresses.Get(ctx, second.ID, home.ID)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected getting the address of another company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		home.City = "Thessaloniki"
+		err = addresses.Update(ctx, home)
+		if err != nil || home.City != "Thessaloniki" || home.UpdatedAt.Before(home.CreatedAt) {
+			t.Errorf("expected the address to be updated but got %+v, %v", home, err)
+		}
+		err = addresses.Update(ctx, &domain.Address{ID: home.ID, CompanyID: second.ID, Line1: "Moved", City: "Moved", CountryCode: "GR"})
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected updating the address of another company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		listed, err := addresses.List(ctx, first.ID, second.ID)
+		if err != nil {
+			t.Fatalf("error listing addresses: %s", err)
+		}
+		var cities []string
+		for _, address := range listed {
+			cities = append(cities, address.City)
+		}
+		if !reflect.DeepEqual(cities, []string{"Thessaloniki", "Patras", "London"}) {
+			t.Errorf("expected the addresses oldest first but got %v", cities)
+		}
+
+		err = addresses.Delete(ctx, first.ID, branch.ID)
+		if err != nil {
+			t.Errorf("error deleting address: %s", err)
+		}
+		err = addresses.Delete(ctx, first.ID, branch.ID)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected deleting a deleted address to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		err = companies.Purge(ctx, second.ID)
+		if err != nil {
+			t.Fatalf("error purging company: %s", err)
+		}
+		listed, err = addresses.List(ctx, first.ID, second.ID)
+		if err != nil || len(listed) != 1 || listed[0].ID != home.ID {
+			t.Errorf("expected only the address of the remaining company but got %+v, %v", listed, err)
+		}
+
+		listed, err = addresses.List(ctx)
+		if err != nil || listed == nil || len(listed) != 0 {
+			t.Errorf("expected no addresses of no companies but got %+v, %v", listed, err)
+		}
+	})
+
+	t.Run("contacts", func(t *testing.T) {
+		company := create(t, "Details Contact")
+
+		contact := &domain.Contact{CompanyID: company.ID, Name: "Jane Doe", Email: "jane@example.com", Role: "CFO"}
+		err := contacts.Create(ctx, contact)
+		if err != nil {
+			t.Fatalf("error creating contact: %s", err)
+		}
+
+		err = contacts.Create(ctx, &domain.Contact{CompanyID: uuid.New(), Name: "Nobody"})
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected creating a contact of an unknown company to return %v but got %v", ErrRecordNotFound, err)
+		}
+
+		contact.Phone = "+30 210 1234567"
+		err = contacts.Update(ctx, contact)
+		if err != nil {
+			t.Fatalf("error updating contact: %s", err)
+		}
+
+		found, err := contacts.Get(ctx, company.ID, contact.ID)
+		if err != nil || found.Name != "Jane Doe" || found.Email != "jane@example.com" || found.Phone != "+30 210 1234567" || found.Role != "CFO" {
+			t.Errorf("expected to get %+v but got %+v, %v", contact, found, err)
+		}
+
+		err = companies.Purge(ctx, company.ID)
+		if err != nil {
+			t.Fatalf("error purging company: %s", err)
+		}
+		_, err = contacts.Get(ctx, company.ID, contact.ID)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected the contacts of a purged company to be gone but got %v", err)
+		}
+	})
+}
+
+// testAttributeDefinitionContract checks the behaviour every attribute
+// definition repository must share.
+func testAttributeDefinitionContract(t *testing.T, repo ports.AttributeDefinitionRepository) {
+	ctx := context.Background()
+
+	definition := &domain.AttributeDefinition{
+		Name:        "contract_industry",
+		Description: "The industry of the company",
+		Schema:      []byte(`{"type":"string"}`),
+		CreatedBy:   "tester",
+	}
+	err := repo.Create(ctx, definition)
+	if err != nil {
+		t.Fatalf("error creating definition: %s", err)
+	}
+	t.Cleanup(func() { _ = repo.Delete(ctx, definition.Name) })
+	if definition.CreatedAt.IsZero() || !definition.UpdatedAt.Equal(definition.CreatedAt) {
+		t.Errorf("expected created_at and updated_at to be set on create but got %+v", definition)
+	}
+
+	err = repo.Create(ctx, &domain.AttributeDefinition{Name: "contract_industry", Schema: []byte(`{}`)})
+	if !errors.Is(err, ErrDuplicateAttribute) {
+		t.Errorf("expected creating a taken name to return %v but got %v", ErrDuplicateAttribute, err)
+	}
+
+	found, err := repo.Get(ctx, "contract_industry")
+	if err != nil {
+		t.Fatalf("error getting definition: %s", err)
+	}
+	if found.Description != definition.Description || found.CreatedBy != "tester" || !found.CreatedAt.Equal(definition.CreatedAt) {
+		t.Errorf("expected to get %+v but got %+v", definition, found)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(found.Schema, &schema); err != nil || schema["type"] != "string" {
+		t.Errorf("expected the schema to be stored but got %s", found.Schema)
+	}
+
+	found.Description = "Where the company does business"
+	found.Schema = []byte(`{"type":"string","enum":["fintech","retail"]}`)
+	err = repo.Update(ctx, found)
+	if err != nil {
+		t.Fatalf("error updating definition: %s", err)
+	}
+	if found.UpdatedAt.Before(found.CreatedAt) || found.CreatedBy != "tester" {
+		t.Errorf("expected updated_at to move forward but got %+v", found)
+	}
+
+	other := &domain.AttributeDefinition{Name: "contract_founded", Schema: []byte(`{"type":"integer"}`)}
+	err = repo.Create(ctx, other)
+	if err != nil {
+		t.Fatalf("error creating definition: %s", err)
+	}
+
+	definitions, err := repo.List(ctx)
+	if err != nil {
+		t.Fatalf("error listing definitions: %s", err)
+	}
+	var names []string
+	for _, definition := range definitions {
+		names = append(names, definition.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"contract_founded", "contract_industry"}) {
+		t.Errorf("expected the definitions ordered by name but got %v", names)
+	}
+
+	err = repo.Delete(ctx, "contract_founded")
+	if err != nil {
+		t.Fatalf("error deleting definition: %s", err)
+	}
+	for _, err := range []error{
+		repo.Delete(ctx, "contract_founded"),
+		repo.Update(ctx, other),
+	} {
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("expected changing a deleted definition to return %v but got %v", ErrRecordNotFound, err)
+		}
+	}
+	_, err = repo.Get(ctx, "contract_founded")
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expected getting a deleted definition to return %v but got %v", ErrRecordNotFound, err)
+	}
+}
+
+func Test_MemoryAttributeDefinitions(t *testing.T) {
+	testAttributeDefinitionContract(t, NewMemoryAttributeDefinitionRepository())
+}
+
+func Test_SQLiteAttributeDefinitions(t *testing.T) {
+	repo, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer repo.Close()
+
+	testAttributeDefinitionContract(t, &AttributeDefinitionRepository{DB: repo.DB})
+}
+
+func Test_MemoryCompanyDetails(t *testing.T) {
+	companies := NewMemoryCompanyRepository()
+	testCompanyDetailsContract(t, companies, NewMemoryAddressRepository(companies), NewMemoryContactRepository(companies))
+}
+
+func Test_SQLiteCompanyDetails(t *testing.T) {
+	repo, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer repo.Close()
+
+	testCompanyDetailsContract(t, repo, &AddressRepository{DB: repo.DB}, &ContactRepository{DB: repo.DB})
+}
+
+// testJobRepositoryContract checks the behaviour every job repository must
+// share, taking a job from its creation to its cancellation.
+func testJobRepositoryContract(t *testing.T, repo ports.JobRepository) {
+	ctx := context.Background()
+	job := &domain.Job{
+		ID:        uuid.New(),
+		Type:      "export",
+		Params:    map[string]string{"format": "csv"},
+		CreatedBy: "tester",
+	}
+
+	err := repo.Create(ctx, job)
+	if err != nil {
+		t.Fatalf("error creating job: %s", err)
+	}
+
+	if job.Status != domain.JobQueued || job.Params["format"] != "csv" {
+		t.Errorf("expected a queued job with its params but got %+v", job)
+	}
+
+	claimed, err := repo.Claim(ctx)
+	if err != nil || claimed == nil {
+		t.Fatalf("expected to claim the job but got %v, %v", claimed, err)
+	}
+
+	if claimed.ID != job.ID || claimed.Status != domain.JobRunning || claimed.Attempts != 1 {
+		t.Errorf("expected job %v to be running on its first attempt but got %+v", job.ID, claimed)
+	}
+
+	none, err := repo.Claim(ctx)
+	if err != nil || none != nil {
+		t.Errorf("expected no job left to claim but got %v, %v", none, err)
+	}
+
+	err = repo.Progress(ctx, job.ID, 42)
+	if err != nil {
+		t.Errorf("error recording progress: %s", err)
+	}
+
+	claimed.Status = domain.JobFailed
+	claimed.Error = "boom"
+	err = repo.Finish(ctx, claimed)
+	if err != nil {
+		t.Errorf("error finishing job: %s", err)
+	}
+
+	err = repo.Progress(ctx, job.ID, 43)
+	if err != ErrJobNotRunning {
+		t.Errorf("expected %v once the job finished but got %v", ErrJobNotRunning, err)
+	}
+
+	requeued, err := repo.Requeue(ctx, 3, time.Minute)
+	if err != nil || requeued != 1 {
+		t.Errorf("expected the failed job to be requeued but got %d, %v", requeued, err)
+	}
+
+	cancelled, err := repo.Cancel(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("error cancelling job: %s", err)
+	}
+
+	if cancelled.Status != domain.JobCancelled || cancelled.FinishedAt == nil {
+		t.Errorf("expected the job to be cancelled but got %+v", cancelled)
+	}
+}
+
+func Test_MemoryJobRepository(t *testing.T) {
+	testJobRepositoryContract(t, NewMemoryJobRepository())
+}
+
+func Test_SQLiteJobRepository(t *testing.T) {
+	repo, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer repo.Close()
+
+	testJobRepositoryContract(t, &SQLiteJobRepository{DB: repo.DB})
+}
+
+// testWebhookRepositoryContract checks the behaviour every webhook repository
+// must share, from queueing deliveries to disabling a failing webhook.
+func testWebhookRepositoryContract(t *testing.T, repo ports.WebhookRepository) {
+	ctx := context.Background()
+	webhook := &domain.Webhook{
+		URL:       "https://example.com/hooks",
+		Secret:    "whsec_test",
+		Events:    []string{domain.WebhookCompanyCreated},
+		CreatedBy: "tester",
+	}
+
+	err := repo.Create(ctx, webhook)
+	if err != nil {
+		t.Fatalf("error creating webhook: %s", err)
+	}
+	defer repo.Delete(ctx, webhook.ID)
+
+	if !webhook.Active || webhook.ID == uuid.Nil {
+		t.Errorf("expected an active webhook with an id but got %+v", webhook)
+	}
+
+	webhooks, err := repo.List(ctx, "tester")
+	if err != nil || len(webhooks) != 1 || webhooks[0].Secret != "whsec_test" {
+		t.Errorf("expected the webhook of tester with its secret but got %v, %v", webhooks, err)
+	}
+
+	enqueued, err := repo.Enqueue(ctx, uuid.New(), domain.WebhookCompanyUpdated, []byte(`{}`))
+	if err != nil || enqueued != 0 {
+		t.Errorf("expected no delivery of an unsubscribed event but got %d, %v", enqueued, err)
+	}
+
+	eventID := uuid.New()
+	enqueued, err = repo.Enqueue(ctx, eventID, domain.WebhookCompanyCreated, []byte(`{"id":1}`))
+	if err != nil || enqueued != 1 {
+		t.Fatalf("expected one delivery but got %d, %v", enqueued, err)
+	}
+
+	delivery, claimed, err := repo.Claim(ctx, time.Minute)
+	if err != nil || delivery == nil {
+		t.Fatalf("expected to claim the delivery but got %v, %v", delivery, err)
+	}
+
+	if delivery.EventID != eventID || delivery.Attempts != 1 || string(delivery.Payload) != `{"id":1}` || claimed.ID != webhook.ID {
+		t.Errorf("expected the first attempt of event %v to webhook %v but got %+v", eventID, webhook.ID, delivery)
+	}
+
+	none, _, err := repo.Claim(ctx, time.Minute)
+	if err != nil || none != nil {
+		t.Errorf("expected the leased delivery not to be claimed again but got %v, %v", none, err)
+	}
+
+	delivery.Status = domain.WebhookDeliveryPending
+	delivery.ResponseStatus = 500
+	delivery.Error = "webhook responded with status 500"
+	delivery.NextAttemptAt = time.Now()
+
+	disabled, err := repo.Record(ctx, delivery, 2)
+	if err != nil || disabled {
+		t.Errorf("expected the webhook to stay active after one failure but got %t, %v", disabled, err)
+	}
+
+	delivery, _, err = repo.Claim(ctx, time.Minute)
+	if err != nil || delivery == nil || delivery.Attempts != 2 {
+		t.Fatalf("expected to claim the second attempt but got %v, %v", delivery, err)
+	}
+
+	disabled, err = repo.Record(ctx, delivery, 2)
+	if err != nil || !disabled {
+		t.Errorf("expected the webhook to be disabled after two failures but got %t, %v", disabled, err)
+	}
+
+	stored, err := repo.Get(ctx, webhook.ID)
+	if err != nil {
+		t.Fatalf("error getting webhook: %s", err)
+	}
+	if stored.Active || stored.DisabledAt == nil || stored.ConsecutiveFailures != 2 {
+		t.Errorf("expected a disabled webhook with 2 failures but got %+v", stored)
+	}
+
+	none, _, err = repo.Claim(ctx, time.Minute)
+	if err != nil || none != nil {
+		t.Errorf("expected no delivery to a disabled webhook but got %v, %v", none, err)
+	}
+
+	deliveries, err := repo.Deliveries(ctx, webhook.ID, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("expected one delivery in the log but got %v, %v", deliveries, err)
+	}
+	if deliveries[0].ResponseStatus != 500 || deliveries[0].Status != domain.WebhookDeliveryPending {
+		t.Errorf("expected the pending delivery with its last response but got %+v", deliveries[0])
+	}
+}
+
+func Test_MemoryWebhookRepository(t *testing.T) {
+	testWebhookRepositoryContract(t, NewMemoryWebhookRepository())
+}
+
+func Test_SQLiteWebhookRepository(t *testing.T) {
+	repo, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer repo.Close()
+
+	testWebhookRepositoryContract(t, &SQLiteWebhookRepository{DB: repo.DB})
+}
+
+// testIdempotencyRepositoryContract checks the behaviour every idempotency
+// repository must share, including the takeover of an expired lease.
+func testIdempotencyRepositoryContract(t *testing.T, repo ports.IdempotencyRepository) {
+	ctx := context.Background()
+
+	reserve := func(hash string, lease time.Duration) (*domain.IdempotentRequest, error) {
+		return repo.Reserve(ctx, &domain.IdempotentRequest{
+			Key:         "lease",
+			Subject:     "tester",
+			RequestHash: []byte(hash),
+			ExpiresAt:   time.Now().Add(time.Hour),
+			LockedUntil: time.Now().Add(lease),
+		})
+	}
+
+	stored, err := reserve("a", -time.Minute)
+	if err != nil || stored != nil {
+		t.Fatalf("expected to reserve the key but got %v, %v", stored, err)
+	}
+
+	stored, err = reserve("b", time.Minute)
+	if err != nil || stored == nil || string(stored.RequestHash) != "a" {
+		t.Errorf("expected a different request not to take the key over but got %v, %v", stored, err)
+	}
+
+	stored, err = reserve("a", time.Minute)
+	if err != nil || stored != nil {
+		t.Errorf("expected the retry to take over the expired lease but got %v, %v", stored, err)
+	}
+
+	stored, err = reserve("a", time.Minute)
+	if err != nil || stored == nil || stored.Status != 0 {
+		t.Errorf("expected the key to be held within the lease but got %v, %v", stored, err)
+	}
+
+	err = repo.Extend(ctx, "tester", "lease", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("error extending the lease: %s", err)
+	}
+
+	err = repo.Complete(ctx, &domain.IdempotentRequest{Key: "lease", Subject: "tester", Status: 201})
+	if err != nil {
+		t.Fatalf("error completing the request: %s", err)
+	}
+
+	stored, err = reserve("a", time.Minute)
+	if err != nil || stored == nil || stored.Status != 201 {
+		t.Errorf("expected a completed request not to be taken over but got %v, %v", stored, err)
+	}
+}
+
+func Test_MemoryIdempotencyRepository(t *testing.T) {
+	testIdempotencyRepositoryContract(t, NewMemoryIdempotencyRepository())
+}
+
+func Test_SQLiteIdempotencyRepository(t *testing.T) {
+	repo, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer repo.Close()
+
+	testIdempotencyRepositoryContract(t, &SQLiteIdempotencyRepository{DB: repo.DB})
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// The queries of the hierarchy of companies are shared by the Postgres and
+// SQLite repositories, which both bind $1 and $2 in order.
+
+// hasSubsidiariesQuery reports whether the company $1 has subsidiaries that
+// are not deleted.
+const hasSubsidiariesQuery = `
+	SELECT EXISTS (
+		SELECT 1
+		FROM companies
+		WHERE parent_id = $1 AND deleted_at IS NULL
+	)`
+
+// parentCycleQuery reports whether the company $2 is the company $1 or one of
+// its ancestors, which would make a cycle of $2 becoming a subsidiary of $1.
+const parentCycleQuery = `
+	WITH RECURSIVE ancestors (id, parent_id) AS (
+		SELECT id, parent_id
+		FROM companies
+		WHERE id = $1
+		UNION
+		SELECT companies.id, companies.parent_id
+		FROM companies
+		JOIN ancestors ON companies.id = ancestors.parent_id
+	)
+	SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`
+
+// childrenQuery selects the subsidiaries of the company $1, ordered by name.
+const childrenQuery = `
+	SELECT ` + companyColumns + `
+	FROM companies
+	WHERE parent_id = $1 AND deleted_at IS NULL
+	ORDER BY name`
+
+// ancestorsQuery selects the ancestors of the company $1, its parent first.
+const ancestorsQuery = `
+	WITH RECURSIVE ancestors (id, depth) AS (
+		SELECT parent_id, 1
+		FROM companies
+		WHERE id = $1 AND parent_id IS NOT NULL
+		UNION ALL
+		SELECT companies.parent_id, ancestors.depth + 1
+		FROM companies
+		JOIN ancestors ON companies.id = ancestors.id
+		WHERE companies.parent_id IS NOT NULL
+	)
+	SELECT ` + companyColumns + `
+	FROM companies
+	JOIN ancestors USING (id)
+	WHERE deleted_at IS NULL
+	ORDER BY depth`
+
+// subtreeQuery selects the company $1 and every subsidiary below it, level by
+// level and ordered by name within each level.
+const subtreeQuery = `
+	WITH RECURSIVE subtree (id, depth) AS (
+		SELECT id, 0
+		FROM companies
+		WHERE id = $1
+		UNION ALL
+		SELECT companies.id, subtree.depth + 1
+		FROM companies
+		JOIN subtree ON companies.parent_id = subtree.id
+		WHERE companies.deleted_at IS NULL
+	)
+	SELECT ` + companyColumns + `
+	FROM companies
+	JOIN subtree USING (id)
+	WHERE deleted_at IS NULL
+	ORDER BY depth, name`
+
+// existsQuery selects the company $1 unless it is soft deleted.
+const existsQuery = `
+	SELECT EXISTS (
+		SELECT 1
+		FROM companies
+		WHERE id = $1 AND deleted_at IS NULL
+	)`
+
+// queryHierarchy runs one of the hierarchy queries for the company id. It
+// returns sql.ErrNoRows when the company does not exist, so that a replica
+// lagging behind is not taken for a failing one.
+func queryHierarchy(ctx context.Context, db querier, query string, id uuid.UUID) ([]*domain.Company, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, existsQuery, id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	companies := []*domain.Company{}
+	for rows.Next() {
+		var company domain.Company
+		err = scanCompany(rows, &company)
+		if err != nil {
+			return nil, err
+		}
+		companies = append(companies, &company)
+	}
+	return companies, rows.Err()
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+type IdempotencyRepository struct {
+	DB *sql.DB
+}
+
+func (a *IdempotencyRepository) Reserve(ctx context.Context, req *domain.IdempotentRequest) (*domain.IdempotentRequest, error) {
+	_, err := a.DB.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE subject = $1 AND key = $2 AND expires_at < now()`, req.Subject, req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (key, subject, request_hash, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (subject, key) DO NOTHING`
+
+	result, err := a.DB.ExecContext(ctx, query, req.Key, req.Subject, req.RequestHash, req.ExpiresAt, req.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	// The request holding the key stopped renewing its lease, most likely
+	// because its process died, so this retry takes over.
+	query = `
+		UPDATE idempotency_keys
+		SET expires_at = $4, locked_until = $5
+		WHERE subject = $1 AND key = $2 AND request_hash = $3
+			AND status = 0 AND locked_until < now()`
+
+	result, err = a.DB.ExecContext(ctx, query, req.Subject, req.Key, req.RequestHash, req.ExpiresAt, req.LockedUntil)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	query = `
+		SELECT key, subject, request_hash, expires_at, locked_until, status, header, body
+		FROM idempotency_keys
+		WHERE subject = $1 AND key = $2`
+
+	var existing domain.IdempotentRequest
+	var header []byte
+
+	err = a.DB.QueryRowContext(ctx, query, req.Subject, req.Key).Scan(
+		&existing.Key,
+		&existing.Subject,
+		&existing.RequestHash,
+		&existing.ExpiresAt,
+		&existing.LockedUntil,
+		&existing.Status,
+		&header,
+		&existing.Body,
+	)
+	if err != nil {
+		switch {
+		// The request holding the key was released in the meantime.
+		case errors.Is(err, sql.ErrNoRows):
+			return a.Reserve(ctx, req)
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(header, &existing.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+func (a *IdempotencyRepository) Extend(ctx context.Context, subject, key string, lockedUntil time.Time) error {
+	query := `
+		UPDATE idempotency_keys
+		SET locked_until = $3
+		WHERE subject = $1 AND key = $2 AND status = 0`
+
+	_, err := a.DB.ExecContext(ctx, query, subject, key, lockedUntil)
+	return err
+}
+
+func (a *IdempotencyRepository) Complete(ctx context.Context, req *domain.IdempotentRequest) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status = $3, header = $4, body = $5
+		WHERE subject = $1 AND key = $2`
+
+	header, err := json.Marshal(req.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.DB.ExecContext(ctx, query, req.Subject, req.Key, req.Status, header, req.Body)
+	return err
+}
+
+func (a *IdempotencyRepository) Release(ctx context.Context, subject, key string) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE subject = $1 AND key = $2`
+
+	_, err := a.DB.ExecContext(ctx, query, subject, key)
+	return err
+}
+
+func (a *IdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at < now()`
+
+	result, err := a.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
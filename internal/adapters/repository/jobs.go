@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+var (
+	ErrJobNotRunning = errors.New("job is not running")
+)
+
+type JobRepository struct {
+	DB *sql.DB
+}
+
+const jobColumns = `id, type, status, params, progress, result_type, error, attempts,
+		created_by, created_at, updated_at, started_at, finished_at`
+
+func scanJob(row rowScanner, job *domain.Job) error {
+	var params []byte
+
+	err := row.Scan(
+		&job.ID,
+		&job.Type,
+		&job.Status,
+		&params,
+		&job.Progress,
+		&job.ResultType,
+		&job.Error,
+		&job.Attempts,
+		&job.CreatedBy,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(params, &job.Params)
+}
+
+func (a *JobRepository) Create(ctx context.Context, job *domain.Job) error {
+	query := `
+		INSERT INTO jobs (id, type, params, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + jobColumns
+
+	params, err := json.Marshal(job.Params)
+	if err != nil {
+		return err
+	}
+
+	return scanJob(a.DB.QueryRowContext(ctx, query, job.ID, job.Type, params, job.CreatedBy), job)
+}
+
+func (a *JobRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE id = $1`
+
+	var job domain.Job
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, id), &job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+// Claim uses SKIP LOCKED so that concurrent workers, of this or another
+// replica, never claim the same job.
+func (a *JobRepository) Claim(ctx context.Context) (*domain.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, progress = 0, error = '',
+			started_at = now(), heartbeat_at = now(), updated_at = now()
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE status = 'queued'
+			ORDER BY created_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	var job domain.Job
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query), &job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+func (a *JobRepository) Progress(ctx context.Context, id uuid.UUID, progress int64) error {
+	query := `
+		UPDATE jobs
+		SET progress = $2, heartbeat_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'running'`
+
+	result, err := a.DB.ExecContext(ctx, query, id, progress)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobNotRunning
+	}
+
+	return nil
+}
+
+func (a *JobRepository) Finish(ctx context.Context, job *domain.Job) error {
+	query := `
+		UPDATE jobs
+		SET status = $2, progress = $3, result_type = $4, error = $5,
+			finished_at = now(), updated_at = now()
+		WHERE id = $1 AND status = 'running'
+		RETURNING ` + jobColumns
+
+	args := []any{job.ID, job.Status, job.Progress, job.ResultType, job.Error}
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, args...), job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrJobNotRunning
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Cancel cancels a queued or running job. Cancelling a job that is already
+// finished leaves it untouched.
+func (a *JobRepository) Cancel(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN status IN ('queued', 'running') THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status IN ('queued', 'running') THEN now() ELSE finished_at END,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING ` + jobColumns
+
+	var job domain.Job
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, id), &job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+func (a *JobRepository) Requeue(ctx context.Context, maxAttempts int, stale time.Duration) (int64, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'queued', updated_at = now()
+		WHERE attempts < $1
+		AND (status = 'failed' OR (status = 'running' AND heartbeat_at < now() - make_interval(secs => $2)))`
+
+	result, err := a.DB.ExecContext(ctx, query, maxAttempts, stale.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
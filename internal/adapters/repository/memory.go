@@ -0,0 +1,587 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// memoryCompany is a stored company along with when it was soft deleted.
+type memoryCompany struct {
+	domain.Company
+	deletedAt *time.Time
+	deletedBy string
+}
+
+// copy returns a copy of the stored company that shares none of its
+// attributes and tags.
+func (c *memoryCompany) copy() *domain.Company {
+	company := c.Company
+	company.Attributes = company.Attributes.Clone()
+	company.Tags = append(domain.Tags(nil), company.Tags...)
+	return &company
+}
+
+// MemoryCompanyRepository keeps companies in memory with the same semantics as
+// the Postgres repository. It is safe for concurrent use. Transactions hold
+// the lock for their whole duration and work on a copy of the companies that
+// replaces the original on commit.
+type MemoryCompanyRepository struct {
+	mu        *sync.Mutex
+	companies map[uuid.UUID]*memoryCompany
+	// inTx is set on the copies bound to a transaction, which already hold
+	// the lock.
+	inTx bool
+}
+
+func NewMemoryCompanyRepository() *MemoryCompanyRepository {
+	return &MemoryCompanyRepository{
+		mu:        &sync.Mutex{},
+		companies: make(map[uuid.UUID]*memoryCompany),
+	}
+}
+
+// lock acquires the lock, unless the repository is bound to a transaction,
+// and returns the function that releases it.
+func (a *MemoryCompanyRepository) lock() func() {
+	if a.inTx {
+		return func() {}
+	}
+	a.mu.Lock()
+	return a.mu.Unlock
+}
+
+// active returns the company with the given id unless it does not exist or is
+// soft deleted.
+func (a *MemoryCompanyRepository) active(id uuid.UUID) (*memoryCompany, bool) {
+	company, ok := a.companies[id]
+	if !ok || company.deletedAt != nil {
+		return nil, false
+	}
+	return company, true
+}
+
+// nameTaken reports whether a company other than the given one holds the name.
+func (a *MemoryCompanyRepository) nameTaken(name string, id uuid.UUID) bool {
+	for _, company := range a.companies {
+		if company.deletedAt == nil && company.Name == name && company.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSubsidiaries reports whether a company that is not deleted has the given
+// parent.
+func (a *MemoryCompanyRepository) hasSubsidiaries(id uuid.UUID) bool {
+	for _, company := range a.companies {
+		if company.deletedAt == nil && company.ParentID != nil && *company.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// checkParent returns ErrParentNotFound unless the parent of the company
+// exists, and ErrHierarchyCycle when the company is the parent or one of its
+// ancestors.
+func (a *MemoryCompanyRepository) checkParent(company *domain.Company) error {
+	if company.ParentID == nil {
+		return nil
+	}
+	if _, ok := a.active(*company.ParentID); !ok {
+		return ErrParentNotFound
+	}
+
+	for id := company.ParentID; id != nil; id = a.companies[*id].ParentID {
+		if *id == company.ID {
+			return ErrHierarchyCycle
+		}
+	}
+	return nil
+}
+
+// detach removes the parent of the companies whose parent was purged.
+func (a *MemoryCompanyRepository) detach() {
+	for _, company := range a.companies {
+		if company.ParentID != nil && a.companies[*company.ParentID] == nil {
+			company.ParentID = nil
+		}
+	}
+}
+
+func (a *MemoryCompanyRepository) InTx(ctx context.Context, fn func(ports.CompanyRepository) error) error {
+	if a.inTx {
+		return fn(a)
+	}
+
+	defer a.lock()()
+
+	companies := make(map[uuid.UUID]*memoryCompany, len(a.companies))
+	for id, company := range a.companies {
+		company := *company
+		companies[id] = &company
+	}
+
+	err := fn(&MemoryCompanyRepository{mu: a.mu, companies: companies, inTx: true})
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	a.companies = companies
+	return nil
+}
+
+func (a *MemoryCompanyRepository) Create(ctx context.Context, company *domain.Company) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.lock()()
+
+	return a.create(company)
+}
+
+func (a *MemoryCompanyRepository) create(company *domain.Company) error {
+	if company.ParentID != nil {
+		if _, ok := a.active(*company.ParentID); !ok {
+			return ErrParentNotFound
+		}
+	}
+	if a.nameTaken(company.Name, uuid.Nil) {
+		return ErrDuplicateName
+	}
+
+	company.ID = uuid.New()
+	company.CreatedAt = time.Now().UTC()
+	company.UpdatedAt = company.CreatedAt
+	company.UpdatedBy = company.CreatedBy
+	company.Tags = nil
+
+	stored := &memoryCompany{Company: *company}
+	stored.Attributes = company.Attributes.Clone()
+	a.companies[company.ID] = stored
+	return nil
+}
+
+func (a *MemoryCompanyRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	company, ok := a.active(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return company.copy(), nil
+}
+
+func (a *MemoryCompanyRepository) Update(ctx context.Context, company *domain.Company) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.lock()()
+
+	stored, ok := a.active(company.ID)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	err := a.checkParent(company)
+	if err != nil {
+		return err
+	}
+	if a.nameTaken(company.Name, company.ID) {
+		return ErrDuplicateName
+	}
+
+	stored.Name = company.Name
+	stored.Description = company.Description
+	stored.NumberOfEmployees = company.NumberOfEmployees
+	stored.Registered = company.Registered
+	stored.Type = company.Type
+	stored.ParentID = company.ParentID
+	stored.Attributes = company.Attributes.Clone()
+	stored.UpdatedAt = time.Now().UTC()
+	stored.UpdatedBy = company.UpdatedBy
+
+	*company = *stored.copy()
+	return nil
+}
+
+// Delete soft deletes the company, recording when and by whom it was deleted,
+// unless it has subsidiaries.
+func (a *MemoryCompanyRepository) Delete(ctx context.Context, id uuid.UUID, deletedBy string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.lock()()
+
+	company, ok := a.active(id)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if a.hasSubsidiaries(id) {
+		return ErrHasSubsidiaries
+	}
+
+	now := time.Now().UTC()
+	company.deletedAt = &now
+	company.deletedBy = deletedBy
+	return nil
+}
+
+// Restore brings back a soft deleted company, unless its parent is no longer
+// there or another company took its name in the meantime.
+func (a *MemoryCompanyRepository) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	company, ok := a.companies[id]
+	if !ok || company.deletedAt == nil {
+		return nil, ErrRecordNotFound
+	}
+	if company.ParentID != nil {
+		if _, ok := a.active(*company.ParentID); !ok {
+			return nil, ErrParentNotFound
+		}
+	}
+	if a.nameTaken(company.Name, id) {
+		return nil, ErrDuplicateName
+	}
+
+	company.deletedAt = nil
+	company.deletedBy = ""
+	company.UpdatedAt = time.Now().UTC()
+	company.UpdatedBy = restoredBy
+
+	return company.copy(), nil
+}
+
+// Purge permanently removes the company, whether it was soft deleted or not,
+// unless it has subsidiaries. Soft deleted subsidiaries lose their parent.
+func (a *MemoryCompanyRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.lock()()
+
+	if _, ok := a.companies[id]; !ok {
+		return ErrRecordNotFound
+	}
+	if a.hasSubsidiaries(id) {
+		return ErrHasSubsidiaries
+	}
+	delete(a.companies, id)
+	a.detach()
+	return nil
+}
+
+// PurgeDeleted permanently removes every company soft deleted before the given
+// time and returns how many were removed. Their soft deleted subsidiaries lose
+// their parent.
+func (a *MemoryCompanyRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	defer a.lock()()
+
+	var purged int64
+	for id, company := range a.companies {
+		if company.deletedAt != nil && company.deletedAt.Before(before) {
+			delete(a.companies, id)
+			purged++
+		}
+	}
+	a.detach()
+	return purged, nil
+}
+
+// Import creates the companies returned by next, until it returns io.EOF, in a
+// single transaction and returns the companies that were created, ordered by
+// name. Companies whose name is already taken, by a stored company or by an
+// earlier company of the import, are skipped.
+func (a *MemoryCompanyRepository) Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	var created []*domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*MemoryCompanyRepository)
+		for {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			company, err := next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if tx.create(company) == nil {
+				created = append(created, company)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(created, func(i, j int) bool {
+		return created[i].Name < created[j].Name
+	})
+	return created, nil
+}
+
+// Children returns the subsidiaries of the company, ordered by name.
+func (a *MemoryCompanyRepository) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	if _, ok := a.active(id); !ok {
+		return nil, ErrRecordNotFound
+	}
+	return a.children(id), nil
+}
+
+// children returns the subsidiaries of the company, ordered by name.
+func (a *MemoryCompanyRepository) children(id uuid.UUID) []*domain.Company {
+	companies := []*domain.Company{}
+	for _, company := range a.companies {
+		if company.deletedAt == nil && company.ParentID != nil && *company.ParentID == id {
+			companies = append(companies, company.copy())
+		}
+	}
+
+	sort.Slice(companies, func(i, j int) bool {
+		return companies[i].Name < companies[j].Name
+	})
+	return companies
+}
+
+// Ancestors returns the parent of the company, its parent and so on up to the
+// top of the group.
+func (a *MemoryCompanyRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	company, ok := a.active(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	companies := []*domain.Company{}
+	for company.ParentID != nil {
+		company, ok = a.active(*company.ParentID)
+		if !ok {
+			break
+		}
+		companies = append(companies, company.copy())
+	}
+	return companies, nil
+}
+
+// Subtree returns the company followed by its subsidiaries, theirs and so on,
+// level by level.
+func (a *MemoryCompanyRepository) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	company, ok := a.active(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	companies := []*domain.Company{company.copy()}
+	for i := 0; i < len(companies); i++ {
+		companies = append(companies, a.children(companies[i].ID)...)
+	}
+	return companies, nil
+}
+
+// matches reports whether the company is not deleted and matches the name,
+// which like in Postgres is matched regardless of case, type, registered,
+// attributes and tags filters.
+func (c *memoryCompany) matches(filter domain.CompanyFilter) bool {
+	switch {
+	case c.deletedAt != nil:
+		return false
+	case !strings.Contains(strings.ToLower(c.Name), strings.ToLower(filter.Name)):
+		return false
+	case filter.Type != nil && c.Type != *filter.Type:
+		return false
+	case filter.Registered != nil && c.Registered != *filter.Registered:
+		return false
+	}
+
+	for name, value := range domain.Attributes(filter.Attributes).Clone() {
+		stored, ok := c.Attributes[name]
+		if !ok || !reflect.DeepEqual(stored, value) {
+			return false
+		}
+	}
+
+	for _, tag := range filter.Tags {
+		if !c.hasTag(tag) {
+			return false
+		}
+	}
+	if len(filter.AnyTags) == 0 {
+		return true
+	}
+	for _, tag := range filter.AnyTags {
+		if c.hasTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *memoryCompany) hasTag(tag string) bool {
+	for _, t := range c.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns up to limit companies matching the filter, ordered by name,
+// starting after the company named after. A negative limit returns them all.
+func (a *MemoryCompanyRepository) list(filter domain.CompanyFilter, after string, limit int) []*domain.Company {
+	var companies []*domain.Company
+	for _, company := range a.companies {
+		if company.Name > after && company.matches(filter) {
+			companies = append(companies, company.copy())
+		}
+	}
+
+	sort.Slice(companies, func(i, j int) bool {
+		return companies[i].Name < companies[j].Name
+	})
+	if limit >= 0 && len(companies) > limit {
+		companies = companies[:limit]
+	}
+	return companies
+}
+
+// List returns up to limit companies matching the filter, ordered by name,
+// starting after the company named after.
+func (a *MemoryCompanyRepository) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	return a.list(filter, after, limit), nil
+}
+
+// Export calls fn for every company matching the filter, ordered by name. The
+// companies are copied before fn is called so that the lock is not held while
+// they are written out.
+func (a *MemoryCompanyRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	unlock := a.lock()
+	companies := a.list(filter, "", -1)
+	unlock()
+
+	for _, company := range companies {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err := fn(company)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateTags adds and removes tags of the company, keeping them sorted.
+func (a *MemoryCompanyRepository) UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	stored, ok := a.active(id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	// skip holds the tags removed and those already kept, so that each is
+	// kept once.
+	skip := make(map[string]bool, len(remove))
+	for _, tag := range remove {
+		skip[tag] = true
+	}
+
+	// The tags are replaced rather than changed in place, since the copies
+	// of transactions share them.
+	var tags domain.Tags
+	for _, tag := range append(append([]string(nil), stored.Tags...), add...) {
+		if !skip[tag] {
+			skip[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	stored.Tags = tags
+	stored.UpdatedAt = time.Now().UTC()
+	stored.UpdatedBy = updatedBy
+	return stored.copy(), nil
+}
+
+// Tags returns the tags of the companies that are not deleted, with the
+// number of companies that have each, ordered by name.
+func (a *MemoryCompanyRepository) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.lock()()
+
+	counts := make(map[string]int64)
+	for _, company := range a.companies {
+		if company.deletedAt != nil {
+			continue
+		}
+		for _, tag := range company.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]*domain.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &domain.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
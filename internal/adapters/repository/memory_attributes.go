@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// MemoryAttributeDefinitionRepository keeps the definitions of the custom
+// attributes of companies in memory. It is safe for concurrent use.
+type MemoryAttributeDefinitionRepository struct {
+	mu          sync.Mutex
+	definitions map[string]*domain.AttributeDefinition
+}
+
+func NewMemoryAttributeDefinitionRepository() *MemoryAttributeDefinitionRepository {
+	return &MemoryAttributeDefinitionRepository{
+		definitions: make(map[string]*domain.AttributeDefinition),
+	}
+}
+
+// copyDefinition returns a copy of the definition that does not share its
+// schema.
+func copyDefinition(definition *domain.AttributeDefinition) *domain.AttributeDefinition {
+	copied := *definition
+	copied.Schema = append([]byte(nil), definition.Schema...)
+	return &copied
+}
+
+func (d *MemoryAttributeDefinitionRepository) Create(ctx context.Context, definition *domain.AttributeDefinition) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.definitions[definition.Name]; ok {
+		return ErrDuplicateAttribute
+	}
+
+	definition.CreatedAt = time.Now().UTC()
+	definition.UpdatedAt = definition.CreatedAt
+
+	d.definitions[definition.Name] = copyDefinition(definition)
+	return nil
+}
+
+func (d *MemoryAttributeDefinitionRepository) Get(ctx context.Context, name string) (*domain.AttributeDefinition, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	definition, ok := d.definitions[name]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyDefinition(definition), nil
+}
+
+// Update changes the description and schema of the definition.
+func (d *MemoryAttributeDefinitionRepository) Update(ctx context.Context, definition *domain.AttributeDefinition) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stored, ok := d.definitions[definition.Name]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	updated := copyDefinition(stored)
+	updated.Description = definition.Description
+	updated.Schema = append([]byte(nil), definition.Schema...)
+	updated.UpdatedAt = time.Now().UTC()
+	d.definitions[definition.Name] = updated
+
+	*definition = *copyDefinition(updated)
+	return nil
+}
+
+func (d *MemoryAttributeDefinitionRepository) Delete(ctx context.Context, name string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.definitions[name]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(d.definitions, name)
+	return nil
+}
+
+// List returns every definition, ordered by name.
+func (d *MemoryAttributeDefinitionRepository) List(ctx context.Context) ([]*domain.AttributeDefinition, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	definitions := make([]*domain.AttributeDefinition, 0, len(d.definitions))
+	for _, definition := range d.definitions {
+		definitions = append(definitions, copyDefinition(definition))
+	}
+
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions, nil
+}
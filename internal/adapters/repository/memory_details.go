@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// MemoryAddressRepository keeps the addresses of the companies of a
+// MemoryCompanyRepository in memory, sharing its lock. Addresses go away with
+// their company when it is purged.
+type MemoryAddressRepository struct {
+	companies *MemoryCompanyRepository
+	addresses []*domain.Address
+}
+
+func NewMemoryAddressRepository(companies *MemoryCompanyRepository) *MemoryAddressRepository {
+	return &MemoryAddressRepository{companies: companies}
+}
+
+// prune drops the addresses of the companies that have been purged.
+func (a *MemoryAddressRepository) prune() {
+	addresses := a.addresses[:0]
+	for _, address := range a.addresses {
+		if _, ok := a.companies.companies[address.CompanyID]; ok {
+			addresses = append(addresses, address)
+		}
+	}
+	a.addresses = addresses
+}
+
+func (a *MemoryAddressRepository) find(companyID, id uuid.UUID) (int, bool) {
+	for i, address := range a.addresses {
+		if address.ID == id && address.CompanyID == companyID {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (a *MemoryAddressRepository) Create(ctx context.Context, address *domain.Address) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.companies.lock()()
+
+	if _, ok := a.companies.companies[address.CompanyID]; !ok {
+		return ErrRecordNotFound
+	}
+	a.prune()
+
+	now := time.Now().UTC()
+	address.ID = uuid.New()
+	address.CreatedAt = now
+	address.UpdatedAt = now
+
+	stored := *address
+	a.addresses = append(a.addresses, &stored)
+	return nil
+}
+
+func (a *MemoryAddressRepository) Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Address, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	i, ok := a.find(companyID, id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	address := *a.addresses[i]
+	return &address, nil
+}
+
+func (a *MemoryAddressRepository) Update(ctx context.Context, address *domain.Address) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	i, ok := a.find(address.CompanyID, address.ID)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	address.CreatedAt = a.addresses[i].CreatedAt
+	address.UpdatedAt = time.Now().UTC()
+
+	stored := *address
+	a.addresses[i] = &stored
+	return nil
+}
+
+func (a *MemoryAddressRepository) Delete(ctx context.Context, companyID, id uuid.UUID) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	i, ok := a.find(companyID, id)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	a.addresses = append(a.addresses[:i], a.addresses[i+1:]...)
+	return nil
+}
+
+// List returns the addresses of the companies, oldest first.
+func (a *MemoryAddressRepository) List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Address, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	wanted := make(map[uuid.UUID]bool, len(companyIDs))
+	for _, id := range companyIDs {
+		wanted[id] = true
+	}
+
+	addresses := []*domain.Address{}
+	for _, address := range a.addresses {
+		if wanted[address.CompanyID] {
+			address := *address
+			addresses = append(addresses, &address)
+		}
+	}
+	return addresses, nil
+}
+
+// MemoryContactRepository keeps the contacts of the companies of a
+// MemoryCompanyRepository in memory, sharing its lock. Contacts go away with
+// their company when it is purged.
+type MemoryContactRepository struct {
+	companies *MemoryCompanyRepository
+	contacts  []*domain.Contact
+}
+
+func NewMemoryContactRepository(companies *MemoryCompanyRepository) *MemoryContactRepository {
+	return &MemoryContactRepository{companies: companies}
+}
+
+// prune drops the contacts of the companies that have been purged.
+func (a *MemoryContactRepository) prune() {
+	contacts := a.contacts[:0]
+	for _, contact := range a.contacts {
+		if _, ok := a.companies.companies[contact.CompanyID]; ok {
+			contacts = append(contacts, contact)
+		}
+	}
+	a.contacts = contacts
+}
+
+func (a *MemoryContactRepository) find(companyID, id uuid.UUID) (int, bool) {
+	for i, contact := range a.contacts {
+		if contact.ID == id && contact.CompanyID == companyID {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (a *MemoryContactRepository) Create(ctx context.Context, contact *domain.Contact) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.companies.lock()()
+
+	if _, ok := a.companies.companies[contact.CompanyID]; !ok {
+		return ErrRecordNotFound
+	}
+	a.prune()
+
+	now := time.Now().UTC()
+	contact.ID = uuid.New()
+	contact.CreatedAt = now
+	contact.UpdatedAt = now
+
+	stored := *contact
+	a.contacts = append(a.contacts, &stored)
+	return nil
+}
+
+func (a *MemoryContactRepository) Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Contact, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	i, ok := a.find(companyID, id)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	contact := *a.contacts[i]
+	return &contact, nil
+}
+
+func (a *MemoryContactRepository) Update(ctx context.Context, contact *domain.Contact) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	i, ok := a.find(contact.CompanyID, contact.ID)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	contact.CreatedAt = a.contacts[i].CreatedAt
+	contact.UpdatedAt = time.Now().UTC()
+
+	stored := *contact
+	a.contacts[i] = &stored
+	return nil
+}
+
+func (a *MemoryContactRepository) Delete(ctx context.Context, companyID, id uuid.UUID) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	i, ok := a.find(companyID, id)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	a.contacts = append(a.contacts[:i], a.contacts[i+1:]...)
+	return nil
+}
+
+// List returns the contacts of the companies, oldest first.
+func (a *MemoryContactRepository) List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Contact, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	defer a.companies.lock()()
+	a.prune()
+
+	wanted := make(map[uuid.UUID]bool, len(companyIDs))
+	for _, id := range companyIDs {
+		wanted[id] = true
+	}
+
+	contacts := []*domain.Contact{}
+	for _, contact := range a.contacts {
+		if wanted[contact.CompanyID] {
+			contact := *contact
+			contacts = append(contacts, &contact)
+		}
+	}
+	return contacts, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// MemoryIdempotencyRepository keeps idempotency keys in memory with the same
+// semantics as the Postgres repository. It is safe for concurrent use, but
+// its keys are only seen by the requests served by this process.
+type MemoryIdempotencyRepository struct {
+	mu       sync.Mutex
+	requests map[[2]string]*domain.IdempotentRequest
+}
+
+func NewMemoryIdempotencyRepository() *MemoryIdempotencyRepository {
+	return &MemoryIdempotencyRepository{
+		requests: make(map[[2]string]*domain.IdempotentRequest),
+	}
+}
+
+// copyIdempotentRequest returns a copy of the request that does not share its
+// hash, header or body.
+func copyIdempotentRequest(req *domain.IdempotentRequest) *domain.IdempotentRequest {
+	copied := *req
+	copied.RequestHash = append([]byte(nil), req.RequestHash...)
+	copied.Header = req.Header.Clone()
+	copied.Body = append([]byte(nil), req.Body...)
+	return &copied
+}
+
+func (a *MemoryIdempotencyRepository) Reserve(ctx context.Context, req *domain.IdempotentRequest) (*domain.IdempotentRequest, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	id := [2]string{req.Subject, req.Key}
+	if stored, ok := a.requests[id]; ok && !stored.ExpiresAt.Before(now) {
+		// The request holding the key stopped renewing its lease, so this
+		// retry takes over.
+		takeOver := stored.Status == 0 && stored.LockedUntil.Before(now) && bytes.Equal(stored.RequestHash, req.RequestHash)
+		if !takeOver {
+			return copyIdempotentRequest(stored), nil
+		}
+	}
+
+	reserved := copyIdempotentRequest(req)
+	reserved.Status, reserved.Header, reserved.Body = 0, http.Header{}, nil
+	a.requests[id] = reserved
+	return nil, nil
+}
+
+func (a *MemoryIdempotencyRepository) Extend(ctx context.Context, subject, key string, lockedUntil time.Time) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if stored, ok := a.requests[[2]string{subject, key}]; ok && stored.Status == 0 {
+		stored.LockedUntil = lockedUntil
+	}
+	return nil
+}
+
+func (a *MemoryIdempotencyRepository) Complete(ctx context.Context, req *domain.IdempotentRequest) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if stored, ok := a.requests[[2]string{req.Subject, req.Key}]; ok {
+		completed := copyIdempotentRequest(req)
+		stored.Status, stored.Header, stored.Body = completed.Status, completed.Header, completed.Body
+	}
+	return nil
+}
+
+func (a *MemoryIdempotencyRepository) Release(ctx context.Context, subject, key string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.requests, [2]string{subject, key})
+	return nil
+}
+
+func (a *MemoryIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	for id, stored := range a.requests {
+		if stored.ExpiresAt.Before(now) {
+			delete(a.requests, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+type memoryJob struct {
+	job         domain.Job
+	heartbeatAt time.Time
+}
+
+// MemoryJobRepository keeps jobs in memory with the same semantics as the
+// Postgres repository. It is safe for concurrent use, but its jobs are only
+// seen by the workers of this process.
+type MemoryJobRepository struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*memoryJob
+}
+
+func NewMemoryJobRepository() *MemoryJobRepository {
+	return &MemoryJobRepository{
+		jobs: make(map[uuid.UUID]*memoryJob),
+	}
+}
+
+// copyJob returns a copy of the job that does not share its params or times.
+func copyJob(job *domain.Job) *domain.Job {
+	copied := *job
+	copied.Params = make(map[string]string, len(job.Params))
+	for name, value := range job.Params {
+		copied.Params[name] = value
+	}
+	if job.StartedAt != nil {
+		startedAt := *job.StartedAt
+		copied.StartedAt = &startedAt
+	}
+	if job.FinishedAt != nil {
+		finishedAt := *job.FinishedAt
+		copied.FinishedAt = &finishedAt
+	}
+	return &copied
+}
+
+func (a *MemoryJobRepository) Create(ctx context.Context, job *domain.Job) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	stored := copyJob(&domain.Job{
+		ID:        job.ID,
+		Type:      job.Type,
+		Status:    domain.JobQueued,
+		Params:    job.Params,
+		CreatedBy: job.CreatedBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	a.jobs[job.ID] = &memoryJob{job: *stored}
+
+	*job = *copyJob(stored)
+	return nil
+}
+
+func (a *MemoryJobRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored, ok := a.jobs[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyJob(&stored.job), nil
+}
+
+func (a *MemoryJobRepository) Claim(ctx context.Context) (*domain.Job, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var oldest *memoryJob
+	for _, stored := range a.jobs {
+		if stored.job.Status == domain.JobQueued && (oldest == nil || stored.job.CreatedAt.Before(oldest.job.CreatedAt)) {
+			oldest = stored
+		}
+	}
+	if oldest == nil {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	oldest.job.Status = domain.JobRunning
+	oldest.job.Attempts++
+	oldest.job.Progress = 0
+	oldest.job.Error = ""
+	oldest.job.StartedAt = &now
+	oldest.job.UpdatedAt = now
+	oldest.heartbeatAt = now
+
+	return copyJob(&oldest.job), nil
+}
+
+func (a *MemoryJobRepository) Progress(ctx context.Context, id uuid.UUID, progress int64) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored, ok := a.jobs[id]
+	if !ok || stored.job.Status != domain.JobRunning {
+		return ErrJobNotRunning
+	}
+
+	stored.job.Progress = progress
+	stored.job.UpdatedAt = time.Now().UTC()
+	stored.heartbeatAt = stored.job.UpdatedAt
+	return nil
+}
+
+func (a *MemoryJobRepository) Finish(ctx context.Context, job *domain.Job) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored, ok := a.jobs[job.ID]
+	if !ok || stored.job.Status != domain.JobRunning {
+		return ErrJobNotRunning
+	}
+
+	now := time.Now().UTC()
+	stored.job.Status = job.Status
+	stored.job.Progress = job.Progress
+	stored.job.ResultType = job.ResultType
+	stored.job.Error = job.Error
+	stored.job.FinishedAt = &now
+	stored.job.UpdatedAt = now
+
+	*job = *copyJob(&stored.job)
+	return nil
+}
+
+// Cancel cancels a queued or running job. Cancelling a job that is already
+// finished leaves it untouched.
+func (a *MemoryJobRepository) Cancel(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored, ok := a.jobs[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	now := time.Now().UTC()
+	if stored.job.Status == domain.JobQueued || stored.job.Status == domain.JobRunning {
+		stored.job.Status = domain.JobCancelled
+		stored.job.FinishedAt = &now
+	}
+	stored.job.UpdatedAt = now
+
+	return copyJob(&stored.job), nil
+}
+
+func (a *MemoryJobRepository) Requeue(ctx context.Context, maxAttempts int, stale time.Duration) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	var requeued int64
+	for _, stored := range a.jobs {
+		if stored.job.Attempts >= maxAttempts {
+			continue
+		}
+		if stored.job.Status == domain.JobFailed || (stored.job.Status == domain.JobRunning && stored.heartbeatAt.Before(now.Add(-stale))) {
+			stored.job.Status = domain.JobQueued
+			stored.job.UpdatedAt = now
+			requeued++
+		}
+	}
+	return requeued, nil
+}
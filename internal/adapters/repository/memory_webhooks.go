@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// MemoryWebhookRepository keeps webhooks and their deliveries in memory with
+// the same semantics as the Postgres repository. It is safe for concurrent
+// use, but its deliveries are only sent by the workers of this process.
+type MemoryWebhookRepository struct {
+	mu         sync.Mutex
+	webhooks   map[uuid.UUID]*domain.Webhook
+	deliveries map[uuid.UUID]*domain.WebhookDelivery
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		webhooks:   make(map[uuid.UUID]*domain.Webhook),
+		deliveries: make(map[uuid.UUID]*domain.WebhookDelivery),
+	}
+}
+
+// copyWebhook returns a copy of the webhook that does not share its events or
+// times.
+func copyWebhook(webhook *domain.Webhook) *domain.Webhook {
+	copied := *webhook
+	copied.Events = append([]string{}, webhook.Events...)
+	if webhook.DisabledAt != nil {
+		disabledAt := *webhook.DisabledAt
+		copied.DisabledAt = &disabledAt
+	}
+	return &copied
+}
+
+// copyDelivery returns a copy of the delivery that does not share its payload
+// or times.
+func copyDelivery(delivery *domain.WebhookDelivery) *domain.WebhookDelivery {
+	copied := *delivery
+	copied.Payload = append([]byte(nil), delivery.Payload...)
+	if delivery.DeliveredAt != nil {
+		deliveredAt := *delivery.DeliveredAt
+		copied.DeliveredAt = &deliveredAt
+	}
+	return &copied
+}
+
+func (a *MemoryWebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	webhook.ID = uuid.New()
+	webhook.Events = nonNil(webhook.Events)
+	webhook.Active = true
+	webhook.ConsecutiveFailures = 0
+	webhook.DisabledAt = nil
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+
+	a.webhooks[webhook.ID] = copyWebhook(webhook)
+	return nil
+}
+
+func (a *MemoryWebhookRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	webhook, ok := a.webhooks[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return copyWebhook(webhook), nil
+}
+
+func (a *MemoryWebhookRepository) List(ctx context.Context, createdBy string) ([]*domain.Webhook, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	webhooks := []*domain.Webhook{}
+	for _, webhook := range a.webhooks {
+		if createdBy == "" || webhook.CreatedBy == createdBy {
+			webhooks = append(webhooks, copyWebhook(webhook))
+		}
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (a *MemoryWebhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored, ok := a.webhooks[webhook.ID]
+	if !ok {
+		return ErrRecordNotFound
+	}
+
+	updated := copyWebhook(webhook)
+	stored.URL = updated.URL
+	stored.Events = updated.Events
+	stored.Active = updated.Active
+	stored.ConsecutiveFailures = updated.ConsecutiveFailures
+	stored.DisabledAt = updated.DisabledAt
+	stored.UpdatedAt = time.Now().UTC()
+
+	*webhook = *copyWebhook(stored)
+	return nil
+}
+
+// Delete removes the webhook along with its deliveries.
+func (a *MemoryWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.webhooks[id]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(a.webhooks, id)
+	for deliveryID, delivery := range a.deliveries {
+		if delivery.WebhookID == id {
+			delete(a.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (a *MemoryWebhookRepository) Enqueue(ctx context.Context, eventID uuid.UUID, event string, payload []byte) (int64, error) {
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	var enqueued int64
+	for _, webhook := range a.webhooks {
+		if !webhook.Active || !webhook.Subscribed(event) {
+			continue
+		}
+
+		delivery := &domain.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       payload,
+			Status:        domain.WebhookDeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		a.deliveries[delivery.ID] = copyDelivery(delivery)
+		enqueued++
+	}
+	return enqueued, nil
+}
+
+// Claim leases the delivery that has been due the longest, so that it is due
+// again once the lease runs out unless its attempt is recorded.
+func (a *MemoryWebhookRepository) Claim(ctx context.Context, lease time.Duration) (*domain.WebhookDelivery, *domain.Webhook, error) {
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	var due *domain.WebhookDelivery
+	for _, delivery := range a.deliveries {
+		if delivery.Status != domain.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if webhook, ok := a.webhooks[delivery.WebhookID]; !ok || !webhook.Active {
+			continue
+		}
+		if due == nil || delivery.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = delivery
+		}
+	}
+	if due == nil {
+		return nil, nil, nil
+	}
+
+	due.Attempts++
+	due.NextAttemptAt = now.Add(lease)
+	due.UpdatedAt = now
+
+	return copyDelivery(due), copyWebhook(a.webhooks[due.WebhookID]), nil
+}
+
+func (a *MemoryWebhookRepository) Record(ctx context.Context, delivery *domain.WebhookDelivery, disableAfter int) (bool, error) {
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stored, ok := a.deliveries[delivery.ID]
+	if !ok {
+		return false, ErrRecordNotFound
+	}
+
+	now := time.Now().UTC()
+	stored.Status = delivery.Status
+	stored.ResponseStatus = delivery.ResponseStatus
+	stored.Error = delivery.Error
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.DeliveredAt = nil
+	if delivery.Status == domain.WebhookDeliverySucceeded {
+		stored.DeliveredAt = &now
+	}
+	stored.UpdatedAt = now
+	*delivery = *copyDelivery(stored)
+
+	webhook, ok := a.webhooks[stored.WebhookID]
+	if !ok {
+		return false, ErrRecordNotFound
+	}
+
+	if delivery.Status == domain.WebhookDeliverySucceeded {
+		webhook.ConsecutiveFailures = 0
+		webhook.UpdatedAt = now
+		return false, nil
+	}
+
+	webhook.ConsecutiveFailures++
+	webhook.UpdatedAt = now
+	if webhook.Active && webhook.ConsecutiveFailures >= disableAfter {
+		webhook.Active = false
+		webhook.DisabledAt = &now
+		return true, nil
+	}
+	return false, nil
+}
+
+func (a *MemoryWebhookRepository) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for _, delivery := range a.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, copyDelivery(delivery))
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
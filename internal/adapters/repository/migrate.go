@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrSchemaAhead   = errors.New("database schema is ahead of the migrations")
+	ErrSchemaDirty   = errors.New("database schema is dirty")
+	ErrUnknownSchema = errors.New("unknown schema version")
+)
+
+// Migration changes the schema from the previous version to Version, and back.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations of fsys, named
+// {version}_{name}.{up|down}.sql, and returns them ordered by version. Every
+// migration must come with both its up and down files.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			return nil, fmt.Errorf("unexpected migration file %q", entry.Name())
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid version of migration file %q", entry.Name())
+		}
+
+		sql, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", migration.Name, match[2], version)
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies migrations to a Postgres database, recording the version of
+// the schema in the schema_migrations table, which is also what the migrate
+// CLI uses. Replicas migrating at once take turns through an advisory lock.
+// Every migration runs in a transaction of its own.
+type Migrator struct {
+	DB         *sql.DB
+	migrations []Migration
+	logger     *log.Logger
+}
+
+func NewMigrator(db *sql.DB, migrations []Migration, logger *log.Logger) *Migrator {
+	return &Migrator{DB: db, migrations: migrations, logger: logger}
+}
+
+// Latest returns the version the migrations bring the schema to.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the current version of the schema, which is 0 before any
+// migration is applied, and whether a migration failed halfway through.
+func (m *Migrator) Version(ctx context.Context) (version uint, dirty bool, err error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	return m.version(ctx, conn)
+}
+
+// Check returns an error unless the schema can be used by this version of the
+// service, i.e. it is neither dirty nor ahead of the migrations.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	return m.check(version, dirty)
+}
+
+func (m *Migrator) check(version uint, dirty bool) error {
+	switch {
+	case dirty:
+		return fmt.Errorf("%w at version %d", ErrSchemaDirty, version)
+	case version > m.Latest():
+		return fmt.Errorf("%w: the schema is at version %d and the latest migration is %d", ErrSchemaAhead, version, m.Latest())
+	case version != 0 && m.index(version) < 0:
+		return fmt.Errorf("%w %d", ErrUnknownSchema, version)
+	}
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn, current uint) error {
+		previous := uint(0)
+		for _, migration := range m.migrations {
+			if migration.Version < current {
+				previous = migration.Version
+			}
+		}
+		return m.migrate(ctx, conn, current, previous)
+	})
+}
+
+// To applies or reverts migrations until the schema is at the given version.
+// Version 0 reverts every migration.
+func (m *Migrator) To(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("%w %d", ErrUnknownSchema, version)
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn, current uint) error {
+		return m.migrate(ctx, conn, current, version)
+	})
+}
+
+// Force records the given version as the current one without applying any
+// migration and clears the dirty flag, e.g. to adopt a database whose schema
+// was created otherwise or to recover from a failed migration by hand.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && m.index(version) < 0 {
+		return fmt.Errorf("%w %d", ErrUnknownSchema, version)
+	}
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return m.withLock(ctx, conn, func() error {
+		_, _, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return m.apply(ctx, conn, "", version)
+	})
+}
+
+func (m *Migrator) index(version uint) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// locked calls fn with a connection holding the migration lock and the
+// current version of the schema, once it is known to be usable.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, current uint) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return m.withLock(ctx, conn, func() error {
+		current, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		err = m.check(current, dirty)
+		if err != nil {
+			return err
+		}
+		return fn(conn, current)
+	})
+}
+
+// withLock calls fn while conn holds the advisory lock that serializes
+// migrations across replicas.
+func (m *Migrator) withLock(ctx context.Context, conn *sql.Conn, fn func() error) error {
+	_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext('schema_migrations'))`)
+	if err != nil {
+		return fmt.Errorf("locking the schema: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext('schema_migrations'))`)
+
+	return fn()
+}
+
+// version returns the version of the schema, creating the table that records
+// it when missing.
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (uint, bool, error) {
+	_, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var version int64
+	var dirty bool
+
+	err = conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return uint(version), dirty, err
+}
+
+// setVersion replaces the version of the schema. Version 0 leaves the table
+// empty, like the migrate CLI does.
+func setVersion(ctx context.Context, tx *sql.Tx, version uint) error {
+	_, err := tx.ExecContext(ctx, `TRUNCATE schema_migrations`)
+	if err != nil || version == 0 {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)`, version)
+	return err
+}
+
+// migrate applies or reverts the migrations between the current and the target
+// version one at a time.
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, current, target uint) error {
+	for current < target {
+		migration := m.migrations[m.index(current)+1]
+		err := m.apply(ctx, conn, migration.Up, migration.Version)
+		if err != nil {
+			return fmt.Errorf("applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		m.logger.Printf("applied migration %d_%s", migration.Version, migration.Name)
+		current = migration.Version
+	}
+
+	for current > target {
+		i := m.index(current)
+		migration := m.migrations[i]
+		previous := uint(0)
+		if i > 0 {
+			previous = m.migrations[i-1].Version
+		}
+
+		err := m.apply(ctx, conn, migration.Down, previous)
+		if err != nil {
+			return fmt.Errorf("reverting migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		m.logger.Printf("reverted migration %d_%s", migration.Version, migration.Name)
+		current = previous
+	}
+
+	return nil
+}
+
+// apply runs the SQL of a migration, if any, and records the version it leads
+// to in a single transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, query string, version uint) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if query != "" {
+		_, err = tx.ExecContext(ctx, query)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setVersion(ctx, tx, version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
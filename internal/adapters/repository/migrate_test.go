@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/petrostrak/xm-companies/db"
+)
+
+func Test_LoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"000002_second.up.sql":   {Data: []byte("up 2")},
+		"000002_second.down.sql": {Data: []byte("down 2")},
+		"000010_tenth.up.sql":    {Data: []byte("up 10")},
+		"000010_tenth.down.sql":  {Data: []byte("down 10")},
+		"000001_first.down.sql":  {Data: []byte("down 1")},
+		"000001_first.up.sql":    {Data: []byte("up 1")},
+	})
+	if err != nil {
+		t.Fatalf("error loading migrations: %s", err)
+	}
+
+	expected := []Migration{
+		{Version: 1, Name: "first", Up: "up 1", Down: "down 1"},
+		{Version: 2, Name: "second", Up: "up 2", Down: "down 2"},
+		{Version: 10, Name: "tenth", Up: "up 10", Down: "down 10"},
+	}
+	if len(migrations) != len(expected) {
+		t.Fatalf("expected %d migrations but got %+v", len(expected), migrations)
+	}
+	for i := range expected {
+		if migrations[i] != expected[i] {
+			t.Errorf("expected migration %+v but got %+v", expected[i], migrations[i])
+		}
+	}
+
+	if latest := NewMigrator(nil, migrations, nil).Latest(); latest != 10 {
+		t.Errorf("expected the latest version to be 10 but got %d", latest)
+	}
+}
+
+func Test_LoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		err   string
+	}{
+		{
+			"missing down",
+			fstest.MapFS{"000001_first.up.sql": {Data: []byte("up")}},
+			"migration 1_first must have both an up and a down file",
+		},
+		{
+			"shared version",
+			fstest.MapFS{"000001_first.up.sql": {Data: []byte("up")}, "000001_other.down.sql": {Data: []byte("down")}},
+			`migrations "first" and "other" share version 1`,
+		},
+		{
+			"unexpected file",
+			fstest.MapFS{"README.md": {Data: []byte("readme")}},
+			`unexpected migration file "README.md"`,
+		},
+		{
+			"version zero",
+			fstest.MapFS{"000000_zero.up.sql": {Data: []byte("up")}},
+			`invalid version of migration file "000000_zero.up.sql"`,
+		},
+	}
+
+	for _, e := range tests {
+		t.Run(e.name, func(t *testing.T) {
+			_, err := LoadMigrations(e.files)
+			if err == nil || err.Error() != e.err {
+				t.Errorf("expected error %q but got %v", e.err, err)
+			}
+		})
+	}
+}
+
+func Test_EmbeddedMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(db.Migrations())
+	if err != nil {
+		t.Fatalf("error loading the embedded migrations: %s", err)
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != uint(i+1) {
+			t.Errorf("expected migration %d_%s to have version %d", migration.Version, migration.Name, i+1)
+		}
+	}
+}
@@ -3,115 +3,457 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
 )
 
 var (
 	ErrRecordNotFound = errors.New("record not Found")
+	ErrDuplicateName  = errors.New("a company with this name already exists")
+	// ErrParentNotFound is returned when the parent of a company does not
+	// exist or is soft deleted.
+	ErrParentNotFound = errors.New("the parent company could not be found")
+	// ErrHierarchyCycle is returned when a company would become a subsidiary
+	// of one of its own subsidiaries.
+	ErrHierarchyCycle = errors.New("a company cannot be a subsidiary of its own subsidiary")
+	// ErrHasSubsidiaries is returned when deleting or purging a company that
+	// still has subsidiaries.
+	ErrHasSubsidiaries = errors.New("the company has subsidiaries")
+	// ErrDuplicateAttribute is returned when creating an attribute definition
+	// whose name is already taken.
+	ErrDuplicateAttribute = errors.New("an attribute with this name already exists")
 )
 
 type PostgresRepository struct {
 	*CompanyRepository
+	*JobRepository
+	*IdempotencyRepository
+	*WebhookRepository
+	*AddressRepository
+	*ContactRepository
+	*AttributeDefinitionRepository
 }
 
-var dsn = "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC connect_timeout=5"
+// PostgresConfig configures the connection pool to Postgres.
+type PostgresConfig struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	DBName   string
+	// SSLMode is one of the sslmode values of lib/pq: "disable", "require",
+	// "verify-ca" or "verify-full".
+	SSLMode string
 
-func NewPostgresRepository() *PostgresRepository {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
 
-	db, err := sql.Open("postgres", fmt.Sprintf(dsn,
-		os.Getenv("POSTGRES_HOST"),
-		os.Getenv("POSTGRES_PORT"),
-		os.Getenv("POSTGRES_USER"),
-		os.Getenv("POSTGRES_PASSWORD"),
-		os.Getenv("POSTGRES_DB_NAME"),
-	))
-	if err != nil {
-		return nil
+	// ConnectTimeout bounds every attempt to connect, unless zero.
+	// ConnectRetries is how many more attempts are made on startup while
+	// Postgres is unreachable, waiting ConnectBackoff before the first retry
+	// and twice as long before every next one.
+	ConnectTimeout time.Duration
+	ConnectRetries int
+	ConnectBackoff time.Duration
+
+	// Replicas are the connection strings of read replicas, which serve the
+	// reads of companies with the same pool settings as the primary. They are
+	// checked every ReplicaHealthInterval.
+	Replicas              []string
+	ReplicaHealthInterval time.Duration
+}
+
+func (c PostgresConfig) validate() error {
+	var missing []string
+	if c.Host == "" {
+		missing = append(missing, "host")
+	}
+	if c.User == "" {
+		missing = append(missing, "user")
+	}
+	if c.DBName == "" {
+		missing = append(missing, "database name")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("postgres %s must be set", strings.Join(missing, ", "))
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
+	switch c.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("unknown postgres ssl mode %q", c.SSLMode)
+	}
 
-	duration, err := time.ParseDuration("15m")
-	if err != nil {
-		return nil
+	switch {
+	case c.Port < 1 || c.Port > 65535:
+		return fmt.Errorf("postgres port %d is out of range", c.Port)
+	case c.MaxOpenConns < 0 || c.MaxIdleConns < 0:
+		return errors.New("postgres pool sizes must not be negative")
+	case c.ConnectTimeout < 0 || c.ConnectBackoff < 0 || c.ConnMaxIdleTime < 0 || c.ConnMaxLifetime < 0 || c.ReplicaHealthInterval < 0:
+		return errors.New("postgres timeouts must not be negative")
+	case c.ConnectRetries < 0:
+		return errors.New("postgres connect retries must not be negative")
+	}
+	return nil
+}
+
+// dsn returns the connection string of lib/pq for the config, quoting the
+// values that need it.
+func (c PostgresConfig) dsn() string {
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", strconv.Itoa(c.Port)},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.DBName},
+		{"sslmode", c.SSLMode},
+		{"timezone", "UTC"},
+		{"connect_timeout", strconv.Itoa(int(math.Ceil(c.ConnectTimeout.Seconds())))},
+	}
+
+	var dsn []string
+	for _, param := range params {
+		dsn = append(dsn, fmt.Sprintf("%s='%s'", param.key, quote.Replace(param.value)))
 	}
-	db.SetConnMaxIdleTime(duration)
+	return strings.Join(dsn, " ")
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// NewPostgresRepository connects to Postgres, retrying as configured while it
+// is unreachable, and returns the repositories backed by it.
+func NewPostgresRepository(config PostgresConfig) (*PostgresRepository, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
 
-	err = db.PingContext(ctx)
+	db, err := config.open(config.dsn())
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("opening postgres connection pool: %w", err)
+	}
+
+	backoff := config.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err = ping(db, config.ConnectTimeout)
+		if err == nil {
+			break
+		}
+		if attempt == config.ConnectRetries {
+			db.Close()
+			return nil, fmt.Errorf("connecting to postgres at %s:%d as %s after %d attempt(s): %w",
+				config.Host, config.Port, config.User, attempt+1, err)
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	var replicas *ReplicaPool
+	if len(config.Replicas) > 0 {
+		replicas, err = config.openReplicas()
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
 	}
 
 	return &PostgresRepository{
-		&CompanyRepository{db},
+		&CompanyRepository{DB: db, replicas: replicas},
+		&JobRepository{DB: db},
+		&IdempotencyRepository{DB: db},
+		&WebhookRepository{DB: db},
+		&AddressRepository{DB: db},
+		&ContactRepository{DB: db},
+		&AttributeDefinitionRepository{DB: db},
+	}, nil
+}
+
+func (c PostgresConfig) open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
 	}
+
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	return db, nil
+}
+
+// openReplicas opens the read replicas. Replicas that cannot be reached do not
+// stop the service; they serve reads once they pass a health check.
+func (c PostgresConfig) openReplicas() (*ReplicaPool, error) {
+	var dbs []*sql.DB
+	for i, dsn := range c.Replicas {
+		db, err := c.open(dsn)
+		if err != nil {
+			for _, db := range dbs {
+				db.Close()
+			}
+			return nil, fmt.Errorf("opening postgres replica %d: %w", i+1, err)
+		}
+		dbs = append(dbs, db)
+	}
+
+	pool := newReplicaPool(dbs, c.ConnectTimeout)
+	pool.check()
+	if c.ReplicaHealthInterval > 0 {
+		go pool.monitor(c.ReplicaHealthInterval)
+	}
+	return pool, nil
+}
+
+// Close closes the connections to the primary and the replicas.
+func (r *PostgresRepository) Close() error {
+	var err error
+	if r.CompanyRepository.replicas != nil {
+		err = r.CompanyRepository.replicas.Close()
+	}
+	return errors.Join(err, r.CompanyRepository.DB.Close())
+}
+
+func ping(db *sql.DB, timeout time.Duration) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return db.PingContext(ctx)
 }
 
 type CompanyRepository struct {
 	DB *sql.DB
+	tx *sql.Tx
+	// replicas serve the reads made outside of transactions, if set.
+	replicas *ReplicaPool
 }
 
-func (a *CompanyRepository) Create(company *domain.Company) error {
-	query := `
-		INSERT INTO companies (name, description, number_of_employees, registered, type)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, name, description, number_of_employees, registered, type`
+// Replicas returns the read replicas, or nil when there are none.
+func (a *CompanyRepository) Replicas() *ReplicaPool {
+	return a.replicas
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// db returns the transaction the repository is bound to, if any, or else the
+// connection pool.
+func (a *CompanyRepository) db() querier {
+	if a.tx != nil {
+		return a.tx
+	}
+	return a.DB
+}
+
+// replica returns the replica to read from, or nil when reads must go to the
+// primary because the repository is bound to a transaction, ctx is pinned to
+// the primary or there is no healthy replica.
+func (a *CompanyRepository) replica(ctx context.Context) *replica {
+	if a.tx != nil || a.replicas == nil || UsesPrimary(ctx) {
+		return nil
+	}
+	return a.replicas.pick()
+}
+
+// read runs fn against a replica, if any, and runs it again against the
+// primary when the replica fails.
+func (a *CompanyRepository) read(ctx context.Context, fn func(querier) error) error {
+	if r := a.replica(ctx); r != nil {
+		err := fn(r.db)
+		if !r.failed(ctx, err) {
+			return err
+		}
+	}
+	return fn(a.db())
+}
+
+// InTx runs fn against a copy of the repository bound to a single transaction,
+// committing when fn succeeds and rolling back otherwise.
+func (a *CompanyRepository) InTx(ctx context.Context, fn func(ports.CompanyRepository) error) error {
+	if a.tx != nil {
+		return fn(a)
+	}
+
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(&CompanyRepository{DB: a.DB, tx: tx})
+	if err != nil {
+		// A transaction is rolled back by itself once its context is done.
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return contextErr(ctx, tx.Commit())
+}
+
+// contextErr returns the error of ctx in place of err once ctx is done, since
+// the driver reports the queries it cancelled with errors of its own.
+func contextErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
 
-	args := []any{company.Name, company.Description, company.NumberOfEmployees, company.Registered, company.Type}
+// companyColumns selects the columns of a company along with its tags, which
+// Postgres and SQLite both aggregate with string_agg.
+const companyColumns = `id, name, description, number_of_employees, registered, type, parent_id,
+		attributes, (
+			SELECT string_agg(tag, ',' ORDER BY tag)
+			FROM company_tags
+			WHERE company_id = companies.id
+		) AS tags, created_at, updated_at, created_by, updated_by`
 
-	return a.DB.QueryRow(query, args...).Scan(
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanCompany(row rowScanner, company *domain.Company) error {
+	return row.Scan(
 		&company.ID,
 		&company.Name,
 		&company.Description,
 		&company.NumberOfEmployees,
 		&company.Registered,
 		&company.Type,
+		&company.ParentID,
+		&company.Attributes,
+		&company.Tags,
+		&company.CreatedAt,
+		&company.UpdatedAt,
+		&company.CreatedBy,
+		&company.UpdatedBy,
 	)
 }
 
-func (a *CompanyRepository) Get(id uuid.UUID) (*domain.Company, error) {
+// Create stores the company. The parent of a subsidiary is locked until the
+// company is stored, so that it cannot be deleted in the meantime.
+func (a *CompanyRepository) Create(ctx context.Context, company *domain.Company) error {
+	if company.ParentID == nil {
+		return a.create(ctx, company)
+	}
+
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*CompanyRepository)
+
+		err := tx.lockParent(ctx, *company.ParentID)
+		if err != nil {
+			return err
+		}
+		return tx.create(ctx, company)
+	})
+}
+
+func (a *CompanyRepository) create(ctx context.Context, company *domain.Company) error {
 	query := `
-		SELECT id, name, description, number_of_employees, registered, type
+		INSERT INTO companies (name, description, number_of_employees, registered, type, parent_id, attributes,
+			created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		RETURNING ` + companyColumns
+
+	args := []any{
+		company.Name,
+		company.Description,
+		company.NumberOfEmployees,
+		company.Registered,
+		company.Type,
+		company.ParentID,
+		company.Attributes,
+		company.CreatedBy,
+	}
+
+	err := scanCompany(a.db().QueryRowContext(ctx, query, args...), company)
+	if isUniqueViolation(err) {
+		return ErrDuplicateName
+	}
+	return contextErr(ctx, err)
+}
+
+func (a *CompanyRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	query := `
+		SELECT ` + companyColumns + `
 		FROM companies
-		WHERE id = $1`
+		WHERE id = $1 AND deleted_at IS NULL`
 
 	var company domain.Company
 
-	err := a.DB.QueryRow(query, id).Scan(
-		&company.ID,
-		&company.Name,
-		&company.Description,
-		&company.NumberOfEmployees,
-		&company.Registered,
-		&company.Type,
-	)
+	err := a.read(ctx, func(db querier) error {
+		return scanCompany(db.QueryRowContext(ctx, query, id), &company)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
 			return nil, ErrRecordNotFound
 		default:
-			return nil, err
+			return nil, contextErr(ctx, err)
 		}
 	}
 	return &company, nil
 }
 
-func (a *CompanyRepository) Update(company *domain.Company) error {
+// Update changes the company. Subsidiaries are updated while their parent is
+// locked, and one at a time, so that concurrent updates cannot make a cycle out
+// of the hierarchy.
+func (a *CompanyRepository) Update(ctx context.Context, company *domain.Company) error {
+	if company.ParentID == nil {
+		return a.update(ctx, company)
+	}
+
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*CompanyRepository)
+
+		_, err := tx.tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('companies_parent_id'))`)
+		if err != nil {
+			return contextErr(ctx, err)
+		}
+
+		err = tx.lockParent(ctx, *company.ParentID)
+		if err != nil {
+			return err
+		}
+
+		var cycle bool
+		err = tx.tx.QueryRowContext(ctx, parentCycleQuery, *company.ParentID, company.ID).Scan(&cycle)
+		if err != nil {
+			return contextErr(ctx, err)
+		}
+		if cycle {
+			return ErrHierarchyCycle
+		}
+
+		return tx.update(ctx, company)
+	})
+}
+
+func (a *CompanyRepository) update(ctx context.Context, company *domain.Company) error {
 	query := `
 		UPDATE companies
-		SET name = $1, description = $2, number_of_employees = $3, registered = $4, type = $5
-		WHERE id = $6
-		RETURNING id, name, description, number_of_employees, registered, type`
+		SET name = $1, description = $2, number_of_employees = $3, registered = $4, type = $5,
+			parent_id = $6, attributes = $7, updated_at = now(), updated_by = $8
+		WHERE id = $9 AND deleted_at IS NULL
+		RETURNING ` + companyColumns
 
 	args := []any{
 		company.Name,
@@ -119,37 +461,449 @@ func (a *CompanyRepository) Update(company *domain.Company) error {
 		company.NumberOfEmployees,
 		company.Registered,
 		company.Type,
+		company.ParentID,
+		company.Attributes,
+		company.UpdatedBy,
 		company.ID,
 	}
 
-	return a.DB.QueryRow(query, args...).Scan(
-		&company.ID,
-		&company.Name,
-		&company.Description,
-		&company.NumberOfEmployees,
-		&company.Registered,
-		&company.Type,
-	)
+	err := scanCompany(a.db().QueryRowContext(ctx, query, args...), company)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrRecordNotFound
+	case isUniqueViolation(err):
+		return ErrDuplicateName
+	}
+	return contextErr(ctx, err)
+}
+
+// Delete soft deletes the company, recording when and by whom it was deleted.
+// Soft deleted companies are excluded from every read until restored or purged.
+// Companies that have subsidiaries are not deleted.
+func (a *CompanyRepository) Delete(ctx context.Context, id uuid.UUID, deletedBy string) error {
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*CompanyRepository)
+
+		err := tx.lockLeaf(ctx, id, false)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE companies
+			SET deleted_at = now(), deleted_by = $2
+			WHERE id = $1`
+
+		_, err = tx.tx.ExecContext(ctx, query, id, deletedBy)
+		return contextErr(ctx, err)
+	})
+}
+
+// lockParent locks the parent of a company until the end of the transaction,
+// so that it cannot be deleted in the meantime, or returns ErrParentNotFound
+// when it does not exist.
+func (a *CompanyRepository) lockParent(ctx context.Context, id uuid.UUID) error {
+	query := `
+		SELECT id
+		FROM companies
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR SHARE`
+
+	err := a.tx.QueryRowContext(ctx, query, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrParentNotFound
+	}
+	return contextErr(ctx, err)
+}
+
+// lockLeaf locks the company, which may be soft deleted when withDeleted is
+// set, until the end of the transaction, so that no subsidiary can be added to
+// it in the meantime, and makes sure that it has none.
+func (a *CompanyRepository) lockLeaf(ctx context.Context, id uuid.UUID, withDeleted bool) error {
+	query := `
+		SELECT id
+		FROM companies
+		WHERE id = $1 AND (deleted_at IS NULL OR $2)
+		FOR UPDATE`
+
+	err := a.tx.QueryRowContext(ctx, query, id, withDeleted).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+
+	var hasSubsidiaries bool
+	err = a.tx.QueryRowContext(ctx, hasSubsidiariesQuery, id).Scan(&hasSubsidiaries)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+	if hasSubsidiaries {
+		return ErrHasSubsidiaries
+	}
+	return nil
 }
 
-func (a *CompanyRepository) Delete(id uuid.UUID) error {
+// Restore brings back a soft deleted company. Subsidiaries are only restored
+// while their parent exists, which is locked until they are.
+func (a *CompanyRepository) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	var company *domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*CompanyRepository)
+
+		var parentID *uuid.UUID
+		err := tx.tx.QueryRowContext(ctx, `SELECT parent_id FROM companies WHERE id = $1 AND deleted_at IS NOT NULL`, id).Scan(&parentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		if err != nil {
+			return contextErr(ctx, err)
+		}
+
+		if parentID != nil {
+			err = tx.lockParent(ctx, *parentID)
+			if err != nil {
+				return err
+			}
+		}
+
+		company, err = tx.restore(ctx, id, restoredBy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return company, nil
+}
+
+func (a *CompanyRepository) restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	query := `
+		UPDATE companies
+		SET deleted_at = NULL, deleted_by = NULL, updated_at = now(), updated_by = $2
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + companyColumns
+
+	var company domain.Company
+
+	err := scanCompany(a.db().QueryRowContext(ctx, query, id, restoredBy), &company)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		case isUniqueViolation(err):
+			return nil, ErrDuplicateName
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &company, nil
+}
+
+// Purge permanently removes the company, whether it was soft deleted or not,
+// unless it has subsidiaries. Soft deleted subsidiaries lose their parent.
+func (a *CompanyRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*CompanyRepository)
+
+		err := tx.lockLeaf(ctx, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.ExecContext(ctx, `DELETE FROM companies WHERE id = $1`, id)
+		return contextErr(ctx, err)
+	})
+}
+
+// PurgeDeleted permanently removes every company soft deleted before the given
+// time and returns how many were removed. Their soft deleted subsidiaries lose
+// their parent.
+func (a *CompanyRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
 	query := `
 		DELETE FROM companies
-		WHERE id = $1`
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1`
 
-	result, err := a.DB.Exec(query, id)
+	result, err := a.db().ExecContext(ctx, query, before)
 	if err != nil {
+		return 0, contextErr(ctx, err)
+	}
+
+	return result.RowsAffected()
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Import copies the companies returned by next, until it returns io.EOF, into
+// the companies table using COPY and returns the companies that were created.
+// Companies whose name is already taken, by a stored company or by an earlier
+// company of the import, are skipped.
+func (a *CompanyRepository) Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	var created []*domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		var err error
+		created, err = repo.(*CompanyRepository).copyIn(ctx, next)
 		return err
+	})
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	return created, nil
+}
+
+func (a *CompanyRepository) copyIn(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	_, err := a.tx.ExecContext(ctx, `
+		CREATE TEMPORARY TABLE companies_import (
+			ordinal bigint NOT NULL,
+			name varchar(15) NOT NULL,
+			description varchar(3000) NULL,
+			number_of_employees integer NOT NULL,
+			registered boolean NOT NULL,
+			type integer NOT NULL,
+			created_by varchar(255) NOT NULL
+		) ON COMMIT DROP`)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := a.tx.PrepareContext(ctx, pq.CopyIn("companies_import",
+		"ordinal", "name", "description", "number_of_employees", "registered", "type", "created_by"))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for ordinal := 0; ; ordinal++ {
+		company, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = stmt.ExecContext(ctx,
+			ordinal,
+			company.Name,
+			company.Description,
+			company.NumberOfEmployees,
+			company.Registered,
+			company.Type,
+			company.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	_, err = stmt.ExecContext(ctx)
 	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO companies (name, description, number_of_employees, registered, type, created_by, updated_by)
+		SELECT DISTINCT ON (name) name, description, number_of_employees, registered, type, created_by, created_by
+		FROM companies_import
+		ORDER BY name, ordinal
+		ON CONFLICT (name) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING ` + companyColumns
+
+	rows, err := a.tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var created []*domain.Company
+	for rows.Next() {
+		var company domain.Company
+		err = scanCompany(rows, &company)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, &company)
+	}
+
+	return created, rows.Err()
+}
+
+// companyFilterClause matches the companies that are not deleted and match
+// the name, type, registered, attributes, tags and any tags filters passed as
+// the first six arguments, the name as a pattern built by namePattern. Every
+// company contains the empty object of no attribute filter, and has every tag
+// of an empty array.
+const companyFilterClause = `deleted_at IS NULL
+		AND name ILIKE $1 ESCAPE '\'
+		AND (type = $2 OR $2 IS NULL)
+		AND (registered = $3 OR $3 IS NULL)
+		AND attributes @> $4
+		AND NOT EXISTS (
+			SELECT 1
+			FROM jsonb_array_elements_text($5) AS wanted (tag)
+			WHERE NOT EXISTS (
+				SELECT 1
+				FROM company_tags
+				WHERE company_id = companies.id AND company_tags.tag = wanted.tag
+			)
+		)
+		AND (jsonb_array_length($6) = 0 OR EXISTS (
+			SELECT 1
+			FROM company_tags
+			WHERE company_id = companies.id AND tag IN (SELECT jsonb_array_elements_text($6))
+		))`
+
+// filterArgs returns the arguments of the filter clauses, followed by extra.
+func filterArgs(filter domain.CompanyFilter, extra ...any) []any {
+	args := []any{
+		namePattern(filter.Name),
+		filter.Type,
+		filter.Registered,
+		domain.Attributes(filter.Attributes),
+		tagArray(filter.Tags),
+		tagArray(filter.AnyTags),
+	}
+	return append(args, extra...)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, and the backslash
+// that escapes them.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// namePattern returns the LIKE pattern of the names that contain name, which
+// every name does when it is empty.
+func namePattern(name string) string {
+	return "%" + likeEscaper.Replace(name) + "%"
+}
+
+// tagArray encodes the tags as a JSON array, which both Postgres and SQLite
+// can expand into rows.
+func tagArray(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+// List returns up to limit companies matching the filter, ordered by name,
+// starting after the company named after.
+func (a *CompanyRepository) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	query := `
+		SELECT ` + companyColumns + `
+		FROM companies
+		WHERE ` + companyFilterClause + `
+		AND name > $7
+		ORDER BY name
+		LIMIT $8`
+
+	var companies []*domain.Company
+	err := a.read(ctx, func(db querier) error {
+		companies = nil
+
+		rows, err := db.QueryContext(ctx, query, filterArgs(filter, after, limit)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var company domain.Company
+			err = scanCompany(rows, &company)
+			if err != nil {
+				return err
+			}
+			companies = append(companies, &company)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	return companies, nil
+}
+
+// Children returns the subsidiaries of the company, ordered by name.
+func (a *CompanyRepository) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.hierarchy(ctx, childrenQuery, id)
+}
+
+// Ancestors returns the parent of the company, its parent and so on up to the
+// top of the group.
+func (a *CompanyRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.hierarchy(ctx, ancestorsQuery, id)
+}
+
+// Subtree returns the company followed by its subsidiaries, theirs and so on,
+// level by level.
+func (a *CompanyRepository) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.hierarchy(ctx, subtreeQuery, id)
+}
+
+func (a *CompanyRepository) hierarchy(ctx context.Context, query string, id uuid.UUID) ([]*domain.Company, error) {
+	var companies []*domain.Company
+	err := a.read(ctx, func(db querier) error {
+		var err error
+		companies, err = queryHierarchy(ctx, db, query, id)
 		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
 	}
+	return companies, nil
+}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+// Export calls fn for every company matching the filter, streaming them from
+// the database one row at a time. A replica that fails is replaced by the
+// primary only until the first company has been passed to fn.
+func (a *CompanyRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	query := `
+		SELECT ` + companyColumns + `
+		FROM companies
+		WHERE ` + companyFilterClause + `
+		ORDER BY name`
+
+	started := false
+	export := func(db querier) error {
+		rows, err := db.QueryContext(ctx, query, filterArgs(filter)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var company domain.Company
+			err = scanCompany(rows, &company)
+			if err != nil {
+				return err
+			}
+
+			started = true
+			err = fn(&company)
+			if err != nil {
+				return err
+			}
+		}
+		return rows.Err()
 	}
 
-	return nil
+	if r := a.replica(ctx); r != nil {
+		err := export(r.db)
+		if started || !r.failed(ctx, err) {
+			return contextErr(ctx, err)
+		}
+	}
+	return contextErr(ctx, export(a.db()))
 }
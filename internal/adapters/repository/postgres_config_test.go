@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPostgresConfig() PostgresConfig {
+	return PostgresConfig{
+		Host:           "localhost",
+		Port:           5432,
+		User:           "postgres",
+		Password:       "password",
+		DBName:         "xm_companies",
+		SSLMode:        "disable",
+		ConnectTimeout: time.Second,
+	}
+}
+
+func Test_PostgresConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*PostgresConfig)
+		err    string
+	}{
+		{"valid", func(*PostgresConfig) {}, ""},
+		{"missing connection details", func(c *PostgresConfig) { c.Host, c.DBName = "", "" }, "postgres host, database name must be set"},
+		{"unknown ssl mode", func(c *PostgresConfig) { c.SSLMode = "maybe" }, `unknown postgres ssl mode "maybe"`},
+		{"port out of range", func(c *PostgresConfig) { c.Port = 0 }, "postgres port 0 is out of range"},
+		{"negative pool size", func(c *PostgresConfig) { c.MaxIdleConns = -1 }, "postgres pool sizes must not be negative"},
+		{"negative timeout", func(c *PostgresConfig) { c.ConnMaxLifetime = -time.Second }, "postgres timeouts must not be negative"},
+		{"negative retries", func(c *PostgresConfig) { c.ConnectRetries = -1 }, "postgres connect retries must not be negative"},
+	}
+
+	for _, e := range tests {
+		t.Run(e.name, func(t *testing.T) {
+			config := testPostgresConfig()
+			e.modify(&config)
+
+			err := config.validate()
+			switch {
+			case e.err == "" && err != nil:
+				t.Errorf("expected no error but got %v", err)
+			case e.err != "" && (err == nil || err.Error() != e.err):
+				t.Errorf("expected error %q but got %v", e.err, err)
+			}
+		})
+	}
+}
+
+func Test_PostgresConfigDSN(t *testing.T) {
+	config := testPostgresConfig()
+	config.Password = `it's a \secret`
+	config.ConnectTimeout = 1500 * time.Millisecond
+
+	expected := `host='localhost' port='5432' user='postgres' password='it\'s a \\secret' dbname='xm_companies' sslmode='disable' timezone='UTC' connect_timeout='2'`
+	if dsn := config.dsn(); dsn != expected {
+		t.Errorf("expected dsn %s but got %s", expected, dsn)
+	}
+}
+
+func Test_NewPostgresRepositoryRetries(t *testing.T) {
+	// A port that was just released refuses connections.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config := testPostgresConfig()
+	config.Host = "127.0.0.1"
+	config.Port = port
+	config.ConnectRetries = 2
+	config.ConnectBackoff = 10 * time.Millisecond
+
+	start := time.Now()
+	repo, err := NewPostgresRepository(config)
+	if err == nil {
+		t.Fatalf("expected connecting to a closed port to fail but got %v", repo)
+	}
+
+	if !strings.Contains(err.Error(), "after 3 attempt(s)") {
+		t.Errorf("expected the error to report 3 attempts but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("expected to back off for at least 30ms between attempts but took %v", elapsed)
+	}
+}
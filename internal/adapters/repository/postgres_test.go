@@ -1,17 +1,23 @@
-// go:build integration
+//go:build integration
+
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"github.com/ory/dockertest"
 	"github.com/ory/dockertest/docker"
+	"github.com/petrostrak/xm-companies/db"
 	"github.com/petrostrak/xm-companies/internal/core/domain"
 )
 
@@ -71,7 +77,13 @@ func TestMain(m *testing.M) {
 	}
 
 	testRepo = PostgresRepository{
-		&CompanyRepository{testDB},
+		&CompanyRepository{DB: testDB},
+		&JobRepository{DB: testDB},
+		&IdempotencyRepository{DB: testDB},
+		&WebhookRepository{DB: testDB},
+		&AddressRepository{DB: testDB},
+		&ContactRepository{DB: testDB},
+		&AttributeDefinitionRepository{DB: testDB},
 	}
 
 	code := m.Run()
@@ -83,14 +95,26 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// createTables applies the migrations and seeds the test company.
 func createTables() error {
-	tableSQL, err := os.ReadFile("./testdata/init_schema.sql")
+	migrations, err := LoadMigrations(db.Migrations())
+	if err != nil {
+		return err
+	}
+
+	err = NewMigrator(testDB, migrations, log.New(io.Discard, "", 0)).Up(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	seedSQL, err := os.ReadFile("./testdata/seed.sql")
 	if err != nil {
 		fmt.Println(err)
 		return err
 	}
 
-	_, err = testDB.Exec(string(tableSQL))
+	_, err = testDB.Exec(string(seedSQL))
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -108,8 +132,44 @@ func Test_PingDB(t *testing.T) {
 
 var testCompanyID = uuid.MustParse("0e6c0248-a659-41d0-b860-795df3a53f44")
 
+func Test_NewPostgresRepository(t *testing.T) {
+	repo, err := NewPostgresRepository(PostgresConfig{
+		Host:           "localhost",
+		Port:           5435,
+		User:           "postgres",
+		Password:       "password",
+		DBName:         "xm_companies_test",
+		SSLMode:        "disable",
+		MaxOpenConns:   5,
+		MaxIdleConns:   5,
+		ConnectTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error connecting to postgres: %s", err)
+	}
+	defer repo.CompanyRepository.DB.Close()
+
+	if stats := repo.CompanyRepository.DB.Stats(); stats.MaxOpenConnections != 5 {
+		t.Errorf("expected at most 5 open connections but got %d", stats.MaxOpenConnections)
+	}
+
+	_, err = NewPostgresRepository(PostgresConfig{
+		Host:           "localhost",
+		Port:           5435,
+		User:           "postgres",
+		Password:       "wrong",
+		DBName:         "xm_companies_test",
+		SSLMode:        "disable",
+		ConnectTimeout: 5 * time.Second,
+	})
+	if err == nil {
+		t.Error("expected connecting with a wrong password to fail")
+	}
+}
+
 func Test_PostgresDBRepoGetCompany(t *testing.T) {
-	company, err := testRepo.CompanyRepository.Get(testCompanyID)
+	ctx := context.Background()
+	company, err := testRepo.CompanyRepository.Get(ctx, testCompanyID)
 	if err != nil {
 		t.Errorf("error getting company by id: %s", err)
 	}
@@ -122,50 +182,352 @@ func Test_PostgresDBRepoGetCompany(t *testing.T) {
 		t.Errorf("wrong company number of employees returned. expected 4 but got %v", company.NumberOfEmployees)
 	}
 
-	if company.Type.ToString() != "Sole Proprietorship" {
-		t.Errorf("wrong company type returned. expected 'Sole Proprietorship' but got %s", company.Name)
+	if company.Type != domain.SoleProprietorship {
+		t.Errorf("wrong company type returned. expected 'sole_proprietorship' but got %s", company.Type)
 	}
 }
 
 func Test_PostgresDBRepoCreateCompany(t *testing.T) {
+	ctx := context.Background()
 	testCompany := domain.Company{
 		Name:              "Golang inc",
 		Description:       "A small family firm",
 		NumberOfEmployees: 4,
 		Registered:        true,
 		Type:              domain.SoleProprietorship,
+		CreatedBy:         "tester",
 	}
 
-	err := testRepo.CompanyRepository.Create(&testCompany)
+	err := testRepo.CompanyRepository.Create(ctx, &testCompany)
 	if err != nil {
 		t.Errorf("insert company returned an error: %s", err)
 	}
+
+	if testCompany.CreatedAt.IsZero() || !testCompany.UpdatedAt.Equal(testCompany.CreatedAt) {
+		t.Errorf("expected created_at and updated_at to be set on insert but got %v and %v", testCompany.CreatedAt, testCompany.UpdatedAt)
+	}
+
+	if testCompany.CreatedBy != "tester" || testCompany.UpdatedBy != "tester" {
+		t.Errorf("expected created_by and updated_by to be 'tester' but got %q and %q", testCompany.CreatedBy, testCompany.UpdatedBy)
+	}
 }
 
 func Test_PostgresDBRepoUpdateCompany(t *testing.T) {
-	company, _ := testRepo.CompanyRepository.Get(testCompanyID)
+	ctx := context.Background()
+	company, _ := testRepo.CompanyRepository.Get(ctx, testCompanyID)
 	company.NumberOfEmployees = 6
 	company.Type = domain.Cooperative
+	company.UpdatedBy = "editor"
+	updatedAt := company.UpdatedAt
 
-	err := testRepo.CompanyRepository.Update(company)
+	err := testRepo.CompanyRepository.Update(ctx, company)
 	if err != nil {
 		t.Errorf("error updating company: %s", err)
 	}
 
-	company, _ = testRepo.CompanyRepository.Get(testCompanyID)
+	if !company.UpdatedAt.After(updatedAt) || company.UpdatedBy != "editor" {
+		t.Errorf("expected updated_at to move forward and updated_by to be 'editor' but got %v and %q", company.UpdatedAt, company.UpdatedBy)
+	}
+
+	company, _ = testRepo.CompanyRepository.Get(ctx, testCompanyID)
 	if company.NumberOfEmployees != 6 || company.Type != domain.Cooperative {
 		t.Errorf("expected updated record to have 6 number of employees and Cooperative type, but got %v and %d", company.NumberOfEmployees, company.Type)
 	}
 }
 
 func Test_PostgresDBRepoDeleteCompany(t *testing.T) {
-	err := testRepo.CompanyRepository.Delete(testCompanyID)
+	ctx := context.Background()
+	err := testRepo.CompanyRepository.Delete(ctx, testCompanyID, "tester")
 	if err != nil {
 		t.Errorf("error deleting company: %s", err)
 	}
 
-	_, err = testRepo.CompanyRepository.Get(testCompanyID)
+	_, err = testRepo.CompanyRepository.Get(ctx, testCompanyID)
 	if err == nil {
 		t.Errorf("got company %v, which should have been deleted", testCompanyID)
 	}
+
+	var deletedBy string
+	err = testDB.QueryRow("SELECT deleted_by FROM companies WHERE id = $1", testCompanyID).Scan(&deletedBy)
+	if err != nil {
+		t.Errorf("soft deleted company %v is no longer stored: %s", testCompanyID, err)
+	}
+	if deletedBy != "tester" {
+		t.Errorf("expected deleted_by to be 'tester' but got %q", deletedBy)
+	}
+
+	err = testRepo.CompanyRepository.Delete(ctx, testCompanyID, "tester")
+	if err != ErrRecordNotFound {
+		t.Errorf("expected deleting a deleted company to return %v but got %v", ErrRecordNotFound, err)
+	}
+}
+
+func Test_PostgresDBRepoRestoreCompany(t *testing.T) {
+	ctx := context.Background()
+	company, err := testRepo.CompanyRepository.Restore(ctx, testCompanyID, "restorer")
+	if err != nil {
+		t.Fatalf("error restoring company: %s", err)
+	}
+
+	if company.ID != testCompanyID {
+		t.Errorf("expected restored company %v but got %v", testCompanyID, company.ID)
+	}
+	if company.UpdatedBy != "restorer" {
+		t.Errorf("expected the company to be updated by restorer but got %q", company.UpdatedBy)
+	}
+
+	_, err = testRepo.CompanyRepository.Get(ctx, testCompanyID)
+	if err != nil {
+		t.Errorf("error getting restored company: %s", err)
+	}
+
+	_, err = testRepo.CompanyRepository.Restore(ctx, testCompanyID, "restorer")
+	if err != ErrRecordNotFound {
+		t.Errorf("expected restoring an active company to return %v but got %v", ErrRecordNotFound, err)
+	}
+}
+
+func Test_PostgresDBRepoPurgeDeleted(t *testing.T) {
+	ctx := context.Background()
+	err := testRepo.CompanyRepository.Delete(ctx, testCompanyID, "tester")
+	if err != nil {
+		t.Fatalf("error deleting company: %s", err)
+	}
+
+	purged, err := testRepo.CompanyRepository.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Errorf("error purging deleted companies: %s", err)
+	}
+	if purged != 0 {
+		t.Errorf("expected no company within the retention period to be purged but got %d", purged)
+	}
+
+	purged, err = testRepo.CompanyRepository.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Errorf("error purging deleted companies: %s", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged company but got %d", purged)
+	}
+
+	_, err = testRepo.CompanyRepository.Restore(ctx, testCompanyID, "restorer")
+	if err != ErrRecordNotFound {
+		t.Errorf("expected purged company to be gone but got %v", err)
+	}
+}
+
+func Test_PostgresDBRepoImportExport(t *testing.T) {
+	ctx := context.Background()
+	companies := []*domain.Company{
+		{Name: "Import One", NumberOfEmployees: 1, Type: domain.NonProfit, CreatedBy: "importer"},
+		{Name: "Import Two", NumberOfEmployees: 2, Registered: true, Type: domain.NonProfit, CreatedBy: "importer"},
+		{Name: "Import One", NumberOfEmployees: 3, Type: domain.NonProfit, CreatedBy: "importer"},
+		{Name: "Golang inc", NumberOfEmployees: 4, Type: domain.NonProfit, CreatedBy: "importer"},
+	}
+
+	i := 0
+	created, err := testRepo.CompanyRepository.Import(ctx, func() (*domain.Company, error) {
+		if i == len(companies) {
+			return nil, io.EOF
+		}
+		i++
+		return companies[i-1], nil
+	})
+	if err != nil {
+		t.Fatalf("import returned an error: %s", err)
+	}
+
+	if len(created) != 2 {
+		t.Errorf("expected 2 imported companies but got %d", len(created))
+	}
+
+	for _, company := range created {
+		if company.Name == "Import One" && company.NumberOfEmployees != 1 {
+			t.Errorf("expected the first of the duplicate rows to be imported but got %+v", company)
+		}
+	}
+
+	nonProfit := domain.NonProfit
+	registered := true
+	var exported []string
+	err = testRepo.CompanyRepository.Export(ctx, domain.CompanyFilter{Name: "import", Type: &nonProfit, Registered: &registered}, func(company *domain.Company) error {
+		exported = append(exported, company.Name)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("export returned an error: %s", err)
+	}
+
+	if len(exported) != 1 || exported[0] != "Import Two" {
+		t.Errorf("expected only 'Import Two' to be exported but got %v", exported)
+	}
+}
+
+func Test_PostgresDBRepoList(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range []string{"List Alpha", "List Beta", "List Gamma"} {
+		err := testRepo.CompanyRepository.Create(ctx, &domain.Company{Name: name, Type: domain.Cooperative})
+		if err != nil {
+			t.Fatalf("error creating company: %s", err)
+		}
+	}
+
+	filter := domain.CompanyFilter{Name: "list"}
+
+	page, err := testRepo.CompanyRepository.List(ctx, filter, "", 2)
+	if err != nil {
+		t.Fatalf("list returned an error: %s", err)
+	}
+
+	if len(page) != 2 || page[0].Name != "List Alpha" || page[1].Name != "List Beta" {
+		t.Errorf("expected the first page to hold 'List Alpha' and 'List Beta' but got %v", page)
+	}
+
+	page, err = testRepo.CompanyRepository.List(ctx, filter, page[len(page)-1].Name, 2)
+	if err != nil {
+		t.Fatalf("list returned an error: %s", err)
+	}
+
+	if len(page) != 1 || page[0].Name != "List Gamma" {
+		t.Errorf("expected the second page to hold 'List Gamma' but got %v", page)
+	}
+}
+
+func Test_PostgresCompanyRepositoryContract(t *testing.T) {
+	testCompanyRepositoryContract(t, testRepo.CompanyRepository)
+}
+
+func Test_PostgresCompanyDetails(t *testing.T) {
+	testCompanyDetailsContract(t, testRepo.CompanyRepository, testRepo.AddressRepository, testRepo.ContactRepository)
+}
+
+func Test_PostgresAttributeDefinitions(t *testing.T) {
+	testAttributeDefinitionContract(t, testRepo.AttributeDefinitionRepository)
+}
+
+func Test_PostgresJobRepository(t *testing.T) {
+	testJobRepositoryContract(t, testRepo.JobRepository)
+}
+
+func Test_PostgresWebhookRepository(t *testing.T) {
+	testWebhookRepositoryContract(t, testRepo.WebhookRepository)
+}
+
+func Test_PostgresIdempotencyRepository(t *testing.T) {
+	testIdempotencyRepositoryContract(t, testRepo.IdempotencyRepository)
+}
+
+func Test_Migrator(t *testing.T) {
+	ctx := context.Background()
+
+	// Migrations run against a database of their own, since reverting them
+	// drops the tables the other tests use.
+	_, err := testDB.Exec("CREATE DATABASE xm_companies_migrate_test")
+	if err != nil {
+		t.Fatalf("error creating database: %s", err)
+	}
+
+	migrateDB, err := sql.Open("postgres", "host=localhost port=5435 user=postgres password=password dbname=xm_companies_migrate_test sslmode=disable timezone=UTC connect_timeout=5")
+	if err != nil {
+		t.Fatalf("error opening database: %s", err)
+	}
+	defer migrateDB.Close()
+
+	migrations, err := LoadMigrations(db.Migrations())
+	if err != nil {
+		t.Fatalf("error loading migrations: %s", err)
+	}
+	migrator := NewMigrator(migrateDB, migrations, log.New(io.Discard, "", 0))
+	latest := migrator.Latest()
+
+	expectVersion := func(t *testing.T, expected uint) {
+		t.Helper()
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil || dirty || version != expected {
+			t.Fatalf("expected a clean schema at version %d but got %d, %t, %v", expected, version, dirty, err)
+		}
+	}
+	tableExists := func(t *testing.T, table string) bool {
+		t.Helper()
+		var exists bool
+		err := migrateDB.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists)
+		if err != nil {
+			t.Fatalf("error looking up table %s: %s", table, err)
+		}
+		return exists
+	}
+
+	expectVersion(t, 0)
+
+	// Replicas starting at once take turns and apply every migration once.
+	errs := make(chan error, 3)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- migrator.Up(ctx) }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("error migrating up: %s", err)
+		}
+	}
+	expectVersion(t, latest)
+	if !tableExists(t, "webhooks") {
+		t.Error("expected the webhooks table to exist")
+	}
+
+	err = migrator.Down(ctx)
+	if err != nil {
+		t.Fatalf("error migrating down: %s", err)
+	}
+	expectVersion(t, latest-1)
+
+	err = migrator.To(ctx, 3)
+	if err != nil {
+		t.Fatalf("error migrating to version 3: %s", err)
+	}
+	expectVersion(t, 3)
+	if tableExists(t, "jobs") || !tableExists(t, "companies") {
+		t.Error("expected only the companies table to exist at version 3")
+	}
+
+	err = migrator.To(ctx, 0)
+	if err != nil {
+		t.Fatalf("error migrating to version 0: %s", err)
+	}
+	expectVersion(t, 0)
+	if tableExists(t, "companies") {
+		t.Error("expected the companies table to be dropped")
+	}
+
+	err = migrator.To(ctx, latest+1)
+	if !errors.Is(err, ErrUnknownSchema) {
+		t.Errorf("expected migrating to an unknown version to return %v but got %v", ErrUnknownSchema, err)
+	}
+
+	// A schema migrated by a newer version of the service is left alone.
+	err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("error migrating up: %s", err)
+	}
+	_, err = migrateDB.Exec("UPDATE schema_migrations SET version = version + 1")
+	if err != nil {
+		t.Fatalf("error bumping the schema version: %s", err)
+	}
+
+	err = migrator.Check(ctx)
+	if !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("expected checking a schema ahead of the migrations to return %v but got %v", ErrSchemaAhead, err)
+	}
+	err = migrator.Up(ctx)
+	if !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("expected migrating a schema ahead of the migrations to return %v but got %v", ErrSchemaAhead, err)
+	}
+
+	err = migrator.Force(ctx, latest)
+	if err != nil {
+		t.Fatalf("error forcing the version: %s", err)
+	}
+	expectVersion(t, latest)
+	if err := migrator.Check(ctx); err != nil {
+		t.Errorf("expected the forced schema to pass the check but got %v", err)
+	}
 }
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// ReplicaPool spreads reads over the read replicas of Postgres in turn,
+// skipping the ones that failed their latest health check or query.
+type ReplicaPool struct {
+	replicas []*replica
+	next     atomic.Uint64
+	timeout  time.Duration
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newReplicaPool(dbs []*sql.DB, timeout time.Duration) *ReplicaPool {
+	pool := &ReplicaPool{timeout: timeout, stop: make(chan struct{})}
+	for _, db := range dbs {
+		pool.replicas = append(pool.replicas, &replica{db: db})
+	}
+	return pool
+}
+
+// pick returns the next healthy replica, or nil when there is none.
+func (p *ReplicaPool) pick() *replica {
+	n := uint64(len(p.replicas))
+	start := p.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := p.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// check pings every replica and records whether it answered.
+func (p *ReplicaPool) check() {
+	var wg sync.WaitGroup
+	for _, r := range p.replicas {
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			r.healthy.Store(ping(r.db, p.timeout) == nil)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// monitor checks the replicas every interval until the pool is closed.
+func (p *ReplicaPool) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.check()
+		}
+	}
+}
+
+// Len returns the number of replicas.
+func (p *ReplicaPool) Len() int {
+	return len(p.replicas)
+}
+
+// Healthy returns how many of the replicas are healthy.
+func (p *ReplicaPool) Healthy() int {
+	healthy := 0
+	for _, r := range p.replicas {
+		if r.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
+
+func (p *ReplicaPool) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	var errs []error
+	for _, r := range p.replicas {
+		errs = append(errs, r.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// failed reports whether err means that a query should be run again on the
+// primary. Replicas that cannot be reached, rather than failing the query
+// itself, are marked unhealthy until their next successful health check.
+func (r *replica) failed(ctx context.Context, err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		r.healthy.Store(false)
+	}
+	return true
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads are served by the primary rather
+// than a replica, for clients that must read their own writes.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether the reads of ctx are pinned to the primary.
+func UsesPrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
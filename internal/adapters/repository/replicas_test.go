@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+func Test_ReplicaPoolPick(t *testing.T) {
+	pool := newReplicaPool([]*sql.DB{nil, nil, nil}, time.Second)
+	for _, r := range pool.replicas {
+		r.healthy.Store(true)
+	}
+
+	seen := make(map[*replica]int)
+	for i := 0; i < 6; i++ {
+		seen[pool.pick()]++
+	}
+	for _, r := range pool.replicas {
+		if seen[r] != 2 {
+			t.Errorf("expected every replica to be picked twice in turn but got %v", seen)
+		}
+	}
+
+	pool.replicas[0].healthy.Store(false)
+	pool.replicas[2].healthy.Store(false)
+	for i := 0; i < 3; i++ {
+		if r := pool.pick(); r != pool.replicas[1] {
+			t.Errorf("expected only the healthy replica to be picked but got %v", r)
+		}
+	}
+	if healthy := pool.Healthy(); healthy != 1 {
+		t.Errorf("expected 1 healthy replica but got %d", healthy)
+	}
+
+	pool.replicas[1].healthy.Store(false)
+	if r := pool.pick(); r != nil {
+		t.Errorf("expected no replica to be picked when none is healthy but got %v", r)
+	}
+}
+
+func Test_ReplicaFailed(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		err     error
+		failed  bool
+		healthy bool
+	}{
+		{"success", ctx, nil, false, true},
+		{"no rows", ctx, sql.ErrNoRows, false, true},
+		{"cancelled", cancelled, errors.New("canceling statement due to user request"), false, true},
+		{"query error", ctx, &pq.Error{Code: "40001"}, true, true},
+		{"connection error", ctx, errors.New("connection refused"), true, false},
+	}
+
+	for _, e := range testCases {
+		t.Run(e.name, func(t *testing.T) {
+			r := &replica{}
+			r.healthy.Store(true)
+
+			if failed := r.failed(e.ctx, e.err); failed != e.failed {
+				t.Errorf("expected failed to be %t but got %t", e.failed, failed)
+			}
+			if healthy := r.healthy.Load(); healthy != e.healthy {
+				t.Errorf("expected healthy to be %t but got %t", e.healthy, healthy)
+			}
+		})
+	}
+}
+
+// Test_CompanyRepositoryReplicaRouting stands in SQLite databases for the
+// primary and its replica, which the queries of Get are compatible with.
+func Test_CompanyRepositoryReplicaRouting(t *testing.T) {
+	ctx := context.Background()
+
+	primary, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+	defer primary.Close()
+
+	replicaDB, err := NewSQLiteCompanyRepository(":memory:")
+	if err != nil {
+		t.Fatalf("error opening sqlite database: %s", err)
+	}
+
+	company := &domain.Company{Name: "Lagging"}
+	err = primary.Create(ctx, company)
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	pool := newReplicaPool([]*sql.DB{replicaDB.DB}, time.Second)
+	pool.check()
+	repo := &CompanyRepository{DB: primary.DB, replicas: pool}
+
+	// The company has not reached the replica yet.
+	_, err = repo.Get(ctx, company.ID)
+	if !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expected the read to be served by the replica but got %v", err)
+	}
+
+	_, err = repo.Get(WithPrimary(ctx), company.ID)
+	if err != nil {
+		t.Errorf("expected the read pinned to the primary to find the company but got %v", err)
+	}
+
+	err = repo.InTx(ctx, func(tx ports.CompanyRepository) error {
+		_, err := tx.Get(ctx, company.ID)
+		return err
+	})
+	if err != nil {
+		t.Errorf("expected the read of a transaction to be served by the primary but got %v", err)
+	}
+
+	// A replica that cannot be reached is replaced by the primary.
+	replicaDB.Close()
+	_, err = repo.Get(ctx, company.ID)
+	if err != nil {
+		t.Errorf("expected the read to fall back to the primary but got %v", err)
+	}
+	if healthy := pool.Healthy(); healthy != 0 {
+		t.Errorf("expected the replica to be marked unhealthy but %d are healthy", healthy)
+	}
+}
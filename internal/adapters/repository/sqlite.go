@@ -0,0 +1,791 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// sqliteSchema mirrors the companies, company_addresses, company_contacts,
+// attribute_definitions, tags, company_tags, jobs, webhooks,
+// webhook_deliveries and idempotency_keys tables of the Postgres migrations.
+const sqliteSchema = `
+	CREATE TABLE IF NOT EXISTS companies (
+		id text NOT NULL PRIMARY KEY,
+		name varchar(15) NOT NULL,
+		description varchar(3000) NULL,
+		number_of_employees integer NOT NULL,
+		registered boolean NOT NULL,
+		type integer NOT NULL,
+		parent_id text NULL REFERENCES companies (id) ON DELETE SET NULL,
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL,
+		attributes text NOT NULL DEFAULT '{}',
+		created_by varchar(255) NOT NULL DEFAULT '',
+		updated_by varchar(255) NOT NULL DEFAULT '',
+		deleted_at timestamp NULL,
+		deleted_by varchar(255) NULL
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS companies_name_active_idx ON companies (name) WHERE deleted_at IS NULL;
+	CREATE INDEX IF NOT EXISTS companies_deleted_at_idx ON companies (deleted_at) WHERE deleted_at IS NOT NULL;
+	CREATE TABLE IF NOT EXISTS company_addresses (
+		id text NOT NULL PRIMARY KEY,
+		company_id text NOT NULL REFERENCES companies (id) ON DELETE CASCADE,
+		label varchar(50) NOT NULL DEFAULT '',
+		line1 varchar(200) NOT NULL,
+		line2 varchar(200) NOT NULL DEFAULT '',
+		city varchar(100) NOT NULL,
+		region varchar(100) NOT NULL DEFAULT '',
+		postal_code varchar(20) NOT NULL DEFAULT '',
+		country_code char(2) NOT NULL,
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS company_addresses_company_idx ON company_addresses (company_id, created_at);
+	CREATE TABLE IF NOT EXISTS company_contacts (
+		id text NOT NULL PRIMARY KEY,
+		company_id text NOT NULL REFERENCES companies (id) ON DELETE CASCADE,
+		name varchar(100) NOT NULL,
+		email varchar(254) NOT NULL DEFAULT '',
+		phone varchar(30) NOT NULL DEFAULT '',
+		role varchar(100) NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS company_contacts_company_idx ON company_contacts (company_id, created_at);
+	CREATE TABLE IF NOT EXISTS attribute_definitions (
+		name varchar(63) NOT NULL PRIMARY KEY,
+		description varchar(500) NOT NULL DEFAULT '',
+		schema text NOT NULL,
+		created_by varchar(255) NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS tags (
+		name varchar(50) NOT NULL PRIMARY KEY,
+		created_at timestamp NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS company_tags (
+		company_id text NOT NULL REFERENCES companies (id) ON DELETE CASCADE,
+		tag varchar(50) NOT NULL REFERENCES tags (name) ON DELETE CASCADE,
+		created_by varchar(255) NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL,
+		PRIMARY KEY (company_id, tag)
+	);
+	CREATE INDEX IF NOT EXISTS company_tags_tag_idx ON company_tags (tag);
+	CREATE TABLE IF NOT EXISTS jobs (
+		id text NOT NULL PRIMARY KEY,
+		type varchar(50) NOT NULL,
+		status varchar(20) NOT NULL DEFAULT 'queued',
+		params text NOT NULL DEFAULT '{}',
+		progress integer NOT NULL DEFAULT 0,
+		result_type varchar(255) NOT NULL DEFAULT '',
+		error text NOT NULL DEFAULT '',
+		attempts integer NOT NULL DEFAULT 0,
+		created_by varchar(255) NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL,
+		started_at timestamp NULL,
+		finished_at timestamp NULL,
+		heartbeat_at timestamp NULL
+	);
+	CREATE INDEX IF NOT EXISTS jobs_pending_idx ON jobs (created_at) WHERE status IN ('queued', 'running', 'failed');
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id text NOT NULL PRIMARY KEY,
+		url varchar(2048) NOT NULL,
+		secret varchar(255) NOT NULL,
+		events text NOT NULL DEFAULT '[]',
+		active boolean NOT NULL DEFAULT true,
+		consecutive_failures integer NOT NULL DEFAULT 0,
+		disabled_at timestamp NULL,
+		created_by varchar(255) NOT NULL DEFAULT '',
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id text NOT NULL PRIMARY KEY,
+		webhook_id text NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+		event_id text NOT NULL,
+		event varchar(50) NOT NULL,
+		payload blob NOT NULL,
+		status varchar(20) NOT NULL DEFAULT 'pending',
+		attempts integer NOT NULL DEFAULT 0,
+		response_status integer NOT NULL DEFAULT 0,
+		error text NOT NULL DEFAULT '',
+		next_attempt_at timestamp NOT NULL,
+		created_at timestamp NOT NULL,
+		updated_at timestamp NOT NULL,
+		delivered_at timestamp NULL
+	);
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+	CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, created_at);
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		key varchar(255) NOT NULL,
+		subject varchar(255) NOT NULL DEFAULT '',
+		request_hash blob NOT NULL,
+		status integer NOT NULL DEFAULT 0,
+		header text NOT NULL DEFAULT '{}',
+		body blob NULL,
+		created_at timestamp NOT NULL,
+		expires_at timestamp NOT NULL,
+		locked_until timestamp NOT NULL,
+		PRIMARY KEY (subject, key)
+	);
+	CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);`
+
+// sqliteParentSchema adds the parent of companies to the databases created
+// before companies had one.
+const sqliteParentSchema = `
+	ALTER TABLE companies ADD COLUMN parent_id text NULL REFERENCES companies (id) ON DELETE SET NULL;`
+
+// sqliteAttributesSchema adds the custom attributes of companies to the
+// databases created before companies had them.
+const sqliteAttributesSchema = `
+	ALTER TABLE companies ADD COLUMN attributes text NOT NULL DEFAULT '{}';`
+
+const sqliteParentIndex = `
+	CREATE INDEX IF NOT EXISTS companies_parent_id_idx ON companies (parent_id) WHERE parent_id IS NOT NULL;`
+
+// SQLiteCompanyRepository stores companies in a SQLite database with the same
+// semantics as the Postgres repository.
+type SQLiteCompanyRepository struct {
+	DB *sql.DB
+	tx *sql.Tx
+}
+
+// NewSQLiteCompanyRepository opens the SQLite database at path, creating it
+// and its schema when missing. A path of ":memory:" keeps the database in
+// memory for as long as the repository is open.
+func NewSQLiteCompanyRepository(path string) (*SQLiteCompanyRepository, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_foreign_keys=1")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer at a time, and every connection to an
+	// in-memory database opens a database of its own.
+	db.SetMaxOpenConns(1)
+
+	err = createSQLiteSchema(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteCompanyRepository{DB: db}, nil
+}
+
+func createSQLiteSchema(db *sql.DB) error {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []struct{ name, schema string }{
+		{"parent_id", sqliteParentSchema},
+		{"attributes", sqliteAttributesSchema},
+	} {
+		var hasColumn bool
+		err = db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('companies') WHERE name = ?1`, column.name).Scan(&hasColumn)
+		if err != nil {
+			return err
+		}
+		if !hasColumn {
+			_, err = db.Exec(column.schema)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = db.Exec(sqliteParentIndex)
+	return err
+}
+
+func (a *SQLiteCompanyRepository) Close() error {
+	return a.DB.Close()
+}
+
+// db returns the transaction the repository is bound to, if any, or else the
+// connection pool.
+func (a *SQLiteCompanyRepository) db() querier {
+	if a.tx != nil {
+		return a.tx
+	}
+	return a.DB
+}
+
+// InTx runs fn against a copy of the repository bound to a single transaction,
+// committing when fn succeeds and rolling back otherwise.
+func (a *SQLiteCompanyRepository) InTx(ctx context.Context, fn func(ports.CompanyRepository) error) error {
+	if a.tx != nil {
+		return fn(a)
+	}
+
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+
+	err = fn(&SQLiteCompanyRepository{DB: a.DB, tx: tx})
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+
+	return contextErr(ctx, tx.Commit())
+}
+
+// isSQLiteUniqueViolation reports whether err is a violation of a unique index
+// or of a primary key, which SQLite reports apart.
+func isSQLiteUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
+
+// parentExists returns ErrParentNotFound unless the parent of a company exists.
+// Within a transaction it holds the only connection to the database, so the
+// parent cannot be deleted before the transaction ends.
+func (a *SQLiteCompanyRepository) parentExists(ctx context.Context, id uuid.UUID) error {
+	var exists bool
+	err := a.db().QueryRowContext(ctx, existsQuery, id).Scan(&exists)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+	if !exists {
+		return ErrParentNotFound
+	}
+	return nil
+}
+
+// Create stores the company, checking within the same transaction that the
+// parent of a subsidiary exists.
+func (a *SQLiteCompanyRepository) Create(ctx context.Context, company *domain.Company) error {
+	if company.ParentID == nil {
+		return a.create(ctx, company)
+	}
+
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*SQLiteCompanyRepository)
+
+		err := tx.parentExists(ctx, *company.ParentID)
+		if err != nil {
+			return err
+		}
+		return tx.create(ctx, company)
+	})
+}
+
+func (a *SQLiteCompanyRepository) create(ctx context.Context, company *domain.Company) error {
+	query := `
+		INSERT INTO companies (id, name, description, number_of_employees, registered, type, parent_id,
+			attributes, created_at, updated_at, created_by, updated_by)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?9, ?10, ?10)
+		RETURNING ` + companyColumns
+
+	args := []any{
+		uuid.New(),
+		company.Name,
+		company.Description,
+		company.NumberOfEmployees,
+		company.Registered,
+		company.Type,
+		company.ParentID,
+		company.Attributes,
+		time.Now().UTC(),
+		company.CreatedBy,
+	}
+
+	err := scanCompany(a.db().QueryRowContext(ctx, query, args...), company)
+	if isSQLiteUniqueViolation(err) {
+		return ErrDuplicateName
+	}
+	return contextErr(ctx, err)
+}
+
+func (a *SQLiteCompanyRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	query := `
+		SELECT ` + companyColumns + `
+		FROM companies
+		WHERE id = ?1 AND deleted_at IS NULL`
+
+	var company domain.Company
+
+	err := scanCompany(a.db().QueryRowContext(ctx, query, id), &company)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &company, nil
+}
+
+// Update changes the company, checking within the same transaction that the
+// parent of a subsidiary exists and is not one of its own subsidiaries.
+func (a *SQLiteCompanyRepository) Update(ctx context.Context, company *domain.Company) error {
+	if company.ParentID == nil {
+		return a.update(ctx, company)
+	}
+
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*SQLiteCompanyRepository)
+
+		err := tx.parentExists(ctx, *company.ParentID)
+		if err != nil {
+			return err
+		}
+
+		var cycle bool
+		err = tx.tx.QueryRowContext(ctx, parentCycleQuery, *company.ParentID, company.ID).Scan(&cycle)
+		if err != nil {
+			return contextErr(ctx, err)
+		}
+		if cycle {
+			return ErrHierarchyCycle
+		}
+
+		return tx.update(ctx, company)
+	})
+}
+
+func (a *SQLiteCompanyRepository) update(ctx context.Context, company *domain.Company) error {
+	query := `
+		UPDATE companies
+		SET name = ?1, description = ?2, number_of_employees = ?3, registered = ?4, type = ?5,
+			parent_id = ?6, attributes = ?7, updated_at = ?8, updated_by = ?9
+		WHERE id = ?10 AND deleted_at IS NULL
+		RETURNING ` + companyColumns
+
+	args := []any{
+		company.Name,
+		company.Description,
+		company.NumberOfEmployees,
+		company.Registered,
+		company.Type,
+		company.ParentID,
+		company.Attributes,
+		time.Now().UTC(),
+		company.UpdatedBy,
+		company.ID,
+	}
+
+	err := scanCompany(a.db().QueryRowContext(ctx, query, args...), company)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return ErrRecordNotFound
+	case isSQLiteUniqueViolation(err):
+		return ErrDuplicateName
+	}
+	return contextErr(ctx, err)
+}
+
+// Delete soft deletes the company, recording when and by whom it was deleted,
+// unless it has subsidiaries.
+func (a *SQLiteCompanyRepository) Delete(ctx context.Context, id uuid.UUID, deletedBy string) error {
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*SQLiteCompanyRepository)
+
+		err := tx.leaf(ctx, id, false)
+		if err != nil {
+			return err
+		}
+
+		query := `
+			UPDATE companies
+			SET deleted_at = ?2, deleted_by = ?3
+			WHERE id = ?1`
+
+		_, err = tx.tx.ExecContext(ctx, query, id, time.Now().UTC(), deletedBy)
+		return contextErr(ctx, err)
+	})
+}
+
+// leaf returns ErrRecordNotFound unless the company exists, soft deleted ones
+// included when withDeleted is set, and ErrHasSubsidiaries when it has
+// subsidiaries. Within a transaction it holds the only connection to the
+// database, so no subsidiary can be added before the transaction ends.
+func (a *SQLiteCompanyRepository) leaf(ctx context.Context, id uuid.UUID, withDeleted bool) error {
+	query := `
+		SELECT id
+		FROM companies
+		WHERE id = ?1 AND (deleted_at IS NULL OR ?2)`
+
+	err := a.db().QueryRowContext(ctx, query, id, withDeleted).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+
+	var hasSubsidiaries bool
+	err = a.db().QueryRowContext(ctx, hasSubsidiariesQuery, id).Scan(&hasSubsidiaries)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+	if hasSubsidiaries {
+		return ErrHasSubsidiaries
+	}
+	return nil
+}
+
+// Restore brings back a soft deleted company, unless its parent is no longer
+// there.
+func (a *SQLiteCompanyRepository) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	var company *domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*SQLiteCompanyRepository)
+
+		var parentID *uuid.UUID
+		err := tx.tx.QueryRowContext(ctx, `SELECT parent_id FROM companies WHERE id = ?1 AND deleted_at IS NOT NULL`, id).Scan(&parentID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecordNotFound
+		}
+		if err != nil {
+			return contextErr(ctx, err)
+		}
+
+		if parentID != nil {
+			err = tx.parentExists(ctx, *parentID)
+			if err != nil {
+				return err
+			}
+		}
+
+		company, err = tx.restore(ctx, id, restoredBy)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return company, nil
+}
+
+func (a *SQLiteCompanyRepository) restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	query := `
+		UPDATE companies
+		SET deleted_at = NULL, deleted_by = NULL, updated_at = ?2, updated_by = ?3
+		WHERE id = ?1 AND deleted_at IS NOT NULL
+		RETURNING ` + companyColumns
+
+	var company domain.Company
+
+	err := scanCompany(a.db().QueryRowContext(ctx, query, id, time.Now().UTC(), restoredBy), &company)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		case isSQLiteUniqueViolation(err):
+			return nil, ErrDuplicateName
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &company, nil
+}
+
+// Purge permanently removes the company, whether it was soft deleted or not,
+// unless it has subsidiaries. Soft deleted subsidiaries lose their parent.
+func (a *SQLiteCompanyRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	return a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*SQLiteCompanyRepository)
+
+		err := tx.leaf(ctx, id, true)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.ExecContext(ctx, `DELETE FROM companies WHERE id = ?1`, id)
+		return contextErr(ctx, err)
+	})
+}
+
+// PurgeDeleted permanently removes every company soft deleted before the given
+// time and returns how many were removed. Their soft deleted subsidiaries lose
+// their parent.
+func (a *SQLiteCompanyRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM companies
+		WHERE deleted_at IS NOT NULL AND deleted_at < ?1`
+
+	result, err := a.db().ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, contextErr(ctx, err)
+	}
+
+	return result.RowsAffected()
+}
+
+// Import creates the companies returned by next, until it returns io.EOF, in a
+// single transaction and returns the companies that were created, ordered by
+// name. Companies whose name is already taken, by a stored company or by an
+// earlier company of the import, are skipped.
+func (a *SQLiteCompanyRepository) Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	var created []*domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		var err error
+		created, err = repo.(*SQLiteCompanyRepository).insertAll(ctx, next)
+		return err
+	})
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	sort.Slice(created, func(i, j int) bool {
+		return created[i].Name < created[j].Name
+	})
+	return created, nil
+}
+
+func (a *SQLiteCompanyRepository) insertAll(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	stmt, err := a.tx.PrepareContext(ctx, `
+		INSERT INTO companies (id, name, description, number_of_employees, registered, type,
+			created_at, updated_at, created_by, updated_by)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?7, ?8, ?8)
+		ON CONFLICT (name) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING `+companyColumns)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var created []*domain.Company
+	for {
+		company, err := next()
+		if errors.Is(err, io.EOF) {
+			return created, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = scanCompany(stmt.QueryRowContext(ctx,
+			uuid.New(),
+			company.Name,
+			company.Description,
+			company.NumberOfEmployees,
+			company.Registered,
+			company.Type,
+			time.Now().UTC(),
+			company.CreatedBy,
+		), company)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			continue
+		case err != nil:
+			return nil, err
+		}
+		created = append(created, company)
+	}
+}
+
+// sqliteCompanyFilterClause matches the companies that are not deleted and
+// match the name, type, registered, attributes, tags and any tags filters
+// passed as the first six arguments. LIKE ignores the case of ASCII letters,
+// like ILIKE does, and every attribute of the filter must have the same type
+// and value in the attributes of a company.
+const sqliteCompanyFilterClause = `deleted_at IS NULL
+		AND name LIKE ?1 ESCAPE '\'
+		AND (type = ?2 OR ?2 IS NULL)
+		AND (registered = ?3 OR ?3 IS NULL)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM json_each(?4) AS filter
+			WHERE json_type(companies.attributes, '$."' || filter.key || '"') IS NOT filter.type
+			OR json_extract(companies.attributes, '$."' || filter.key || '"') IS NOT filter.value
+		)
+		AND NOT EXISTS (
+			SELECT 1
+			FROM json_each(?5) AS wanted
+			WHERE NOT EXISTS (
+				SELECT 1
+				FROM company_tags
+				WHERE company_id = companies.id AND tag = wanted.value
+			)
+		)
+		AND (json_array_length(?6) = 0 OR EXISTS (
+			SELECT 1
+			FROM company_tags
+			WHERE company_id = companies.id AND tag IN (SELECT value FROM json_each(?6))
+		))`
+
+// List returns up to limit companies matching the filter, ordered by name,
+// starting after the company named after.
+func (a *SQLiteCompanyRepository) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	query := `
+		SELECT ` + companyColumns + `
+		FROM companies
+		WHERE ` + sqliteCompanyFilterClause + `
+		AND name > ?7
+		ORDER BY name
+		LIMIT ?8`
+
+	rows, err := a.db().QueryContext(ctx, query, filterArgs(filter, after, limit)...)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	defer rows.Close()
+
+	var companies []*domain.Company
+	for rows.Next() {
+		var company domain.Company
+		err = scanCompany(rows, &company)
+		if err != nil {
+			return nil, err
+		}
+		companies = append(companies, &company)
+	}
+
+	return companies, contextErr(ctx, rows.Err())
+}
+
+// Children returns the subsidiaries of the company, ordered by name.
+func (a *SQLiteCompanyRepository) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.hierarchy(ctx, childrenQuery, id)
+}
+
+// Ancestors returns the parent of the company, its parent and so on up to the
+// top of the group.
+func (a *SQLiteCompanyRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.hierarchy(ctx, ancestorsQuery, id)
+}
+
+// Subtree returns the company followed by its subsidiaries, theirs and so on,
+// level by level.
+func (a *SQLiteCompanyRepository) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	return a.hierarchy(ctx, subtreeQuery, id)
+}
+
+func (a *SQLiteCompanyRepository) hierarchy(ctx context.Context, query string, id uuid.UUID) ([]*domain.Company, error) {
+	companies, err := queryHierarchy(ctx, a.db(), query, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return companies, nil
+}
+
+// Export calls fn for every company matching the filter, streaming them from
+// the database one row at a time.
+func (a *SQLiteCompanyRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	query := `
+		SELECT ` + companyColumns + `
+		FROM companies
+		WHERE ` + sqliteCompanyFilterClause + `
+		ORDER BY name`
+
+	rows, err := a.db().QueryContext(ctx, query, filterArgs(filter)...)
+	if err != nil {
+		return contextErr(ctx, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var company domain.Company
+		err = scanCompany(rows, &company)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&company)
+		if err != nil {
+			return err
+		}
+	}
+
+	return contextErr(ctx, rows.Err())
+}
+
+// UpdateTags adds and removes tags of the company in a single transaction,
+// which also records who changed the company. Tags are removed after they are
+// added, so a tag that is both is removed.
+func (a *SQLiteCompanyRepository) UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	var company domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*SQLiteCompanyRepository)
+		now := time.Now().UTC()
+
+		err := tx.tx.QueryRowContext(ctx, `
+			UPDATE companies
+			SET updated_at = ?1, updated_by = ?2
+			WHERE id = ?3 AND deleted_at IS NULL
+			RETURNING id`, now, updatedBy, id).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		if len(add) > 0 {
+			_, err = tx.tx.ExecContext(ctx, `
+				INSERT INTO tags (name, created_at)
+				SELECT value, ?2
+				FROM json_each(?1)
+				WHERE true
+				ON CONFLICT DO NOTHING`, tagArray(add), now)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.tx.ExecContext(ctx, `
+				INSERT INTO company_tags (company_id, tag, created_by, created_at)
+				SELECT ?1, value, ?3, ?4
+				FROM json_each(?2)
+				WHERE true
+				ON CONFLICT DO NOTHING`, id, tagArray(add), updatedBy, now)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(remove) > 0 {
+			_, err = tx.tx.ExecContext(ctx, `
+				DELETE FROM company_tags
+				WHERE company_id = ?1 AND tag IN (SELECT value FROM json_each(?2))`, id, tagArray(remove))
+			if err != nil {
+				return err
+			}
+		}
+
+		query := `
+			SELECT ` + companyColumns + `
+			FROM companies
+			WHERE id = ?1`
+		return scanCompany(tx.tx.QueryRowContext(ctx, query, id), &company)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &company, nil
+}
+
+// Tags returns the tags of the companies that are not deleted, with the
+// number of companies that have each, ordered by name.
+func (a *SQLiteCompanyRepository) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	tags, err := queryTags(ctx, a.db())
+	return tags, contextErr(ctx, err)
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// SQLiteIdempotencyRepository stores idempotency keys in the SQLite database
+// of the companies with the same semantics as the Postgres repository.
+type SQLiteIdempotencyRepository struct {
+	DB *sql.DB
+}
+
+func (a *SQLiteIdempotencyRepository) Reserve(ctx context.Context, req *domain.IdempotentRequest) (*domain.IdempotentRequest, error) {
+	now := time.Now().UTC()
+
+	_, err := a.DB.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE subject = ?1 AND key = ?2 AND expires_at < ?3`, req.Subject, req.Key, now)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	query := `
+		INSERT INTO idempotency_keys (key, subject, request_hash, created_at, expires_at, locked_until)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+		ON CONFLICT (subject, key) DO NOTHING`
+
+	args := []any{req.Key, req.Subject, req.RequestHash, now, req.ExpiresAt.UTC(), req.LockedUntil.UTC()}
+
+	result, err := a.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	// The request holding the key stopped renewing its lease, most likely
+	// because its process died, so this retry takes over.
+	query = `
+		UPDATE idempotency_keys
+		SET expires_at = ?4, locked_until = ?5
+		WHERE subject = ?1 AND key = ?2 AND request_hash = ?3
+			AND status = 0 AND locked_until < ?6`
+
+	result, err = a.DB.ExecContext(ctx, query, req.Subject, req.Key, req.RequestHash, req.ExpiresAt.UTC(), req.LockedUntil.UTC(), now)
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 1 {
+		return nil, nil
+	}
+
+	query = `
+		SELECT key, subject, request_hash, expires_at, locked_until, status, header, body
+		FROM idempotency_keys
+		WHERE subject = ?1 AND key = ?2`
+
+	var existing domain.IdempotentRequest
+	var header string
+
+	err = a.DB.QueryRowContext(ctx, query, req.Subject, req.Key).Scan(
+		&existing.Key,
+		&existing.Subject,
+		&existing.RequestHash,
+		&existing.ExpiresAt,
+		&existing.LockedUntil,
+		&existing.Status,
+		&header,
+		&existing.Body,
+	)
+	if err != nil {
+		switch {
+		// The request holding the key was released in the meantime.
+		case errors.Is(err, sql.ErrNoRows):
+			return a.Reserve(ctx, req)
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+
+	err = json.Unmarshal([]byte(header), &existing.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &existing, nil
+}
+
+func (a *SQLiteIdempotencyRepository) Extend(ctx context.Context, subject, key string, lockedUntil time.Time) error {
+	query := `
+		UPDATE idempotency_keys
+		SET locked_until = ?3
+		WHERE subject = ?1 AND key = ?2 AND status = 0`
+
+	_, err := a.DB.ExecContext(ctx, query, subject, key, lockedUntil.UTC())
+	return contextErr(ctx, err)
+}
+
+func (a *SQLiteIdempotencyRepository) Complete(ctx context.Context, req *domain.IdempotentRequest) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status = ?3, header = ?4, body = ?5
+		WHERE subject = ?1 AND key = ?2`
+
+	header, err := json.Marshal(req.Header)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.DB.ExecContext(ctx, query, req.Subject, req.Key, req.Status, string(header), req.Body)
+	return contextErr(ctx, err)
+}
+
+func (a *SQLiteIdempotencyRepository) Release(ctx context.Context, subject, key string) error {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE subject = ?1 AND key = ?2`
+
+	_, err := a.DB.ExecContext(ctx, query, subject, key)
+	return contextErr(ctx, err)
+}
+
+func (a *SQLiteIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at < ?1`
+
+	result, err := a.DB.ExecContext(ctx, query, time.Now().UTC())
+	if err != nil {
+		return 0, contextErr(ctx, err)
+	}
+
+	return result.RowsAffected()
+}
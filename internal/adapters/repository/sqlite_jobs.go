@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// SQLiteJobRepository stores jobs in the SQLite database of the companies with
+// the same semantics as the Postgres repository. SQLite allows a single
+// writer at a time, so workers never claim the same job.
+type SQLiteJobRepository struct {
+	DB *sql.DB
+}
+
+func (a *SQLiteJobRepository) Create(ctx context.Context, job *domain.Job) error {
+	query := `
+		INSERT INTO jobs (id, type, params, created_by, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?5)
+		RETURNING ` + jobColumns
+
+	params, err := json.Marshal(job.Params)
+	if err != nil {
+		return err
+	}
+
+	return scanJob(a.DB.QueryRowContext(ctx, query, job.ID, job.Type, string(params), job.CreatedBy, time.Now().UTC()), job)
+}
+
+func (a *SQLiteJobRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	query := `
+		SELECT ` + jobColumns + `
+		FROM jobs
+		WHERE id = ?1`
+
+	var job domain.Job
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, id), &job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+func (a *SQLiteJobRepository) Claim(ctx context.Context) (*domain.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, progress = 0, error = '',
+			started_at = ?1, heartbeat_at = ?1, updated_at = ?1
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE status = 'queued'
+			ORDER BY created_at
+			LIMIT 1
+		)
+		RETURNING ` + jobColumns
+
+	var job domain.Job
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, time.Now().UTC()), &job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+func (a *SQLiteJobRepository) Progress(ctx context.Context, id uuid.UUID, progress int64) error {
+	query := `
+		UPDATE jobs
+		SET progress = ?2, heartbeat_at = ?3, updated_at = ?3
+		WHERE id = ?1 AND status = 'running'`
+
+	result, err := a.DB.ExecContext(ctx, query, id, progress, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrJobNotRunning
+	}
+
+	return nil
+}
+
+func (a *SQLiteJobRepository) Finish(ctx context.Context, job *domain.Job) error {
+	query := `
+		UPDATE jobs
+		SET status = ?2, progress = ?3, result_type = ?4, error = ?5,
+			finished_at = ?6, updated_at = ?6
+		WHERE id = ?1 AND status = 'running'
+		RETURNING ` + jobColumns
+
+	args := []any{job.ID, job.Status, job.Progress, job.ResultType, job.Error, time.Now().UTC()}
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, args...), job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrJobNotRunning
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Cancel cancels a queued or running job. Cancelling a job that is already
+// finished leaves it untouched.
+func (a *SQLiteJobRepository) Cancel(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = CASE WHEN status IN ('queued', 'running') THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status IN ('queued', 'running') THEN ?2 ELSE finished_at END,
+			updated_at = ?2
+		WHERE id = ?1
+		RETURNING ` + jobColumns
+
+	var job domain.Job
+
+	err := scanJob(a.DB.QueryRowContext(ctx, query, id, time.Now().UTC()), &job)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+func (a *SQLiteJobRepository) Requeue(ctx context.Context, maxAttempts int, stale time.Duration) (int64, error) {
+	query := `
+		UPDATE jobs
+		SET status = 'queued', updated_at = ?2
+		WHERE attempts < ?1
+		AND (status = 'failed' OR (status = 'running' AND heartbeat_at < ?3))`
+
+	now := time.Now().UTC()
+	result, err := a.DB.ExecContext(ctx, query, maxAttempts, now, now.Add(-stale))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// SQLiteWebhookRepository stores webhooks and their deliveries in the SQLite
+// database of the companies with the same semantics as the Postgres
+// repository. Events are stored as a JSON array.
+type SQLiteWebhookRepository struct {
+	DB *sql.DB
+}
+
+func scanSQLiteWebhook(row rowScanner, webhook *domain.Webhook) error {
+	var events string
+
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		&events,
+		&webhook.Active,
+		&webhook.ConsecutiveFailures,
+		&webhook.DisabledAt,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(events), &webhook.Events)
+}
+
+// sqliteEvents returns the events of a webhook as a JSON array.
+func sqliteEvents(events []string) string {
+	data, _ := json.Marshal(nonNil(events))
+	return string(data)
+}
+
+func (a *SQLiteWebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, url, secret, events, created_by, created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)
+		RETURNING ` + webhookColumns
+
+	args := []any{uuid.New(), webhook.URL, webhook.Secret, sqliteEvents(webhook.Events), webhook.CreatedBy, time.Now().UTC()}
+
+	return scanSQLiteWebhook(a.DB.QueryRowContext(ctx, query, args...), webhook)
+}
+
+func (a *SQLiteWebhookRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE id = ?1`
+
+	var webhook domain.Webhook
+
+	err := scanSQLiteWebhook(a.DB.QueryRowContext(ctx, query, id), &webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &webhook, nil
+}
+
+func (a *SQLiteWebhookRepository) List(ctx context.Context, createdBy string) ([]*domain.Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE created_by = ?1 OR ?1 = ''
+		ORDER BY created_at`
+
+	rows, err := a.DB.QueryContext(ctx, query, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*domain.Webhook{}
+	for rows.Next() {
+		var webhook domain.Webhook
+		err := scanSQLiteWebhook(rows, &webhook)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (a *SQLiteWebhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = ?2, events = ?3, active = ?4, consecutive_failures = ?5, disabled_at = ?6,
+			updated_at = ?7
+		WHERE id = ?1
+		RETURNING ` + webhookColumns
+
+	args := []any{
+		webhook.ID,
+		webhook.URL,
+		sqliteEvents(webhook.Events),
+		webhook.Active,
+		webhook.ConsecutiveFailures,
+		webhook.DisabledAt,
+		time.Now().UTC(),
+	}
+
+	err := scanSQLiteWebhook(a.DB.QueryRowContext(ctx, query, args...), webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *SQLiteWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM webhooks
+		WHERE id = ?1`
+
+	result, err := a.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Enqueue generates the ids of the deliveries, which SQLite cannot, so it
+// inserts them one by one within a transaction.
+func (a *SQLiteWebhookRepository) Enqueue(ctx context.Context, eventID uuid.UUID, event string, payload []byte) (int64, error) {
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id
+		FROM webhooks
+		WHERE active AND (events = '[]' OR EXISTS (SELECT 1 FROM json_each(webhooks.events) WHERE value = ?1))`, event)
+	if err != nil {
+		return 0, err
+	}
+
+	var webhookIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload, next_attempt_at,
+			created_at, updated_at)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6, ?6)`
+
+	now := time.Now().UTC()
+	for _, webhookID := range webhookIDs {
+		_, err = tx.ExecContext(ctx, query, uuid.New(), webhookID, eventID, event, payload, now)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(webhookIDs)), tx.Commit()
+}
+
+// Claim leases the delivery that has been due the longest. SQLite allows a
+// single writer at a time, so workers never claim the same delivery, and the
+// lease makes the deliveries of workers that died due again.
+func (a *SQLiteWebhookRepository) Claim(ctx context.Context, lease time.Duration) (*domain.WebhookDelivery, *domain.Webhook, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = ?2, updated_at = ?1
+		WHERE id = (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= ?1 AND w.active
+			ORDER BY d.next_attempt_at
+			LIMIT 1
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	var delivery domain.WebhookDelivery
+
+	now := time.Now().UTC()
+	err := scanWebhookDelivery(a.DB.QueryRowContext(ctx, query, now, now.Add(lease)), &delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, nil
+		default:
+			return nil, nil, err
+		}
+	}
+
+	webhook, err := a.Get(ctx, delivery.WebhookID)
+	if err != nil {
+		// The webhook was deleted since, along with the delivery.
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return &delivery, webhook, nil
+}
+
+func (a *SQLiteWebhookRepository) Record(ctx context.Context, delivery *domain.WebhookDelivery, disableAfter int) (bool, error) {
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?2, response_status = ?3, error = ?4, next_attempt_at = ?5,
+			delivered_at = CASE WHEN ?2 = 'succeeded' THEN ?6 END, updated_at = ?6
+		WHERE id = ?1
+		RETURNING ` + webhookDeliveryColumns
+
+	now := time.Now().UTC()
+	args := []any{delivery.ID, delivery.Status, delivery.ResponseStatus, delivery.Error, delivery.NextAttemptAt.UTC(), now}
+
+	err = scanWebhookDelivery(tx.QueryRowContext(ctx, query, args...), delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrRecordNotFound
+		default:
+			return false, err
+		}
+	}
+
+	// The webhook is read before it is changed to tell whether this very
+	// update disabled it, which the Postgres repository does with a self-join.
+	var active bool
+	var failures int
+	err = tx.QueryRowContext(ctx, `SELECT active, consecutive_failures FROM webhooks WHERE id = ?1`, delivery.WebhookID).Scan(&active, &failures)
+	if err != nil {
+		return false, err
+	}
+
+	succeeded := delivery.Status == domain.WebhookDeliverySucceeded
+	disabled := active && !succeeded && failures+1 >= disableAfter
+
+	query = `
+		UPDATE webhooks
+		SET consecutive_failures = CASE WHEN ?2 THEN 0 ELSE consecutive_failures + 1 END,
+			active = active AND NOT ?3,
+			disabled_at = CASE WHEN ?3 THEN ?4 ELSE disabled_at END,
+			updated_at = ?4
+		WHERE id = ?1`
+
+	_, err = tx.ExecContext(ctx, query, delivery.WebhookID, succeeded, disabled, now)
+	if err != nil {
+		return false, err
+	}
+
+	return disabled, tx.Commit()
+}
+
+func (a *SQLiteWebhookRepository) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = ?1
+		ORDER BY created_at DESC
+		LIMIT ?2`
+
+	rows, err := a.DB.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for rows.Next() {
+		var delivery domain.WebhookDelivery
+		err := scanWebhookDelivery(rows, &delivery)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// tagCountsQuery selects the tags of the companies that are not deleted, with
+// the number of companies that have each, ordered by name. It is shared by the
+// Postgres and SQLite repositories.
+const tagCountsQuery = `
+	SELECT tag, count(*)
+	FROM company_tags
+	JOIN companies ON companies.id = company_tags.company_id
+	WHERE companies.deleted_at IS NULL
+	GROUP BY tag
+	ORDER BY tag`
+
+func queryTags(ctx context.Context, db querier) ([]*domain.Tag, error) {
+	rows, err := db.QueryContext(ctx, tagCountsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*domain.Tag{}
+	for rows.Next() {
+		var tag domain.Tag
+		err = rows.Scan(&tag.Name, &tag.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+// UpdateTags adds and removes tags of the company in a single transaction,
+// which also records who changed the company. Tags are removed after they are
+// added, so a tag that is both is removed.
+func (a *CompanyRepository) UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	var company domain.Company
+
+	err := a.InTx(ctx, func(repo ports.CompanyRepository) error {
+		tx := repo.(*CompanyRepository)
+
+		err := tx.tx.QueryRowContext(ctx, `
+			UPDATE companies
+			SET updated_at = now(), updated_by = $1
+			WHERE id = $2 AND deleted_at IS NULL
+			RETURNING id`, updatedBy, id).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		if len(add) > 0 {
+			_, err = tx.tx.ExecContext(ctx, `
+				INSERT INTO tags (name)
+				SELECT jsonb_array_elements_text($1)
+				ON CONFLICT DO NOTHING`, tagArray(add))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.tx.ExecContext(ctx, `
+				INSERT INTO company_tags (company_id, tag, created_by)
+				SELECT $1::uuid, jsonb_array_elements_text($2), $3
+				ON CONFLICT DO NOTHING`, id, tagArray(add), updatedBy)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(remove) > 0 {
+			_, err = tx.tx.ExecContext(ctx, `
+				DELETE FROM company_tags
+				WHERE company_id = $1 AND tag IN (SELECT jsonb_array_elements_text($2))`, id, tagArray(remove))
+			if err != nil {
+				return err
+			}
+		}
+
+		query := `
+			SELECT ` + companyColumns + `
+			FROM companies
+			WHERE id = $1`
+		return scanCompany(tx.tx.QueryRowContext(ctx, query, id), &company)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, contextErr(ctx, err)
+		}
+	}
+	return &company, nil
+}
+
+// Tags returns the tags of the companies that are not deleted, with the
+// number of companies that have each, ordered by name.
+func (a *CompanyRepository) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	var tags []*domain.Tag
+	err := a.read(ctx, func(db querier) error {
+		var err error
+		tags, err = queryTags(ctx, db)
+		return err
+	})
+	if err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	return tags, nil
+}
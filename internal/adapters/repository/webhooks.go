@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+type WebhookRepository struct {
+	DB *sql.DB
+}
+
+const webhookColumns = `id, url, secret, events, active, consecutive_failures, disabled_at,
+		created_by, created_at, updated_at`
+
+func scanWebhook(row rowScanner, webhook *domain.Webhook) error {
+	return row.Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.Events),
+		&webhook.Active,
+		&webhook.ConsecutiveFailures,
+		&webhook.DisabledAt,
+		&webhook.CreatedBy,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts,
+		response_status, error, next_attempt_at, created_at, updated_at, delivered_at`
+
+func scanWebhookDelivery(row rowScanner, delivery *domain.WebhookDelivery) error {
+	return row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.Event,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.ResponseStatus,
+		&delivery.Error,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+		&delivery.DeliveredAt,
+	)
+}
+
+func (a *WebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	query := `
+		INSERT INTO webhooks (url, secret, events, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + webhookColumns
+
+	args := []any{webhook.URL, webhook.Secret, pq.Array(nonNil(webhook.Events)), webhook.CreatedBy}
+
+	return scanWebhook(a.DB.QueryRowContext(ctx, query, args...), webhook)
+}
+
+func (a *WebhookRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE id = $1`
+
+	var webhook domain.Webhook
+
+	err := scanWebhook(a.DB.QueryRowContext(ctx, query, id), &webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &webhook, nil
+}
+
+func (a *WebhookRepository) List(ctx context.Context, createdBy string) ([]*domain.Webhook, error) {
+	query := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE created_by = $1 OR $1 = ''
+		ORDER BY created_at`
+
+	rows, err := a.DB.QueryContext(ctx, query, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*domain.Webhook{}
+	for rows.Next() {
+		var webhook domain.Webhook
+		err := scanWebhook(rows, &webhook)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (a *WebhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = $2, events = $3, active = $4, consecutive_failures = $5, disabled_at = $6,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING ` + webhookColumns
+
+	args := []any{
+		webhook.ID,
+		webhook.URL,
+		pq.Array(nonNil(webhook.Events)),
+		webhook.Active,
+		webhook.ConsecutiveFailures,
+		webhook.DisabledAt,
+	}
+
+	err := scanWebhook(a.DB.QueryRowContext(ctx, query, args...), webhook)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *WebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		DELETE FROM webhooks
+		WHERE id = $1`
+
+	result, err := a.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (a *WebhookRepository) Enqueue(ctx context.Context, eventID uuid.UUID, event string, payload []byte) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT id, $1, $2, $3
+		FROM webhooks
+		WHERE active AND (events = '{}' OR $2 = ANY(events))`
+
+	result, err := a.DB.ExecContext(ctx, query, eventID, event, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Claim uses SKIP LOCKED so that concurrent workers, of this or another
+// replica, never claim the same delivery, and the lease makes the deliveries
+// of workers that died due again.
+func (a *WebhookRepository) Claim(ctx context.Context, lease time.Duration) (*domain.WebhookDelivery, *domain.Webhook, error) {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $1),
+			updated_at = now()
+		WHERE id = (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= now() AND w.active
+			ORDER BY d.next_attempt_at
+			FOR UPDATE OF d SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	var delivery domain.WebhookDelivery
+
+	err := scanWebhookDelivery(a.DB.QueryRowContext(ctx, query, lease.Seconds()), &delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, nil
+		default:
+			return nil, nil, err
+		}
+	}
+
+	webhook, err := a.Get(ctx, delivery.WebhookID)
+	if err != nil {
+		// The webhook was deleted since, along with the delivery.
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return &delivery, webhook, nil
+}
+
+func (a *WebhookRepository) Record(ctx context.Context, delivery *domain.WebhookDelivery, disableAfter int) (bool, error) {
+	tx, err := a.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, response_status = $3, error = $4, next_attempt_at = $5,
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN now() END, updated_at = now()
+		WHERE id = $1
+		RETURNING ` + webhookDeliveryColumns
+
+	args := []any{delivery.ID, delivery.Status, delivery.ResponseStatus, delivery.Error, delivery.NextAttemptAt}
+
+	err = scanWebhookDelivery(tx.QueryRowContext(ctx, query, args...), delivery)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrRecordNotFound
+		default:
+			return false, err
+		}
+	}
+
+	// The expressions of SET see the row as it was before the update, while
+	// RETURNING sees it after, hence the self-join to tell whether this very
+	// update disabled the webhook.
+	query = `
+		UPDATE webhooks w
+		SET consecutive_failures = CASE WHEN $2 THEN 0 ELSE w.consecutive_failures + 1 END,
+			active = w.active AND ($2 OR w.consecutive_failures + 1 < $3),
+			disabled_at = CASE WHEN w.active AND NOT $2 AND w.consecutive_failures + 1 >= $3 THEN now() ELSE w.disabled_at END,
+			updated_at = now()
+		FROM webhooks old
+		WHERE w.id = $1 AND old.id = w.id
+		RETURNING old.active AND NOT w.active`
+
+	succeeded := delivery.Status == domain.WebhookDeliverySucceeded
+
+	var disabled bool
+	err = tx.QueryRowContext(ctx, query, delivery.WebhookID, succeeded, disableAfter).Scan(&disabled)
+	if err != nil {
+		return false, err
+	}
+
+	return disabled, tx.Commit()
+}
+
+func (a *WebhookRepository) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	rows, err := a.DB.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for rows.Next() {
+		var delivery domain.WebhookDelivery
+		err := scanWebhookDelivery(rows, &delivery)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// nonNil stores webhooks without events as an empty array rather than NULL.
+func nonNil(events []string) []string {
+	if events == nil {
+		return []string{}
+	}
+	return events
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-chi/jwtauth/v5"
+	companyv1 "github.com/petrostrak/xm-companies/api/company/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods can be called without a JWT, like their REST counterparts.
+// WatchCompanies requires one, like the REST stream.
+var publicMethods = map[string]bool{
+	companyv1.CompanyService_GetCompany_FullMethodName:    true,
+	companyv1.CompanyService_ListCompanies_FullMethodName: true,
+	companyv1.CompanyService_ListChildren_FullMethodName:  true,
+	companyv1.CompanyService_ListAncestors_FullMethodName: true,
+	companyv1.CompanyService_ListSubtree_FullMethodName:   true,
+	companyv1.CompanyService_ListTags_FullMethodName:      true,
+}
+
+// UnaryAuthenticator requires a valid JWT for every method of the company
+// service but the public ones, the way jwtauth.Verifier and
+// jwtauth.Authenticator do for the REST routes. The verified token is stored
+// in the context where jwtauth.FromContext finds it.
+func UnaryAuthenticator(auth *jwtauth.JWTAuth) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthenticator is the streaming counterpart of UnaryAuthenticator.
+func StreamAuthenticator(auth *jwtauth.JWTAuth) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), auth, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ss, ctx})
+	}
+}
+
+func authenticate(ctx context.Context, auth *jwtauth.JWTAuth, method string) (context.Context, error) {
+	// Only the company service is protected, leaving health checks and
+	// reflection open.
+	if publicMethods[method] || !strings.HasPrefix(method, "/"+companyv1.CompanyService_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+
+	bearer, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(bearer, "bearer") {
+		return nil, status.Error(codes.Unauthenticated, "authorization metadata must be a bearer token")
+	}
+
+	t, err := jwtauth.VerifyToken(auth, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, jwtauth.ErrorReason(err).Error())
+	}
+
+	return jwtauth.NewContext(ctx, t, nil), nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// subject returns the subject of the JWT of the call, if any.
+func subject(ctx context.Context) string {
+	_, claims, _ := jwtauth.FromContext(ctx)
+	sub, _ := claims["sub"].(string)
+	return sub
+}
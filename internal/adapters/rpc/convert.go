@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	companyv1 "github.com/petrostrak/xm-companies/api/company/v1"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/utils"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The protobuf company types are those of the domain shifted by one, leaving
+// zero for an unspecified type.
+func toProtoType(t domain.CompanyType) companyv1.CompanyType {
+	return companyv1.CompanyType(t + 1)
+}
+
+// fromProtoType maps an unspecified type to an invalid domain type, which
+// validation rejects.
+func fromProtoType(t companyv1.CompanyType) domain.CompanyType {
+	return domain.CompanyType(t - 1)
+}
+
+// fromProtoParentID maps an empty parent id to no parent.
+func fromProtoParentID(s string) (*uuid.UUID, error) {
+	if s == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, invalidArgument(domain.ValidationErrors{"parent_id": "must be a valid UUID"})
+	}
+	return &id, nil
+}
+
+// fromProtoAttributeFilters maps the attribute filters of a listing to those
+// of the company filter, rejecting the values that are not strings, numbers
+// or bools, like the REST API.
+func fromProtoAttributeFilters(filters map[string]*structpb.Value) (map[string]any, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	attributes := make(map[string]any, len(filters))
+	for name, value := range filters {
+		if !domain.ValidAttributeName(name) {
+			return nil, status.Errorf(codes.InvalidArgument, "attributes filter %q is not a valid attribute name", name)
+		}
+		switch value := value.AsInterface().(type) {
+		case string, float64, bool:
+			attributes[name] = value
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "attributes filter %q must be a string, a number or a bool", name)
+		}
+	}
+	return attributes, nil
+}
+
+// fromProtoTagFilter normalizes the tags of a filter of a listing, rejecting
+// invalid tags like the REST API.
+func fromProtoTagFilter(field string, tags []string) ([]string, error) {
+	var filter []string
+	for _, tag := range tags {
+		tag = domain.NormalizeTag(tag)
+		if tag == "" {
+			continue
+		}
+		if !domain.ValidTag(tag) {
+			return nil, status.Errorf(codes.InvalidArgument, "%s must be tags but got %q", field, tag)
+		}
+		filter = append(filter, tag)
+	}
+	return filter, nil
+}
+
+// fromProtoSubsidiaryPolicy maps an unspecified policy to
+// RestrictSubsidiaries, like an absent subsidiaries parameter of the REST
+// API.
+func fromProtoSubsidiaryPolicy(p companyv1.SubsidiaryPolicy) domain.SubsidiaryPolicy {
+	switch p {
+	case companyv1.SubsidiaryPolicy_SUBSIDIARY_POLICY_DETACH:
+		return domain.DetachSubsidiaries
+	case companyv1.SubsidiaryPolicy_SUBSIDIARY_POLICY_CASCADE:
+		return domain.CascadeSubsidiaries
+	default:
+		return domain.RestrictSubsidiaries
+	}
+}
+
+func toProto(company *domain.Company) *companyv1.Company {
+	pb := &companyv1.Company{
+		Id:                company.ID.String(),
+		Name:              company.Name,
+		Description:       company.Description,
+		NumberOfEmployees: int32(company.NumberOfEmployees),
+		Registered:        company.Registered,
+		Type:              toProtoType(company.Type),
+		CreatedBy:         company.CreatedBy,
+		UpdatedBy:         company.UpdatedBy,
+		Tags:              append([]string(nil), company.Tags...),
+	}
+	if company.ParentID != nil {
+		pb.ParentId = company.ParentID.String()
+	}
+	// Cloned attributes are decoded JSON, which NewStruct always accepts.
+	if attributes, err := structpb.NewStruct(company.Attributes.Clone()); err == nil {
+		pb.Attributes = attributes
+	}
+	if !company.CreatedAt.IsZero() {
+		pb.CreatedAt = timestamppb.New(company.CreatedAt)
+	}
+	if !company.UpdatedAt.IsZero() {
+		pb.UpdatedAt = timestamppb.New(company.UpdatedAt)
+	}
+	return pb
+}
+
+func toProtoEvent(event domain.CompanyEvent) *companyv1.CompanyEvent {
+	pb := &companyv1.CompanyEvent{Company: toProto(&event.Company)}
+
+	switch event.Method {
+	case http.MethodPost:
+		pb.Kind = companyv1.CompanyEvent_KIND_CREATED
+	case http.MethodPatch:
+		pb.Kind = companyv1.CompanyEvent_KIND_UPDATED
+	case http.MethodDelete:
+		pb.Kind = companyv1.CompanyEvent_KIND_DELETED
+		pb.Company = &companyv1.Company{Id: event.Company.ID.String()}
+	}
+
+	return pb
+}
+
+// statusFromError maps the errors of the company service to gRPC statuses,
+// the way the REST handlers map them to HTTP statuses.
+func statusFromError(err error) error {
+	var validationErrs domain.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrs):
+		return invalidArgument(validationErrs)
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return status.Error(codes.NotFound, "the requested company could not be found")
+	case errors.Is(err, repository.ErrDuplicateName):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, repository.ErrParentNotFound), errors.Is(err, repository.ErrHierarchyCycle):
+		return invalidArgument(domain.ValidationErrors{"parent_id": err.Error()})
+	case errors.Is(err, repository.ErrHasSubsidiaries):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "the request was cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "the request took too long to process")
+	default:
+		utils.LogError(err)
+		return status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+	}
+}
+
+// invalidArgument reports every invalid field as a field violation.
+func invalidArgument(errs domain.ValidationErrors) error {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(fields))
+	for _, field := range fields {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: errs[field],
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, errs.Error()).
+		WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return status.Error(codes.InvalidArgument, errs.Error())
+	}
+	return st.Err()
+}
@@ -0,0 +1,345 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	companyv1 "github.com/petrostrak/xm-companies/api/company/v1"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+
+	// watchBuffer is how many events a watcher may fall behind by before its
+	// stream is ended.
+	watchBuffer = 64
+)
+
+var errInvalidID = status.Error(codes.InvalidArgument, "id must be a valid UUID")
+
+// CompanyServer serves the company service over gRPC with the same service the
+// REST handlers use.
+type CompanyServer struct {
+	companyv1.UnimplementedCompanyServiceServer
+	service *services.CompanyService
+	events  *services.Broadcaster
+}
+
+func NewCompanyServer(service *services.CompanyService, events *services.Broadcaster) *CompanyServer {
+	return &CompanyServer{service: service, events: events}
+}
+
+// NewServer returns a gRPC server with the company service, protected by the
+// JWTs of auth, along with the health and reflection services.
+func NewServer(server *CompanyServer, auth *jwtauth.JWTAuth) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryAuthenticator(auth)),
+		grpc.ChainStreamInterceptor(StreamAuthenticator(auth)),
+	)
+
+	companyv1.RegisterCompanyServiceServer(s, server)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(companyv1.CompanyService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(s, healthServer)
+
+	reflection.Register(s)
+
+	return s
+}
+
+func (s *CompanyServer) GetCompany(ctx context.Context, req *companyv1.GetCompanyRequest) (*companyv1.Company, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	company, err := s.service.Get(ctx, id)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return toProto(company), nil
+}
+
+func (s *CompanyServer) ListCompanies(ctx context.Context, req *companyv1.ListCompaniesRequest) (*companyv1.ListCompaniesResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	}
+
+	// Page tokens hold the name of the last company of the previous page.
+	after, err := base64.RawURLEncoding.DecodeString(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "page_token is invalid")
+	}
+
+	attributes, err := fromProtoAttributeFilters(req.GetAttributes())
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := fromProtoTagFilter("tags", req.GetTags())
+	if err != nil {
+		return nil, err
+	}
+	anyTags, err := fromProtoTagFilter("any_tags", req.GetAnyTags())
+	if err != nil {
+		return nil, err
+	}
+
+	filter := domain.CompanyFilter{Name: req.GetName(), Registered: req.Registered, Attributes: attributes, Tags: tags, AnyTags: anyTags}
+	if req.GetType() != companyv1.CompanyType_COMPANY_TYPE_UNSPECIFIED {
+		t := fromProtoType(req.GetType())
+		filter.Type = &t
+	}
+
+	// One more company than asked for tells whether there is another page.
+	companies, err := s.service.List(ctx, filter, string(after), pageSize+1)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	res := &companyv1.ListCompaniesResponse{}
+	if len(companies) > pageSize {
+		companies = companies[:pageSize]
+		res.NextPageToken = base64.RawURLEncoding.EncodeToString([]byte(companies[pageSize-1].Name))
+	}
+
+	for _, company := range companies {
+		res.Companies = append(res.Companies, toProto(company))
+	}
+
+	return res, nil
+}
+
+func (s *CompanyServer) CreateCompany(ctx context.Context, req *companyv1.CreateCompanyRequest) (*companyv1.Company, error) {
+	input := req.GetCompany()
+
+	parentID, err := fromProtoParentID(input.GetParentId())
+	if err != nil {
+		return nil, err
+	}
+
+	company := &domain.Company{
+		Name:              input.GetName(),
+		Description:       input.GetDescription(),
+		NumberOfEmployees: int(input.GetNumberOfEmployees()),
+		Registered:        input.GetRegistered(),
+		Type:              fromProtoType(input.GetType()),
+		ParentID:          parentID,
+		Attributes:        input.GetAttributes().AsMap(),
+		CreatedBy:         subject(ctx),
+	}
+
+	err = s.service.Create(ctx, company)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return toProto(company), nil
+}
+
+func (s *CompanyServer) UpdateCompany(ctx context.Context, req *companyv1.UpdateCompanyRequest) (*companyv1.Company, error) {
+	input := req.GetCompany()
+
+	id, err := uuid.Parse(input.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		paths = []string{"name", "description", "number_of_employees", "registered", "type", "parent_id", "attributes"}
+	}
+
+	company, err := s.service.Get(ctx, id)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	for _, path := range paths {
+		switch path {
+		case "name":
+			company.Name = input.GetName()
+		case "description":
+			company.Description = input.GetDescription()
+		case "number_of_employees":
+			company.NumberOfEmployees = int(input.GetNumberOfEmployees())
+		case "registered":
+			company.Registered = input.GetRegistered()
+		case "type":
+			company.Type = fromProtoType(input.GetType())
+		case "parent_id":
+			company.ParentID, err = fromProtoParentID(input.GetParentId())
+			if err != nil {
+				return nil, err
+			}
+		case "attributes":
+			company.Attributes = domain.MergePatch(company.Attributes, input.GetAttributes().AsMap()).(map[string]any)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "update_mask path %q is not an updatable field", path)
+		}
+	}
+	company.UpdatedBy = subject(ctx)
+
+	err = s.service.Update(ctx, company)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return toProto(company), nil
+}
+
+func (s *CompanyServer) DeleteCompany(ctx context.Context, req *companyv1.DeleteCompanyRequest) (*emptypb.Empty, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	err = s.service.Delete(ctx, id, subject(ctx), fromProtoSubsidiaryPolicy(req.GetSubsidiaries()))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *CompanyServer) ListChildren(ctx context.Context, req *companyv1.ListHierarchyRequest) (*companyv1.ListHierarchyResponse, error) {
+	return listHierarchy(ctx, req, s.service.Children)
+}
+
+func (s *CompanyServer) ListAncestors(ctx context.Context, req *companyv1.ListHierarchyRequest) (*companyv1.ListHierarchyResponse, error) {
+	return listHierarchy(ctx, req, s.service.Ancestors)
+}
+
+func (s *CompanyServer) ListSubtree(ctx context.Context, req *companyv1.ListHierarchyRequest) (*companyv1.ListHierarchyResponse, error) {
+	return listHierarchy(ctx, req, s.service.Subtree)
+}
+
+func listHierarchy(ctx context.Context, req *companyv1.ListHierarchyRequest, read func(context.Context, uuid.UUID) ([]*domain.Company, error)) (*companyv1.ListHierarchyResponse, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	companies, err := read(ctx, id)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	res := &companyv1.ListHierarchyResponse{}
+	for _, company := range companies {
+		res.Companies = append(res.Companies, toProto(company))
+	}
+	return res, nil
+}
+
+func (s *CompanyServer) AddTags(ctx context.Context, req *companyv1.AddTagsRequest) (*companyv1.Company, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	company, err := s.service.AddTags(ctx, id, req.GetTags(), subject(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return toProto(company), nil
+}
+
+func (s *CompanyServer) RemoveTags(ctx context.Context, req *companyv1.RemoveTagsRequest) (*companyv1.Company, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	company, err := s.service.RemoveTags(ctx, id, req.GetTags(), subject(ctx))
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	return toProto(company), nil
+}
+
+func (s *CompanyServer) ListTags(ctx context.Context, req *companyv1.ListTagsRequest) (*companyv1.ListTagsResponse, error) {
+	tags, err := s.service.Tags(ctx)
+	if err != nil {
+		return nil, statusFromError(err)
+	}
+
+	res := &companyv1.ListTagsResponse{}
+	for _, tag := range tags {
+		res.Tags = append(res.Tags, &companyv1.Tag{Name: tag.Name, Count: tag.Count})
+	}
+	return res, nil
+}
+
+// WatchCompanies streams the changes published by the broadcaster until the
+// client goes away, starting once the response header has been sent. Clients
+// that fall behind have their stream ended with ResourceExhausted and should
+// watch again.
+func (s *CompanyServer) WatchCompanies(req *companyv1.WatchCompaniesRequest, stream companyv1.CompanyService_WatchCompaniesServer) error {
+	ids := make(map[uuid.UUID]bool, len(req.GetIds()))
+	for _, value := range req.GetIds() {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return errInvalidID
+		}
+		ids[id] = true
+	}
+
+	events, unsubscribe := s.events.Subscribe(watchBuffer)
+	defer unsubscribe()
+
+	// The header tells the client that every change from now on is watched.
+	err := stream.SendHeader(metadata.MD{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "the watch fell behind the changes and was ended")
+			}
+
+			if len(ids) > 0 && !ids[event.Company.ID] {
+				continue
+			}
+
+			pb := toProtoEvent(event)
+			if req.GetType() != companyv1.CompanyType_COMPANY_TYPE_UNSPECIFIED &&
+				pb.Kind != companyv1.CompanyEvent_KIND_DELETED && pb.Company.Type != req.GetType() {
+				continue
+			}
+
+			err := stream.Send(pb)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
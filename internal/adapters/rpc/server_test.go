@@ -0,0 +1,427 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+	companyv1 "github.com/petrostrak/xm-companies/api/company/v1"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/services"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type discardProducer struct{}
+
+func (discardProducer) ProduceCompany(company *domain.Company, method string) error {
+	return nil
+}
+
+// newTestClient serves a company server over an in-memory connection.
+func newTestClient(t *testing.T) (*grpc.ClientConn, *jwtauth.JWTAuth) {
+	auth := jwtauth.New("HS256", []byte("secret"), nil)
+	broadcaster := services.NewBroadcaster(discardProducer{}, services.BroadcastConfig{})
+	definitions := repository.NewMemoryAttributeDefinitionRepository()
+	for _, definition := range []*domain.AttributeDefinition{
+		{Name: "industry", Schema: json.RawMessage(`{"type":"string","enum":["fintech","retail"]}`)},
+		{Name: "founded", Schema: json.RawMessage(`{"type":"integer"}`)},
+	} {
+		err := definitions.Create(context.Background(), definition)
+		if err != nil {
+			t.Fatalf("error creating definition: %s", err)
+		}
+	}
+	service := services.NewCompanyService(repository.NewMemoryCompanyRepository(), definitions, broadcaster, services.CompanyConfig{})
+
+	lis := bufconn.Listen(1 << 20)
+	server := NewServer(NewCompanyServer(service, broadcaster), auth)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("error dialing server: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, auth
+}
+
+func withToken(t *testing.T, auth *jwtauth.JWTAuth, sub string) context.Context {
+	_, token, err := auth.Encode(map[string]interface{}{"sub": sub})
+	if err != nil {
+		t.Fatalf("error encoding token: %s", err)
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func Test_CompanyServer(t *testing.T) {
+	conn, auth := newTestClient(t)
+	client := companyv1.NewCompanyServiceClient(conn)
+	ctx := withToken(t, auth, "tester")
+
+	_, err := client.CreateCompany(context.Background(), &companyv1.CreateCompanyRequest{
+		Company: &companyv1.Company{Name: "Alpha", Type: companyv1.CompanyType_COMPANY_TYPE_COOPERATIVE},
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected %s without a token but got %v", codes.Unauthenticated, err)
+	}
+
+	_, err = client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{Company: &companyv1.Company{}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected %s for an invalid company but got %v", codes.InvalidArgument, err)
+	}
+
+	var badRequest *errdetails.BadRequest
+	for _, detail := range status.Convert(err).Details() {
+		badRequest, _ = detail.(*errdetails.BadRequest)
+	}
+	if badRequest == nil || len(badRequest.GetFieldViolations()) != 2 {
+		t.Errorf("expected violations of name and type but got %v", status.Convert(err).Details())
+	}
+
+	alpha, err := client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+		Company: &companyv1.Company{Name: "Alpha", NumberOfEmployees: 3, Type: companyv1.CompanyType_COMPANY_TYPE_COOPERATIVE},
+	})
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	if alpha.GetCreatedBy() != "tester" || alpha.GetCreatedAt() == nil {
+		t.Errorf("expected company created by tester with a timestamp but got %v", alpha)
+	}
+
+	// Reading a company needs no token.
+	got, err := client.GetCompany(context.Background(), &companyv1.GetCompanyRequest{Id: alpha.GetId()})
+	if err != nil || got.GetName() != "Alpha" || got.GetType() != companyv1.CompanyType_COMPANY_TYPE_COOPERATIVE {
+		t.Errorf("expected to get Alpha but got %v, %v", got, err)
+	}
+
+	updated, err := client.UpdateCompany(ctx, &companyv1.UpdateCompanyRequest{
+		Company:    &companyv1.Company{Id: alpha.GetId(), Name: "Ignored", NumberOfEmployees: 7},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"number_of_employees"}},
+	})
+	if err != nil || updated.GetName() != "Alpha" || updated.GetNumberOfEmployees() != 7 {
+		t.Errorf("expected only the number of employees to be updated but got %v, %v", updated, err)
+	}
+
+	for _, name := range []string{"Beta", "Gamma"} {
+		_, err = client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+			Company: &companyv1.Company{Name: name, Type: companyv1.CompanyType_COMPANY_TYPE_COOPERATIVE},
+		})
+		if err != nil {
+			t.Fatalf("error creating company: %s", err)
+		}
+	}
+
+	// Listing companies needs no token either.
+	var names []string
+	var pages int
+	req := &companyv1.ListCompaniesRequest{PageSize: 2}
+	for {
+		res, err := client.ListCompanies(context.Background(), req)
+		if err != nil {
+			t.Fatalf("error listing companies: %s", err)
+		}
+		pages++
+		for _, company := range res.GetCompanies() {
+			names = append(names, company.GetName())
+		}
+		if res.GetNextPageToken() == "" {
+			break
+		}
+		req.PageToken = res.GetNextPageToken()
+	}
+
+	if pages != 2 || len(names) != 3 || names[0] != "Alpha" || names[2] != "Gamma" {
+		t.Errorf("expected Alpha, Beta and Gamma in 2 pages but got %v in %d", names, pages)
+	}
+
+	_, err = client.DeleteCompany(ctx, &companyv1.DeleteCompanyRequest{Id: alpha.GetId()})
+	if err != nil {
+		t.Fatalf("error deleting company: %s", err)
+	}
+
+	_, err = client.GetCompany(ctx, &companyv1.GetCompanyRequest{Id: alpha.GetId()})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected %s for a deleted company but got %v", codes.NotFound, err)
+	}
+
+	_, err = client.GetCompany(ctx, &companyv1.GetCompanyRequest{Id: "not-a-uuid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for an invalid id but got %v", codes.InvalidArgument, err)
+	}
+}
+
+func Test_CompanyHierarchy(t *testing.T) {
+	conn, auth := newTestClient(t)
+	client := companyv1.NewCompanyServiceClient(conn)
+	ctx := withToken(t, auth, "tester")
+
+	create := func(name, parentID string) *companyv1.Company {
+		company, err := client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+			Company: &companyv1.Company{Name: name, Type: companyv1.CompanyType_COMPANY_TYPE_CORPORATIONS, ParentId: parentID},
+		})
+		if err != nil {
+			t.Fatalf("error creating %s: %s", name, err)
+		}
+		return company
+	}
+
+	group := create("Group", "")
+	europe := create("Europe", group.GetId())
+	greece := create("Greece", europe.GetId())
+
+	if europe.GetParentId() != group.GetId() {
+		t.Errorf("expected Europe to be a subsidiary of Group but got parent %q", europe.GetParentId())
+	}
+
+	_, err := client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+		Company: &companyv1.Company{Name: "Orphan", Type: companyv1.CompanyType_COMPANY_TYPE_CORPORATIONS, ParentId: "not-a-uuid"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for an invalid parent id but got %v", codes.InvalidArgument, err)
+	}
+
+	// Listing the hierarchy needs no token.
+	names := func(res *companyv1.ListHierarchyResponse, err error) []string {
+		if err != nil {
+			t.Fatalf("error listing the hierarchy: %s", err)
+		}
+		var names []string
+		for _, company := range res.GetCompanies() {
+			names = append(names, company.GetName())
+		}
+		return names
+	}
+
+	req := &companyv1.ListHierarchyRequest{Id: group.GetId()}
+	if got := names(client.ListChildren(context.Background(), req)); !reflect.DeepEqual(got, []string{"Europe"}) {
+		t.Errorf("expected the children of Group to be Europe but got %v", got)
+	}
+	if got := names(client.ListSubtree(context.Background(), req)); !reflect.DeepEqual(got, []string{"Group", "Europe", "Greece"}) {
+		t.Errorf("expected the subtree of Group to be Group, Europe and Greece but got %v", got)
+	}
+	req = &companyv1.ListHierarchyRequest{Id: greece.GetId()}
+	if got := names(client.ListAncestors(context.Background(), req)); !reflect.DeepEqual(got, []string{"Europe", "Group"}) {
+		t.Errorf("expected the ancestors of Greece to be Europe and Group but got %v", got)
+	}
+
+	_, err = client.UpdateCompany(ctx, &companyv1.UpdateCompanyRequest{
+		Company:    &companyv1.Company{Id: group.GetId(), ParentId: greece.GetId()},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"parent_id"}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for a cycle but got %v", codes.InvalidArgument, err)
+	}
+
+	_, err = client.DeleteCompany(ctx, &companyv1.DeleteCompanyRequest{Id: europe.GetId()})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected %s for a company with subsidiaries but got %v", codes.FailedPrecondition, err)
+	}
+
+	_, err = client.DeleteCompany(ctx, &companyv1.DeleteCompanyRequest{Id: europe.GetId(), Subsidiaries: companyv1.SubsidiaryPolicy_SUBSIDIARY_POLICY_DETACH})
+	if err != nil {
+		t.Fatalf("error deleting Europe: %s", err)
+	}
+
+	got, err := client.GetCompany(ctx, &companyv1.GetCompanyRequest{Id: greece.GetId()})
+	if err != nil || got.GetParentId() != "" {
+		t.Errorf("expected Greece to be detached but got %v, %v", got, err)
+	}
+
+	_, err = client.DeleteCompany(ctx, &companyv1.DeleteCompanyRequest{Id: greece.GetId(), Subsidiaries: companyv1.SubsidiaryPolicy_SUBSIDIARY_POLICY_CASCADE})
+	if err != nil {
+		t.Errorf("error deleting Greece: %s", err)
+	}
+}
+
+func Test_CompanyAttributes(t *testing.T) {
+	conn, auth := newTestClient(t)
+	client := companyv1.NewCompanyServiceClient(conn)
+	ctx := withToken(t, auth, "tester")
+
+	attributes := func(values map[string]any) *structpb.Struct {
+		s, err := structpb.NewStruct(values)
+		if err != nil {
+			t.Fatalf("error encoding attributes: %s", err)
+		}
+		return s
+	}
+
+	_, err := client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+		Company: &companyv1.Company{Name: "Alpha", Type: companyv1.CompanyType_COMPANY_TYPE_CORPORATIONS, Attributes: attributes(map[string]any{"industry": "mining"})},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for an attribute off its schema but got %v", codes.InvalidArgument, err)
+	}
+
+	alpha, err := client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+		Company: &companyv1.Company{Name: "Alpha", Type: companyv1.CompanyType_COMPANY_TYPE_CORPORATIONS, Attributes: attributes(map[string]any{"industry": "fintech", "founded": 1999})},
+	})
+	if err != nil {
+		t.Fatalf("error creating Alpha: %s", err)
+	}
+	_, err = client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+		Company: &companyv1.Company{Name: "Beta", Type: companyv1.CompanyType_COMPANY_TYPE_CORPORATIONS, Attributes: attributes(map[string]any{"industry": "retail"})},
+	})
+	if err != nil {
+		t.Fatalf("error creating Beta: %s", err)
+	}
+
+	if got := alpha.GetAttributes().AsMap(); !reflect.DeepEqual(got, map[string]any{"industry": "fintech", "founded": 1999.0}) {
+		t.Errorf("expected the attributes of Alpha but got %v", got)
+	}
+
+	// Updates merge the attributes, removing those that are null.
+	updated, err := client.UpdateCompany(ctx, &companyv1.UpdateCompanyRequest{
+		Company:    &companyv1.Company{Id: alpha.GetId(), Attributes: attributes(map[string]any{"industry": "retail", "founded": nil})},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"attributes"}},
+	})
+	if err != nil || !reflect.DeepEqual(updated.GetAttributes().AsMap(), map[string]any{"industry": "retail"}) {
+		t.Errorf("expected the attributes to be merged but got %v, %v", updated, err)
+	}
+
+	res, err := client.ListCompanies(context.Background(), &companyv1.ListCompaniesRequest{
+		Attributes: map[string]*structpb.Value{"industry": structpb.NewStringValue("retail")},
+	})
+	if err != nil || len(res.GetCompanies()) != 2 {
+		t.Errorf("expected both companies in retail but got %v, %v", res, err)
+	}
+
+	_, err = client.ListCompanies(context.Background(), &companyv1.ListCompaniesRequest{
+		Attributes: map[string]*structpb.Value{"industry": structpb.NewListValue(&structpb.ListValue{})},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for a list filter but got %v", codes.InvalidArgument, err)
+	}
+}
+
+func Test_CompanyTags(t *testing.T) {
+	conn, auth := newTestClient(t)
+	client := companyv1.NewCompanyServiceClient(conn)
+	ctx := withToken(t, auth, "tester")
+
+	ids := make(map[string]string)
+	for _, name := range []string{"Alpha", "Beta"} {
+		company, err := client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{
+			Company: &companyv1.Company{Name: name, Type: companyv1.CompanyType_COMPANY_TYPE_CORPORATIONS},
+		})
+		if err != nil {
+			t.Fatalf("error creating %s: %s", name, err)
+		}
+		ids[name] = company.GetId()
+	}
+
+	_, err := client.AddTags(context.Background(), &companyv1.AddTagsRequest{Id: ids["Alpha"], Tags: []string{"tier:1"}})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected %s without a token but got %v", codes.Unauthenticated, err)
+	}
+
+	alpha, err := client.AddTags(ctx, &companyv1.AddTagsRequest{Id: ids["Alpha"], Tags: []string{"Tier:1", "region:emea"}})
+	if err != nil || !reflect.DeepEqual(alpha.GetTags(), []string{"region:emea", "tier:1"}) || alpha.GetUpdatedBy() != "tester" {
+		t.Errorf("expected Alpha to be tagged by tester but got %v, %v", alpha, err)
+	}
+	_, err = client.AddTags(ctx, &companyv1.AddTagsRequest{Id: ids["Beta"], Tags: []string{"tier:1"}})
+	if err != nil {
+		t.Fatalf("error tagging Beta: %s", err)
+	}
+
+	_, err = client.AddTags(ctx, &companyv1.AddTagsRequest{Id: ids["Beta"], Tags: []string{"not a tag"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for an invalid tag but got %v", codes.InvalidArgument, err)
+	}
+
+	res, err := client.ListCompanies(context.Background(), &companyv1.ListCompaniesRequest{Tags: []string{"tier:1", "REGION:EMEA"}})
+	if err != nil || len(res.GetCompanies()) != 1 || res.GetCompanies()[0].GetName() != "Alpha" {
+		t.Errorf("expected only Alpha to have both tags but got %v, %v", res, err)
+	}
+
+	res, err = client.ListCompanies(context.Background(), &companyv1.ListCompaniesRequest{AnyTags: []string{"tier:1", "region:emea"}})
+	if err != nil || len(res.GetCompanies()) != 2 {
+		t.Errorf("expected both companies to have either tag but got %v, %v", res, err)
+	}
+
+	_, err = client.ListCompanies(context.Background(), &companyv1.ListCompaniesRequest{Tags: []string{"not a tag"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected %s for an invalid tag filter but got %v", codes.InvalidArgument, err)
+	}
+
+	alpha, err = client.RemoveTags(ctx, &companyv1.RemoveTagsRequest{Id: ids["Alpha"], Tags: []string{"region:emea", "unknown"}})
+	if err != nil || !reflect.DeepEqual(alpha.GetTags(), []string{"tier:1"}) {
+		t.Errorf("expected Alpha to keep tier:1 only but got %v, %v", alpha, err)
+	}
+
+	tags, err := client.ListTags(context.Background(), &companyv1.ListTagsRequest{})
+	if err != nil || len(tags.GetTags()) != 1 || tags.GetTags()[0].GetName() != "tier:1" || tags.GetTags()[0].GetCount() != 2 {
+		t.Errorf("expected tier:1 on both companies but got %v, %v", tags, err)
+	}
+}
+
+func Test_WatchCompanies(t *testing.T) {
+	conn, auth := newTestClient(t)
+	client := companyv1.NewCompanyServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(withToken(t, auth, "tester"), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchCompanies(ctx, &companyv1.WatchCompaniesRequest{Type: companyv1.CompanyType_COMPANY_TYPE_NON_PROFIT})
+	if err != nil {
+		t.Fatalf("error watching companies: %s", err)
+	}
+
+	// The header is sent once the watch receives changes.
+	_, err = stream.Header()
+	if err != nil {
+		t.Fatalf("error reading header: %s", err)
+	}
+
+	for _, company := range []*companyv1.Company{
+		{Name: "Coop", Type: companyv1.CompanyType_COMPANY_TYPE_COOPERATIVE},
+		{Name: "Charity", Type: companyv1.CompanyType_COMPANY_TYPE_NON_PROFIT},
+	} {
+		_, err = client.CreateCompany(ctx, &companyv1.CreateCompanyRequest{Company: company})
+		if err != nil {
+			t.Fatalf("error creating company: %s", err)
+		}
+	}
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	if event.GetKind() != companyv1.CompanyEvent_KIND_CREATED || event.GetCompany().GetName() != "Charity" {
+		t.Errorf("expected only the creation of Charity to be watched but got %v", event)
+	}
+
+	unauthenticated, _ := client.WatchCompanies(context.Background(), &companyv1.WatchCompaniesRequest{})
+	_, err = unauthenticated.Recv()
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected %s without a token but got %v", codes.Unauthenticated, err)
+	}
+
+	health, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: companyv1.CompanyService_ServiceDesc.ServiceName,
+	})
+	if err != nil || health.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected the company service to be serving but got %v, %v", health, err)
+	}
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Address is a postal address of a company, such as its headquarters or where
+// it is billed, told apart by their labels.
+type Address struct {
+	ID          uuid.UUID `json:"id"`
+	CompanyID   uuid.UUID `json:"company_id"`
+	Label       string    `json:"label"`
+	Line1       string    `json:"line1"`
+	Line2       string    `json:"line2"`
+	City        string    `json:"city"`
+	Region      string    `json:"region"`
+	PostalCode  string    `json:"postal_code"`
+	CountryCode string    `json:"country_code"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// countryCodes are the officially assigned ISO 3166-1 alpha-2 codes.
+var countryCodes = make(map[string]bool)
+
+func init() {
+	for _, code := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ
+		BA BB BD BE BF BG BH BI BJ BL BM BN BO BQ BR BS BT BV BW BY BZ
+		CA CC CD CF CG CH CI CK CL CM CN CO CR CU CV CW CX CY CZ
+		DE DJ DK DM DO DZ
+		EC EE EG EH ER ES ET
+		FI FJ FK FM FO FR
+		GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY
+		HK HM HN HR HT HU
+		ID IE IL IM IN IO IQ IR IS IT
+		JE JM JO JP
+		KE KG KH KI KM KN KP KR KW KY KZ
+		LA LB LC LI LK LR LS LT LU LV LY
+		MA MC MD ME MF MG MH MK ML MM MN MO MP MQ MR MS MT MU MV MW MX MY MZ
+		NA NC NE NF NG NI NL NO NP NR NU NZ
+		OM
+		PA PE PF PG PH PK PL PM PN PR PS PT PW PY
+		QA
+		RE RO RS RU RW
+		SA SB SC SD SE SG SH SI SJ SK SL SM SN SO SR SS ST SV SX SY SZ
+		TC TD TF TG TH TJ TK TL TM TN TO TR TT TV TW TZ
+		UA UG UM US UY UZ
+		VA VC VE VG VI VN VU
+		WF WS
+		YE YT
+		ZA ZM ZW`) {
+		countryCodes[code] = true
+	}
+}
+
+// ValidCountryCode reports whether code is an ISO 3166-1 alpha-2 code, in
+// upper case.
+func ValidCountryCode(code string) bool {
+	return countryCodes[code]
+}
+
+// Validate checks the address against the constraints of the
+// company_addresses table. It returns ValidationErrors when any field is
+// invalid.
+func (a *Address) Validate() error {
+	errs := ValidationErrors{}
+
+	if utf8.RuneCountInString(a.Label) > 50 {
+		errs["label"] = "must not be more than 50 characters long"
+	}
+
+	switch {
+	case strings.TrimSpace(a.Line1) == "":
+		errs["line1"] = "must be provided"
+	case utf8.RuneCountInString(a.Line1) > 200:
+		errs["line1"] = "must not be more than 200 characters long"
+	}
+
+	if utf8.RuneCountInString(a.Line2) > 200 {
+		errs["line2"] = "must not be more than 200 characters long"
+	}
+
+	switch {
+	case strings.TrimSpace(a.City) == "":
+		errs["city"] = "must be provided"
+	case utf8.RuneCountInString(a.City) > 100:
+		errs["city"] = "must not be more than 100 characters long"
+	}
+
+	if utf8.RuneCountInString(a.Region) > 100 {
+		errs["region"] = "must not be more than 100 characters long"
+	}
+
+	if utf8.RuneCountInString(a.PostalCode) > 20 {
+		errs["postal_code"] = "must not be more than 20 characters long"
+	}
+
+	if !ValidCountryCode(a.CountryCode) {
+		errs["country_code"] = "must be an ISO 3166-1 alpha-2 country code"
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
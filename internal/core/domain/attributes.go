@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Attributes are the custom attributes of a company, keyed by the name of
+// their definition. Values are decoded JSON: strings, float64 numbers, bools,
+// nil, []any and map[string]any.
+type Attributes map[string]any
+
+// MarshalJSON encodes missing attributes as an empty object.
+func (a Attributes) MarshalJSON() ([]byte, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(map[string]any(a))
+}
+
+// Value stores the attributes as a JSON object.
+func (a Attributes) Value() (driver.Value, error) {
+	data, err := a.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (a *Attributes) Scan(src any) error {
+	var data []byte
+	switch src := src.(type) {
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("cannot scan %T into attributes", src)
+	}
+
+	*a = nil
+	return json.Unmarshal(data, (*map[string]any)(a))
+}
+
+// Clone returns a deep copy of the attributes, with their numbers decoded as
+// float64 whatever their Go type was.
+func (a Attributes) Clone() Attributes {
+	if a == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]any(a))
+	if err != nil {
+		return nil
+	}
+	var clone Attributes
+	_ = json.Unmarshal(data, &clone)
+	return clone
+}
+
+// Validate checks every attribute against its definition. It returns
+// ValidationErrors, keyed by "attributes.<name>", when an attribute is not
+// defined or does not match the schema of its definition.
+func (a Attributes) Validate(definitions []*AttributeDefinition) error {
+	byName := make(map[string]*AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		byName[definition.Name] = definition
+	}
+
+	errs := ValidationErrors{}
+	for name, value := range a {
+		definition, ok := byName[name]
+		if !ok {
+			errs["attributes."+name] = "is not defined"
+			continue
+		}
+
+		err := definition.Check(value)
+		if err != nil {
+			errs["attributes."+name] = err.Error()
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var attributeNameRX = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// ValidAttributeName reports whether name can name an attribute: lower case
+// letters, digits and underscores, starting with a letter.
+func ValidAttributeName(name string) bool {
+	return len(name) <= 63 && attributeNameRX.MatchString(name)
+}
+
+// AttributeDefinition defines a custom attribute of companies, whose values
+// must match its schema. Schemas are written in the subset of JSON Schema
+// that OpenAPI 3.0 supports.
+type AttributeDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	CreatedBy   string          `json:"created_by"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Validate checks the name, description and schema of the definition. It
+// returns ValidationErrors when any of them is invalid.
+func (d *AttributeDefinition) Validate() error {
+	errs := ValidationErrors{}
+
+	if !ValidAttributeName(d.Name) {
+		errs["name"] = "must be up to 63 lower case letters, digits and underscores, starting with a letter"
+	}
+
+	if utf8.RuneCountInString(d.Description) > 500 {
+		errs["description"] = "must not be more than 500 characters long"
+	}
+
+	_, err := d.compile()
+	if err != nil {
+		errs["schema"] = err.Error()
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *AttributeDefinition) compile() (*openapi3.Schema, error) {
+	if len(d.Schema) == 0 || d.Schema[0] != '{' {
+		return nil, errors.New("must be a JSON object")
+	}
+
+	var schema openapi3.Schema
+	err := json.Unmarshal(d.Schema, &schema)
+	if err != nil {
+		return nil, errors.New("must be a JSON object")
+	}
+
+	err = schema.Validate(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("must be a valid schema: %s", err)
+	}
+	return &schema, nil
+}
+
+// Check returns an error describing why the value does not match the schema
+// of the definition, if it does not.
+func (d *AttributeDefinition) Check(value any) error {
+	schema, err := d.compile()
+	if err != nil {
+		return err
+	}
+
+	err = schema.VisitJSON(value)
+	if err == nil {
+		return nil
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return err
+	}
+	if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+		return fmt.Errorf("at /%s: %s", strings.Join(pointer, "/"), schemaErr.Reason)
+	}
+	return errors.New(schemaErr.Reason)
+}
@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Contact is a person to get in touch with at a company.
+type Contact struct {
+	ID        uuid.UUID `json:"id"`
+	CompanyID uuid.UUID `json:"company_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the contact against the constraints of the company_contacts
+// table. Emails must be bare addresses, without a display name, and phone
+// numbers must have between 7 and 15 digits, optionally preceded by a + and
+// separated by spaces, dots, dashes or parentheses. It returns
+// ValidationErrors when any field is invalid.
+func (c *Contact) Validate() error {
+	errs := ValidationErrors{}
+
+	switch {
+	case strings.TrimSpace(c.Name) == "":
+		errs["name"] = "must be provided"
+	case utf8.RuneCountInString(c.Name) > 100:
+		errs["name"] = "must not be more than 100 characters long"
+	}
+
+	if c.Email != "" {
+		addr, err := mail.ParseAddress(c.Email)
+		switch {
+		case err != nil || addr.Address != c.Email:
+			errs["email"] = "must be a valid email address"
+		case len(c.Email) > 254:
+			errs["email"] = "must not be more than 254 characters long"
+		}
+	}
+
+	if c.Phone != "" {
+		switch {
+		case !validPhone(c.Phone):
+			errs["phone"] = "must be a valid phone number"
+		case len(c.Phone) > 30:
+			errs["phone"] = "must not be more than 30 characters long"
+		}
+	}
+
+	if utf8.RuneCountInString(c.Role) > 100 {
+		errs["role"] = "must not be more than 100 characters long"
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validPhone(phone string) bool {
+	digits := 0
+	for i, r := range phone {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0:
+		case r == ' ' || r == '.' || r == '-' || r == '(' || r == ')':
+		default:
+			return false
+		}
+	}
+	return digits >= 7 && digits <= 15
+}
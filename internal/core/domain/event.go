@@ -0,0 +1,24 @@
+package domain
+
+import "net/http"
+
+// CompanyEvent announces that a company was created, updated or deleted, with
+// the HTTP method of the change as Method. Deleted companies only carry their
+// ID. The ID of the event is assigned when it is broadcast.
+type CompanyEvent struct {
+	ID      string
+	Method  string
+	Company Company
+}
+
+// Kind names the change announced by the event.
+func (e CompanyEvent) Kind() string {
+	switch e.Method {
+	case http.MethodPost:
+		return "created"
+	case http.MethodDelete:
+		return "deleted"
+	default:
+		return "updated"
+	}
+}
@@ -0,0 +1,16 @@
+package domain
+
+// CompanyFilter narrows down the companies returned by listing queries. Zero
+// values do not filter.
+type CompanyFilter struct {
+	Name       string
+	Type       *CompanyType
+	Registered *bool
+	// Attributes matches the companies whose attributes of these names hold
+	// these values, which are strings, float64 numbers or bools.
+	Attributes map[string]any
+	// Tags matches the companies that have every one of these tags, and
+	// AnyTags those that have at least one of them.
+	Tags    []string
+	AnyTags []string
+}
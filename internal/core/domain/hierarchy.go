@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidSubsidiaryPolicy = errors.New(`subsidiaries must be either "restrict", "detach" or "cascade"`)
+
+// SubsidiaryPolicy decides what happens to the subsidiaries of a company when
+// the company is deleted.
+type SubsidiaryPolicy int
+
+const (
+	// RestrictSubsidiaries refuses to delete companies that have
+	// subsidiaries.
+	RestrictSubsidiaries SubsidiaryPolicy = iota
+	// DetachSubsidiaries moves the subsidiaries to the top of their own
+	// groups.
+	DetachSubsidiaries
+	// CascadeSubsidiaries deletes the subsidiaries along with the company,
+	// and theirs along with them.
+	CascadeSubsidiaries
+)
+
+var subsidiaryPolicies = [...]string{
+	RestrictSubsidiaries: "restrict",
+	DetachSubsidiaries:   "detach",
+	CascadeSubsidiaries:  "cascade",
+}
+
+func (p SubsidiaryPolicy) String() string {
+	if p < RestrictSubsidiaries || p > CascadeSubsidiaries {
+		return fmt.Sprintf("SubsidiaryPolicy(%d)", int(p))
+	}
+	return subsidiaryPolicies[p]
+}
+
+// ParseSubsidiaryPolicy parses a policy from its name. The empty string stands
+// for RestrictSubsidiaries.
+func ParseSubsidiaryPolicy(s string) (SubsidiaryPolicy, error) {
+	if s == "" {
+		return RestrictSubsidiaries, nil
+	}
+	for p, name := range subsidiaryPolicies {
+		if s == name {
+			return SubsidiaryPolicy(p), nil
+		}
+	}
+	return 0, ErrInvalidSubsidiaryPolicy
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotentRequest is a mutating request made with an Idempotency-Key, along
+// with its response once it has completed.
+type IdempotentRequest struct {
+	Key         string
+	Subject     string
+	RequestHash []byte
+	ExpiresAt   time.Time
+	// LockedUntil is when the lease of a request still being processed runs
+	// out, after which a retry takes the key over.
+	LockedUntil time.Time
+
+	// Status is 0 while the request is still being processed.
+	Status int
+	Header http.Header
+	Body   []byte
+}
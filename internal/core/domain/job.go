@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is a long-running operation, such as an import or an export, that is
+// processed in the background by the job workers.
+type Job struct {
+	ID         uuid.UUID         `json:"id"`
+	Type       string            `json:"type"`
+	Status     JobStatus         `json:"status"`
+	Params     map[string]string `json:"params"`
+	Progress   int64             `json:"progress"`
+	ResultType string            `json:"result_type,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Attempts   int               `json:"attempts"`
+	CreatedBy  string            `json:"created_by"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has reached a final status.
+func (j *Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
@@ -0,0 +1,312 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned for patches that are malformed, whatever
+	// they are applied to.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchConflict is returned for patches that do not apply to the
+	// document: a path that does not exist or a test that fails.
+	ErrPatchConflict = errors.New("the patch does not apply")
+)
+
+// PatchOperation is an operation of a JSON Patch (RFC 6902). From is only
+// read by move and copy, and Value, which is nil when it is missing, by add,
+// replace and test.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// JSONPatch is a JSON Patch (RFC 6902), a list of operations applied in turn.
+type JSONPatch []PatchOperation
+
+// Apply applies the patch to the document, a decoded JSON value, and returns
+// the result. The operations are checked before any is applied, so a
+// malformed patch returns ErrInvalidPatch whatever the document; a patch that
+// does not apply returns ErrPatchConflict. The document is changed in place,
+// and left in an unspecified state on errors.
+func (p JSONPatch) Apply(doc any) (any, error) {
+	type operation struct {
+		op         string
+		path, from []string
+		value      any
+	}
+
+	ops := make([]operation, len(p))
+	for i, op := range p {
+		if op.Path == nil {
+			return nil, fmt.Errorf("%w: operation %d: path must be provided", ErrInvalidPatch, i)
+		}
+		path, err := parsePointer(*op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %s", ErrInvalidPatch, i, err)
+		}
+		ops[i] = operation{op: op.Op, path: path}
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d: value must be provided", ErrInvalidPatch, i)
+			}
+			err = json.Unmarshal(op.Value, &ops[i].value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %s", ErrInvalidPatch, i, err)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, fmt.Errorf("%w: operation %d: from must be provided", ErrInvalidPatch, i)
+			}
+			ops[i].from, err = parsePointer(*op.From)
+			if err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %s", ErrInvalidPatch, i, err)
+			}
+			if op.Op == "move" && strings.HasPrefix(*op.Path, *op.From+"/") {
+				return nil, fmt.Errorf("%w: operation %d: cannot move %q into itself", ErrInvalidPatch, i, *op.From)
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("%w: operation %d: unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+	}
+
+	for i, op := range ops {
+		var err error
+		switch op.op {
+		case "add":
+			doc, err = addValue(doc, op.path, op.value)
+		case "remove":
+			doc, _, err = removeValue(doc, op.path)
+		case "replace":
+			doc, _, err = removeValue(doc, op.path)
+			if err == nil {
+				doc, err = addValue(doc, op.path, op.value)
+			}
+		case "move":
+			var value any
+			doc, value, err = removeValue(doc, op.from)
+			if err == nil {
+				doc, err = addValue(doc, op.path, value)
+			}
+		case "copy":
+			var value any
+			value, err = getValue(doc, op.from)
+			if err == nil {
+				doc, err = addValue(doc, op.path, cloneValue(value))
+			}
+		case "test":
+			var value any
+			value, err = getValue(doc, op.path)
+			if err == nil && !reflect.DeepEqual(value, op.value) {
+				err = fmt.Errorf("test of %q failed", *p[i].Path)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %s", ErrPatchConflict, i, err)
+		}
+	}
+	return doc, nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer returns the reference tokens of a JSON Pointer (RFC 6901),
+// none for the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("path %q must be empty or start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("path %q has an invalid escape", pointer)
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex returns the index the token refers to in an array of the given
+// length. The index may be the length itself when end is set, and "-" stands
+// for it.
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if end && token == "-" {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > length || (i == length && !end) {
+		return 0, fmt.Errorf("index %s is out of range", token)
+	}
+	return i, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+	}
+	return doc, nil
+}
+
+// updateValue returns the document with the parent of the path replaced by
+// what change returns for it and the last token of the path, which must not
+// be empty.
+func updateValue(doc any, path []string, change func(parent any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%q does not exist", path[0])
+		}
+		child, err := updateValue(child, path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = child
+		return node, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateValue(node[i], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	default:
+		return nil, fmt.Errorf("%q does not exist", path[0])
+	}
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return updateValue(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		default:
+			return nil, fmt.Errorf("%q cannot be added to a %s", token, jsonType(parent))
+		}
+	})
+}
+
+// removeValue returns the document without the value at the path, along with
+// the value.
+func removeValue(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed any
+	doc, err := updateValue(doc, path, func(parent any, token string) (any, error) {
+		switch node := parent.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i:i], node[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+	})
+	return doc, removed, err
+}
+
+// cloneValue returns a deep copy of a decoded JSON value, so that copies do
+// not share the objects and arrays that later operations change.
+func cloneValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		clone := make(map[string]any, len(value))
+		for name, v := range value {
+			clone[name] = cloneValue(v)
+		}
+		return clone
+	case []any:
+		clone := make([]any, len(value))
+		for i, v := range value {
+			clone[i] = cloneValue(v)
+		}
+		return clone
+	default:
+		return value
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	default:
+		return "value"
+	}
+}
@@ -0,0 +1,40 @@
+package domain
+
+// MergePatch applies a JSON Merge Patch (RFC 7386) to the target and returns
+// the result. Both are decoded JSON values. The target is left untouched:
+// the objects it shares with the result are copied before they are changed.
+func MergePatch(target, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := asObject(target)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+
+	result := make(map[string]any, len(targetObject)+len(patchObject))
+	for name, value := range targetObject {
+		result[name] = value
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(result, name)
+			continue
+		}
+		result[name] = MergePatch(result[name], value)
+	}
+	return result
+}
+
+func asObject(value any) (map[string]any, bool) {
+	switch value := value.(type) {
+	case map[string]any:
+		return value, true
+	case Attributes:
+		return value, true
+	default:
+		return nil, false
+	}
+}
@@ -1,9 +1,24 @@
 package domain
 
 import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 )
 
+var ErrInvalidCompanyType = errors.New("invalid company type")
+
+// Company is a company, which may be a subsidiary of the company identified by
+// ParentID. Companies without a parent are at the top of their group.
+// Attributes hold the custom attributes defined by AttributeDefinitions, and
+// Tags classify the company.
 type Company struct {
 	ID                uuid.UUID   `json:"id"`
 	Name              string      `json:"name"`
@@ -11,8 +26,17 @@ type Company struct {
 	NumberOfEmployees int         `json:"number_of_employees"`
 	Registered        bool        `json:"registered"`
 	Type              CompanyType `json:"type"`
+	ParentID          *uuid.UUID  `json:"parent_id"`
+	Attributes        Attributes  `json:"attributes"`
+	Tags              Tags        `json:"tags"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+	CreatedBy         string      `json:"created_by"`
+	UpdatedBy         string      `json:"updated_by"`
 }
 
+// CompanyType is encoded as its slug, such as "non_profit", everywhere but in
+// the database, where it is stored as an integer.
 type CompanyType int
 
 const (
@@ -23,15 +47,119 @@ const (
 	Unknown
 )
 
-func (c CompanyType) ToString() string {
-	switch c {
-	case Corporations:
-		return "Corporations"
-	case NonProfit:
-		return "Non Profit"
-	case Cooperative:
-		return "Cooperative"
+var companyTypeSlugs = [...]string{
+	Corporations:       "corporations",
+	NonProfit:          "non_profit",
+	Cooperative:        "cooperative",
+	SoleProprietorship: "sole_proprietorship",
+	Unknown:            "unknown",
+}
+
+// CompanyTypes returns every company type, in order.
+func CompanyTypes() []CompanyType {
+	return []CompanyType{Corporations, NonProfit, Cooperative, SoleProprietorship, Unknown}
+}
+
+// Valid reports whether c is one of the company types.
+func (c CompanyType) Valid() bool {
+	return c >= Corporations && c <= Unknown
+}
+
+// String returns the slug of the company type.
+func (c CompanyType) String() string {
+	if !c.Valid() {
+		return fmt.Sprintf("CompanyType(%d)", int(c))
+	}
+	return companyTypeSlugs[c]
+}
+
+// ParseCompanyType parses a company type from its slug or its integer value.
+// Slugs are matched regardless of case, and spaces or hyphens may stand for
+// underscores so that names such as "Non Profit" are accepted as well.
+func ParseCompanyType(s string) (CompanyType, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		c := CompanyType(n)
+		if !c.Valid() {
+			return 0, fmt.Errorf("%w: %d", ErrInvalidCompanyType, n)
+		}
+		return c, nil
+	}
+
+	slug := strings.ToLower(strings.TrimSpace(s))
+	slug = strings.NewReplacer(" ", "_", "-", "_").Replace(slug)
+	for c, candidate := range companyTypeSlugs {
+		if slug == candidate {
+			return CompanyType(c), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidCompanyType, s)
+}
+
+func (c CompanyType) MarshalText() ([]byte, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCompanyType, int(c))
+	}
+	return []byte(companyTypeSlugs[c]), nil
+}
+
+func (c *CompanyType) UnmarshalText(text []byte) error {
+	parsed, err := ParseCompanyType(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+func (c CompanyType) MarshalJSON() ([]byte, error) {
+	text, err := c.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON accepts either the slug of the company type or, for clients
+// written before slugs were introduced, its integer value.
+func (c *CompanyType) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		err := json.Unmarshal(data, &s)
+		if err != nil {
+			return err
+		}
+		return c.UnmarshalText([]byte(s))
+	}
+
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	var n int
+	err := json.Unmarshal(data, &n)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidCompanyType, data)
+	}
+	return c.UnmarshalText(data)
+}
+
+// Value stores the company type as its integer value.
+func (c CompanyType) Value() (driver.Value, error) {
+	if !c.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCompanyType, int(c))
+	}
+	return int64(c), nil
+}
+
+func (c *CompanyType) Scan(src any) error {
+	switch src := src.(type) {
+	case int64:
+		return c.UnmarshalText([]byte(strconv.FormatInt(src, 10)))
+	case []byte:
+		return c.UnmarshalText(src)
+	case string:
+		return c.UnmarshalText([]byte(src))
 	default:
-		return "Sole Proprietorship"
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidCompanyType, src)
 	}
 }
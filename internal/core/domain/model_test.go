@@ -0,0 +1,488 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func Test_CompanyTypeJSON(t *testing.T) {
+	testCases := []struct {
+		json         string
+		expectedType CompanyType
+		expectedErr  bool
+	}{
+		{`"non_profit"`, NonProfit, false},
+		{`"Sole Proprietorship"`, SoleProprietorship, false},
+		{`"unknown"`, Unknown, false},
+		{`2`, Cooperative, false},
+		{`"0"`, Corporations, false},
+		{`"partnership"`, 0, true},
+		{`9`, 0, true},
+		{`-1`, 0, true},
+		{`1.5`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range testCases {
+		var companyType CompanyType
+		err := json.Unmarshal([]byte(tt.json), &companyType)
+		if (err != nil) != tt.expectedErr || companyType != tt.expectedType {
+			t.Errorf("%s: expected %v (error %t) but got %v, %v", tt.json, tt.expectedType, tt.expectedErr, companyType, err)
+		}
+		if tt.expectedErr && !errors.Is(err, ErrInvalidCompanyType) {
+			t.Errorf("%s: expected ErrInvalidCompanyType but got %v", tt.json, err)
+		}
+	}
+
+	data, err := json.Marshal(Company{Type: NonProfit})
+	if err != nil {
+		t.Fatalf("error marshalling company: %s", err)
+	}
+	var company map[string]any
+	json.Unmarshal(data, &company)
+	if company["type"] != "non_profit" {
+		t.Errorf("expected the type to be marshalled as its slug but got %v", company["type"])
+	}
+
+	_, err = json.Marshal(CompanyType(9))
+	if !errors.Is(err, ErrInvalidCompanyType) {
+		t.Errorf("expected marshalling an invalid type to fail but got %v", err)
+	}
+}
+
+func Test_CompanyTypeSQL(t *testing.T) {
+	value, err := Cooperative.Value()
+	if err != nil || value != int64(2) {
+		t.Errorf("expected cooperatives to be stored as 2 but got %v, %v", value, err)
+	}
+
+	testCases := []struct {
+		src          any
+		expectedType CompanyType
+		expectedErr  bool
+	}{
+		{int64(3), SoleProprietorship, false},
+		{[]byte("1"), NonProfit, false},
+		{"4", Unknown, false},
+		{int64(7), 0, true},
+		{nil, 0, true},
+	}
+
+	for _, tt := range testCases {
+		var companyType CompanyType
+		err := companyType.Scan(tt.src)
+		if (err != nil) != tt.expectedErr || companyType != tt.expectedType {
+			t.Errorf("%v: expected %v (error %t) but got %v, %v", tt.src, tt.expectedType, tt.expectedErr, companyType, err)
+		}
+	}
+}
+
+func Test_ParseSubsidiaryPolicy(t *testing.T) {
+	testCases := []struct {
+		s              string
+		expectedPolicy SubsidiaryPolicy
+		expectedErr    bool
+	}{
+		{"", RestrictSubsidiaries, false},
+		{"restrict", RestrictSubsidiaries, false},
+		{"detach", DetachSubsidiaries, false},
+		{"cascade", CascadeSubsidiaries, false},
+		{"Cascade", 0, true},
+		{"orphan", 0, true},
+	}
+
+	for _, tt := range testCases {
+		policy, err := ParseSubsidiaryPolicy(tt.s)
+		if (err != nil) != tt.expectedErr || policy != tt.expectedPolicy {
+			t.Errorf("%q: expected %v (error %t) but got %v, %v", tt.s, tt.expectedPolicy, tt.expectedErr, policy, err)
+		}
+		if tt.expectedErr && !errors.Is(err, ErrInvalidSubsidiaryPolicy) {
+			t.Errorf("%q: expected ErrInvalidSubsidiaryPolicy but got %v", tt.s, err)
+		}
+		if !tt.expectedErr && tt.s != "" && policy.String() != tt.s {
+			t.Errorf("%q: expected the policy to be named %q but got %q", tt.s, tt.s, policy.String())
+		}
+	}
+}
+
+func Test_AddressValidate(t *testing.T) {
+	valid := Address{Line1: "1 Main Street", City: "Athens", CountryCode: "GR"}
+
+	testCases := []struct {
+		name          string
+		change        func(*Address)
+		expectedField string
+	}{
+		{"valid", func(*Address) {}, ""},
+		{"missing line1", func(a *Address) { a.Line1 = "  " }, "line1"},
+		{"missing city", func(a *Address) { a.City = "" }, "city"},
+		{"unknown country", func(a *Address) { a.CountryCode = "XX" }, "country_code"},
+		{"lower case country", func(a *Address) { a.CountryCode = "gr" }, "country_code"},
+		{"long postal code", func(a *Address) { a.PostalCode = strings.Repeat("1", 21) }, "postal_code"},
+	}
+
+	for _, tt := range testCases {
+		address := valid
+		tt.change(&address)
+
+		err := address.Validate()
+		var errs ValidationErrors
+		switch {
+		case tt.expectedField == "" && err != nil:
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+		case tt.expectedField != "" && (!errors.As(err, &errs) || errs[tt.expectedField] == ""):
+			t.Errorf("%s: expected an error for %s but got %v", tt.name, tt.expectedField, err)
+		}
+	}
+}
+
+func Test_ContactValidate(t *testing.T) {
+	valid := Contact{Name: "Jane Doe"}
+
+	testCases := []struct {
+		name          string
+		change        func(*Contact)
+		expectedField string
+	}{
+		{"valid", func(*Contact) {}, ""},
+		{"full", func(c *Contact) { c.Email, c.Phone, c.Role = "jane@example.com", "+30 (210) 123-4567", "CFO" }, ""},
+		{"missing name", func(c *Contact) { c.Name = "" }, "name"},
+		{"invalid email", func(c *Contact) { c.Email = "jane" }, "email"},
+		{"email with name", func(c *Contact) { c.Email = "Jane <jane@example.com>" }, "email"},
+		{"short phone", func(c *Contact) { c.Phone = "12345" }, "phone"},
+		{"phone with letters", func(c *Contact) { c.Phone = "210 CALL NOW" }, "phone"},
+	}
+
+	for _, tt := range testCases {
+		contact := valid
+		tt.change(&contact)
+
+		err := contact.Validate()
+		var errs ValidationErrors
+		switch {
+		case tt.expectedField == "" && err != nil:
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+		case tt.expectedField != "" && (!errors.As(err, &errs) || errs[tt.expectedField] == ""):
+			t.Errorf("%s: expected an error for %s but got %v", tt.name, tt.expectedField, err)
+		}
+	}
+}
+
+func Test_AttributeDefinitionValidate(t *testing.T) {
+	valid := AttributeDefinition{Name: "industry", Schema: json.RawMessage(`{"type":"string","enum":["fintech","retail"]}`)}
+
+	testCases := []struct {
+		name          string
+		change        func(*AttributeDefinition)
+		expectedField string
+	}{
+		{"valid", func(*AttributeDefinition) {}, ""},
+		{"empty schema", func(d *AttributeDefinition) { d.Schema = json.RawMessage(`{}`) }, ""},
+		{"missing name", func(d *AttributeDefinition) { d.Name = "" }, "name"},
+		{"upper case name", func(d *AttributeDefinition) { d.Name = "Industry" }, "name"},
+		{"name starting with a digit", func(d *AttributeDefinition) { d.Name = "1st" }, "name"},
+		{"long name", func(d *AttributeDefinition) { d.Name = strings.Repeat("a", 64) }, "name"},
+		{"long description", func(d *AttributeDefinition) { d.Description = strings.Repeat("a", 501) }, "description"},
+		{"missing schema", func(d *AttributeDefinition) { d.Schema = nil }, "schema"},
+		{"schema that is not an object", func(d *AttributeDefinition) { d.Schema = json.RawMessage(`"string"`) }, "schema"},
+		{"invalid schema", func(d *AttributeDefinition) { d.Schema = json.RawMessage(`{"type":"text"}`) }, "schema"},
+	}
+
+	for _, tt := range testCases {
+		definition := valid
+		tt.change(&definition)
+
+		err := definition.Validate()
+		var errs ValidationErrors
+		switch {
+		case tt.expectedField == "" && err != nil:
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+		case tt.expectedField != "" && (!errors.As(err, &errs) || errs[tt.expectedField] == ""):
+			t.Errorf("%s: expected an error for %s but got %v", tt.name, tt.expectedField, err)
+		}
+	}
+}
+
+func Test_WebhookValidate(t *testing.T) {
+	valid := Webhook{URL: "https://example.com/hooks", Events: []string{WebhookCompanyCreated}}
+
+	testCases := []struct {
+		name          string
+		change        func(*Webhook)
+		expectedField string
+	}{
+		{"valid", func(*Webhook) {}, ""},
+		{"public address", func(w *Webhook) { w.URL = "http://93.184.216.34:8080/hooks" }, ""},
+		{"public ipv6 address", func(w *Webhook) { w.URL = "http://[2606:2800:220:1::1]/hooks" }, ""},
+		{"missing url", func(w *Webhook) { w.URL = "" }, "url"},
+		{"ftp url", func(w *Webhook) { w.URL = "ftp://example.com" }, "url"},
+		{"loopback", func(w *Webhook) { w.URL = "http://127.0.0.1:8000/hooks" }, "url"},
+		{"ipv6 loopback", func(w *Webhook) { w.URL = "http://[::1]/hooks" }, "url"},
+		{"ipv4 mapped loopback", func(w *Webhook) { w.URL = "http://[::ffff:127.0.0.1]/hooks" }, "url"},
+		{"nat64 private address", func(w *Webhook) { w.URL = "http://[64:ff9b::a00:1]/hooks" }, "url"},
+		{"local-use nat64 address", func(w *Webhook) { w.URL = "http://[64:ff9b:1::7f00:1]/hooks" }, "url"},
+		{"private address", func(w *Webhook) { w.URL = "http://10.0.0.1/hooks" }, "url"},
+		{"private address of another range", func(w *Webhook) { w.URL = "http://192.168.1.1/hooks" }, "url"},
+		{"link-local address", func(w *Webhook) { w.URL = "http://169.254.169.254/latest/meta-data" }, "url"},
+		{"unspecified address", func(w *Webhook) { w.URL = "http://0.0.0.0/hooks" }, "url"},
+		{"localhost", func(w *Webhook) { w.URL = "http://localhost:8000/hooks" }, "url"},
+		{"upper case localhost", func(w *Webhook) { w.URL = "http://LOCALHOST./hooks" }, "url"},
+		{"internal name", func(w *Webhook) { w.URL = "http://metadata.google.internal/hooks" }, "url"},
+		{"name without a dot", func(w *Webhook) { w.URL = "http://redis:6379" }, "url"},
+		{"unknown event", func(w *Webhook) { w.Events = []string{"company.merged"} }, "events"},
+	}
+
+	for _, tt := range testCases {
+		webhook := valid
+		tt.change(&webhook)
+
+		err := webhook.Validate()
+		var errs ValidationErrors
+		switch {
+		case tt.expectedField == "" && err != nil:
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+		case tt.expectedField != "" && (!errors.As(err, &errs) || errs[tt.expectedField] == ""):
+			t.Errorf("%s: expected an error for %s but got %v", tt.name, tt.expectedField, err)
+		}
+	}
+
+	webhook := valid
+	webhook.URL = "http://127.0.0.1:8000/hooks"
+	if err := webhook.ValidateAllowingPrivateHosts(); err != nil {
+		t.Errorf("expected private hosts to be allowed but got %v", err)
+	}
+}
+
+func Test_AttributesValidate(t *testing.T) {
+	definitions := []*AttributeDefinition{
+		{Name: "industry", Schema: json.RawMessage(`{"type":"string","enum":["fintech","retail"]}`)},
+		{Name: "founded", Schema: json.RawMessage(`{"type":"integer","minimum":1800}`)},
+		{Name: "offices", Schema: json.RawMessage(`{"type":"array","items":{"type":"object","required":["city"],"properties":{"city":{"type":"string"}}}}`)},
+	}
+
+	testCases := []struct {
+		name       string
+		attributes string
+		expected   map[string]string
+	}{
+		{"none", `{}`, nil},
+		{"valid", `{"industry":"fintech","founded":2010,"offices":[{"city":"Athens"}]}`, nil},
+		{"undefined", `{"size":"large"}`, map[string]string{"attributes.size": "is not defined"}},
+		{"wrong type", `{"founded":"2010"}`, map[string]string{"attributes.founded": `value must be an integer`}},
+		{"not in enum", `{"industry":"mining"}`, map[string]string{"attributes.industry": `value is not one of the allowed values ["fintech","retail"]`}},
+		{"nested", `{"offices":[{"city":1}]}`, map[string]string{"attributes.offices": `at /0/city: value must be a string`}},
+	}
+
+	for _, tt := range testCases {
+		var attributes Attributes
+		if err := json.Unmarshal([]byte(tt.attributes), &attributes); err != nil {
+			t.Fatalf("%s: error decoding attributes: %s", tt.name, err)
+		}
+
+		err := attributes.Validate(definitions)
+		var errs ValidationErrors
+		switch {
+		case tt.expected == nil && err != nil:
+			t.Errorf("%s: expected no error but got %v", tt.name, err)
+		case tt.expected != nil && (!errors.As(err, &errs) || !reflect.DeepEqual(map[string]string(errs), tt.expected)):
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func Test_AttributesSQL(t *testing.T) {
+	value, err := Attributes(nil).Value()
+	if err != nil || value != "{}" {
+		t.Errorf("expected no attributes to be stored as {} but got %v, %v", value, err)
+	}
+
+	for _, src := range []any{[]byte(`{"listed":true}`), `{"listed":true}`} {
+		var attributes Attributes
+		err := attributes.Scan(src)
+		if err != nil || !reflect.DeepEqual(attributes, Attributes{"listed": true}) {
+			t.Errorf("%T: expected to scan the attributes but got %v, %v", src, attributes, err)
+		}
+	}
+}
+
+// Test_MergePatch runs the examples of RFC 7386, appendix A.
+func Test_MergePatch(t *testing.T) {
+	testCases := []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range testCases {
+		var target, patch, expected any
+		for _, doc := range []struct {
+			s string
+			v *any
+		}{{tt.target, &target}, {tt.patch, &patch}, {tt.expected, &expected}} {
+			if err := json.Unmarshal([]byte(doc.s), doc.v); err != nil {
+				t.Fatalf("error decoding %s: %s", doc.s, err)
+			}
+		}
+		original, _ := json.Marshal(target)
+
+		got := MergePatch(target, patch)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s patched with %s: expected %s but got %v", tt.target, tt.patch, tt.expected, got)
+		}
+		if after, _ := json.Marshal(target); string(after) != string(original) {
+			t.Errorf("%s patched with %s: expected the target to be left alone but it became %s", tt.target, tt.patch, after)
+		}
+	}
+}
+
+// Test_JSONPatch runs the examples of RFC 6902, appendix A.
+func Test_JSONPatch(t *testing.T) {
+	testCases := []struct {
+		doc, patch, expected string
+		expectedErr          error
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrPatchConflict},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, ErrPatchConflict},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, nil},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``, ErrPatchConflict},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, nil},
+		{`{"foo":null}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"replace","path":"/foo","value":{}}]`, `{"foo":{},"bar":null}`, nil},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/foo","value":null}]`, `{"foo":null}`, nil},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`, ``, ErrPatchConflict},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"baz"}]`, ``, ErrPatchConflict},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, ``, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"erase","path":"/foo"}]`, ``, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"foo"}]`, ``, ErrInvalidPatch},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar"}]`, ``, ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/foo"},{"op":"test","path":"/~2","value":1}]`, ``, ErrInvalidPatch},
+	}
+
+	for _, tt := range testCases {
+		var doc any
+		var patch JSONPatch
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatalf("error decoding %s: %s", tt.doc, err)
+		}
+		if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+			t.Fatalf("error decoding %s: %s", tt.patch, err)
+		}
+
+		got, err := patch.Apply(doc)
+		if tt.expectedErr != nil {
+			if !errors.Is(err, tt.expectedErr) {
+				t.Errorf("%s patched with %s: expected %v but got %v", tt.doc, tt.patch, tt.expectedErr, err)
+			}
+			continue
+		}
+
+		var expected any
+		_ = json.Unmarshal([]byte(tt.expected), &expected)
+		if err != nil || !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s patched with %s: expected %s but got %v, %v", tt.doc, tt.patch, tt.expected, got, err)
+		}
+	}
+}
+
+func Test_PatchCompany(t *testing.T) {
+	newCompany := func() *Company {
+		return &Company{
+			Name:              "XM",
+			Description:       "A broker",
+			NumberOfEmployees: 10,
+			Registered:        true,
+			Type:              NonProfit,
+			Attributes:        Attributes{"industry": "fintech", "listed": true},
+			Tags:              Tags{"tier:1"},
+			CreatedBy:         "tester",
+		}
+	}
+
+	testCases := []struct {
+		name           string
+		patch          string
+		expected       func(*Company)
+		expectedFields []string
+	}{
+		{"clear description", `{"description": null}`, func(c *Company) { c.Description = "" }, nil},
+		{"merge attributes", `{"attributes": {"listed": null, "founded": 2010}, "type": "cooperative"}`, func(c *Company) {
+			c.Attributes = Attributes{"industry": "fintech", "founded": 2010.0}
+			c.Type = Cooperative
+		}, nil},
+		{"remove attributes", `{"attributes": null}`, func(c *Company) { c.Attributes = nil }, nil},
+		{"unchanged read only fields", `{"tags": ["tier:1"], "created_by": "tester"}`, func(c *Company) {}, nil},
+		{"read only fields", `{"id": "0e6c0248-a659-41d0-b860-795df3a53f44", "tags": null}`, nil, []string{"id", "tags"}},
+		{"required fields", `{"type": null, "number_of_employees": null}`, nil, []string{"number_of_employees", "type"}},
+		{"wrong types", `{"name": 1, "number_of_employees": 1.5, "parent_id": "parent", "type": "partnership"}`, nil, []string{"name", "number_of_employees", "parent_id", "type"}},
+		{"unknown fields", `{"descripton": "A broker"}`, nil, []string{"descripton"}},
+	}
+
+	for _, tt := range testCases {
+		var patch any
+		if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+			t.Fatalf("%s: error decoding %s: %s", tt.name, tt.patch, err)
+		}
+
+		company := newCompany()
+		err := PatchCompany(company, func(doc any) (any, error) {
+			return MergePatch(doc, patch), nil
+		})
+
+		if tt.expectedFields != nil {
+			var errs ValidationErrors
+			var fields []string
+			if errors.As(err, &errs) {
+				for field := range errs {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+			}
+			if !reflect.DeepEqual(fields, tt.expectedFields) {
+				t.Errorf("%s: expected errors of %v but got %v", tt.name, tt.expectedFields, err)
+			}
+			if !reflect.DeepEqual(company, newCompany()) {
+				t.Errorf("%s: expected the company to be left untouched but got %+v", tt.name, company)
+			}
+			continue
+		}
+
+		expected := newCompany()
+		tt.expected(expected)
+		if err != nil || !reflect.DeepEqual(company, expected) {
+			t.Errorf("%s: expected %+v but got %+v, %v", tt.name, expected, company, err)
+		}
+	}
+
+	company := newCompany()
+	err := PatchCompany(company, func(any) (any, error) { return []any{}, nil })
+	if !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("expected a patch that is not an object to be invalid but got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// companyPatchFields are the members of the JSON document of a company that
+// patches may change, with the field each is decoded into and the error of
+// the values it does not take. Required members cannot be removed or null.
+var companyPatchFields = []struct {
+	name     string
+	field    func(*Company) any
+	invalid  string
+	required bool
+}{
+	{"name", func(c *Company) any { return &c.Name }, "must be a string", false},
+	{"description", func(c *Company) any { return &c.Description }, "must be a string", false},
+	{"number_of_employees", func(c *Company) any { return &c.NumberOfEmployees }, "must be an integer", true},
+	{"registered", func(c *Company) any { return &c.Registered }, "must be a boolean", true},
+	{"type", func(c *Company) any { return &c.Type }, "must be a valid company type", true},
+	{"parent_id", func(c *Company) any { return &c.ParentID }, "must be a uuid or null", false},
+	{"attributes", func(c *Company) any { return &c.Attributes }, "must be an object or null", false},
+}
+
+// companyReadOnlyFields are the members of the JSON document of a company that
+// patches must leave as they are.
+var companyReadOnlyFields = []string{"id", "tags", "created_at", "updated_at", "created_by", "updated_by"}
+
+// PatchCompany applies a patch to the JSON document of the company, the way it
+// is returned by the API, and sets the fields of the company to those of the
+// result. The writable members that the patch removes or sets to null take
+// their zero value, so that a description can be cleared, but
+// number_of_employees, registered and type cannot be removed. apply is passed
+// a document of its own, which it may change in place.
+//
+// It returns the errors of apply as they are, and ValidationErrors when the
+// result adds members, changes read-only ones or has values of the wrong
+// type. The company is left untouched on errors.
+func PatchCompany(company *Company, apply func(doc any) (any, error)) error {
+	data, err := json.Marshal(company)
+	if err != nil {
+		return err
+	}
+
+	var original, doc any
+	for _, v := range []*any{&original, &doc} {
+		err = json.Unmarshal(data, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	doc, err = apply(doc)
+	if err != nil {
+		return err
+	}
+	patched, ok := doc.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: the company must remain an object", ErrInvalidPatch)
+	}
+
+	errs := ValidationErrors{}
+	fields := original.(map[string]any)
+	for name := range patched {
+		if _, ok := fields[name]; !ok {
+			errs[name] = "is not a field of companies"
+		}
+	}
+	for _, name := range companyReadOnlyFields {
+		if !reflect.DeepEqual(patched[name], fields[name]) {
+			errs[name] = "cannot be changed"
+		}
+	}
+
+	result := *company
+	for _, f := range companyPatchFields {
+		value := patched[f.name]
+		if value == nil && f.required {
+			errs[f.name] = "must be provided"
+			continue
+		}
+
+		target := f.field(&result)
+		reflect.ValueOf(target).Elem().SetZero()
+		if value == nil {
+			continue
+		}
+
+		data, err := json.Marshal(value)
+		if err == nil {
+			err = json.Unmarshal(data, target)
+		}
+		if err != nil {
+			errs[f.name] = f.invalid
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	*company = result
+	return nil
+}
@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Requests requests per Period, in bursts of up to Requests
+// requests. A limit of zero requests allows every request.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether the limit allows every request.
+func (l RateLimit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// RateLimitResult is the outcome of taking a request from a client's bucket.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     RateLimit
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed when this one
+	// was not.
+	RetryAfter time.Duration
+}
+
+// RateLimits holds the limits of the routes that have their own along with
+// the limit of every other route. Routes are keyed by their pattern, e.g.
+// "/companies/{id}", optionally preceded by a method, e.g.
+// "GET /companies/{id}".
+type RateLimits struct {
+	Default RateLimit
+	Routes  map[string]RateLimit
+}
+
+// For returns the limit of the route with the given method and pattern along
+// with the key it is configured under, which is empty for the default limit.
+func (l RateLimits) For(method, pattern string) (string, RateLimit) {
+	for _, route := range []string{method + " " + pattern, pattern} {
+		if limit, ok := l.Routes[route]; ok {
+			return route, limit
+		}
+	}
+	return "", l.Default
+}
+
+// ParseRateLimit parses a limit in the form "100/1m".
+func ParseRateLimit(s string) (RateLimit, error) {
+	requests, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("rate limit %q must be in the form requests/period", s)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q must have a non-negative number of requests", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("rate limit %q must have a positive period", s)
+	}
+
+	return RateLimit{Requests: n, Period: d}, nil
+}
+
+// ParseRouteRateLimits parses a comma separated list of route limits such as
+// "GET /companies/{id}=300/1m, /companies:batch=10/1m".
+func ParseRouteRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("route rate limit %q must be in the form route=requests/period", entry)
+		}
+
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			return nil, err
+		}
+
+		limits[strings.Join(strings.Fields(route), " ")] = limit
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,87 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tag is a label that classifies companies, such as "region:emea" or
+// "tier:1", along with the number of companies that have it.
+type Tag struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,49}$`)
+
+// NormalizeTag returns the tag in the form it is stored in, without
+// surrounding spaces and in lower case.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// ValidTag reports whether the normalized tag is up to 50 lower case letters,
+// digits, underscores, dots, colons and hyphens, starting with a letter or a
+// digit.
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
+
+// Tags are the tags of a company, sorted.
+type Tags []string
+
+// MarshalJSON encodes missing tags as an empty array.
+func (t Tags) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(t))
+}
+
+// Scan reads the tags from a comma separated list, which tags cannot contain,
+// or from NULL when there are none.
+func (t *Tags) Scan(src any) error {
+	var list string
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		list = string(src)
+	case string:
+		list = src
+	default:
+		return fmt.Errorf("cannot scan %T into tags", src)
+	}
+
+	*t = nil
+	if list != "" {
+		*t = strings.Split(list, ",")
+	}
+	return nil
+}
+
+// ParseTags normalizes the tags, dropping duplicates, and returns them
+// sorted. It returns ValidationErrors when there are none or one is not
+// valid.
+func ParseTags(tags []string) (Tags, error) {
+	seen := make(map[string]bool, len(tags))
+	parsed := Tags{}
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if !ValidTag(tag) {
+			return nil, ValidationErrors{"tags": fmt.Sprintf("must be up to 50 letters, digits, underscores, dots, colons and hyphens, starting with a letter or a digit, but got %q", tag)}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			parsed = append(parsed, tag)
+		}
+	}
+
+	if len(parsed) == 0 {
+		return nil, ValidationErrors{"tags": "must contain at least one tag"}
+	}
+	sort.Strings(parsed)
+	return parsed, nil
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationErrors maps the name of each invalid field to the reason it was
+// rejected.
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	fields := make([]string, 0, len(v))
+	for field := range v {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, fmt.Sprintf("%s %s", field, v[field]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks the company against the constraints of the companies table.
+// It returns ValidationErrors when any field is invalid.
+func (c *Company) Validate() error {
+	errs := ValidationErrors{}
+
+	switch {
+	case c.Name == "":
+		errs["name"] = "must be provided"
+	case utf8.RuneCountInString(c.Name) > 15:
+		errs["name"] = "must not be more than 15 characters long"
+	}
+
+	if utf8.RuneCountInString(c.Description) > 3000 {
+		errs["description"] = "must not be more than 3000 characters long"
+	}
+
+	if c.NumberOfEmployees < 0 {
+		errs["number_of_employees"] = "must not be negative"
+	}
+
+	if !c.Type.Valid() {
+		errs["type"] = "must be a valid company type"
+	}
+
+	if c.ParentID != nil && *c.ParentID == c.ID {
+		errs["parent_id"] = "must not be the company itself"
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
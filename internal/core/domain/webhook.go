@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The events webhooks can subscribe to.
+const (
+	WebhookCompanyCreated = "company.created"
+	WebhookCompanyUpdated = "company.updated"
+	WebhookCompanyDeleted = "company.deleted"
+)
+
+var webhookEvents = map[string]bool{
+	WebhookCompanyCreated: true,
+	WebhookCompanyUpdated: true,
+	WebhookCompanyDeleted: true,
+}
+
+// reservedNetworks are the networks, besides the loopback, private and
+// link-local ones, that are not reachable on the internet. The NAT64
+// prefixes are included as they embed an IPv4 address, which may well be a
+// private one, that a gateway translates them to.
+var reservedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+	mustParseCIDR("64:ff9b::/96"),
+	mustParseCIDR("64:ff9b:1::/48"),
+}
+
+// internalDomains are the domains of hosts that only resolve within a
+// private network.
+var internalDomains = []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// PublicIP reports whether ip is reachable on the internet, as opposed to a
+// loopback, private, link-local, multicast or reserved address.
+func PublicIP(ip net.IP) bool {
+	if ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicHost reports whether host is a public IP address or a name that is
+// not internal. Names are only resolved when delivering, so they must be
+// checked again then.
+func publicHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return PublicIP(ip)
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	// Names without a dot, such as localhost, are completed by the search
+	// domains of the resolver.
+	if !strings.Contains(host, ".") {
+		return false
+	}
+	for _, domain := range internalDomains {
+		if strings.HasSuffix(host, domain) {
+			return false
+		}
+	}
+	return true
+}
+
+// Webhook is a subscription of a URL to company events. Webhooks with no
+// events are subscribed to all of them.
+type Webhook struct {
+	ID     uuid.UUID `json:"id"`
+	URL    string    `json:"url"`
+	Secret string    `json:"-"`
+	Events []string  `json:"events"`
+	Active bool      `json:"active"`
+	// ConsecutiveFailures counts the failed delivery attempts since the last
+	// successful one. Webhooks are disabled once it grows too large.
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedBy           string     `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Validate returns ValidationErrors when the URL is not an absolute HTTP URL
+// of a public host or an event is unknown.
+func (w *Webhook) Validate() error {
+	return w.validate(false)
+}
+
+// ValidateAllowingPrivateHosts is Validate for webhooks that may also point
+// to loopback, private or internal hosts.
+func (w *Webhook) ValidateAllowingPrivateHosts() error {
+	return w.validate(true)
+}
+
+func (w *Webhook) validate(allowPrivateHosts bool) error {
+	errs := ValidationErrors{}
+
+	u, err := url.Parse(w.URL)
+	switch {
+	case w.URL == "":
+		errs["url"] = "must be provided"
+	case len(w.URL) > 2048:
+		errs["url"] = "must not be more than 2048 characters long"
+	case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		errs["url"] = "must be an absolute http or https URL"
+	case !allowPrivateHosts && !publicHost(u.Hostname()):
+		errs["url"] = "must not point to a loopback, private or internal host"
+	}
+
+	for _, event := range w.Events {
+		if !webhookEvents[event] {
+			errs["events"] = "must only contain company.created, company.updated or company.deleted"
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Subscribed reports whether the webhook is subscribed to the event.
+func (w *Webhook) Subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed deliveries have failed every attempt and are no
+	// longer retried.
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook, along with the
+// outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id"`
+	WebhookID      uuid.UUID             `json:"webhook_id"`
+	EventID        uuid.UUID             `json:"event_id"`
+	Event          string                `json:"event"`
+	Payload        []byte                `json:"-"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
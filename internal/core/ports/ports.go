@@ -1,13 +1,157 @@
 package ports
 
 import (
+	"context"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/petrostrak/xm-companies/internal/core/domain"
 )
 
 type CompanyRepository interface {
-	Create(*domain.Company) error
-	Update(*domain.Company) error
+	Create(context.Context, *domain.Company) error
+	Update(context.Context, *domain.Company) error
+	// Delete soft deletes the company, unless it has subsidiaries.
+	Delete(ctx context.Context, id uuid.UUID, deletedBy string) error
+	// Restore undoes a soft delete on behalf of restoredBy, unless the parent
+	// of the company is no longer there.
+	Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error)
+	// Purge permanently removes the company, unless it has subsidiaries.
+	Purge(context.Context, uuid.UUID) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	Get(context.Context, uuid.UUID) (*domain.Company, error)
+	// Children returns the subsidiaries of the company, ordered by name.
+	Children(context.Context, uuid.UUID) ([]*domain.Company, error)
+	// Ancestors returns the parent of the company, its parent and so on up
+	// to the top of the group.
+	Ancestors(context.Context, uuid.UUID) ([]*domain.Company, error)
+	// Subtree returns the company followed by every subsidiary below it,
+	// level by level and ordered by name within each level.
+	Subtree(context.Context, uuid.UUID) ([]*domain.Company, error)
+	// InTx runs fn against a repository bound to a single transaction, which
+	// is rolled back when ctx is done before it commits.
+	InTx(ctx context.Context, fn func(CompanyRepository) error) error
+	Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error)
+	Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error
+	// List returns up to limit companies matching the filter, ordered by
+	// name, starting after the company named after.
+	List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error)
+	// UpdateTags adds and removes tags of the company on behalf of
+	// updatedBy, and returns the company with its tags.
+	UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error)
+	// Tags returns the tags of the companies that are not deleted, with the
+	// number of companies that have each, ordered by name.
+	Tags(context.Context) ([]*domain.Tag, error)
+}
+
+// AddressRepository stores the addresses of companies. Addresses are removed
+// along with their company when it is purged.
+type AddressRepository interface {
+	// Create stores the address, unless its company does not exist.
+	Create(context.Context, *domain.Address) error
+	Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Address, error)
+	Update(context.Context, *domain.Address) error
+	Delete(ctx context.Context, companyID, id uuid.UUID) error
+	// List returns the addresses of the companies, oldest first.
+	List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Address, error)
+}
+
+// ContactRepository stores the contacts of companies. Contacts are removed
+// along with their company when it is purged.
+type ContactRepository interface {
+	// Create stores the contact, unless its company does not exist.
+	Create(context.Context, *domain.Contact) error
+	Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Contact, error)
+	Update(context.Context, *domain.Contact) error
+	Delete(ctx context.Context, companyID, id uuid.UUID) error
+	// List returns the contacts of the companies, oldest first.
+	List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Contact, error)
+}
+
+// AttributeDefinitionRepository stores the definitions of the custom
+// attributes of companies, keyed by their name.
+type AttributeDefinitionRepository interface {
+	// Create stores the definition, unless one with the same name exists.
+	Create(context.Context, *domain.AttributeDefinition) error
+	Get(ctx context.Context, name string) (*domain.AttributeDefinition, error)
+	// Update changes the description and schema of the definition.
+	Update(context.Context, *domain.AttributeDefinition) error
+	Delete(ctx context.Context, name string) error
+	// List returns every definition, ordered by name.
+	List(context.Context) ([]*domain.AttributeDefinition, error)
+}
+
+type JobRepository interface {
+	Create(context.Context, *domain.Job) error
+	Get(context.Context, uuid.UUID) (*domain.Job, error)
+	// Claim marks the oldest queued job as running and returns it, or returns
+	// nil when there is no queued job.
+	Claim(context.Context) (*domain.Job, error)
+	// Progress records the progress of a running job. It fails once the job
+	// is no longer running, e.g. because it was cancelled.
+	Progress(ctx context.Context, id uuid.UUID, progress int64) error
+	// Finish records the final status of a running job.
+	Finish(context.Context, *domain.Job) error
+	Cancel(context.Context, uuid.UUID) (*domain.Job, error)
+	// Requeue queues again the failed jobs, and the running jobs that have not
+	// reported progress within stale, that have been attempted fewer than
+	// maxAttempts times.
+	Requeue(ctx context.Context, maxAttempts int, stale time.Duration) (int64, error)
+}
+
+type IdempotencyRepository interface {
+	// Reserve stores the request under its key and returns nil, unless an
+	// unexpired request already holds the key, in which case that request is
+	// returned instead. A request with the same hash takes over the key of
+	// one still being processed whose lease has run out, and gets nil too.
+	Reserve(context.Context, *domain.IdempotentRequest) (*domain.IdempotentRequest, error)
+	// Extend renews the lease of a reserved request still being processed.
+	Extend(ctx context.Context, subject, key string, lockedUntil time.Time) error
+	// Complete stores the response of a reserved request.
+	Complete(context.Context, *domain.IdempotentRequest) error
+	// Release frees the key of a reserved request.
+	Release(ctx context.Context, subject, key string) error
+	DeleteExpired(context.Context) (int64, error)
+}
+
+type WebhookRepository interface {
+	Create(context.Context, *domain.Webhook) error
+	Get(context.Context, uuid.UUID) (*domain.Webhook, error)
+	// List returns the webhooks created by createdBy, or all of them when
+	// createdBy is empty.
+	List(ctx context.Context, createdBy string) ([]*domain.Webhook, error)
+	Update(context.Context, *domain.Webhook) error
+	Delete(context.Context, uuid.UUID) error
+	// Enqueue queues a delivery of the payload of the event to every active
+	// webhook subscribed to it and returns how many were queued.
+	Enqueue(ctx context.Context, eventID uuid.UUID, event string, payload []byte) (int64, error)
+	// Claim leases the delivery that has been due the longest among those of
+	// active webhooks, counting an attempt and postponing its next one by
+	// lease, and returns it with its webhook. It returns nil when no delivery
+	// is due.
+	Claim(ctx context.Context, lease time.Duration) (*domain.WebhookDelivery, *domain.Webhook, error)
+	// Record stores the outcome of an attempt of a claimed delivery and counts
+	// it towards the consecutive failures of its webhook, disabling the
+	// webhook once they reach disableAfter. It reports whether the webhook was
+	// disabled.
+	Record(ctx context.Context, delivery *domain.WebhookDelivery, disableAfter int) (bool, error)
+	// Deliveries returns the latest deliveries to the webhook, newest first.
+	Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error)
+}
+
+type CompanyCache interface {
+	// Get returns the cached company and whether it was cached.
+	Get(uuid.UUID) (*domain.Company, bool, error)
+	Set(*domain.Company) error
 	Delete(uuid.UUID) error
-	Get(uuid.UUID) (*domain.Company, error)
+}
+
+type RateLimitStore interface {
+	// Take refills the token bucket identified by key for the time elapsed
+	// until now and then takes a token from it if there is one left.
+	Take(key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error)
+}
+
+type CompanyProducer interface {
+	ProduceCompany(company *domain.Company, method string) error
 }
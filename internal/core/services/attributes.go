@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// validate checks the fields of the company and its custom attributes, which
+// must each be defined and match the schema of their definition. The
+// definitions are only read when the company has attributes.
+func (c *CompanyService) validate(ctx context.Context, company *domain.Company) error {
+	errs := domain.ValidationErrors{}
+
+	err := company.Validate()
+	if err != nil && !errors.As(err, &errs) {
+		return err
+	}
+
+	if len(company.Attributes) > 0 {
+		var definitions []*domain.AttributeDefinition
+		if c.definitions != nil {
+			ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+			defer cancel()
+
+			definitions, err = c.definitions.List(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		var attributeErrs domain.ValidationErrors
+		if errors.As(company.Attributes.Validate(definitions), &attributeErrs) {
+			for field, msg := range attributeErrs {
+				errs[field] = msg
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// AttributeService manages the definitions of the custom attributes of
+// companies. Deleting a definition leaves the values of its attribute on the
+// companies that have one, which then fail validation until it is removed
+// from them or defined again.
+type AttributeService struct {
+	definitions ports.AttributeDefinitionRepository
+	config      CompanyConfig
+}
+
+func NewAttributeService(definitions ports.AttributeDefinitionRepository, config CompanyConfig) *AttributeService {
+	return &AttributeService{definitions, config}
+}
+
+// List returns every definition, ordered by name.
+func (a *AttributeService) List(ctx context.Context) ([]*domain.AttributeDefinition, error) {
+	ctx, cancel := withTimeout(ctx, a.config.ReadTimeout)
+	defer cancel()
+
+	return a.definitions.List(ctx)
+}
+
+func (a *AttributeService) Get(ctx context.Context, name string) (*domain.AttributeDefinition, error) {
+	ctx, cancel := withTimeout(ctx, a.config.ReadTimeout)
+	defer cancel()
+
+	return a.definitions.Get(ctx, name)
+}
+
+func (a *AttributeService) Create(ctx context.Context, definition *domain.AttributeDefinition) error {
+	err := definition.Validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, a.config.WriteTimeout)
+	defer cancel()
+
+	return a.definitions.Create(ctx, definition)
+}
+
+// Update changes the description and schema of the definition. The values
+// already stored are not checked against the new schema.
+func (a *AttributeService) Update(ctx context.Context, definition *domain.AttributeDefinition) error {
+	err := definition.Validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, a.config.WriteTimeout)
+	defer cancel()
+
+	return a.definitions.Update(ctx, definition)
+}
+
+func (a *AttributeService) Delete(ctx context.Context, name string) error {
+	ctx, cancel := withTimeout(ctx, a.config.WriteTimeout)
+	defer cancel()
+
+	return a.definitions.Delete(ctx, name)
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// fakeDefinitions keeps attribute definitions in a map and counts how often
+// they are listed.
+type fakeDefinitions struct {
+	definitions map[string]*domain.AttributeDefinition
+	listed      int
+}
+
+func (f *fakeDefinitions) Create(ctx context.Context, definition *domain.AttributeDefinition) error {
+	f.definitions[definition.Name] = definition
+	return nil
+}
+
+func (f *fakeDefinitions) Get(ctx context.Context, name string) (*domain.AttributeDefinition, error) {
+	definition, ok := f.definitions[name]
+	if !ok {
+		return nil, errNotFound
+	}
+	return definition, nil
+}
+
+func (f *fakeDefinitions) Update(ctx context.Context, definition *domain.AttributeDefinition) error {
+	if _, ok := f.definitions[definition.Name]; !ok {
+		return errNotFound
+	}
+	f.definitions[definition.Name] = definition
+	return nil
+}
+
+func (f *fakeDefinitions) Delete(ctx context.Context, name string) error {
+	if _, ok := f.definitions[name]; !ok {
+		return errNotFound
+	}
+	delete(f.definitions, name)
+	return nil
+}
+
+func (f *fakeDefinitions) List(ctx context.Context) ([]*domain.AttributeDefinition, error) {
+	f.listed++
+	definitions := []*domain.AttributeDefinition{}
+	for _, definition := range f.definitions {
+		definitions = append(definitions, definition)
+	}
+	return definitions, nil
+}
+
+func Test_CompanyAttributes(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	definitions := &fakeDefinitions{definitions: make(map[string]*domain.AttributeDefinition)}
+	service := NewCompanyService(repo, definitions, &fakeProducer{}, CompanyConfig{})
+	attributes := NewAttributeService(definitions, CompanyConfig{})
+
+	var errs domain.ValidationErrors
+	err := attributes.Create(ctx, &domain.AttributeDefinition{Name: "founded", Schema: json.RawMessage(`{"type":"number"}`)})
+	if err != nil {
+		t.Fatalf("error creating attribute definition: %s", err)
+	}
+	err = attributes.Create(ctx, &domain.AttributeDefinition{Name: "Size", Schema: json.RawMessage(`{"type":"string"}`)})
+	if !errors.As(err, &errs) || errs["name"] == "" || len(definitions.definitions) != 1 {
+		t.Errorf("expected an invalid definition to be rejected before storing it but got %v", err)
+	}
+
+	err = service.Create(ctx, &domain.Company{Name: "Plain", Type: domain.Cooperative})
+	if err != nil || definitions.listed != 0 {
+		t.Errorf("expected a company without attributes to be created without reading the definitions but got %v after %d reads", err, definitions.listed)
+	}
+
+	company := &domain.Company{Name: "Founded", Type: domain.Cooperative, Attributes: domain.Attributes{"founded": 2010.0}}
+	err = service.Create(ctx, company)
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	err = service.Create(ctx, &domain.Company{Type: domain.Cooperative, Attributes: domain.Attributes{"founded": "2010", "size": "large"}})
+	if !errors.As(err, &errs) || errs["name"] == "" || errs["attributes.founded"] == "" || errs["attributes.size"] == "" {
+		t.Errorf("expected the errors of the fields and the attributes together but got %v", err)
+	}
+
+	err = attributes.Delete(ctx, "founded")
+	if err != nil {
+		t.Fatalf("error deleting attribute definition: %s", err)
+	}
+	company.Description = "Founded long ago"
+	err = service.Update(ctx, company)
+	if !errors.As(err, &errs) || errs["attributes.founded"] == "" {
+		t.Errorf("expected a company with the value of a deleted attribute to be rejected but got %v", err)
+	}
+	company.Attributes = nil
+	err = service.Update(ctx, company)
+	if err != nil {
+		t.Errorf("expected the company to be updated once the value is removed but got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// ErrBatchAborted is reported for the items of an atomic batch that were not
+// applied because another item failed.
+var ErrBatchAborted = errors.New("not applied because another item in the batch failed")
+
+// CompanyUpdate is a single item of a batch update: the company to change and
+// the changes to apply to it.
+type CompanyUpdate struct {
+	ID    uuid.UUID
+	Apply func(*domain.Company)
+}
+
+// CreateBatch creates the given companies and returns one error per company,
+// nil for those that were created. When atomic is set either every company is
+// created or none is.
+func (c *CompanyService) CreateBatch(ctx context.Context, companies []*domain.Company, atomic bool) []error {
+	errs := c.runBatch(ctx, len(companies), atomic,
+		func(i int) error {
+			return c.validate(ctx, companies[i])
+		},
+		func(ctx context.Context, repo ports.CompanyRepository, i int) error {
+			return repo.Create(ctx, companies[i])
+		},
+	)
+
+	c.produceBatch(errs, http.MethodPost, func(i int) *domain.Company { return companies[i] })
+	return errs
+}
+
+// UpdateBatch applies the given updates on behalf of updatedBy and returns the
+// updated companies along with one error per update.
+func (c *CompanyService) UpdateBatch(ctx context.Context, updates []CompanyUpdate, updatedBy string, atomic bool) ([]*domain.Company, []error) {
+	companies := make([]*domain.Company, len(updates))
+
+	errs := c.runBatch(ctx, len(updates), atomic, nil, func(ctx context.Context, repo ports.CompanyRepository, i int) error {
+		company, err := repo.Get(ctx, updates[i].ID)
+		if err != nil {
+			return err
+		}
+
+		updates[i].Apply(company)
+		company.UpdatedBy = updatedBy
+
+		err = c.validate(ctx, company)
+		if err != nil {
+			return err
+		}
+
+		err = repo.Update(ctx, company)
+		if err != nil {
+			return err
+		}
+
+		companies[i] = company
+		return nil
+	})
+
+	c.produceBatch(errs, http.MethodPatch, func(i int) *domain.Company { return companies[i] })
+	return companies, errs
+}
+
+// DeleteBatch soft deletes the given companies on behalf of deletedBy and
+// returns one error per company.
+func (c *CompanyService) DeleteBatch(ctx context.Context, ids []uuid.UUID, deletedBy string, atomic bool) []error {
+	errs := c.runBatch(ctx, len(ids), atomic, nil, func(ctx context.Context, repo ports.CompanyRepository, i int) error {
+		return repo.Delete(ctx, ids[i], deletedBy)
+	})
+
+	c.produceBatch(errs, http.MethodDelete, func(i int) *domain.Company { return &domain.Company{ID: ids[i]} })
+	return errs
+}
+
+// runBatch validates and applies n items. In atomic mode the items are applied
+// in a single transaction that is rolled back as soon as one of them fails,
+// otherwise each item is applied on its own. The whole batch is bounded by the
+// write timeout.
+func (c *CompanyService) runBatch(ctx context.Context, n int, atomic bool, validate func(i int) error, apply func(context.Context, ports.CompanyRepository, int) error) []error {
+	errs := make([]error, n)
+
+	if validate != nil {
+		invalid := false
+		for i := range errs {
+			errs[i] = validate(i)
+			if errs[i] != nil {
+				invalid = true
+			}
+		}
+
+		if invalid && atomic {
+			abortBatch(errs)
+			return errs
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	if !atomic {
+		for i := range errs {
+			if errs[i] == nil {
+				errs[i] = apply(ctx, c.repo, i)
+			}
+		}
+		return errs
+	}
+
+	err := c.repo.InTx(ctx, func(repo ports.CompanyRepository) error {
+		for i := range errs {
+			if err := apply(ctx, repo, i); err != nil {
+				errs[i] = err
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		failed := false
+		for i := range errs {
+			if errs[i] != nil {
+				failed = true
+			}
+		}
+
+		// The transaction itself failed, e.g. on commit, so every item failed.
+		if !failed {
+			for i := range errs {
+				errs[i] = err
+			}
+			return errs
+		}
+
+		abortBatch(errs)
+	}
+
+	return errs
+}
+
+// produceBatch publishes one event per successfully applied item. A failure to
+// publish is reported as the item's error.
+func (c *CompanyService) produceBatch(errs []error, method string, company func(i int) *domain.Company) {
+	for i, err := range errs {
+		if err != nil {
+			continue
+		}
+		errs[i] = c.producer.ProduceCompany(company(i), method)
+	}
+}
+
+func abortBatch(errs []error) {
+	for i := range errs {
+		if errs[i] == nil {
+			errs[i] = ErrBatchAborted
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+type BroadcastConfig struct {
+	// History is how many of the latest events are kept for subscribers that
+	// resume after the event they saw last.
+	History int
+	// Remote is set when every event reaches Publish from elsewhere, e.g. from
+	// the company topic consumed by every replica, in which case the events
+	// produced in this process are not published a second time.
+	Remote bool
+}
+
+// Broadcaster is a producer that hands every company event to another
+// producer and to the subscribers in this process.
+type Broadcaster struct {
+	next   ports.CompanyProducer
+	config BroadcastConfig
+	// epoch tells apart the event IDs of this process from those of other
+	// processes, whose events cannot be resumed from.
+	epoch string
+
+	mu          sync.Mutex
+	seq         uint64
+	history     []domain.CompanyEvent
+	subscribers map[chan domain.CompanyEvent]struct{}
+}
+
+func NewBroadcaster(next ports.CompanyProducer, config BroadcastConfig) *Broadcaster {
+	return &Broadcaster{
+		next:        next,
+		config:      config,
+		epoch:       strings.ReplaceAll(uuid.NewString(), "-", "")[:12],
+		subscribers: make(map[chan domain.CompanyEvent]struct{}),
+	}
+}
+
+func (b *Broadcaster) ProduceCompany(company *domain.Company, method string) error {
+	err := b.next.ProduceCompany(company, method)
+	if !b.config.Remote {
+		b.Publish(domain.CompanyEvent{Method: method, Company: *company})
+	}
+	return err
+}
+
+// Publish assigns the event an ID and hands it to every subscriber.
+// Subscribers that have fallen behind by more than their buffer are
+// unsubscribed, which closes their channel, rather than slowing down every
+// change.
+func (b *Broadcaster) Publish(event domain.CompanyEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.ID = b.epoch + "-" + strconv.FormatUint(b.seq, 10)
+
+	if b.config.History > 0 {
+		if len(b.history) == b.config.History {
+			b.history = append(b.history[:0], b.history[1:]...)
+		}
+		b.history = append(b.history, event)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the events published from now on,
+// buffering up to buffer of them, and a function that unsubscribes it.
+func (b *Broadcaster) Subscribe(buffer int) (<-chan domain.CompanyEvent, func()) {
+	ch, unsubscribe, _ := b.SubscribeAfter("", buffer)
+	return ch, unsubscribe
+}
+
+// SubscribeAfter is like Subscribe, but the channel first receives the events
+// published after the event with the given ID. It reports whether they could
+// all be found, which they cannot when the ID is unknown, e.g. because the
+// event was published by another process or has left the history. Nothing is
+// replayed for an empty ID.
+func (b *Broadcaster) SubscribeAfter(lastID string, buffer int) (<-chan domain.CompanyEvent, func(), bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	missed, resumed := b.after(lastID)
+
+	ch := make(chan domain.CompanyEvent, buffer+len(missed))
+	for _, event := range missed {
+		ch <- event
+	}
+	b.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}, resumed
+}
+
+// after returns the events of the history published after the event with the
+// given ID.
+func (b *Broadcaster) after(lastID string) ([]domain.CompanyEvent, bool) {
+	if lastID == "" {
+		return nil, true
+	}
+
+	epoch, value, ok := strings.Cut(lastID, "-")
+	if !ok || epoch != b.epoch {
+		return nil, false
+	}
+
+	seq, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || seq > b.seq {
+		return nil, false
+	}
+
+	// The history holds the events numbered up to b.seq without gaps.
+	missed := int(b.seq - seq)
+	if missed > len(b.history) {
+		return nil, false
+	}
+	return append([]domain.CompanyEvent(nil), b.history[len(b.history)-missed:]...), true
+}
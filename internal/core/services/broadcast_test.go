@@ -0,0 +1,86 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+type discardProducer struct{}
+
+func (discardProducer) ProduceCompany(company *domain.Company, method string) error {
+	return nil
+}
+
+func receive(ch <-chan domain.CompanyEvent) []domain.CompanyEvent {
+	var events []domain.CompanyEvent
+	for {
+		select {
+		case event := <-ch:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func Test_BroadcasterSubscribeAfter(t *testing.T) {
+	b := NewBroadcaster(discardProducer{}, BroadcastConfig{History: 3})
+
+	live, unsubscribe := b.Subscribe(10)
+	defer unsubscribe()
+
+	for i := 0; i < 5; i++ {
+		_ = b.ProduceCompany(&domain.Company{ID: uuid.New()}, http.MethodPost)
+	}
+
+	events := receive(live)
+	if len(events) != 5 || events[0].ID == "" || events[0].ID == events[1].ID {
+		t.Fatalf("expected 5 events with distinct IDs but got %v", events)
+	}
+
+	testCases := []struct {
+		name            string
+		lastID          string
+		expectedResumed bool
+		expectedMissed  int
+	}{
+		{"from now on", "", true, 0},
+		{"latest", events[4].ID, true, 0},
+		{"within history", events[1].ID, true, 3},
+		{"beyond history", events[0].ID, false, 0},
+		{"other process", "0123456789ab-4", false, 0},
+		{"invalid", "invalid", false, 0},
+	}
+
+	for _, tt := range testCases {
+		ch, unsubscribe, resumed := b.SubscribeAfter(tt.lastID, 1)
+		missed := receive(ch)
+		unsubscribe()
+
+		if resumed != tt.expectedResumed || len(missed) != tt.expectedMissed {
+			t.Errorf("%s: expected resumed %t with %d missed events but got %t with %d", tt.name, tt.expectedResumed, tt.expectedMissed, resumed, len(missed))
+		}
+		if len(missed) > 0 && missed[0].ID != events[2].ID {
+			t.Errorf("%s: expected to resume with event %s but got %s", tt.name, events[2].ID, missed[0].ID)
+		}
+	}
+
+	slow, _ := b.Subscribe(1)
+	_ = b.ProduceCompany(&domain.Company{}, http.MethodPatch)
+	_ = b.ProduceCompany(&domain.Company{}, http.MethodPatch)
+	<-slow
+	if _, ok := <-slow; ok {
+		t.Errorf("expected the channel of a subscriber that fell behind to be closed")
+	}
+
+	remote := NewBroadcaster(discardProducer{}, BroadcastConfig{Remote: true})
+	ch, unsubscribe := remote.Subscribe(1)
+	defer unsubscribe()
+	_ = remote.ProduceCompany(&domain.Company{}, http.MethodPost)
+	if events := receive(ch); len(events) != 0 {
+		t.Errorf("expected produced events not to be published when remote but got %v", events)
+	}
+}
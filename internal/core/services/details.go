@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// CompanyDetailsService manages the addresses and contacts of companies. Only
+// companies that are not deleted can have their details read or changed.
+type CompanyDetailsService struct {
+	companies ports.CompanyRepository
+	addresses ports.AddressRepository
+	contacts  ports.ContactRepository
+	config    CompanyConfig
+}
+
+func NewCompanyDetailsService(companies ports.CompanyRepository, addresses ports.AddressRepository, contacts ports.ContactRepository, config CompanyConfig) *CompanyDetailsService {
+	return &CompanyDetailsService{companies, addresses, contacts, config}
+}
+
+// checkCompany returns the error of getting the company, which is not found
+// when it does not exist or is deleted.
+func (c *CompanyDetailsService) checkCompany(ctx context.Context, id uuid.UUID) error {
+	_, err := c.companies.Get(ctx, id)
+	return err
+}
+
+func (c *CompanyDetailsService) ListAddresses(ctx context.Context, companyID uuid.UUID) ([]*domain.Address, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	err := c.checkCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.addresses.List(ctx, companyID)
+}
+
+func (c *CompanyDetailsService) GetAddress(ctx context.Context, companyID, id uuid.UUID) (*domain.Address, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	err := c.checkCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.addresses.Get(ctx, companyID, id)
+}
+
+func (c *CompanyDetailsService) CreateAddress(ctx context.Context, address *domain.Address) error {
+	err := address.Validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err = c.checkCompany(ctx, address.CompanyID)
+	if err != nil {
+		return err
+	}
+	return c.addresses.Create(ctx, address)
+}
+
+func (c *CompanyDetailsService) UpdateAddress(ctx context.Context, address *domain.Address) error {
+	err := address.Validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err = c.checkCompany(ctx, address.CompanyID)
+	if err != nil {
+		return err
+	}
+	return c.addresses.Update(ctx, address)
+}
+
+func (c *CompanyDetailsService) DeleteAddress(ctx context.Context, companyID, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err := c.checkCompany(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	return c.addresses.Delete(ctx, companyID, id)
+}
+
+// AddressesOf returns the addresses of each of the companies, without
+// checking that the companies exist.
+func (c *CompanyDetailsService) AddressesOf(ctx context.Context, companyIDs ...uuid.UUID) (map[uuid.UUID][]*domain.Address, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	addresses, err := c.addresses.List(ctx, companyIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	byCompany := make(map[uuid.UUID][]*domain.Address, len(companyIDs))
+	for _, id := range companyIDs {
+		byCompany[id] = []*domain.Address{}
+	}
+	for _, address := range addresses {
+		byCompany[address.CompanyID] = append(byCompany[address.CompanyID], address)
+	}
+	return byCompany, nil
+}
+
+func (c *CompanyDetailsService) ListContacts(ctx context.Context, companyID uuid.UUID) ([]*domain.Contact, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	err := c.checkCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.contacts.List(ctx, companyID)
+}
+
+func (c *CompanyDetailsService) GetContact(ctx context.Context, companyID, id uuid.UUID) (*domain.Contact, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	err := c.checkCompany(ctx, companyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.contacts.Get(ctx, companyID, id)
+}
+
+func (c *CompanyDetailsService) CreateContact(ctx context.Context, contact *domain.Contact) error {
+	err := contact.Validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err = c.checkCompany(ctx, contact.CompanyID)
+	if err != nil {
+		return err
+	}
+	return c.contacts.Create(ctx, contact)
+}
+
+func (c *CompanyDetailsService) UpdateContact(ctx context.Context, contact *domain.Contact) error {
+	err := contact.Validate()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err = c.checkCompany(ctx, contact.CompanyID)
+	if err != nil {
+		return err
+	}
+	return c.contacts.Update(ctx, contact)
+}
+
+func (c *CompanyDetailsService) DeleteContact(ctx context.Context, companyID, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err := c.checkCompany(ctx, companyID)
+	if err != nil {
+		return err
+	}
+	return c.contacts.Delete(ctx, companyID, id)
+}
+
+// ContactsOf returns the contacts of each of the companies, without checking
+// that the companies exist.
+func (c *CompanyDetailsService) ContactsOf(ctx context.Context, companyIDs ...uuid.UUID) (map[uuid.UUID][]*domain.Contact, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	contacts, err := c.contacts.List(ctx, companyIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	byCompany := make(map[uuid.UUID][]*domain.Contact, len(companyIDs))
+	for _, id := range companyIDs {
+		byCompany[id] = []*domain.Contact{}
+	}
+	for _, contact := range contacts {
+		byCompany[contact.CompanyID] = append(byCompany[contact.CompanyID], contact)
+	}
+	return byCompany, nil
+}
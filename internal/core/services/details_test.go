@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// fakeAddresses keeps addresses in a slice, in the order they were created.
+type fakeAddresses struct {
+	addresses []domain.Address
+}
+
+func (f *fakeAddresses) Create(ctx context.Context, address *domain.Address) error {
+	address.ID = uuid.New()
+	f.addresses = append(f.addresses, *address)
+	return nil
+}
+
+func (f *fakeAddresses) Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Address, error) {
+	for _, address := range f.addresses {
+		if address.ID == id && address.CompanyID == companyID {
+			return &address, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (f *fakeAddresses) Update(ctx context.Context, address *domain.Address) error {
+	for i := range f.addresses {
+		if f.addresses[i].ID == address.ID && f.addresses[i].CompanyID == address.CompanyID {
+			f.addresses[i] = *address
+			return nil
+		}
+	}
+	return errNotFound
+}
+
+func (f *fakeAddresses) Delete(ctx context.Context, companyID, id uuid.UUID) error {
+	for i, address := range f.addresses {
+		if address.ID == id && address.CompanyID == companyID {
+			f.addresses = append(f.addresses[:i], f.addresses[i+1:]...)
+			return nil
+		}
+	}
+	return errNotFound
+}
+
+func (f *fakeAddresses) List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Address, error) {
+	addresses := []*domain.Address{}
+	for _, address := range f.addresses {
+		for _, id := range companyIDs {
+			if address.CompanyID == id {
+				address := address
+				addresses = append(addresses, &address)
+			}
+		}
+	}
+	return addresses, nil
+}
+
+// fakeContacts only holds the contacts created, which the tests do not read.
+type fakeContacts struct {
+	created int
+}
+
+func (f *fakeContacts) Create(ctx context.Context, contact *domain.Contact) error {
+	f.created++
+	return nil
+}
+
+func (f *fakeContacts) Get(ctx context.Context, companyID, id uuid.UUID) (*domain.Contact, error) {
+	return nil, errNotFound
+}
+
+func (f *fakeContacts) Update(ctx context.Context, contact *domain.Contact) error {
+	return errNotFound
+}
+
+func (f *fakeContacts) Delete(ctx context.Context, companyID, id uuid.UUID) error {
+	return errNotFound
+}
+
+func (f *fakeContacts) List(ctx context.Context, companyIDs ...uuid.UUID) ([]*domain.Contact, error) {
+	return []*domain.Contact{}, nil
+}
+
+func Test_CompanyDetails(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	addresses := &fakeAddresses{}
+	contacts := &fakeContacts{}
+	service := NewCompanyDetailsService(repo, addresses, contacts, CompanyConfig{})
+
+	first := &domain.Company{Name: "First"}
+	second := &domain.Company{Name: "Second"}
+	for _, company := range []*domain.Company{first, second} {
+		err := repo.Create(ctx, company)
+		if err != nil {
+			t.Fatalf("error creating company: %s", err)
+		}
+	}
+
+	err := service.CreateAddress(ctx, &domain.Address{CompanyID: first.ID, Line1: "1 Main Street", City: "Athens", CountryCode: "GR"})
+	if err != nil {
+		t.Fatalf("error creating address: %s", err)
+	}
+
+	var errs domain.ValidationErrors
+	err = service.CreateAddress(ctx, &domain.Address{CompanyID: first.ID, Line1: "2 Side Street", City: "Athens", CountryCode: "ZZ"})
+	if !errors.As(err, &errs) || errs["country_code"] == "" || len(addresses.addresses) != 1 {
+		t.Errorf("expected an invalid country code to be rejected before storing the address but got %v", err)
+	}
+
+	err = service.CreateAddress(ctx, &domain.Address{CompanyID: uuid.New(), Line1: "3 High Street", City: "London", CountryCode: "GB"})
+	if !errors.Is(err, errNotFound) || len(addresses.addresses) != 1 {
+		t.Errorf("expected the address of an unknown company to be rejected but got %v", err)
+	}
+
+	_, err = service.ListAddresses(ctx, uuid.New())
+	if !errors.Is(err, errNotFound) {
+		t.Errorf("expected listing the addresses of an unknown company to return %v but got %v", errNotFound, err)
+	}
+
+	byCompany, err := service.AddressesOf(ctx, first.ID, second.ID)
+	if err != nil {
+		t.Fatalf("error reading addresses: %s", err)
+	}
+	if len(byCompany[first.ID]) != 1 || byCompany[first.ID][0].City != "Athens" {
+		t.Errorf("expected the address of the first company but got %+v", byCompany[first.ID])
+	}
+	if second := byCompany[second.ID]; second == nil || len(second) != 0 {
+		t.Errorf("expected no addresses, rather than none at all, for the second company but got %#v", second)
+	}
+
+	err = service.CreateContact(ctx, &domain.Contact{CompanyID: first.ID, Email: "jane@example.com"})
+	if !errors.As(err, &errs) || errs["name"] == "" || contacts.created != 0 {
+		t.Errorf("expected a contact without a name to be rejected but got %v", err)
+	}
+
+	err = repo.Delete(ctx, first.ID, "")
+	if err != nil {
+		t.Fatalf("error deleting company: %s", err)
+	}
+	err = service.CreateContact(ctx, &domain.Contact{CompanyID: first.ID, Name: "Jane Doe"})
+	if !errors.Is(err, errNotFound) || contacts.created != 0 {
+		t.Errorf("expected the contact of a deleted company to be rejected but got %v", err)
+	}
+}
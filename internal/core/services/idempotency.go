@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("the idempotency key has already been used for a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+type IdempotencyConfig struct {
+	// TTL is how long the response to a key is kept and replayed.
+	TTL time.Duration
+	// Lease is how long a request being processed holds its key without
+	// renewing it. Once it runs out, e.g. because the process died, a retry
+	// takes the key over instead of being rejected until the TTL passes.
+	// Without a lease the key is held for the whole TTL.
+	Lease time.Duration
+	// Timeout bounds each query of the keys.
+	Timeout time.Duration
+}
+
+type IdempotencyService struct {
+	repo   ports.IdempotencyRepository
+	config IdempotencyConfig
+}
+
+func NewIdempotencyService(repo ports.IdempotencyRepository, config IdempotencyConfig) *IdempotencyService {
+	return &IdempotencyService{repo, config}
+}
+
+// Begin reserves the key of subject for the request with the given hash. When
+// the same request was already completed with this key it returns that
+// request so that its response can be replayed, otherwise it returns nil and
+// the request must be processed, holding the key with Hold, and then
+// completed or abandoned.
+func (s *IdempotencyService) Begin(ctx context.Context, subject, key string, hash []byte) (*domain.IdempotentRequest, error) {
+	ctx, cancel := withTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	stored, err := s.repo.Reserve(ctx, &domain.IdempotentRequest{
+		Key:         key,
+		Subject:     subject,
+		RequestHash: hash,
+		ExpiresAt:   time.Now().Add(s.config.TTL),
+		LockedUntil: time.Now().Add(s.lease()),
+	})
+	if err != nil || stored == nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(stored.RequestHash, hash) {
+		return nil, ErrIdempotencyKeyReused
+	}
+
+	if stored.Status == 0 {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	return stored, nil
+}
+
+// Hold renews the lease of a request begun with Begin until the returned
+// function is called, which must be done once the request is completed or
+// abandoned. The errors of renewing it are passed to onError.
+func (s *IdempotencyService) Hold(subject, key string, onError func(error)) (release func()) {
+	if s.config.Lease <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.config.Lease / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := withTimeout(context.Background(), s.config.Timeout)
+				err := s.repo.Extend(ctx, subject, key, time.Now().Add(s.config.Lease))
+				cancel()
+				if err != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// Complete stores the response of a request begun with Begin.
+func (s *IdempotencyService) Complete(ctx context.Context, subject, key string, status int, header http.Header, body []byte) error {
+	ctx, cancel := withTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	return s.repo.Complete(ctx, &domain.IdempotentRequest{
+		Key:     key,
+		Subject: subject,
+		Status:  status,
+		Header:  header,
+		Body:    body,
+	})
+}
+
+// Abandon frees the key of a request begun with Begin so that it can be
+// retried.
+func (s *IdempotencyService) Abandon(ctx context.Context, subject, key string) error {
+	ctx, cancel := withTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	return s.repo.Release(ctx, subject, key)
+}
+
+func (s *IdempotencyService) lease() time.Duration {
+	if s.config.Lease <= 0 {
+		return s.config.TTL
+	}
+	return s.config.Lease
+}
+
+// PurgeExpired removes the keys whose time to live has passed.
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.repo.DeleteExpired(ctx)
+}
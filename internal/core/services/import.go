@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// ErrNameTaken is reported for imported rows whose name belongs to a stored
+// company or to an earlier row of the same import.
+var ErrNameTaken = errors.New("a company with this name already exists")
+
+// ImportSource yields the companies of an import one row at a time and returns
+// io.EOF after the last one. A *RowError rejects only the current row while
+// any other error aborts the import.
+type ImportSource interface {
+	Next() (*domain.Company, error)
+	// Row returns the number of the row last returned by Next.
+	Row() int
+}
+
+// RowError describes why a row of an import was rejected.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportResult reports the companies created by an import and the rows that
+// were rejected. PublishErr is set when the companies were created but some of
+// their creations could not be produced.
+type ImportResult struct {
+	Created    []*domain.Company
+	Errors     []*RowError
+	PublishErr error
+}
+
+// Import creates the companies read from src on behalf of createdBy. Invalid
+// rows are collected in the result instead of failing the import.
+func (c *CompanyService) Import(ctx context.Context, src ImportSource, createdBy string) (*ImportResult, error) {
+	result := &ImportResult{}
+
+	type importedRow struct {
+		row  int
+		name string
+	}
+	var rows []importedRow
+
+	next := func() (*domain.Company, error) {
+		for {
+			company, err := src.Next()
+
+			var rowErr *RowError
+			switch {
+			case errors.As(err, &rowErr):
+				result.Errors = append(result.Errors, rowErr)
+				continue
+			case err != nil:
+				return nil, err
+			}
+
+			err = company.Validate()
+			if err != nil {
+				result.Errors = append(result.Errors, &RowError{Row: src.Row(), Err: err})
+				continue
+			}
+
+			company.CreatedBy = createdBy
+			rows = append(rows, importedRow{src.Row(), company.Name})
+			return company, nil
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.BulkTimeout)
+	defer cancel()
+
+	created, err := c.repo.Import(ctx, next)
+	if err != nil {
+		return nil, err
+	}
+	result.Created = created
+
+	names := make(map[string]bool, len(created))
+	for _, company := range created {
+		names[company.Name] = true
+	}
+
+	for _, row := range rows {
+		if !names[row.name] {
+			result.Errors = append(result.Errors, &RowError{Row: row.row, Err: ErrNameTaken})
+			continue
+		}
+		// Only the first row with a given name is created.
+		delete(names, row.name)
+	}
+
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Row < result.Errors[j].Row
+	})
+
+	// The companies are committed by now, so a failure to produce them does
+	// not fail the import.
+	var errs []error
+	for _, company := range created {
+		errs = append(errs, c.producer.ProduceCompany(company, http.MethodPost))
+	}
+	result.PublishErr = errors.Join(errs...)
+
+	return result, nil
+}
+
+// Export calls fn for every company matching the filter without loading them
+// all in memory.
+func (c *CompanyService) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	ctx, cancel := withTimeout(ctx, c.config.BulkTimeout)
+	defer cancel()
+
+	return c.repo.Export(ctx, filter, fn)
+}
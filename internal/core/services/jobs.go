@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+var (
+	ErrUnknownJobType = errors.New("unknown job type")
+	ErrJobNotFinished = errors.New("job has not finished successfully")
+)
+
+// JobRunner processes a job. It reads the job's input, if any, from input,
+// writes its result to output and reports how many items it has processed
+// through progress, which fails once the job has been cancelled. It returns
+// the media type of the result.
+type JobRunner func(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error)
+
+type JobConfig struct {
+	// Dir is where the inputs and results of jobs are stored. Replicas must
+	// share it to process each other's jobs.
+	Dir          string
+	Concurrency  int
+	PollInterval time.Duration
+	MaxAttempts  int
+	// StaleAfter is how long a running job may go without reporting progress
+	// before it is considered abandoned by a worker that died.
+	StaleAfter time.Duration
+}
+
+type JobService struct {
+	repo    ports.JobRepository
+	config  JobConfig
+	logger  *log.Logger
+	runners map[string]JobRunner
+
+	mu      sync.Mutex
+	running map[uuid.UUID]context.CancelFunc
+}
+
+func NewJobService(repo ports.JobRepository, config JobConfig, logger *log.Logger) *JobService {
+	return &JobService{
+		repo:    repo,
+		config:  config,
+		logger:  logger,
+		runners: make(map[string]JobRunner),
+		running: make(map[uuid.UUID]context.CancelFunc),
+	}
+}
+
+// Register makes jobs of the given type processable by runner.
+func (s *JobService) Register(jobType string, runner JobRunner) {
+	s.runners[jobType] = runner
+}
+
+// Enqueue stores the input of a new job and queues it for the workers.
+func (s *JobService) Enqueue(ctx context.Context, jobType string, params map[string]string, input io.Reader, createdBy string) (*domain.Job, error) {
+	if _, ok := s.runners[jobType]; !ok {
+		return nil, ErrUnknownJobType
+	}
+
+	job := &domain.Job{
+		ID:        uuid.New(),
+		Type:      jobType,
+		Params:    params,
+		CreatedBy: createdBy,
+	}
+
+	if input != nil {
+		err := s.writeFile(s.inputPath(job.ID), input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := s.repo.Create(ctx, job)
+	if err != nil {
+		os.Remove(s.inputPath(job.ID))
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *JobService) Get(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// Cancel cancels a queued or running job, stopping it right away when it runs
+// on this replica and at its next progress report otherwise.
+func (s *JobService) Cancel(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	job, err := s.repo.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if cancel, ok := s.running[id]; ok {
+		cancel()
+	}
+	s.mu.Unlock()
+
+	return job, nil
+}
+
+// Result opens the result of a job that succeeded.
+func (s *JobService) Result(ctx context.Context, id uuid.UUID) (*domain.Job, io.ReadCloser, error) {
+	job, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if job.Status != domain.JobSucceeded {
+		return nil, nil, ErrJobNotFinished
+	}
+
+	f, err := os.Open(s.resultPath(id))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return job, f, nil
+}
+
+// Run requeues the jobs that failed or were abandoned by a previous run and
+// then processes queued jobs with the configured number of workers until ctx
+// is done.
+func (s *JobService) Run(ctx context.Context) error {
+	err := os.MkdirAll(s.config.Dir, 0o750)
+	if err != nil {
+		return err
+	}
+
+	requeued, err := s.repo.Requeue(ctx, s.config.MaxAttempts, s.config.StaleAfter)
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		s.logger.Printf("requeued %d jobs", requeued)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (s *JobService) work(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep claiming jobs while there are any before waiting for the next
+		// tick.
+		for ctx.Err() == nil {
+			job, err := s.repo.Claim(ctx)
+			if err != nil {
+				s.logger.Printf("[ERROR] claiming job: %v", err)
+				break
+			}
+			if job == nil {
+				break
+			}
+			s.process(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *JobService) process(ctx context.Context, job *domain.Job) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+	}()
+
+	progress := func(n int64) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		job.Progress = n
+		return s.repo.Progress(ctx, job.ID, n)
+	}
+
+	resultType, err := s.run(ctx, job, progress)
+
+	job.Status = domain.JobSucceeded
+	job.ResultType = resultType
+	if err != nil {
+		job.Status = domain.JobFailed
+		job.Error = err.Error()
+		os.Remove(s.resultPath(job.ID))
+	}
+
+	// The job is finished even when ctx is done, so that a job stopped by a
+	// shutdown is recorded as failed rather than left running.
+	err = s.repo.Finish(context.Background(), job)
+	if err != nil {
+		// The job was cancelled while running, which already finished it.
+		if ctx.Err() == nil {
+			s.logger.Printf("[ERROR] finishing job %s: %v", job.ID, err)
+		}
+		return
+	}
+
+	if job.Status == domain.JobSucceeded {
+		os.Remove(s.inputPath(job.ID))
+	}
+	s.logger.Printf("job %s (%s) %s after processing %d items", job.ID, job.Type, job.Status, job.Progress)
+}
+
+func (s *JobService) run(ctx context.Context, job *domain.Job, progress func(int64) error) (resultType string, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("job panicked: %v", rec)
+		}
+	}()
+
+	runner, ok := s.runners[job.Type]
+	if !ok {
+		return "", ErrUnknownJobType
+	}
+
+	var input io.Reader
+	f, err := os.Open(s.inputPath(job.ID))
+	switch {
+	case err == nil:
+		defer f.Close()
+		input = f
+	case !errors.Is(err, os.ErrNotExist):
+		return "", err
+	}
+
+	output, err := os.Create(s.resultPath(job.ID))
+	if err != nil {
+		return "", err
+	}
+	defer output.Close()
+
+	resultType, err = runner(ctx, job, input, output, progress)
+	if err != nil {
+		return "", err
+	}
+
+	return resultType, output.Close()
+}
+
+func (s *JobService) writeFile(path string, r io.Reader) error {
+	err := os.MkdirAll(s.config.Dir, 0o750)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+
+	return f.Close()
+}
+
+func (s *JobService) inputPath(id uuid.UUID) string {
+	return filepath.Join(s.config.Dir, id.String()+".input")
+}
+
+func (s *JobService) resultPath(id uuid.UUID) string {
+	return filepath.Join(s.config.Dir, id.String()+".result")
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+var errJobNotRunning = errors.New("job is not running")
+
+type fakeJobRepository struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*domain.Job
+}
+
+func newFakeJobRepository() *fakeJobRepository {
+	return &fakeJobRepository{jobs: make(map[uuid.UUID]*domain.Job)}
+}
+
+func (f *fakeJobRepository) Create(ctx context.Context, job *domain.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	job.Status = domain.JobQueued
+	job.CreatedAt = time.Now()
+	stored := *job
+	f.jobs[job.ID] = &stored
+	return nil
+}
+
+func (f *fakeJobRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	job, ok := f.jobs[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (f *fakeJobRepository) Claim(ctx context.Context) (*domain.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, job := range f.jobs {
+		if job.Status == domain.JobQueued {
+			job.Status = domain.JobRunning
+			job.Attempts++
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeJobRepository) Progress(ctx context.Context, id uuid.UUID, progress int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	job := f.jobs[id]
+	if job.Status != domain.JobRunning {
+		return errJobNotRunning
+	}
+	job.Progress = progress
+	return nil
+}
+
+func (f *fakeJobRepository) Finish(ctx context.Context, job *domain.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.jobs[job.ID]
+	if stored.Status != domain.JobRunning {
+		return errJobNotRunning
+	}
+	*stored = *job
+	return nil
+}
+
+func (f *fakeJobRepository) Cancel(ctx context.Context, id uuid.UUID) (*domain.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	job := f.jobs[id]
+	if !job.Done() {
+		job.Status = domain.JobCancelled
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (f *fakeJobRepository) Requeue(ctx context.Context, maxAttempts int, stale time.Duration) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var requeued int64
+	for _, job := range f.jobs {
+		if job.Status == domain.JobFailed && job.Attempts < maxAttempts {
+			job.Status = domain.JobQueued
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+func newTestJobService(t *testing.T, repo *fakeJobRepository) *JobService {
+	return NewJobService(repo, JobConfig{
+		Dir:          t.TempDir(),
+		Concurrency:  2,
+		PollInterval: 5 * time.Millisecond,
+		MaxAttempts:  2,
+		StaleAfter:   time.Minute,
+	}, log.New(io.Discard, "", 0))
+}
+
+// waitForJob polls the job until it has finished.
+func waitForJob(t *testing.T, service *JobService, id uuid.UUID) *domain.Job {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := service.Get(context.Background(), id)
+		if err != nil {
+			t.Fatalf("error getting job: %s", err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish in time", id)
+	return nil
+}
+
+func Test_JobServiceRun(t *testing.T) {
+	repo := newFakeJobRepository()
+	service := newTestJobService(t, repo)
+
+	var attempts atomic.Int32
+	service.Register("upper", func(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error) {
+		data, err := io.ReadAll(input)
+		if err != nil {
+			return "", err
+		}
+		_, err = output.Write([]byte(strings.ToUpper(string(data))))
+		if err != nil {
+			return "", err
+		}
+		return "text/plain", progress(int64(len(data)))
+	})
+	service.Register("flaky", func(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error) {
+		attempts.Add(1)
+		return "", errors.New("flaky job failed")
+	})
+
+	_, err := service.Enqueue(context.Background(), "unknown", nil, nil, "tester")
+	if err != ErrUnknownJobType {
+		t.Errorf("expected %v but got %v", ErrUnknownJobType, err)
+	}
+
+	upper, err := service.Enqueue(context.Background(), "upper", map[string]string{"greeting": "true"}, strings.NewReader("hello"), "tester")
+	if err != nil {
+		t.Fatalf("error enqueuing job: %s", err)
+	}
+
+	flaky, err := service.Enqueue(context.Background(), "flaky", nil, nil, "tester")
+	if err != nil {
+		t.Fatalf("error enqueuing job: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = service.Run(ctx)
+		close(done)
+	}()
+
+	job := waitForJob(t, service, upper.ID)
+	if job.Status != domain.JobSucceeded || job.Progress != 5 || job.ResultType != "text/plain" {
+		t.Errorf("expected job to succeed after processing 5 items with a text/plain result but got %+v", job)
+	}
+
+	_, result, err := service.Result(context.Background(), upper.ID)
+	if err != nil {
+		t.Fatalf("error opening job result: %s", err)
+	}
+	data, _ := io.ReadAll(result)
+	result.Close()
+	if string(data) != "HELLO" {
+		t.Errorf("expected result HELLO but got %s", data)
+	}
+
+	job = waitForJob(t, service, flaky.ID)
+	if job.Status != domain.JobFailed || job.Error != "flaky job failed" {
+		t.Errorf("expected job to fail with its error but got %+v", job)
+	}
+
+	_, _, err = service.Result(context.Background(), flaky.ID)
+	if err != ErrJobNotFinished {
+		t.Errorf("expected %v but got %v", ErrJobNotFinished, err)
+	}
+
+	cancel()
+	<-done
+
+	// A restart retries the failed job until it runs out of attempts.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = service.Run(ctx) }()
+
+	time.Sleep(50 * time.Millisecond)
+	job = waitForJob(t, service, flaky.ID)
+	if attempts.Load() != 2 || job.Attempts != 2 {
+		t.Errorf("expected the failed job to be retried once but it ran %d times", attempts.Load())
+	}
+}
+
+func Test_JobServiceCancel(t *testing.T) {
+	repo := newFakeJobRepository()
+	service := newTestJobService(t, repo)
+
+	started := make(chan struct{})
+	service.Register("endless", func(ctx context.Context, job *domain.Job, input io.Reader, output io.Writer, progress func(int64) error) (string, error) {
+		close(started)
+		for i := int64(1); ; i++ {
+			if err := progress(i); err != nil {
+				return "", err
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	job, err := service.Enqueue(context.Background(), "endless", nil, nil, "tester")
+	if err != nil {
+		t.Fatalf("error enqueuing job: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = service.Run(ctx) }()
+
+	<-started
+
+	cancelled, err := service.Cancel(context.Background(), job.ID)
+	if err != nil {
+		t.Fatalf("error cancelling job: %s", err)
+	}
+
+	if cancelled.Status != domain.JobCancelled {
+		t.Errorf("expected job to be cancelled but got %s", cancelled.Status)
+	}
+
+	job = waitForJob(t, service, job.ID)
+	if job.Status != domain.JobCancelled {
+		t.Errorf("expected job to stay cancelled but got %s", job.Status)
+	}
+}
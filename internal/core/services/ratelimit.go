@@ -0,0 +1,39 @@
+package services
+
+import (
+	"time"
+
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+type RateLimitService struct {
+	store  ports.RateLimitStore
+	limits domain.RateLimits
+}
+
+func NewRateLimitService(store ports.RateLimitStore, limits domain.RateLimits) *RateLimitService {
+	return &RateLimitService{store, limits}
+}
+
+// Take takes a request of client from its bucket for the route with the given
+// method and pattern. Every route with its own limit has its own buckets,
+// while the routes without one share theirs. It returns nil when the route is
+// unlimited.
+func (s *RateLimitService) Take(method, pattern, client string) (*domain.RateLimitResult, error) {
+	route, limit := s.limits.For(method, pattern)
+	if limit.Unlimited() {
+		return nil, nil
+	}
+
+	if route == "" {
+		route = "*"
+	}
+
+	res, err := s.store.Take("ratelimit:"+route+":"+client, limit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
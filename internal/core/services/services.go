@@ -1,47 +1,220 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/petrostrak/xm-companies/internal/adapters/kafka/producer"
 	"github.com/petrostrak/xm-companies/internal/core/domain"
 	"github.com/petrostrak/xm-companies/internal/core/ports"
-	"github.com/petrostrak/xm-companies/utils"
 )
 
+// CompanyConfig bounds how long each kind of operation may take. Zero
+// durations leave the operations bounded only by the context they are given.
+type CompanyConfig struct {
+	// ReadTimeout bounds getting and listing companies.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds every change to companies, including batches.
+	WriteTimeout time.Duration
+	// BulkTimeout bounds imports, exports and purges of deleted companies.
+	BulkTimeout time.Duration
+}
+
 type CompanyService struct {
 	repo ports.CompanyRepository
+	// definitions validate the custom attributes of companies. Without them
+	// no attribute is defined.
+	definitions ports.AttributeDefinitionRepository
+	producer    ports.CompanyProducer
+	config      CompanyConfig
+}
+
+func NewCompanyService(repo ports.CompanyRepository, definitions ports.AttributeDefinitionRepository, producer ports.CompanyProducer, config CompanyConfig) *CompanyService {
+	return &CompanyService{repo, definitions, producer, config}
+}
+
+// withTimeout derives a context that is done once timeout has elapsed, unless
+// timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (c *CompanyService) Create(ctx context.Context, company *domain.Company) error {
+	err := c.validate(ctx, company)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err = c.repo.Create(ctx, company)
+	if err != nil {
+		return err
+	}
+	return c.producer.ProduceCompany(company, http.MethodPost)
 }
 
-func NewCompanyService(repo ports.CompanyRepository) *CompanyService {
-	return &CompanyService{repo}
+func (c *CompanyService) Update(ctx context.Context, company *domain.Company) error {
+	err := c.validate(ctx, company)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err = c.repo.Update(ctx, company)
+	if err != nil {
+		return err
+	}
+	return c.producer.ProduceCompany(company, http.MethodPatch)
 }
 
-func (c *CompanyService) Create(company *domain.Company) error {
-	err := producer.ProduceCompany(company, http.MethodPost)
+// Delete soft deletes the company on behalf of deletedBy. A company that has
+// subsidiaries is only deleted when the policy detaches them, moving them to
+// the top of their own groups, or deletes them along with it.
+func (c *CompanyService) Delete(ctx context.Context, id uuid.UUID, deletedBy string, subsidiaries domain.SubsidiaryPolicy) error {
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	var detached []*domain.Company
+	deleted := []uuid.UUID{id}
+
+	var err error
+	switch subsidiaries {
+	case domain.DetachSubsidiaries:
+		err = c.repo.InTx(ctx, func(repo ports.CompanyRepository) error {
+			children, err := repo.Children(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			for _, company := range children {
+				company.ParentID = nil
+				company.UpdatedBy = deletedBy
+				err = repo.Update(ctx, company)
+				if err != nil {
+					return err
+				}
+			}
+
+			detached = children
+			return repo.Delete(ctx, id, deletedBy)
+		})
+	case domain.CascadeSubsidiaries:
+		err = c.repo.InTx(ctx, func(repo ports.CompanyRepository) error {
+			subtree, err := repo.Subtree(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			// Companies are deleted deepest first, since a company cannot
+			// be deleted while it has subsidiaries.
+			deleted = deleted[:0]
+			for i := len(subtree) - 1; i >= 0; i-- {
+				err = repo.Delete(ctx, subtree[i].ID, deletedBy)
+				if err != nil {
+					return err
+				}
+				deleted = append(deleted, subtree[i].ID)
+			}
+			return nil
+		})
+	default:
+		err = c.repo.Delete(ctx, id, deletedBy)
+	}
 	if err != nil {
-		utils.ServerErrorResponse(w, r, err)
+		return err
+	}
+
+	var errs []error
+	for _, company := range detached {
+		errs = append(errs, c.producer.ProduceCompany(company, http.MethodPatch))
+	}
+	for _, id := range deleted {
+		errs = append(errs, c.producer.ProduceCompany(&domain.Company{ID: id}, http.MethodDelete))
 	}
-	return c.repo.Create(company)
+	return errors.Join(errs...)
 }
 
-func (c *CompanyService) Update(company *domain.Company) error {
-	err := producer.ProduceCompany(company, http.MethodPatch)
+// Restore undoes a soft delete on behalf of restoredBy and announces the
+// company again.
+func (c *CompanyService) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	company, err := c.repo.Restore(ctx, id, restoredBy)
 	if err != nil {
-		utils.ServerErrorResponse(w, r, err)
+		return nil, err
 	}
-	return c.repo.Update(company)
+	return company, c.producer.ProduceCompany(company, http.MethodPost)
 }
 
-func (c *CompanyService) Delete(id uuid.UUID) error {
-	err := producer.ProduceCompany(&company, http.MethodDelete)
+// Purge permanently removes the company.
+func (c *CompanyService) Purge(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	err := c.repo.Purge(ctx, id)
 	if err != nil {
-		utils.ServerErrorResponse(w, r, err)
+		return err
 	}
-	return c.repo.Delete(id)
+	return c.producer.ProduceCompany(&domain.Company{ID: id}, http.MethodDelete)
+}
+
+// PurgeDeleted permanently removes the companies that have been soft deleted
+// for longer than the retention period.
+func (c *CompanyService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, cancel := withTimeout(ctx, c.config.BulkTimeout)
+	defer cancel()
+
+	return c.repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+}
+
+func (c *CompanyService) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	return c.repo.Get(ctx, id)
+}
+
+// List returns a page of up to limit companies matching the filter, ordered by
+// name and starting after the company named after.
+func (c *CompanyService) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	return c.repo.List(ctx, filter, after, limit)
+}
+
+// Children returns the subsidiaries of the company, ordered by name.
+func (c *CompanyService) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	return c.repo.Children(ctx, id)
+}
+
+// Ancestors returns the parent of the company, its parent and so on up to the
+// top of the group.
+func (c *CompanyService) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	return c.repo.Ancestors(ctx, id)
 }
 
-func (c *CompanyService) Get(id uuid.UUID) (*domain.Company, error) {
-	return c.repo.Get(id)
+// Subtree returns the company followed by every subsidiary below it, level by
+// level.
+func (c *CompanyService) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	return c.repo.Subtree(ctx, id)
 }
@@ -0,0 +1,570 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+var (
+	errNotFound        = errors.New("not found")
+	errHasSubsidiaries = errors.New("has subsidiaries")
+)
+
+// fakeRepository keeps companies in a map. Transactions work on a copy of the
+// map that replaces the original on commit.
+type fakeRepository struct {
+	companies map[uuid.UUID]domain.Company
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{companies: make(map[uuid.UUID]domain.Company)}
+}
+
+func (f *fakeRepository) Create(ctx context.Context, company *domain.Company) error {
+	for _, c := range f.companies {
+		if c.Name == company.Name {
+			return errors.New("duplicate name")
+		}
+	}
+	company.ID = uuid.New()
+	f.companies[company.ID] = *company
+	return nil
+}
+
+func (f *fakeRepository) Update(ctx context.Context, company *domain.Company) error {
+	if _, ok := f.companies[company.ID]; !ok {
+		return errNotFound
+	}
+	f.companies[company.ID] = *company
+	return nil
+}
+
+func (f *fakeRepository) Delete(ctx context.Context, id uuid.UUID, deletedBy string) error {
+	if _, ok := f.companies[id]; !ok {
+		return errNotFound
+	}
+	if children, _ := f.Children(ctx, id); len(children) > 0 {
+		return errHasSubsidiaries
+	}
+	delete(f.companies, id)
+	return nil
+}
+
+func (f *fakeRepository) Restore(ctx context.Context, id uuid.UUID, restoredBy string) (*domain.Company, error) {
+	return nil, errNotFound
+}
+
+func (f *fakeRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	return f.Delete(ctx, id, "")
+}
+
+func (f *fakeRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	company, ok := f.companies[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &company, nil
+}
+
+func (f *fakeRepository) InTx(ctx context.Context, fn func(ports.CompanyRepository) error) error {
+	tx := newFakeRepository()
+	for id, company := range f.companies {
+		tx.companies[id] = company
+	}
+
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+
+	f.companies = tx.companies
+	return nil
+}
+
+func (f *fakeRepository) Import(ctx context.Context, next func() (*domain.Company, error)) ([]*domain.Company, error) {
+	var created []*domain.Company
+	for {
+		company, err := next()
+		if errors.Is(err, io.EOF) {
+			return created, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if f.Create(ctx, company) == nil {
+			created = append(created, company)
+		}
+	}
+}
+
+func (f *fakeRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	for _, company := range f.companies {
+		company := company
+		if err := fn(&company); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepository) List(ctx context.Context, filter domain.CompanyFilter, after string, limit int) ([]*domain.Company, error) {
+	var companies []*domain.Company
+	for _, company := range f.companies {
+		if company.Name > after {
+			company := company
+			companies = append(companies, &company)
+		}
+	}
+
+	sort.Slice(companies, func(i, j int) bool { return companies[i].Name < companies[j].Name })
+	if len(companies) > limit {
+		companies = companies[:limit]
+	}
+	return companies, nil
+}
+
+func (f *fakeRepository) Children(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	companies := []*domain.Company{}
+	for _, company := range f.companies {
+		if company.ParentID != nil && *company.ParentID == id {
+			company := company
+			companies = append(companies, &company)
+		}
+	}
+
+	sort.Slice(companies, func(i, j int) bool { return companies[i].Name < companies[j].Name })
+	return companies, nil
+}
+
+func (f *fakeRepository) Ancestors(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	company, err := f.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	companies := []*domain.Company{}
+	for company.ParentID != nil {
+		company, err = f.Get(ctx, *company.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		companies = append(companies, company)
+	}
+	return companies, nil
+}
+
+func (f *fakeRepository) Subtree(ctx context.Context, id uuid.UUID) ([]*domain.Company, error) {
+	company, err := f.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	companies := []*domain.Company{company}
+	for i := 0; i < len(companies); i++ {
+		children, _ := f.Children(ctx, companies[i].ID)
+		companies = append(companies, children...)
+	}
+	return companies, nil
+}
+
+// UpdateTags adds and then removes the tags, keeping them sorted.
+func (f *fakeRepository) UpdateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	company, ok := f.companies[id]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	tagged := make(map[string]bool)
+	for _, tag := range append(append([]string(nil), company.Tags...), add...) {
+		tagged[tag] = true
+	}
+	for _, tag := range remove {
+		delete(tagged, tag)
+	}
+
+	company.Tags = nil
+	for tag := range tagged {
+		company.Tags = append(company.Tags, tag)
+	}
+	sort.Strings(company.Tags)
+	company.UpdatedBy = updatedBy
+
+	f.companies[id] = company
+	return &company, nil
+}
+
+func (f *fakeRepository) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	return nil, nil
+}
+
+type fakeProducer struct {
+	events []string
+	// err fails every event, which is recorded nonetheless.
+	err error
+}
+
+func (f *fakeProducer) ProduceCompany(company *domain.Company, method string) error {
+	f.events = append(f.events, method+" "+company.Name)
+	return f.err
+}
+
+func Test_CreateBatch(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name           string
+		atomic         bool
+		names          []string
+		expectedErrs   []error
+		expectedStored int
+		expectedEvents int
+	}{
+		{"atomic", true, []string{"Alpha", "Beta"}, []error{nil, nil}, 2, 2},
+		{"atomic-invalid", true, []string{"Alpha", ""}, []error{ErrBatchAborted, domain.ValidationErrors{}}, 0, 0},
+		{"atomic-duplicate", true, []string{"Alpha", "Alpha", "Beta"}, []error{ErrBatchAborted, errors.New(""), ErrBatchAborted}, 0, 0},
+		{"best-effort", false, []string{"Alpha", "", "Alpha", "Beta"}, []error{nil, domain.ValidationErrors{}, errors.New(""), nil}, 2, 2},
+	}
+
+	for _, tt := range testCases {
+		repo := newFakeRepository()
+		prod := &fakeProducer{}
+		service := NewCompanyService(repo, nil, prod, CompanyConfig{})
+
+		companies := make([]*domain.Company, len(tt.names))
+		for i, name := range tt.names {
+			companies[i] = &domain.Company{Name: name, Type: domain.Cooperative}
+		}
+
+		errs := service.CreateBatch(ctx, companies, tt.atomic)
+
+		for i, err := range errs {
+			expected := tt.expectedErrs[i]
+			switch {
+			case expected == nil && err != nil:
+				t.Errorf("%s: item %d: expected no error but got %v", tt.name, i, err)
+			case expected == ErrBatchAborted && err != ErrBatchAborted:
+				t.Errorf("%s: item %d: expected %v but got %v", tt.name, i, ErrBatchAborted, err)
+			case expected != nil && err == nil:
+				t.Errorf("%s: item %d: expected an error but got none", tt.name, i)
+			}
+
+			var validationErrs domain.ValidationErrors
+			if _, ok := expected.(domain.ValidationErrors); ok && !errors.As(err, &validationErrs) {
+				t.Errorf("%s: item %d: expected a validation error but got %v", tt.name, i, err)
+			}
+		}
+
+		if len(repo.companies) != tt.expectedStored {
+			t.Errorf("%s: expected %d stored companies but got %d", tt.name, tt.expectedStored, len(repo.companies))
+		}
+
+		if len(prod.events) != tt.expectedEvents {
+			t.Errorf("%s: expected %d events but got %d", tt.name, tt.expectedEvents, len(prod.events))
+		}
+	}
+}
+
+func Test_UpdateBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	prod := &fakeProducer{}
+	service := NewCompanyService(repo, nil, prod, CompanyConfig{})
+
+	company := &domain.Company{Name: "Alpha", Type: domain.Cooperative}
+	_ = repo.Create(ctx, company)
+
+	rename := func(name string) func(*domain.Company) {
+		return func(c *domain.Company) { c.Name = name }
+	}
+
+	_, errs := service.UpdateBatch(ctx, []CompanyUpdate{
+		{ID: company.ID, Apply: rename("Gamma")},
+		{ID: uuid.New(), Apply: rename("Delta")},
+	}, "editor", true)
+
+	if errs[0] != ErrBatchAborted || errs[1] != errNotFound {
+		t.Errorf("expected the atomic batch to abort on the missing company but got %v", errs)
+	}
+
+	stored, _ := repo.Get(ctx, company.ID)
+	if stored.Name != "Alpha" {
+		t.Errorf("expected the aborted batch to leave the company untouched but got %s", stored.Name)
+	}
+
+	updated, errs := service.UpdateBatch(ctx, []CompanyUpdate{
+		{ID: company.ID, Apply: rename("Gamma")},
+		{ID: uuid.New(), Apply: rename("Delta")},
+	}, "editor", false)
+
+	if errs[0] != nil || errs[1] != errNotFound {
+		t.Errorf("expected only the missing company to fail but got %v", errs)
+	}
+
+	if updated[0].Name != "Gamma" || updated[0].UpdatedBy != "editor" {
+		t.Errorf("expected company to be renamed to Gamma by editor but got %s by %s", updated[0].Name, updated[0].UpdatedBy)
+	}
+
+	if len(prod.events) != 1 {
+		t.Errorf("expected 1 event but got %d", len(prod.events))
+	}
+}
+
+func Test_DeleteBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	prod := &fakeProducer{}
+	service := NewCompanyService(repo, nil, prod, CompanyConfig{})
+
+	alpha := &domain.Company{Name: "Alpha"}
+	beta := &domain.Company{Name: "Beta"}
+	_ = repo.Create(ctx, alpha)
+	_ = repo.Create(ctx, beta)
+
+	errs := service.DeleteBatch(ctx, []uuid.UUID{alpha.ID, beta.ID}, "admin", true)
+	if errs[0] != nil || errs[1] != nil {
+		t.Errorf("expected batch delete to succeed but got %v", errs)
+	}
+
+	if len(repo.companies) != 0 || len(prod.events) != 2 {
+		t.Errorf("expected both companies deleted with 2 events but got %d companies and %d events", len(repo.companies), len(prod.events))
+	}
+}
+
+func Test_DeleteSubsidiaries(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name           string
+		subsidiaries   domain.SubsidiaryPolicy
+		expectedErr    error
+		expectedStored []string
+		expectedEvents []string
+	}{
+		{"restrict", domain.RestrictSubsidiaries, errHasSubsidiaries, []string{"Alpha", "Beta", "Gamma", "Root"}, nil},
+		{"detach", domain.DetachSubsidiaries, nil, []string{"Alpha", "Beta", "Gamma"}, []string{"PATCH Alpha", "PATCH Beta", "DELETE "}},
+		{"cascade", domain.CascadeSubsidiaries, nil, nil, []string{"DELETE ", "DELETE ", "DELETE ", "DELETE "}},
+	}
+
+	for _, tt := range testCases {
+		repo := newFakeRepository()
+		prod := &fakeProducer{}
+		service := NewCompanyService(repo, nil, prod, CompanyConfig{})
+
+		// Root has the subsidiaries Alpha and Beta, and Alpha has Gamma.
+		root := &domain.Company{Name: "Root"}
+		_ = repo.Create(ctx, root)
+		alpha := &domain.Company{Name: "Alpha", ParentID: &root.ID}
+		_ = repo.Create(ctx, alpha)
+		_ = repo.Create(ctx, &domain.Company{Name: "Beta", ParentID: &root.ID})
+		_ = repo.Create(ctx, &domain.Company{Name: "Gamma", ParentID: &alpha.ID})
+
+		err := service.Delete(ctx, root.ID, "admin", tt.subsidiaries)
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.expectedErr, err)
+		}
+
+		var stored []string
+		for _, company := range repo.companies {
+			stored = append(stored, company.Name)
+			if company.Name == "Gamma" {
+				if company.ParentID == nil || *company.ParentID != alpha.ID {
+					t.Errorf("%s: expected Gamma to stay a subsidiary of Alpha but got %v", tt.name, company.ParentID)
+				}
+			} else if company.ParentID != nil && tt.subsidiaries == domain.DetachSubsidiaries {
+				t.Errorf("%s: expected %s to be detached but got %v", tt.name, company.Name, company.ParentID)
+			}
+		}
+		sort.Strings(stored)
+		if !reflect.DeepEqual(stored, tt.expectedStored) {
+			t.Errorf("%s: expected %v stored but got %v", tt.name, tt.expectedStored, stored)
+		}
+
+		if !reflect.DeepEqual(prod.events, tt.expectedEvents) {
+			t.Errorf("%s: expected events %v but got %v", tt.name, tt.expectedEvents, prod.events)
+		}
+	}
+}
+
+type sliceSource struct {
+	rows []any
+	row  int
+}
+
+func (s *sliceSource) Next() (*domain.Company, error) {
+	if s.row >= len(s.rows) {
+		return nil, io.EOF
+	}
+	s.row++
+
+	switch row := s.rows[s.row-1].(type) {
+	case error:
+		return nil, &RowError{Row: s.row, Err: row}
+	default:
+		return row.(*domain.Company), nil
+	}
+}
+
+func (s *sliceSource) Row() int {
+	return s.row
+}
+
+func Test_Import(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	prod := &fakeProducer{}
+	service := NewCompanyService(repo, nil, prod, CompanyConfig{})
+
+	_ = repo.Create(ctx, &domain.Company{Name: "Taken"})
+
+	src := &sliceSource{rows: []any{
+		&domain.Company{Name: "Alpha"},
+		errors.New("unreadable"),
+		&domain.Company{Name: ""},
+		&domain.Company{Name: "Alpha"},
+		&domain.Company{Name: "Taken"},
+		&domain.Company{Name: "Beta"},
+	}}
+
+	result, err := service.Import(ctx, src, "importer")
+	if err != nil {
+		t.Fatalf("import returned an error: %s", err)
+	}
+
+	if len(result.Created) != 2 || len(prod.events) != 2 {
+		t.Errorf("expected 2 companies created with 2 events but got %d and %d", len(result.Created), len(prod.events))
+	}
+
+	expectedRows := []int{2, 3, 4, 5}
+	if len(result.Errors) != len(expectedRows) {
+		t.Fatalf("expected errors for rows %v but got %v", expectedRows, result.Errors)
+	}
+
+	for i, rowErr := range result.Errors {
+		if rowErr.Row != expectedRows[i] {
+			t.Errorf("expected error %d to be for row %d but got row %d", i, expectedRows[i], rowErr.Row)
+		}
+	}
+
+	if result.Errors[2].Err != ErrNameTaken || result.Errors[3].Err != ErrNameTaken {
+		t.Errorf("expected duplicate names to be reported as %v but got %v", ErrNameTaken, result.Errors)
+	}
+
+	for _, company := range result.Created {
+		if company.CreatedBy != "importer" {
+			t.Errorf("expected imported company to be created by importer but got %q", company.CreatedBy)
+		}
+	}
+
+	if result.PublishErr != nil {
+		t.Errorf("expected the companies to be produced but got %v", result.PublishErr)
+	}
+}
+
+func Test_ImportPublishFailure(t *testing.T) {
+	errBroker := errors.New("broker unavailable")
+	prod := &fakeProducer{err: errBroker}
+	service := NewCompanyService(newFakeRepository(), nil, prod, CompanyConfig{})
+
+	src := &sliceSource{rows: []any{&domain.Company{Name: "Alpha"}, &domain.Company{Name: "Beta"}}}
+	result, err := service.Import(context.Background(), src, "importer")
+	if err != nil {
+		t.Fatalf("expected the committed import to succeed but got %s", err)
+	}
+
+	if len(result.Created) != 2 || len(prod.events) != 2 {
+		t.Errorf("expected 2 companies created and 2 events attempted but got %d and %d", len(result.Created), len(prod.events))
+	}
+	if !errors.Is(result.PublishErr, errBroker) {
+		t.Errorf("expected the publish failure to be reported but got %v", result.PublishErr)
+	}
+}
+
+// deadlineRepository records the deadline of the context of every read and
+// write, and blocks until that context is done.
+type deadlineRepository struct {
+	*fakeRepository
+	deadlines []time.Duration
+}
+
+func (f *deadlineRepository) wait(ctx context.Context) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		f.deadlines = append(f.deadlines, 0)
+		return nil
+	}
+	f.deadlines = append(f.deadlines, time.Until(deadline).Round(time.Minute))
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (f *deadlineRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Company, error) {
+	return nil, f.wait(ctx)
+}
+
+func (f *deadlineRepository) Create(ctx context.Context, company *domain.Company) error {
+	return f.wait(ctx)
+}
+
+func (f *deadlineRepository) Export(ctx context.Context, filter domain.CompanyFilter, fn func(*domain.Company) error) error {
+	return f.wait(ctx)
+}
+
+func Test_CompanyServiceTimeouts(t *testing.T) {
+	repo := &deadlineRepository{fakeRepository: newFakeRepository()}
+	service := NewCompanyService(repo, nil, &fakeProducer{}, CompanyConfig{
+		ReadTimeout:  time.Minute,
+		WriteTimeout: 2 * time.Minute,
+		BulkTimeout:  3 * time.Minute,
+	})
+
+	// The parent deadline is shorter than every configured one and wins.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := service.Get(ctx, uuid.New())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded but got %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	err = service.Create(ctx, &domain.Company{Name: "Alpha"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled but got %v", err)
+	}
+
+	err = service.Export(ctx, domain.CompanyFilter{}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the request to be cancelled but got %v", err)
+	}
+
+	// The read kept the parent deadline, rounded down to no time at all.
+	expected := []time.Duration{0, 2 * time.Minute, 3 * time.Minute}
+	if len(repo.deadlines) != len(expected) {
+		t.Fatalf("expected %d repository calls but got %d", len(expected), len(repo.deadlines))
+	}
+	for i, deadline := range repo.deadlines {
+		if deadline != expected[i] {
+			t.Errorf("call %d: expected a deadline in %s but got %s", i, expected[i], deadline)
+		}
+	}
+
+	unbounded := &deadlineRepository{fakeRepository: newFakeRepository()}
+	_, err = NewCompanyService(unbounded, nil, &fakeProducer{}, CompanyConfig{}).Get(context.Background(), uuid.New())
+	if err != nil || len(unbounded.deadlines) != 1 || unbounded.deadlines[0] != 0 {
+		t.Errorf("expected no deadline without a timeout but got %v, %v", unbounded.deadlines, err)
+	}
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+// AddTags tags the company on behalf of updatedBy, leaving the tags it
+// already has, and returns it with its tags. The change is produced as an
+// update of the company.
+func (c *CompanyService) AddTags(ctx context.Context, id uuid.UUID, tags []string, updatedBy string) (*domain.Company, error) {
+	parsed, err := domain.ParseTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	return c.updateTags(ctx, id, parsed, nil, updatedBy)
+}
+
+// RemoveTags removes the tags from the company on behalf of updatedBy,
+// ignoring those it does not have, and returns it with its tags. The change
+// is produced as an update of the company.
+func (c *CompanyService) RemoveTags(ctx context.Context, id uuid.UUID, tags []string, updatedBy string) (*domain.Company, error) {
+	parsed, err := domain.ParseTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	return c.updateTags(ctx, id, nil, parsed, updatedBy)
+}
+
+func (c *CompanyService) updateTags(ctx context.Context, id uuid.UUID, add, remove []string, updatedBy string) (*domain.Company, error) {
+	ctx, cancel := withTimeout(ctx, c.config.WriteTimeout)
+	defer cancel()
+
+	company, err := c.repo.UpdateTags(ctx, id, add, remove, updatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return company, c.producer.ProduceCompany(company, http.MethodPatch)
+}
+
+// Tags returns the tags of the companies that are not deleted, with the
+// number of companies that have each, ordered by name.
+func (c *CompanyService) Tags(ctx context.Context) ([]*domain.Tag, error) {
+	ctx, cancel := withTimeout(ctx, c.config.ReadTimeout)
+	defer cancel()
+
+	return c.repo.Tags(ctx)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+func Test_CompanyTags(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeRepository()
+	prod := &fakeProducer{}
+	service := NewCompanyService(repo, nil, prod, CompanyConfig{})
+
+	company := &domain.Company{Name: "Tagged"}
+	err := repo.Create(ctx, company)
+	if err != nil {
+		t.Fatalf("error creating company: %s", err)
+	}
+
+	tagged, err := service.AddTags(ctx, company.ID, []string{" Tier:1", "region:emea", "tier:1"}, "tester")
+	if err != nil || !reflect.DeepEqual(tagged.Tags, domain.Tags{"region:emea", "tier:1"}) || tagged.UpdatedBy != "tester" {
+		t.Errorf("expected the tags normalized, without duplicates and sorted but got %+v, %v", tagged, err)
+	}
+	if !reflect.DeepEqual(prod.events, []string{"PATCH Tagged"}) {
+		t.Errorf("expected the tags to be produced as an update but got %v", prod.events)
+	}
+
+	var errs domain.ValidationErrors
+	for _, tags := range [][]string{nil, {" "}, {"region/emea"}, {"-tier"}} {
+		_, err = service.AddTags(ctx, company.ID, tags, "tester")
+		if !errors.As(err, &errs) || errs["tags"] == "" {
+			t.Errorf("%q: expected a validation error of the tags but got %v", tags, err)
+		}
+	}
+	if len(prod.events) != 1 {
+		t.Errorf("expected no event for rejected tags but got %v", prod.events)
+	}
+
+	untagged, err := service.RemoveTags(ctx, company.ID, []string{"TIER:1", "owner:jane"}, "tester")
+	if err != nil || !reflect.DeepEqual(untagged.Tags, domain.Tags{"region:emea"}) {
+		t.Errorf("expected only the region to be left but got %+v, %v", untagged, err)
+	}
+
+	_, err = service.AddTags(ctx, uuid.New(), []string{"tier:2"}, "tester")
+	if !errors.Is(err, errNotFound) || len(prod.events) != 2 {
+		t.Errorf("expected tagging an unknown company to return %v without an event but got %v", errNotFound, err)
+	}
+}
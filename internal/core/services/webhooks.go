@@ -0,0 +1,349 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+	"github.com/petrostrak/xm-companies/internal/core/ports"
+)
+
+// The headers of the requests delivering webhook events.
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+var (
+	ErrWebhookPrivateAddress = errors.New("webhook address is not public")
+)
+
+type WebhookConfig struct {
+	Concurrency  int
+	PollInterval time.Duration
+	// Timeout bounds each delivery attempt.
+	Timeout     time.Duration
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubling with every
+	// further retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// DisableAfter is how many consecutive failed attempts disable a webhook.
+	DisableAfter int
+	// AllowPrivateNetworks lets webhooks point to loopback, private and
+	// internal hosts, which they must not reach in production.
+	AllowPrivateNetworks bool
+}
+
+type WebhookService struct {
+	repo   ports.WebhookRepository
+	config WebhookConfig
+	client *http.Client
+	logger *log.Logger
+}
+
+func NewWebhookService(repo ports.WebhookRepository, config WebhookConfig, logger *log.Logger) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		config: config,
+		client: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: webhookTransport(config.AllowPrivateNetworks),
+			// Redirects are reported as failures rather than followed, so that
+			// payloads only go to the URL that was subscribed.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger,
+	}
+}
+
+// webhookTransport connects to webhooks only when their addresses are public,
+// unless private networks are allowed. The check is made on the resolved
+// address, so that host names cannot be pointed to private addresses once
+// their webhooks are validated. Proxies are not used, since they would
+// connect on behalf of the transport, past the check.
+func webhookTransport(allowPrivateNetworks bool) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivateNetworks {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !domain.PublicIP(ip) {
+				return ErrWebhookPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// validate validates the webhook, allowing private hosts when configured to.
+func (s *WebhookService) validate(webhook *domain.Webhook) error {
+	if s.config.AllowPrivateNetworks {
+		return webhook.ValidateAllowingPrivateHosts()
+	}
+	return webhook.Validate()
+}
+
+// Create stores the webhook with a new secret, which signs its deliveries.
+func (s *WebhookService) Create(ctx context.Context, webhook *domain.Webhook) error {
+	err := s.validate(webhook)
+	if err != nil {
+		return err
+	}
+
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	webhook.Secret = "whsec_" + hex.EncodeToString(secret)
+
+	return s.repo.Create(ctx, webhook)
+}
+
+func (s *WebhookService) Get(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *WebhookService) List(ctx context.Context, createdBy string) ([]*domain.Webhook, error) {
+	return s.repo.List(ctx, createdBy)
+}
+
+// Update stores the changes to the webhook. Reactivating a webhook resets its
+// failures, and its pending deliveries are attempted again.
+func (s *WebhookService) Update(ctx context.Context, webhook *domain.Webhook) error {
+	err := s.validate(webhook)
+	if err != nil {
+		return err
+	}
+
+	if webhook.Active {
+		webhook.ConsecutiveFailures = 0
+		webhook.DisabledAt = nil
+	} else if webhook.DisabledAt == nil {
+		now := time.Now()
+		webhook.DisabledAt = &now
+	}
+
+	return s.repo.Update(ctx, webhook)
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	_, err := s.repo.Get(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Deliveries(ctx, webhookID, limit)
+}
+
+// WebhookPayload is the body of the requests delivering webhook events.
+type WebhookPayload struct {
+	ID         uuid.UUID      `json:"id"`
+	Event      string         `json:"event"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	Company    domain.Company `json:"company"`
+}
+
+// Enqueue queues a delivery of the change to every webhook subscribed to it.
+func (s *WebhookService) Enqueue(ctx context.Context, company *domain.Company, method string) error {
+	payload := WebhookPayload{
+		ID:         uuid.New(),
+		Event:      "company." + domain.CompanyEvent{Method: method}.Kind(),
+		OccurredAt: time.Now().UTC(),
+		Company:    *company,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.Enqueue(ctx, payload.ID, payload.Event, body)
+	return err
+}
+
+// WebhookSignature returns the signature of a delivery: the hex encoded
+// HMAC-SHA256, keyed by the secret of the webhook, of its timestamp and body
+// joined by a dot.
+func WebhookSignature(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run delivers the due deliveries with the configured number of workers until
+// ctx is done.
+func (s *WebhookService) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (s *WebhookService) work(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep delivering while there are due deliveries before waiting for
+		// the next tick.
+		for ctx.Err() == nil {
+			delivered, err := s.deliverNext(ctx)
+			if err != nil {
+				s.logger.Printf("[ERROR] delivering webhook: %v", err)
+				break
+			}
+			if !delivered {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverNext attempts the delivery that has been due the longest and reports
+// whether there was one.
+func (s *WebhookService) deliverNext(ctx context.Context) (bool, error) {
+	// The lease outlasts the attempt, so that no other worker claims the
+	// delivery in the meantime.
+	delivery, webhook, err := s.repo.Claim(ctx, 2*s.config.Timeout)
+	if err != nil || delivery == nil {
+		return false, err
+	}
+
+	delivery.ResponseStatus, err = s.post(ctx, webhook, delivery)
+
+	switch {
+	case err == nil:
+		delivery.Status = domain.WebhookDeliverySucceeded
+		delivery.Error = ""
+	case delivery.Attempts >= s.config.MaxAttempts:
+		delivery.Status = domain.WebhookDeliveryFailed
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = domain.WebhookDeliveryPending
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(s.backoff(delivery.Attempts))
+	}
+
+	// The attempt is recorded even when ctx is done, so that a delivery cut
+	// short by a shutdown is retried after its backoff rather than its lease.
+	disabled, err := s.repo.Record(context.Background(), delivery, s.config.DisableAfter)
+	if err != nil {
+		return true, err
+	}
+
+	if disabled {
+		s.logger.Printf("webhook %s disabled after %d consecutive failures", webhook.ID, s.config.DisableAfter)
+	}
+	return true, nil
+}
+
+// post sends the delivery to the webhook, failing unless it responds with a
+// 2xx status.
+func (s *WebhookService) post(ctx context.Context, webhook *domain.Webhook, delivery *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "xm-companies-webhooks")
+	req.Header.Set(WebhookIDHeader, delivery.ID.String())
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, WebhookSignature(webhook.Secret, timestamp, delivery.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Draining a little of the body lets the connection be reused.
+	_, _ = io.CopyN(io.Discard, res.Body, 4096)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	backoff := s.config.Backoff
+	for i := 1; i < attempts && backoff < s.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > s.config.MaxBackoff {
+		backoff = s.config.MaxBackoff
+	}
+	return backoff
+}
+
+// WebhookProducer is a producer that queues a delivery of every company event
+// to the webhooks subscribed to it and hands the event to another producer,
+// doing both even if one of them fails. Producers are not given the context
+// of the change, so deliveries are queued with a background context.
+type WebhookProducer struct {
+	webhooks *WebhookService
+	next     ports.CompanyProducer
+}
+
+func NewWebhookProducer(webhooks *WebhookService, next ports.CompanyProducer) *WebhookProducer {
+	return &WebhookProducer{webhooks: webhooks, next: next}
+}
+
+func (p *WebhookProducer) ProduceCompany(company *domain.Company, method string) error {
+	return errors.Join(
+		p.webhooks.Enqueue(context.Background(), company, method),
+		p.next.ProduceCompany(company, method),
+	)
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/petrostrak/xm-companies/internal/core/domain"
+)
+
+type fakeWebhookRepository struct {
+	mu         sync.Mutex
+	webhooks   map[uuid.UUID]*domain.Webhook
+	deliveries []*domain.WebhookDelivery
+}
+
+func newFakeWebhookRepository() *fakeWebhookRepository {
+	return &fakeWebhookRepository{webhooks: make(map[uuid.UUID]*domain.Webhook)}
+}
+
+func (f *fakeWebhookRepository) Create(ctx context.Context, webhook *domain.Webhook) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	webhook.ID = uuid.New()
+	webhook.Active = true
+	webhook.CreatedAt = time.Now()
+	stored := *webhook
+	f.webhooks[webhook.ID] = &stored
+	return nil
+}
+
+func (f *fakeWebhookRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	webhook, ok := f.webhooks[id]
+	if !ok {
+		return nil, errNotFound
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (f *fakeWebhookRepository) List(ctx context.Context, createdBy string) ([]*domain.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	webhooks := []*domain.Webhook{}
+	for _, webhook := range f.webhooks {
+		if createdBy == "" || webhook.CreatedBy == createdBy {
+			copied := *webhook
+			webhooks = append(webhooks, &copied)
+		}
+	}
+	return webhooks, nil
+}
+
+func (f *fakeWebhookRepository) Update(ctx context.Context, webhook *domain.Webhook) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.webhooks[webhook.ID]; !ok {
+		return errNotFound
+	}
+	stored := *webhook
+	f.webhooks[webhook.ID] = &stored
+	return nil
+}
+
+func (f *fakeWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.webhooks[id]; !ok {
+		return errNotFound
+	}
+	delete(f.webhooks, id)
+	return nil
+}
+
+func (f *fakeWebhookRepository) Enqueue(ctx context.Context, eventID uuid.UUID, event string, payload []byte) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var enqueued int64
+	for _, webhook := range f.webhooks {
+		if webhook.Active && webhook.Subscribed(event) {
+			f.deliveries = append(f.deliveries, &domain.WebhookDelivery{
+				ID:            uuid.New(),
+				WebhookID:     webhook.ID,
+				EventID:       eventID,
+				Event:         event,
+				Payload:       payload,
+				Status:        domain.WebhookDeliveryPending,
+				NextAttemptAt: time.Now(),
+				CreatedAt:     time.Now(),
+			})
+			enqueued++
+		}
+	}
+	return enqueued, nil
+}
+
+func (f *fakeWebhookRepository) Claim(ctx context.Context, lease time.Duration) (*domain.WebhookDelivery, *domain.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, delivery := range f.deliveries {
+		webhook := f.webhooks[delivery.WebhookID]
+		if delivery.Status != domain.WebhookDeliveryPending || delivery.NextAttemptAt.After(time.Now()) || !webhook.Active {
+			continue
+		}
+		delivery.Attempts++
+		delivery.NextAttemptAt = time.Now().Add(lease)
+		copiedDelivery, copiedWebhook := *delivery, *webhook
+		return &copiedDelivery, &copiedWebhook, nil
+	}
+	return nil, nil, nil
+}
+
+func (f *fakeWebhookRepository) Record(ctx context.Context, delivery *domain.WebhookDelivery, disableAfter int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, stored := range f.deliveries {
+		if stored.ID == delivery.ID {
+			copied := *delivery
+			f.deliveries[i] = &copied
+		}
+	}
+
+	webhook := f.webhooks[delivery.WebhookID]
+	if delivery.Status == domain.WebhookDeliverySucceeded {
+		webhook.ConsecutiveFailures = 0
+		return false, nil
+	}
+
+	webhook.ConsecutiveFailures++
+	if webhook.Active && webhook.ConsecutiveFailures >= disableAfter {
+		now := time.Now()
+		webhook.Active = false
+		webhook.DisabledAt = &now
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f *fakeWebhookRepository) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	deliveries := []*domain.WebhookDelivery{}
+	for i := len(f.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		if f.deliveries[i].WebhookID == webhookID {
+			copied := *f.deliveries[i]
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	return deliveries, nil
+}
+
+func newTestWebhookService(repo *fakeWebhookRepository, maxAttempts, disableAfter int) *WebhookService {
+	return NewWebhookService(repo, WebhookConfig{
+		Concurrency:  2,
+		PollInterval: 5 * time.Millisecond,
+		Timeout:      time.Second,
+		MaxAttempts:  maxAttempts,
+		Backoff:      time.Millisecond,
+		MaxBackoff:   4 * time.Millisecond,
+		DisableAfter: disableAfter,
+		// The receivers of the tests listen on the loopback.
+		AllowPrivateNetworks: true,
+	}, log.New(io.Discard, "", 0))
+}
+
+// waitFor polls the condition until it holds.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func lastDelivery(t *testing.T, service *WebhookService, webhookID uuid.UUID) *domain.WebhookDelivery {
+	deliveries, err := service.Deliveries(context.Background(), webhookID, 1)
+	if err != nil {
+		t.Fatalf("error listing deliveries: %s", err)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return deliveries[0]
+}
+
+func Test_WebhookServiceDelivery(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	service := newTestWebhookService(repo, 3, 10)
+
+	type received struct {
+		header  http.Header
+		payload WebhookPayload
+		valid   bool
+	}
+	requests := make(chan received, 10)
+
+	var secret string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+
+		var payload WebhookPayload
+		_ = json.Unmarshal(body, &payload)
+
+		requests <- received{
+			header:  r.Header,
+			payload: payload,
+			valid:   r.Header.Get(WebhookSignatureHeader) == WebhookSignature(secret, timestamp, body),
+		}
+	}))
+	defer receiver.Close()
+
+	err := service.Create(context.Background(), &domain.Webhook{URL: "ftp://example.com"})
+	if _, ok := err.(domain.ValidationErrors); !ok {
+		t.Errorf("expected validation errors for a non http URL but got %v", err)
+	}
+
+	webhook := &domain.Webhook{URL: receiver.URL, Events: []string{domain.WebhookCompanyCreated}, CreatedBy: "tester"}
+	err = service.Create(context.Background(), webhook)
+	if err != nil {
+		t.Fatalf("error creating webhook: %s", err)
+	}
+	secret = webhook.Secret
+
+	company := &domain.Company{ID: uuid.New(), Name: "XM", NumberOfEmployees: 10}
+
+	producer := NewWebhookProducer(service, &fakeProducer{})
+	for _, method := range []string{http.MethodPost, http.MethodPatch} {
+		err = producer.ProduceCompany(company, method)
+		if err != nil {
+			t.Fatalf("error producing %s: %s", method, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = service.Run(ctx) }()
+
+	select {
+	case r := <-requests:
+		if !r.valid {
+			t.Errorf("expected a valid signature but got %s", r.header.Get(WebhookSignatureHeader))
+		}
+		if r.header.Get(WebhookEventHeader) != domain.WebhookCompanyCreated || r.payload.Event != domain.WebhookCompanyCreated {
+			t.Errorf("expected event %s but got %s", domain.WebhookCompanyCreated, r.payload.Event)
+		}
+		if r.payload.Company.ID != company.ID || r.payload.Company.Name != "XM" {
+			t.Errorf("expected company %v in the payload but got %+v", company.ID, r.payload.Company)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the delivery")
+	}
+
+	waitFor(t, "the delivery to succeed", func() bool {
+		delivery := lastDelivery(t, service, webhook.ID)
+		return delivery != nil && delivery.Status == domain.WebhookDeliverySucceeded
+	})
+
+	select {
+	case r := <-requests:
+		t.Errorf("expected only subscribed events to be delivered but got %s", r.payload.Event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func Test_WebhookServiceRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		maxAttempts  int
+		disableAfter int
+		status       domain.WebhookDeliveryStatus
+		attempts     int
+		active       bool
+	}{
+		{"succeeds after retries", 2, 5, 10, domain.WebhookDeliverySucceeded, 3, true},
+		{"fails after max attempts", 100, 3, 10, domain.WebhookDeliveryFailed, 3, true},
+		{"disables after consecutive failures", 100, 5, 2, domain.WebhookDeliveryPending, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeWebhookRepository()
+			service := newTestWebhookService(repo, tt.maxAttempts, tt.disableAfter)
+
+			var calls atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer receiver.Close()
+
+			webhook := &domain.Webhook{URL: receiver.URL}
+			err := service.Create(context.Background(), webhook)
+			if err != nil {
+				t.Fatalf("error creating webhook: %s", err)
+			}
+
+			err = service.Enqueue(context.Background(), &domain.Company{ID: uuid.New(), Name: "XM"}, http.MethodDelete)
+			if err != nil {
+				t.Fatalf("error enqueuing delivery: %s", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() { _ = service.Run(ctx) }()
+
+			waitFor(t, "the delivery to be attempted", func() bool {
+				delivery := lastDelivery(t, service, webhook.ID)
+				return delivery.Attempts == tt.attempts && delivery.Status == tt.status
+			})
+
+			// Give the workers the chance of an attempt too many.
+			time.Sleep(20 * time.Millisecond)
+
+			delivery := lastDelivery(t, service, webhook.ID)
+			if delivery.Status != tt.status || delivery.Attempts != tt.attempts {
+				t.Errorf("expected a %s delivery after %d attempts but got a %s one after %d", tt.status, tt.attempts, delivery.Status, delivery.Attempts)
+			}
+
+			if tt.status != domain.WebhookDeliverySucceeded && delivery.ResponseStatus != http.StatusServiceUnavailable {
+				t.Errorf("expected response status %d but got %d", http.StatusServiceUnavailable, delivery.ResponseStatus)
+			}
+
+			webhook, err = service.Get(context.Background(), webhook.ID)
+			if err != nil {
+				t.Fatalf("error getting webhook: %s", err)
+			}
+			if webhook.Active != tt.active || (webhook.DisabledAt == nil) != tt.active {
+				t.Errorf("expected webhook active to be %t but got %+v", tt.active, webhook)
+			}
+		})
+	}
+}
+
+func Test_WebhookServicePrivateAddress(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	service := NewWebhookService(repo, WebhookConfig{
+		Concurrency:  1,
+		PollInterval: 5 * time.Millisecond,
+		Timeout:      time.Second,
+		MaxAttempts:  1,
+		DisableAfter: 10,
+	}, log.New(io.Discard, "", 0))
+
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer receiver.Close()
+
+	err := service.Create(context.Background(), &domain.Webhook{URL: receiver.URL})
+	if _, ok := err.(domain.ValidationErrors); !ok {
+		t.Errorf("expected validation errors for a loopback URL but got %v", err)
+	}
+
+	// A public name that resolves to the loopback gets past the validation,
+	// so the webhook is stored as such a name would be.
+	webhook := &domain.Webhook{URL: receiver.URL}
+	err = repo.Create(context.Background(), webhook)
+	if err != nil {
+		t.Fatalf("error creating webhook: %s", err)
+	}
+
+	err = service.Enqueue(context.Background(), &domain.Company{ID: uuid.New(), Name: "XM"}, http.MethodPost)
+	if err != nil {
+		t.Fatalf("error enqueuing delivery: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = service.Run(ctx) }()
+
+	waitFor(t, "the delivery to fail", func() bool {
+		delivery := lastDelivery(t, service, webhook.ID)
+		return delivery.Status == domain.WebhookDeliveryFailed
+	})
+
+	delivery := lastDelivery(t, service, webhook.ID)
+	if !strings.Contains(delivery.Error, ErrWebhookPrivateAddress.Error()) {
+		t.Errorf("expected the delivery to fail with %q but got %q", ErrWebhookPrivateAddress, delivery.Error)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no request to reach the receiver but got %d", calls.Load())
+	}
+}
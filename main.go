@@ -1,21 +1,83 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net"
 	"net/http"
 	"os"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/petrostrak/xm-companies/internal/adapters/kafka/consumer"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err := runMigrate(os.Args[2:])
+		if err != nil {
+			log.Fatalf("error migrating the database: %v", err)
+		}
+		return
+	}
+
 	var app Application
 
 	err := app.New()
 	if err != nil {
-		os.Exit(1)
+		log.Fatalf("error starting the application: %v", err)
+	}
+
+	if app.Config.PurgeInterval > 0 {
+		go app.purge(context.Background())
+	}
+
+	if app.CompanyCache != nil {
+		go func() {
+			err := consumer.ConsumeCompanyInvalidations(context.Background(), app.CompanyCache.Invalidate)
+			if err != nil {
+				app.Logger.Printf("cache invalidation stopped: %v", err)
+			}
+		}()
 	}
 
+	if app.Config.StreamSource == "kafka" {
+		go func() {
+			err := consumer.ConsumeCompanyEvents(context.Background(), app.Broadcaster.Publish)
+			if err != nil {
+				app.Logger.Printf("company event stream stopped: %v", err)
+			}
+		}()
+	}
+
+	go func() {
+		err := app.JobService.Run(context.Background())
+		if err != nil {
+			app.Logger.Printf("job workers stopped: %v", err)
+		}
+	}()
+
+	go func() {
+		err := app.WebhookService.Run(context.Background())
+		if err != nil {
+			app.Logger.Printf("webhook workers stopped: %v", err)
+		}
+	}()
+
+	go func() {
+		lis, err := net.Listen("tcp", app.Config.GRPCAddress)
+		if err != nil {
+			app.Logger.Printf("grpc server not started: %v", err)
+			return
+		}
+
+		app.Logger.Printf("starting grpc server on %s", lis.Addr())
+		err = app.GRPCServer.Serve(lis)
+		if err != nil {
+			app.Logger.Printf("grpc server stopped: %v", err)
+		}
+	}()
+
 	srv := &http.Server{
 		Addr:        app.Config.ServerAddress,
 		Handler:     app.Routes,
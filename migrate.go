@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/petrostrak/xm-companies/db"
+	"github.com/petrostrak/xm-companies/internal/adapters/repository"
+	"github.com/petrostrak/xm-companies/utils"
+)
+
+const migrateUsage = "usage: migrate up | down | to VERSION | force VERSION | version"
+
+// runMigrate runs the migrate command against the database of app.env.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	config, err := utils.LoadConfig(".")
+	if err != nil {
+		return err
+	}
+
+	store, err := repository.NewPostgresRepository(postgresConfig(config))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	migrations, err := repository.LoadMigrations(db.Migrations())
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	migrator := repository.NewMigrator(store.CompanyRepository.DB, migrations, logger)
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return migrator.Up(ctx)
+	case args[0] == "down" && len(args) == 1:
+		return migrator.Down(ctx)
+	case (args[0] == "to" || args[0] == "force") && len(args) == 2:
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if args[0] == "force" {
+			return migrator.Force(ctx, uint(version))
+		}
+		return migrator.To(ctx, uint(version))
+	case args[0] == "version" && len(args) == 1:
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("version %d of %d, dirty: %t\n", version, migrator.Latest(), dirty)
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AttributeDefinition defines a custom attribute of companies, whose values
+// must match its schema.
+type AttributeDefinition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Schema is written in the subset of JSON Schema that OpenAPI 3.0
+	// supports.
+	Schema    json.RawMessage `json:"schema"`
+	CreatedBy string          `json:"created_by"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// AttributeDefinitionInput holds the fields of a new attribute definition.
+type AttributeDefinitionInput struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+// AttributeDefinitionUpdate holds the fields to change of an attribute
+// definition. Nil fields are left unchanged.
+type AttributeDefinitionUpdate struct {
+	Description *string         `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema,omitempty"`
+}
+
+type attributeEnvelope struct {
+	Attribute AttributeDefinition `json:"attribute"`
+}
+
+func attributePath(name string) string {
+	return "/attributes/" + url.PathEscape(name)
+}
+
+// ListAttributes returns every attribute definition, ordered by name.
+func (c *Client) ListAttributes(ctx context.Context) ([]AttributeDefinition, error) {
+	var env struct {
+		Attributes []AttributeDefinition `json:"attributes"`
+	}
+	err := c.do(ctx, &request{method: http.MethodGet, path: "/attributes"}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Attributes, nil
+}
+
+func (c *Client) GetAttribute(ctx context.Context, name string) (*AttributeDefinition, error) {
+	var env attributeEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: attributePath(name)}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Attribute, nil
+}
+
+// CreateAttribute defines a custom attribute of companies. It requires a
+// token with the admin role.
+func (c *Client) CreateAttribute(ctx context.Context, input AttributeDefinitionInput) (*AttributeDefinition, error) {
+	req, err := jsonRequest(http.MethodPost, "/attributes", input)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env attributeEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Attribute, nil
+}
+
+// UpdateAttribute changes an attribute definition. It requires a token with
+// the admin role.
+func (c *Client) UpdateAttribute(ctx context.Context, name string, update AttributeDefinitionUpdate) (*AttributeDefinition, error) {
+	req, err := jsonRequest(http.MethodPatch, attributePath(name), update)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env attributeEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Attribute, nil
+}
+
+// DeleteAttribute deletes an attribute definition. Companies keep the values
+// they have for it. It requires a token with the admin role.
+func (c *Client) DeleteAttribute(ctx context.Context, name string) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: attributePath(name), idempotent: true}, nil)
+}
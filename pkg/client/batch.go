@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// BatchMode selects how the items of a batch are applied.
+type BatchMode string
+
+const (
+	// Atomic batches are applied entirely or not at all.
+	Atomic BatchMode = "atomic"
+	// BestEffort batches apply every item that succeeds.
+	BestEffort BatchMode = "best-effort"
+)
+
+// BatchResult is the outcome of an item of a batch.
+type BatchResult struct {
+	Index  int
+	Status int
+	// ID is the ID of the company of an update that failed or of a deletion.
+	ID *uuid.UUID
+	// Company is the company created or updated by the item.
+	Company *Company
+	// Err is the reason the item failed, or nil when it succeeded. Items of a
+	// failed atomic batch that were not at fault fail with 424.
+	Err *Error
+}
+
+func (r *BatchResult) UnmarshalJSON(data []byte) error {
+	var result struct {
+		Index   int             `json:"index"`
+		Status  int             `json:"status"`
+		ID      *uuid.UUID      `json:"id"`
+		Company *Company        `json:"company"`
+		Error   json.RawMessage `json:"error"`
+	}
+	err := json.Unmarshal(data, &result)
+	if err != nil {
+		return err
+	}
+
+	*r = BatchResult{Index: result.Index, Status: result.Status, ID: result.ID, Company: result.Company}
+	if len(result.Error) > 0 {
+		r.Err = &Error{StatusCode: result.Status}
+		r.Err.Message, r.Err.Fields = decodeErrorMessage(result.Error)
+	}
+	return nil
+}
+
+// CompanyBatchUpdate is the update of a company in a batch.
+type CompanyBatchUpdate struct {
+	ID uuid.UUID `json:"id"`
+	CompanyUpdate
+}
+
+// CreateCompanies creates companies in a batch. Best-effort batches return the
+// result of every item, some of which may have failed, while atomic batches
+// that fail return an *Error holding them.
+func (c *Client) CreateCompanies(ctx context.Context, companies []CompanyInput, mode BatchMode) ([]BatchResult, error) {
+	return c.batch(ctx, http.MethodPost, companies, mode)
+}
+
+// UpdateCompanies updates companies in a batch, with the same outcomes as
+// CreateCompanies.
+func (c *Client) UpdateCompanies(ctx context.Context, updates []CompanyBatchUpdate, mode BatchMode) ([]BatchResult, error) {
+	return c.batch(ctx, http.MethodPatch, updates, mode)
+}
+
+// DeleteCompanies deletes companies in a batch, with the same outcomes as
+// CreateCompanies.
+func (c *Client) DeleteCompanies(ctx context.Context, ids []uuid.UUID, mode BatchMode) ([]BatchResult, error) {
+	return c.batch(ctx, http.MethodDelete, ids, mode)
+}
+
+func (c *Client) batch(ctx context.Context, method string, items any, mode BatchMode) ([]BatchResult, error) {
+	req, err := jsonRequest(method, "/companies:batch", items)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+	if mode != "" {
+		req.query = url.Values{"mode": {string(mode)}}
+	}
+
+	var env struct {
+		Results []BatchResult `json:"results"`
+	}
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Results, nil
+}
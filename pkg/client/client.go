@@ -0,0 +1,293 @@
+// Package client is a Go client for the companies API.
+//
+// Every method takes a context, which bounds the request along with its
+// retries. Requests are retried with exponential backoff when the API responds
+// with 429, honouring its Retry-After header, and, when it is safe to send them
+// again, with a 5xx status or not at all. Reads are always safe to retry and
+// the client sends the mutations that support it with an Idempotency-Key, so
+// that the API replays the response of the first attempt to the retries.
+//
+// Error responses are returned as *Error, which can be matched against
+// ErrNotFound, ErrValidation and the other sentinel errors with errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Client sends requests to the companies API. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	token      string
+	apiKey     string
+	userAgent  string
+
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client the requests are sent with, e.g. to
+// change its transport or timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken authenticates the requests with a JWT, which every route but the
+// reads of companies requires.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithAPIKey sends an API key in the X-API-Key header, for gateways in front
+// of the API that check it. The API itself identifies clients by their token
+// or, without one, by their IP address.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetries sets how many times a request is retried and the bounds of the
+// backoff between the attempts, which doubles from minBackoff up to
+// maxBackoff. Zero retries disables them.
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New returns a client of the API served at baseURL, e.g.
+// "http://localhost:8000".
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("base URL must be an absolute http or https URL but got %q", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		userAgent:  "xm-companies-go",
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	return c, nil
+}
+
+// request describes a request to the API. Bodies held in body can be sent
+// again, while those read from stream are sent once and never retried.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        []byte
+	stream      io.Reader
+	contentType string
+	accept      string
+
+	// idempotent requests are sent with an Idempotency-Key, which makes them
+	// safe to retry.
+	idempotent bool
+}
+
+// jsonRequest returns a request whose body is v encoded as JSON.
+func jsonRequest(method, path string, v any) (*request, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &request{method: method, path: path, body: body, contentType: "application/json"}, nil
+}
+
+// do sends the request and decodes the JSON body of a successful response into
+// out, unless out is nil. Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, req *request, out any) error {
+	res, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	}
+
+	err = json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("decoding response to %s %s: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send sends the request, retrying it as long as it is allowed to, and returns
+// the first successful response, whose body the caller must close.
+func (c *Client) send(ctx context.Context, req *request) (*http.Response, error) {
+	var key string
+	if req.idempotent {
+		key = uuid.NewString()
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.attempt(ctx, req, key)
+
+		var wait time.Duration
+		retry := attempt < c.maxRetries && req.stream == nil
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			retry = retry && c.safeToRetry(req)
+		case res.StatusCode == http.StatusTooManyRequests:
+			wait = retryAfter(res)
+		case res.StatusCode >= http.StatusInternalServerError:
+			retry = retry && c.safeToRetry(req)
+		default:
+			retry = false
+		}
+
+		if !retry {
+			if err != nil {
+				return nil, err
+			}
+			if res.StatusCode >= http.StatusBadRequest {
+				defer res.Body.Close()
+				return nil, newError(res)
+			}
+			return res, nil
+		}
+
+		if res != nil {
+			_, _ = io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req *request, idempotencyKey string) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	body := req.stream
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.contentType != "" {
+		r.Header.Set("Content-Type", req.contentType)
+	}
+	if req.accept != "" {
+		r.Header.Set("Accept", req.accept)
+	} else {
+		r.Header.Set("Accept", "application/json")
+	}
+	if c.token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.apiKey != "" {
+		r.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.userAgent != "" {
+		r.Header.Set("User-Agent", c.userAgent)
+	}
+	if idempotencyKey != "" {
+		r.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	return c.httpClient.Do(r)
+}
+
+// safeToRetry reports whether the request can be sent again after a server
+// error or a failure to get a response, which may have been applied already.
+func (c *Client) safeToRetry(req *request) bool {
+	return req.method == http.MethodGet || req.idempotent
+}
+
+// backoff returns the time to wait before the retry following attempt, with
+// full jitter so that clients do not retry in lockstep.
+func (c *Client) backoff(attempt int) time.Duration {
+	backoff := c.minBackoff << attempt
+	if backoff > c.maxBackoff || backoff <= 0 {
+		backoff = c.maxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff))) + 1
+}
+
+// retryAfter returns the delay of the Retry-After header of the response, in
+// seconds, or 0 when it has none.
+func retryAfter(res *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// stubServer responds with the given responses in turn, repeating the last
+// one, and records the requests it receives.
+type stubServer struct {
+	mu        sync.Mutex
+	responses []stubResponse
+	requests  []*http.Request
+	bodies    []string
+}
+
+type stubResponse struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+func (s *stubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, string(body))
+
+	res := s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+
+	for name, value := range res.header {
+		w.Header().Set(name, value)
+	}
+	w.WriteHeader(res.status)
+	fmt.Fprint(w, res.body)
+}
+
+func newStubClient(t *testing.T, stub *stubServer, options ...Option) *Client {
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+
+	options = append([]Option{WithRetries(3, time.Millisecond, 5*time.Millisecond)}, options...)
+	c, err := New(srv.URL, options...)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	return c
+}
+
+const companyBody = `{"Company": {"id": "0e6c0248-a659-41d0-b860-795df3a53f44", "name": "XM", "type": "non_profit"}}`
+
+func Test_ClientRetries(t *testing.T) {
+	unavailable := stubResponse{status: http.StatusServiceUnavailable, body: `{"error": "unavailable"}`}
+	limited := stubResponse{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "0"}, body: `{"error": "rate limit exceeded"}`}
+	created := stubResponse{status: http.StatusCreated, body: companyBody}
+	id := uuid.MustParse("0e6c0248-a659-41d0-b860-795df3a53f44")
+
+	testCases := []struct {
+		name             string
+		responses        []stubResponse
+		call             func(context.Context, *Client) error
+		expectedErr      error
+		expectedRequests int
+	}{
+		{"get after server errors", []stubResponse{unavailable, unavailable, {status: http.StatusOK, body: companyBody}}, func(ctx context.Context, c *Client) error {
+			_, err := c.GetCompany(ctx, id)
+			return err
+		}, nil, 3},
+		{"get until retries run out", []stubResponse{unavailable}, func(ctx context.Context, c *Client) error {
+			_, err := c.GetCompany(ctx, id)
+			return err
+		}, ErrServer, 4},
+		{"idempotent create after a server error", []stubResponse{unavailable, created}, func(ctx context.Context, c *Client) error {
+			_, err := c.CreateCompany(ctx, CompanyInput{Name: "XM"})
+			return err
+		}, nil, 2},
+		{"idempotent webhook after a server error", []stubResponse{unavailable, {status: http.StatusCreated, body: `{"webhook": {}, "secret": "s"}`}}, func(ctx context.Context, c *Client) error {
+			_, _, err := c.CreateWebhook(ctx, WebhookInput{URL: "https://example.com"})
+			return err
+		}, nil, 2},
+		{"webhook after rate limiting", []stubResponse{limited, {status: http.StatusCreated, body: `{"webhook": {}, "secret": "s"}`}}, func(ctx context.Context, c *Client) error {
+			_, _, err := c.CreateWebhook(ctx, WebhookInput{URL: "https://example.com"})
+			return err
+		}, nil, 2},
+		{"import not retried", []stubResponse{limited}, func(ctx context.Context, c *Client) error {
+			_, err := c.ImportCompanies(ctx, CSV, strings.NewReader("name\nXM\n"))
+			return err
+		}, ErrRateLimited, 1},
+		{"client error not retried", []stubResponse{{status: http.StatusBadRequest, body: `{"error": "bad"}`}}, func(ctx context.Context, c *Client) error {
+			_, err := c.GetCompany(ctx, id)
+			return err
+		}, ErrBadRequest, 1},
+	}
+
+	for _, tt := range testCases {
+		stub := &stubServer{responses: tt.responses}
+		c := newStubClient(t, stub)
+
+		err := tt.call(context.Background(), c)
+		if !errors.Is(err, tt.expectedErr) {
+			t.Errorf("%s: expected error %v but got %v", tt.name, tt.expectedErr, err)
+		}
+		if len(stub.requests) != tt.expectedRequests {
+			t.Errorf("%s: expected %d requests but got %d", tt.name, tt.expectedRequests, len(stub.requests))
+		}
+	}
+}
+
+func Test_ClientIdempotencyKey(t *testing.T) {
+	stub := &stubServer{responses: []stubResponse{
+		{status: http.StatusBadGateway},
+		{status: http.StatusCreated, body: companyBody},
+		{status: http.StatusCreated, body: companyBody},
+	}}
+	c := newStubClient(t, stub)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.CreateCompany(context.Background(), CompanyInput{Name: "XM"})
+		if err != nil {
+			t.Fatalf("error creating company: %s", err)
+		}
+	}
+
+	first, retry, second := stub.requests[0].Header.Get("Idempotency-Key"), stub.requests[1].Header.Get("Idempotency-Key"), stub.requests[2].Header.Get("Idempotency-Key")
+	if first == "" || first != retry {
+		t.Errorf("expected retries to send the same key but got %q and %q", first, retry)
+	}
+	if second == first {
+		t.Errorf("expected another request to send another key but got %q", second)
+	}
+	if stub.bodies[0] != stub.bodies[1] {
+		t.Errorf("expected retries to send the same body but got %s and %s", stub.bodies[0], stub.bodies[1])
+	}
+}
+
+func Test_ClientContext(t *testing.T) {
+	stub := &stubServer{responses: []stubResponse{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "60"}}}}
+	c := newStubClient(t, stub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetCompany(ctx, uuid.New())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait for Retry-After to stop with the context but it took %s", elapsed)
+	}
+}
+
+func Test_ClientAuthentication(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		options               []Option
+		expectedAuthorization string
+		expectedAPIKey        string
+	}{
+		{"token", []Option{WithToken("jwt")}, "Bearer jwt", ""},
+		{"api key", []Option{WithAPIKey("key")}, "", "key"},
+		{"anonymous", nil, "", ""},
+	}
+
+	for _, tt := range testCases {
+		stub := &stubServer{responses: []stubResponse{{status: http.StatusOK, body: companyBody}}}
+		c := newStubClient(t, stub, tt.options...)
+
+		_, err := c.GetCompany(context.Background(), uuid.New())
+		if err != nil {
+			t.Fatalf("%s: error getting company: %s", tt.name, err)
+		}
+
+		header := stub.requests[0].Header
+		if header.Get("Authorization") != tt.expectedAuthorization || header.Get("X-API-Key") != tt.expectedAPIKey {
+			t.Errorf("%s: expected %q and %q but got %q and %q", tt.name, tt.expectedAuthorization, tt.expectedAPIKey, header.Get("Authorization"), header.Get("X-API-Key"))
+		}
+	}
+}
+
+func Test_ClientErrors(t *testing.T) {
+	testCases := []struct {
+		name            string
+		status          int
+		body            string
+		expectedErr     error
+		expectedMessage string
+	}{
+		{"not found", http.StatusMethodNotAllowed, `{"error": "the requested resource could not be found"}`, ErrNotFound, "companies api: 405: the requested resource could not be found"},
+		{"validation", http.StatusUnprocessableEntity, `{"error": {"type": "must be a valid company type", "name": "must be provided"}}`, ErrValidation, "companies api: 422: name must be provided; type must be a valid company type"},
+		{"plain text", http.StatusUnauthorized, "token is unauthorized\n", ErrUnauthorized, "companies api: 401: token is unauthorized"},
+		{"no body", http.StatusForbidden, "", ErrForbidden, "companies api: 403: Forbidden"},
+		{"conflict", http.StatusConflict, `{"error": "a company with this name already exists"}`, ErrConflict, "companies api: 409: a company with this name already exists"},
+	}
+
+	for _, tt := range testCases {
+		stub := &stubServer{responses: []stubResponse{{status: tt.status, body: tt.body}}}
+		c := newStubClient(t, stub)
+
+		_, err := c.GetCompany(context.Background(), uuid.New())
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+			t.Errorf("%s: expected an *Error with status %d but got %v", tt.name, tt.status, err)
+		}
+		if !errors.Is(err, tt.expectedErr) || err.Error() != tt.expectedMessage {
+			t.Errorf("%s: expected %q matching %v but got %q", tt.name, tt.expectedMessage, tt.expectedErr, err)
+		}
+	}
+}
+
+func Test_CompanyTypeUnmarshal(t *testing.T) {
+	testCases := []struct {
+		json         string
+		expectedType CompanyType
+		expectedErr  bool
+	}{
+		{`2`, Cooperative, false},
+		{`"non_profit"`, NonProfit, false},
+		{`"sole_proprietorship"`, SoleProprietorship, false},
+		{`"partnership"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range testCases {
+		var companyType CompanyType
+		err := companyType.UnmarshalJSON([]byte(tt.json))
+		if (err != nil) != tt.expectedErr || companyType != tt.expectedType {
+			t.Errorf("%s: expected %v (error %t) but got %v, %v", tt.json, tt.expectedType, tt.expectedErr, companyType, err)
+		}
+	}
+}
+
+func Test_ClientSubsidiaries(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.MustParse("0e6c0248-a659-41d0-b860-795df3a53f44")
+	stub := &stubServer{responses: []stubResponse{
+		{status: http.StatusOK, body: companyBody},
+		{status: http.StatusOK, body: `{"message": "Company successfully deleted"}`},
+		{status: http.StatusOK, body: `{"companies": [{"id": "121f03cd-ce8c-447d-8747-fb8cb7aa3a52", "name": "XM Kid", "type": "non_profit", "parent_id": "0e6c0248-a659-41d0-b860-795df3a53f44"}]}`},
+	}}
+	c := newStubClient(t, stub)
+
+	_, err := c.UpdateCompany(ctx, id, CompanyUpdate{Parent: &ParentUpdate{}})
+	if err != nil {
+		t.Fatalf("error updating company: %s", err)
+	}
+	if stub.bodies[0] != `{"parent_id":null}` {
+		t.Errorf("expected detaching the company to send a null parent but sent %s", stub.bodies[0])
+	}
+
+	err = c.DeleteCompanyWithSubsidiaries(ctx, id, CascadeSubsidiaries)
+	if err != nil {
+		t.Fatalf("error deleting company: %s", err)
+	}
+	if query := stub.requests[1].URL.RawQuery; query != "subsidiaries=cascade" {
+		t.Errorf("expected the policy to be sent as subsidiaries=cascade but got %q", query)
+	}
+
+	children, err := c.ListChildren(ctx, id)
+	if err != nil {
+		t.Fatalf("error listing children: %s", err)
+	}
+	if stub.requests[2].URL.Path != "/companies/"+id.String()+"/children" {
+		t.Errorf("expected the children to be requested but got %s", stub.requests[2].URL.Path)
+	}
+	if len(children) != 1 || children[0].ParentID == nil || *children[0].ParentID != id {
+		t.Errorf("expected a subsidiary of the company but got %+v", children)
+	}
+}
+
+func Test_ClientExportIncomplete(t *testing.T) {
+	ctx := context.Background()
+	stub := &stubServer{responses: []stubResponse{
+		{status: http.StatusOK, body: `{"name": "XM"}` + "\n"},
+		{status: http.StatusOK, body: `{"name": "XM"}` + "\n", header: map[string]string{http.TrailerPrefix + "X-Export-Error": "the export could not be completed"}},
+	}}
+	c := newStubClient(t, stub)
+
+	for i, expected := range []error{nil, ErrExportIncomplete} {
+		export, err := c.ExportCompanies(ctx, NDJSON, CompanyFilter{})
+		if err != nil {
+			t.Fatalf("error exporting companies: %s", err)
+		}
+
+		body, err := io.ReadAll(export)
+		_ = export.Close()
+		if string(body) != `{"name": "XM"}`+"\n" || !errors.Is(err, expected) {
+			t.Errorf("export %d: expected the companies with error %v but got %q, %v", i, expected, body, err)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CompanyType int
+
+const (
+	Corporations CompanyType = iota
+	NonProfit
+	Cooperative
+	SoleProprietorship
+	Unknown
+)
+
+var companyTypeSlugs = map[CompanyType]string{
+	Corporations:       "corporations",
+	NonProfit:          "non_profit",
+	Cooperative:        "cooperative",
+	SoleProprietorship: "sole_proprietorship",
+	Unknown:            "unknown",
+}
+
+// String returns the slug the API uses for the type.
+func (t CompanyType) String() string {
+	if slug, ok := companyTypeSlugs[t]; ok {
+		return slug
+	}
+	return strconv.Itoa(int(t))
+}
+
+func (t CompanyType) MarshalText() ([]byte, error) {
+	slug, ok := companyTypeSlugs[t]
+	if !ok {
+		return nil, fmt.Errorf("unknown company type %d", int(t))
+	}
+	return []byte(slug), nil
+}
+
+func (t *CompanyType) UnmarshalText(text []byte) error {
+	for companyType, slug := range companyTypeSlugs {
+		if slug == string(text) {
+			*t = companyType
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown company type %q", text)
+}
+
+// UnmarshalJSON accepts the types both as slugs and as the numbers older
+// versions of the API respond with.
+func (t *CompanyType) UnmarshalJSON(data []byte) error {
+	var n int
+	if json.Unmarshal(data, &n) == nil {
+		*t = CompanyType(n)
+		return nil
+	}
+
+	var slug string
+	err := json.Unmarshal(data, &slug)
+	if err != nil {
+		return fmt.Errorf("company type must be a slug or a number but got %s", data)
+	}
+	return t.UnmarshalText([]byte(slug))
+}
+
+type Company struct {
+	ID                uuid.UUID   `json:"id"`
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	NumberOfEmployees int         `json:"number_of_employees"`
+	Registered        bool        `json:"registered"`
+	Type              CompanyType `json:"type"`
+	ParentID          *uuid.UUID  `json:"parent_id"`
+	CreatedAt         time.Time   `json:"created_at"`
+	UpdatedAt         time.Time   `json:"updated_at"`
+	CreatedBy         string      `json:"created_by"`
+	UpdatedBy         string      `json:"updated_by"`
+	// Attributes are the custom attributes of the company, keyed by the
+	// name of their definition.
+	Attributes map[string]any `json:"attributes"`
+	// Tags are the tags of the company, sorted. They are changed with
+	// AddTags and RemoveTag.
+	Tags []string `json:"tags"`
+	// Addresses and Contacts are only returned when they are included.
+	Addresses []Address `json:"addresses,omitempty"`
+	Contacts  []Contact `json:"contacts,omitempty"`
+}
+
+// CompanyInput holds the fields of a new company.
+type CompanyInput struct {
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	NumberOfEmployees int         `json:"number_of_employees"`
+	Registered        bool        `json:"registered"`
+	Type              CompanyType `json:"type"`
+	ParentID          *uuid.UUID  `json:"parent_id,omitempty"`
+	// Attributes must each be defined and match the schema of their
+	// definition.
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// CompanyUpdate holds the fields to change of a company. Nil fields are left
+// unchanged.
+type CompanyUpdate struct {
+	Name              *string       `json:"name,omitempty"`
+	Description       *string       `json:"description,omitempty"`
+	NumberOfEmployees *int          `json:"number_of_employees,omitempty"`
+	Registered        *bool         `json:"registered,omitempty"`
+	Type              *CompanyType  `json:"type,omitempty"`
+	Parent            *ParentUpdate `json:"parent_id,omitempty"`
+	// Attributes are merged into those of the company: attributes set to nil
+	// are removed and the others are added or replaced.
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// ParentUpdate changes the parent of a company. A nil ID detaches the company
+// from its parent.
+type ParentUpdate struct {
+	ID *uuid.UUID
+}
+
+func (p ParentUpdate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.ID)
+}
+
+// SubsidiaryPolicy decides what happens to the subsidiaries of a deleted
+// company.
+type SubsidiaryPolicy string
+
+const (
+	// RestrictSubsidiaries refuses to delete companies that have
+	// subsidiaries, which is what DeleteCompany does.
+	RestrictSubsidiaries SubsidiaryPolicy = "restrict"
+	// DetachSubsidiaries moves the subsidiaries to the top of their own
+	// groups.
+	DetachSubsidiaries SubsidiaryPolicy = "detach"
+	// CascadeSubsidiaries deletes the subsidiaries along with the company.
+	CascadeSubsidiaries SubsidiaryPolicy = "cascade"
+)
+
+// CompanyFilter narrows down the companies listed or exported. Zero values do
+// not filter.
+type CompanyFilter struct {
+	// Name matches the companies whose name contains it.
+	Name       string
+	Type       *CompanyType
+	Registered *bool
+	// Attributes matches the companies whose attributes have all of these
+	// values, which are strings, numbers or booleans.
+	Attributes map[string]any
+	// Tags matches the companies that have all of these tags, and AnyTags
+	// those that have at least one of them.
+	Tags    []string
+	AnyTags []string
+}
+
+func (f CompanyFilter) query() url.Values {
+	qs := make(url.Values)
+	if f.Name != "" {
+		qs.Set("name", f.Name)
+	}
+	if f.Type != nil {
+		qs.Set("type", f.Type.String())
+	}
+	if f.Registered != nil {
+		qs.Set("registered", strconv.FormatBool(*f.Registered))
+	}
+	for name, value := range f.Attributes {
+		// Values are sent as JSON, so that strings are never taken for
+		// numbers or booleans.
+		data, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		qs.Set("attributes["+name+"]", string(data))
+	}
+	for _, tag := range f.Tags {
+		qs.Add("tag", tag)
+	}
+	for _, tag := range f.AnyTags {
+		qs.Add("tag_any", tag)
+	}
+	return qs
+}
+
+// ListOptions selects a page of companies.
+type ListOptions struct {
+	CompanyFilter
+	// Limit is the size of the page, between 1 and 1000. The API returns 100
+	// companies when it is 0.
+	Limit int
+	// After is the cursor returned as the NextCursor of the previous page.
+	After string
+	// Include lists the details to return along with the companies, out of
+	// IncludeAddresses and IncludeContacts.
+	Include []string
+}
+
+// CompanyPage is a page of companies, ordered by name.
+type CompanyPage struct {
+	Companies []Company `json:"companies"`
+	// NextCursor is the cursor of the next page, or empty on the last page.
+	NextCursor string `json:"next_cursor"`
+}
+
+type companyEnvelope struct {
+	Company Company `json:"Company"`
+}
+
+type companiesEnvelope struct {
+	Companies []Company `json:"companies"`
+}
+
+func companyPath(id uuid.UUID) string {
+	return "/companies/" + id.String()
+}
+
+func (c *Client) GetCompany(ctx context.Context, id uuid.UUID) (*Company, error) {
+	return c.GetCompanyIncluding(ctx, id)
+}
+
+// GetCompanyIncluding returns a company along with the details listed in
+// include, out of IncludeAddresses and IncludeContacts.
+func (c *Client) GetCompanyIncluding(ctx context.Context, id uuid.UUID, include ...string) (*Company, error) {
+	var qs url.Values
+	if len(include) > 0 {
+		qs = url.Values{"include": {strings.Join(include, ",")}}
+	}
+
+	var env companyEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: companyPath(id), query: qs}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
+
+// ListCompanies returns a page of the companies matching the options.
+func (c *Client) ListCompanies(ctx context.Context, opts ListOptions) (*CompanyPage, error) {
+	qs := opts.query()
+	if opts.Limit != 0 {
+		qs.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.After != "" {
+		qs.Set("after", opts.After)
+	}
+	if len(opts.Include) > 0 {
+		qs.Set("include", strings.Join(opts.Include, ","))
+	}
+
+	var page CompanyPage
+	err := c.do(ctx, &request{method: http.MethodGet, path: "/companies", query: qs}, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// IterateCompanies returns an iterator over every company matching the
+// options, fetching the pages of opts.Limit companies as they are needed.
+func (c *Client) IterateCompanies(opts ListOptions) *CompanyIterator {
+	return &CompanyIterator{client: c, opts: opts}
+}
+
+// CompanyIterator iterates over the companies of successive pages:
+//
+//	it := c.IterateCompanies(client.ListOptions{})
+//	for it.Next(ctx) {
+//		company := it.Company()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type CompanyIterator struct {
+	client  *Client
+	opts    ListOptions
+	page    []Company
+	current Company
+	done    bool
+	err     error
+}
+
+// Next advances to the next company, fetching the next page when the current
+// one is exhausted. It returns false at the end of the companies or when a
+// page could not be fetched, which Err then returns.
+func (it *CompanyIterator) Next(ctx context.Context) bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+
+		page, err := it.client.ListCompanies(ctx, it.opts)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = page.Companies
+		it.opts.After = page.NextCursor
+		it.done = page.NextCursor == ""
+	}
+
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Company returns the company Next advanced to.
+func (it *CompanyIterator) Company() Company {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *CompanyIterator) Err() error {
+	return it.err
+}
+
+func (c *Client) CreateCompany(ctx context.Context, company CompanyInput) (*Company, error) {
+	req, err := jsonRequest(http.MethodPost, "/companies", company)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env companyEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
+
+func (c *Client) UpdateCompany(ctx context.Context, id uuid.UUID, update CompanyUpdate) (*Company, error) {
+	req, err := jsonRequest(http.MethodPatch, companyPath(id), update)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env companyEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
+
+// MergePatchCompany updates a company with a JSON merge patch (RFC 7386),
+// such as a map or a struct, whose null members clear the fields. Unlike
+// UpdateCompany, it can clear the description.
+func (c *Client) MergePatchCompany(ctx context.Context, id uuid.UUID, patch any) (*Company, error) {
+	return c.patchCompany(ctx, id, "application/merge-patch+json", patch)
+}
+
+// PatchOperation is an operation of a JSON Patch (RFC 6902). Value is sent by
+// every operation, and only read by add, replace and test.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value"`
+}
+
+// JSONPatchCompany updates a company with a JSON Patch (RFC 6902), which is
+// only applied when every operation succeeds. A failed test returns an error
+// that matches ErrConflict.
+func (c *Client) JSONPatchCompany(ctx context.Context, id uuid.UUID, ops ...PatchOperation) (*Company, error) {
+	if ops == nil {
+		ops = []PatchOperation{}
+	}
+	return c.patchCompany(ctx, id, "application/json-patch+json", ops)
+}
+
+func (c *Client) patchCompany(ctx context.Context, id uuid.UUID, contentType string, patch any) (*Company, error) {
+	req, err := jsonRequest(http.MethodPatch, companyPath(id), patch)
+	if err != nil {
+		return nil, err
+	}
+	req.contentType = contentType
+	req.idempotent = true
+
+	var env companyEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
+
+// DeleteCompany soft deletes a company, which can be restored until it is
+// purged. Companies that have subsidiaries are not deleted.
+func (c *Client) DeleteCompany(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: companyPath(id), idempotent: true}, nil)
+}
+
+// DeleteCompanyWithSubsidiaries soft deletes a company, handling its
+// subsidiaries according to the policy.
+func (c *Client) DeleteCompanyWithSubsidiaries(ctx context.Context, id uuid.UUID, subsidiaries SubsidiaryPolicy) error {
+	qs := url.Values{"subsidiaries": {string(subsidiaries)}}
+	return c.do(ctx, &request{method: http.MethodDelete, path: companyPath(id), query: qs, idempotent: true}, nil)
+}
+
+// PurgeCompany deletes a company for good. It requires an admin token.
+func (c *Client) PurgeCompany(ctx context.Context, id uuid.UUID) error {
+	qs := url.Values{"purge": {"true"}}
+	return c.do(ctx, &request{method: http.MethodDelete, path: companyPath(id), query: qs, idempotent: true}, nil)
+}
+
+// RestoreCompany restores a deleted company.
+func (c *Client) RestoreCompany(ctx context.Context, id uuid.UUID) (*Company, error) {
+	var env companyEnvelope
+	err := c.do(ctx, &request{method: http.MethodPost, path: companyPath(id) + "/restore", idempotent: true}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
+
+// ListChildren returns the subsidiaries of a company, ordered by name.
+func (c *Client) ListChildren(ctx context.Context, id uuid.UUID) ([]Company, error) {
+	return c.hierarchy(ctx, companyPath(id)+"/children")
+}
+
+// ListAncestors returns the parent of a company, its parent and so on up to
+// the top of the group.
+func (c *Client) ListAncestors(ctx context.Context, id uuid.UUID) ([]Company, error) {
+	return c.hierarchy(ctx, companyPath(id)+"/ancestors")
+}
+
+// GetSubtree returns a company followed by every subsidiary below it, level by
+// level.
+func (c *Client) GetSubtree(ctx context.Context, id uuid.UUID) ([]Company, error) {
+	return c.hierarchy(ctx, companyPath(id)+"/subtree")
+}
+
+func (c *Client) hierarchy(ctx context.Context, path string) ([]Company, error) {
+	var env companiesEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: path}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Companies, nil
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The details of companies that can be included along with them.
+const (
+	IncludeAddresses = "addresses"
+	IncludeContacts  = "contacts"
+)
+
+// Address is an address of a company.
+type Address struct {
+	ID         uuid.UUID `json:"id"`
+	CompanyID  uuid.UUID `json:"company_id"`
+	Label      string    `json:"label"`
+	Line1      string    `json:"line1"`
+	Line2      string    `json:"line2"`
+	City       string    `json:"city"`
+	Region     string    `json:"region"`
+	PostalCode string    `json:"postal_code"`
+	// CountryCode is an ISO 3166-1 alpha-2 country code, in upper case.
+	CountryCode string    `json:"country_code"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// AddressInput holds the fields of a new address.
+type AddressInput struct {
+	Label       string `json:"label,omitempty"`
+	Line1       string `json:"line1"`
+	Line2       string `json:"line2,omitempty"`
+	City        string `json:"city"`
+	Region      string `json:"region,omitempty"`
+	PostalCode  string `json:"postal_code,omitempty"`
+	CountryCode string `json:"country_code"`
+}
+
+// AddressUpdate holds the fields to change of an address. Nil fields are left
+// unchanged.
+type AddressUpdate struct {
+	Label       *string `json:"label,omitempty"`
+	Line1       *string `json:"line1,omitempty"`
+	Line2       *string `json:"line2,omitempty"`
+	City        *string `json:"city,omitempty"`
+	Region      *string `json:"region,omitempty"`
+	PostalCode  *string `json:"postal_code,omitempty"`
+	CountryCode *string `json:"country_code,omitempty"`
+}
+
+// Contact is a person to get in touch with at a company.
+type Contact struct {
+	ID        uuid.UUID `json:"id"`
+	CompanyID uuid.UUID `json:"company_id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ContactInput holds the fields of a new contact.
+type ContactInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Phone string `json:"phone,omitempty"`
+	Role  string `json:"role,omitempty"`
+}
+
+// ContactUpdate holds the fields to change of a contact. Nil fields are left
+// unchanged.
+type ContactUpdate struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Phone *string `json:"phone,omitempty"`
+	Role  *string `json:"role,omitempty"`
+}
+
+type addressEnvelope struct {
+	Address Address `json:"address"`
+}
+
+type contactEnvelope struct {
+	Contact Contact `json:"contact"`
+}
+
+func addressPath(companyID, id uuid.UUID) string {
+	return companyPath(companyID) + "/addresses/" + id.String()
+}
+
+func contactPath(companyID, id uuid.UUID) string {
+	return companyPath(companyID) + "/contacts/" + id.String()
+}
+
+// ListAddresses returns the addresses of a company, oldest first.
+func (c *Client) ListAddresses(ctx context.Context, companyID uuid.UUID) ([]Address, error) {
+	var env struct {
+		Addresses []Address `json:"addresses"`
+	}
+	err := c.do(ctx, &request{method: http.MethodGet, path: companyPath(companyID) + "/addresses"}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Addresses, nil
+}
+
+func (c *Client) GetAddress(ctx context.Context, companyID, id uuid.UUID) (*Address, error) {
+	var env addressEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: addressPath(companyID, id)}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Address, nil
+}
+
+func (c *Client) CreateAddress(ctx context.Context, companyID uuid.UUID, address AddressInput) (*Address, error) {
+	req, err := jsonRequest(http.MethodPost, companyPath(companyID)+"/addresses", address)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env addressEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Address, nil
+}
+
+func (c *Client) UpdateAddress(ctx context.Context, companyID, id uuid.UUID, update AddressUpdate) (*Address, error) {
+	req, err := jsonRequest(http.MethodPatch, addressPath(companyID, id), update)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env addressEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Address, nil
+}
+
+func (c *Client) DeleteAddress(ctx context.Context, companyID, id uuid.UUID) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: addressPath(companyID, id), idempotent: true}, nil)
+}
+
+// ListContacts returns the contacts of a company, oldest first.
+func (c *Client) ListContacts(ctx context.Context, companyID uuid.UUID) ([]Contact, error) {
+	var env struct {
+		Contacts []Contact `json:"contacts"`
+	}
+	err := c.do(ctx, &request{method: http.MethodGet, path: companyPath(companyID) + "/contacts"}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Contacts, nil
+}
+
+func (c *Client) GetContact(ctx context.Context, companyID, id uuid.UUID) (*Contact, error) {
+	var env contactEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: contactPath(companyID, id)}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Contact, nil
+}
+
+func (c *Client) CreateContact(ctx context.Context, companyID uuid.UUID, contact ContactInput) (*Contact, error) {
+	req, err := jsonRequest(http.MethodPost, companyPath(companyID)+"/contacts", contact)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env contactEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Contact, nil
+}
+
+func (c *Client) UpdateContact(ctx context.Context, companyID, id uuid.UUID, update ContactUpdate) (*Contact, error) {
+	req, err := jsonRequest(http.MethodPatch, contactPath(companyID, id), update)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env contactEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Contact, nil
+}
+
+func (c *Client) DeleteContact(ctx context.Context, companyID, id uuid.UUID) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: contactPath(companyID, id), idempotent: true}, nil)
+}
@@ -0,0 +1,147 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The errors an *Error matches with errors.Is, depending on its status.
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrValidation           = errors.New("validation failed")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrServer               = errors.New("server error")
+)
+
+// Error is an error response of the API.
+type Error struct {
+	StatusCode int
+	// Message is the error message, unless the error is a failed validation.
+	Message string
+	// Fields maps the name of each invalid field to the reason it was
+	// rejected when the error is a failed validation.
+	Fields map[string]string
+	// RetryAfter is how long the API asked to wait before sending the request
+	// again, if it did.
+	RetryAfter time.Duration
+	// Results holds the result of every item of a batch that failed.
+	Results []BatchResult
+	// Import holds the summary of an import whose every row was rejected.
+	Import *ImportSummary
+}
+
+func (e *Error) Error() string {
+	message := e.Message
+	if len(e.Fields) > 0 {
+		message = formatFields(e.Fields)
+	}
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	return fmt.Sprintf("companies api: %d: %s", e.StatusCode, message)
+}
+
+// formatFields formats the reasons the fields were rejected, sorted by field.
+func formatFields(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s %s", name, fields[name])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is matches the error with the sentinel error of its status.
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest:
+		return target == ErrBadRequest
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	// The API reports missing resources with 405.
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusUnsupportedMediaType:
+		return target == ErrUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return target == ErrValidation
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	}
+	return e.StatusCode >= http.StatusInternalServerError && target == ErrServer
+}
+
+// newError reads an error response, which the API sends as an {"error": ...}
+// envelope, or as plain text when the token is rejected, except for failed
+// batches and imports, whose response holds the outcome of their items.
+func newError(res *http.Response) *Error {
+	e := &Error{StatusCode: res.StatusCode, RetryAfter: retryAfter(res)}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		e.Message = err.Error()
+		return e
+	}
+
+	var env struct {
+		Error    json.RawMessage  `json:"error"`
+		Results  []BatchResult    `json:"results"`
+		Imported *int             `json:"imported"`
+		Failed   int              `json:"failed"`
+		Errors   []ImportRowError `json:"errors"`
+	}
+	if json.Unmarshal(data, &env) != nil {
+		e.Message = strings.TrimSpace(string(data))
+		return e
+	}
+
+	e.Message, e.Fields = decodeErrorMessage(env.Error)
+	switch {
+	case env.Results != nil:
+		e.Results = env.Results
+		e.Message = "the batch failed"
+	case env.Imported != nil:
+		e.Import = &ImportSummary{Imported: *env.Imported, Failed: env.Failed, Errors: env.Errors}
+		e.Message = "every row of the import was rejected"
+	}
+	return e
+}
+
+// decodeErrorMessage decodes the message of an error, which is either a string
+// or a map of the invalid fields.
+func decodeErrorMessage(raw json.RawMessage) (string, map[string]string) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	var fields map[string]string
+	if json.Unmarshal(raw, &fields) == nil {
+		return "", fields
+	}
+
+	var message string
+	if json.Unmarshal(raw, &message) == nil {
+		return message, nil
+	}
+	return string(raw), nil
+}
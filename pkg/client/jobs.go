@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Job is an import or an export processed in the background.
+type Job struct {
+	ID     uuid.UUID         `json:"id"`
+	Type   string            `json:"type"`
+	Status JobStatus         `json:"status"`
+	Params map[string]string `json:"params"`
+	// Progress is the number of companies imported or exported so far.
+	Progress   int64      `json:"progress"`
+	ResultType string     `json:"result_type,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Attempts   int        `json:"attempts"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Done reports whether the job has reached a final status.
+func (j *Job) Done() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+type jobEnvelope struct {
+	Job Job `json:"job"`
+}
+
+func jobPath(id uuid.UUID) string {
+	return "/jobs/" + id.String()
+}
+
+// CreateImportJob queues the import of the companies read from r. The body is
+// streamed, so the request is never retried.
+func (c *Client) CreateImportJob(ctx context.Context, format Format, r io.Reader) (*Job, error) {
+	req := &request{
+		method:      http.MethodPost,
+		path:        "/jobs/imports",
+		stream:      r,
+		contentType: format.contentType(),
+	}
+
+	var env jobEnvelope
+	err := c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Job, nil
+}
+
+// CreateExportJob queues the export of the companies matching the filter.
+func (c *Client) CreateExportJob(ctx context.Context, format Format, filter CompanyFilter) (*Job, error) {
+	qs := filter.query()
+	qs.Set("format", string(format))
+
+	var env jobEnvelope
+	err := c.do(ctx, &request{method: http.MethodPost, path: "/jobs/exports", query: qs, idempotent: true}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Job, nil
+}
+
+func (c *Client) GetJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	var env jobEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: jobPath(id)}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Job, nil
+}
+
+// WaitJob polls the job every interval until it is done and returns it.
+func (c *Client) WaitJob(ctx context.Context, id uuid.UUID, interval time.Duration) (*Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := c.GetJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.Done() {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// JobResult returns the result of a job that succeeded: the export, or the
+// ImportSummary of an import as JSON. The caller must close it. Jobs that are
+// not done yet return an *Error matching ErrConflict.
+func (c *Client) JobResult(ctx context.Context, id uuid.UUID) (io.ReadCloser, error) {
+	res, err := c.send(ctx, &request{method: http.MethodGet, path: jobPath(id) + "/result", accept: "*/*"})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// CancelJob cancels a job that is not done yet. Jobs that are done return an
+// *Error matching ErrConflict.
+func (c *Client) CancelJob(ctx context.Context, id uuid.UUID) (*Job, error) {
+	var env jobEnvelope
+	err := c.do(ctx, &request{method: http.MethodPost, path: jobPath(id) + "/cancel", idempotent: true}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Job, nil
+}
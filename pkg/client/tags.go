@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Tag is a tag of companies, along with the number of companies that have
+// it.
+type Tag struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// ListTags returns the tags of the companies that are not deleted, ordered by
+// name.
+func (c *Client) ListTags(ctx context.Context) ([]Tag, error) {
+	var env struct {
+		Tags []Tag `json:"tags"`
+	}
+	err := c.do(ctx, &request{method: http.MethodGet, path: "/tags"}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Tags, nil
+}
+
+// AddTags tags a company, which keeps the tags it already has. Tags are
+// stored in lower case.
+func (c *Client) AddTags(ctx context.Context, id uuid.UUID, tags ...string) (*Company, error) {
+	req, err := jsonRequest(http.MethodPost, companyPath(id)+"/tags", struct {
+		Tags []string `json:"tags"`
+	}{tags})
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env companyEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
+
+// RemoveTag removes a tag from a company. It succeeds when the company does
+// not have the tag.
+func (c *Client) RemoveTag(ctx context.Context, id uuid.UUID, tag string) (*Company, error) {
+	var env companyEnvelope
+	err := c.do(ctx, &request{method: http.MethodDelete, path: companyPath(id) + "/tags/" + url.PathEscape(tag), idempotent: true}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Company, nil
+}
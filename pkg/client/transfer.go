@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Format is the format of imports and exports.
+type Format string
+
+const (
+	// CSV has a header row naming the columns, in any order.
+	CSV Format = "csv"
+	// NDJSON has a company per line, as a JSON object.
+	NDJSON Format = "ndjson"
+)
+
+func (f Format) contentType() string {
+	if f == NDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv"
+}
+
+// ImportSummary is the outcome of an import.
+type ImportSummary struct {
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ImportRowError is the reason a row of an import was rejected.
+type ImportRowError struct {
+	Row     int
+	Message string
+	Fields  map[string]string
+}
+
+func (e ImportRowError) Error() string {
+	if len(e.Fields) > 0 {
+		return fmt.Sprintf("row %d: %s", e.Row, formatFields(e.Fields))
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+func (e *ImportRowError) UnmarshalJSON(data []byte) error {
+	var row struct {
+		Row   int             `json:"row"`
+		Error json.RawMessage `json:"error"`
+	}
+	err := json.Unmarshal(data, &row)
+	if err != nil {
+		return err
+	}
+
+	e.Row = row.Row
+	e.Message, e.Fields = decodeErrorMessage(row.Error)
+	return nil
+}
+
+// ImportCompanies creates the companies read from r in the given format. Rows
+// that are rejected are reported by the summary, unless every row is, in which
+// case an *Error matching ErrValidation is returned along with the summary.
+// The body is streamed, so the request is never retried.
+func (c *Client) ImportCompanies(ctx context.Context, format Format, r io.Reader) (*ImportSummary, error) {
+	req := &request{
+		method:      http.MethodPost,
+		path:        "/companies/import",
+		stream:      r,
+		contentType: format.contentType(),
+	}
+
+	var summary ImportSummary
+	err := c.do(ctx, req, &summary)
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Import != nil {
+		return apiErr.Import, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ErrExportIncomplete is returned by the last read of an export that the API
+// cut short after sending its first companies.
+var ErrExportIncomplete = errors.New("export incomplete")
+
+// ExportCompanies returns the companies matching the filter in the given
+// format. The caller must close the export, which is streamed as it is read.
+// An export the API cut short fails its last read with ErrExportIncomplete
+// instead of io.EOF.
+func (c *Client) ExportCompanies(ctx context.Context, format Format, filter CompanyFilter) (io.ReadCloser, error) {
+	qs := filter.query()
+	qs.Set("format", string(format))
+
+	res, err := c.send(ctx, &request{method: http.MethodGet, path: "/companies/export", query: qs, accept: format.contentType()})
+	if err != nil {
+		return nil, err
+	}
+	return &exportBody{res}, nil
+}
+
+// exportBody reads an export, checking the X-Export-Error trailer, which is
+// only known once the body has been read.
+type exportBody struct {
+	res *http.Response
+}
+
+func (b *exportBody) Read(p []byte) (int, error) {
+	n, err := b.res.Body.Read(p)
+	if errors.Is(err, io.EOF) {
+		if reason := b.res.Trailer.Get("X-Export-Error"); reason != "" {
+			return n, fmt.Errorf("%w: %s", ErrExportIncomplete, reason)
+		}
+	}
+	return n, err
+}
+
+func (b *exportBody) Close() error {
+	return b.res.Body.Close()
+}
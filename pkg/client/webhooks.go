@@ -0,0 +1,163 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The events webhooks can subscribe to.
+const (
+	WebhookCompanyCreated = "company.created"
+	WebhookCompanyUpdated = "company.updated"
+	WebhookCompanyDeleted = "company.deleted"
+)
+
+// Webhook is a subscription of a URL to company events.
+type Webhook struct {
+	ID  uuid.UUID `json:"id"`
+	URL string    `json:"url"`
+	// Events are the events the webhook is subscribed to, or all of them when
+	// it is empty.
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+	// ConsecutiveFailures counts the failed delivery attempts since the last
+	// successful one.
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedBy           string     `json:"created_by"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// WebhookInput holds the fields of a new webhook.
+type WebhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+// WebhookUpdate holds the fields to change of a webhook. Nil fields are left
+// unchanged.
+type WebhookUpdate struct {
+	URL    *string   `json:"url,omitempty"`
+	Events *[]string `json:"events,omitempty"`
+	// Active set back to true resumes the deliveries of a disabled webhook.
+	Active *bool `json:"active,omitempty"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the delivery of an event to a webhook, along with the
+// outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id"`
+	WebhookID      uuid.UUID             `json:"webhook_id"`
+	EventID        uuid.UUID             `json:"event_id"`
+	Event          string                `json:"event"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus int                   `json:"response_status,omitempty"`
+	Error          string                `json:"error,omitempty"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+type webhookEnvelope struct {
+	Webhook Webhook `json:"webhook"`
+}
+
+func webhookPath(id uuid.UUID) string {
+	return "/webhooks/" + id.String()
+}
+
+// CreateWebhook subscribes a URL to company events and returns the webhook
+// along with the secret that signs its deliveries, which is never returned
+// again.
+func (c *Client) CreateWebhook(ctx context.Context, input WebhookInput) (*Webhook, string, error) {
+	req, err := jsonRequest(http.MethodPost, "/webhooks", input)
+	if err != nil {
+		return nil, "", err
+	}
+	req.idempotent = true
+
+	var env struct {
+		Webhook Webhook `json:"webhook"`
+		Secret  string  `json:"secret"`
+	}
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, "", err
+	}
+	return &env.Webhook, env.Secret, nil
+}
+
+// ListWebhooks lists the webhooks created with the token, or every webhook for
+// admins.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var env struct {
+		Webhooks []Webhook `json:"webhooks"`
+	}
+	err := c.do(ctx, &request{method: http.MethodGet, path: "/webhooks"}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Webhooks, nil
+}
+
+func (c *Client) GetWebhook(ctx context.Context, id uuid.UUID) (*Webhook, error) {
+	var env webhookEnvelope
+	err := c.do(ctx, &request{method: http.MethodGet, path: webhookPath(id)}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Webhook, nil
+}
+
+func (c *Client) UpdateWebhook(ctx context.Context, id uuid.UUID, update WebhookUpdate) (*Webhook, error) {
+	req, err := jsonRequest(http.MethodPatch, webhookPath(id), update)
+	if err != nil {
+		return nil, err
+	}
+	req.idempotent = true
+
+	var env webhookEnvelope
+	err = c.do(ctx, req, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env.Webhook, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, &request{method: http.MethodDelete, path: webhookPath(id), idempotent: true}, nil)
+}
+
+// WebhookDeliveries returns the latest deliveries to a webhook, newest first,
+// up to limit, or 50 when it is 0.
+func (c *Client) WebhookDeliveries(ctx context.Context, id uuid.UUID, limit int) ([]WebhookDelivery, error) {
+	var qs url.Values
+	if limit != 0 {
+		qs = url.Values{"limit": {strconv.Itoa(limit)}}
+	}
+
+	var env struct {
+		Deliveries []WebhookDelivery `json:"deliveries"`
+	}
+	err := c.do(ctx, &request{method: http.MethodGet, path: webhookPath(id) + "/deliveries", query: qs}, &env)
+	if err != nil {
+		return nil, err
+	}
+	return env.Deliveries, nil
+}
@@ -1,33 +1,133 @@
 package main
 
 import (
+	"expvar"
 	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/internal/adapters/handlers"
 )
 
 func (app *Application) routes() http.Handler {
+	idempotent := handlers.Idempotency(app.IdempotencyService)
+
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	if app.OpenAPIValidation != nil {
+		r.Use(app.OpenAPIValidation)
+	}
+	r.Use(handlers.RateLimit(app.RateLimitService, app.AuthenticationToken))
+	if app.ReadYourWrites != nil {
+		r.Use(app.ReadYourWrites)
+	}
 
 	r.Route("/companies", func(r chi.Router) {
+		r.Get("/", app.CompanyHandler.ListCompanies)
 		r.Get("/{id}", app.CompanyHandler.GetCompany)
+		r.Get("/{id}/children", app.CompanyHandler.GetChildren)
+		r.Get("/{id}/ancestors", app.CompanyHandler.GetAncestors)
+		r.Get("/{id}/subtree", app.CompanyHandler.GetSubtree)
+		r.Get("/{id}/addresses", app.CompanyHandler.ListAddresses)
+		r.Get("/{id}/addresses/{address_id}", app.CompanyHandler.GetAddress)
+		r.Get("/{id}/contacts", app.CompanyHandler.ListContacts)
+		r.Get("/{id}/contacts/{contact_id}", app.CompanyHandler.GetContact)
+
+		r.Group(func(r chi.Router) {
+			r.Use(jwtauth.Verifier(app.AuthenticationToken))
+			r.Use(jwtauth.Authenticator)
+			r.With(idempotent).Post("/", app.CompanyHandler.CreateCompany)
+			r.With(idempotent).Post("/import", app.CompanyHandler.ImportCompanies)
+			r.Get("/export", app.CompanyHandler.ExportCompanies)
+			r.Get("/stream", app.StreamHandler.Stream)
+			r.Get("/stream/ws", app.StreamHandler.WebSocket)
+			r.With(idempotent).Patch("/{id}", app.CompanyHandler.UpdateCompany)
+			r.With(idempotent).Delete("/{id}", app.CompanyHandler.DeleteCompany)
+			r.With(idempotent).Post("/{id}/restore", app.CompanyHandler.RestoreCompany)
+			r.With(idempotent).Post("/{id}/addresses", app.CompanyHandler.CreateAddress)
+			r.With(idempotent).Patch("/{id}/addresses/{address_id}", app.CompanyHandler.UpdateAddress)
+			r.With(idempotent).Delete("/{id}/addresses/{address_id}", app.CompanyHandler.DeleteAddress)
+			r.With(idempotent).Post("/{id}/contacts", app.CompanyHandler.CreateContact)
+			r.With(idempotent).Patch("/{id}/contacts/{contact_id}", app.CompanyHandler.UpdateContact)
+			r.With(idempotent).Delete("/{id}/contacts/{contact_id}", app.CompanyHandler.DeleteContact)
+			r.With(idempotent).Post("/{id}/tags", app.CompanyHandler.AddTags)
+			r.With(idempotent).Delete("/{id}/tags/{tag}", app.CompanyHandler.RemoveTag)
+		})
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(jwtauth.Verifier(app.AuthenticationToken))
+		r.Use(jwtauth.Authenticator)
+		r.Use(idempotent)
+		r.Post("/companies:batch", app.CompanyHandler.CreateCompanies)
+		r.Patch("/companies:batch", app.CompanyHandler.UpdateCompanies)
+		r.Delete("/companies:batch", app.CompanyHandler.DeleteCompanies)
+	})
+
+	// Queries are public, while the handler requires a valid JWT for
+	// mutations.
+	r.Group(func(r chi.Router) {
+		r.Use(jwtauth.Verifier(app.AuthenticationToken))
+		r.Method(http.MethodGet, "/graphql", app.GraphQLHandler)
+		r.Method(http.MethodPost, "/graphql", app.GraphQLHandler)
+	})
+
+	r.Route("/jobs", func(r chi.Router) {
+		r.Use(jwtauth.Verifier(app.AuthenticationToken))
+		r.Use(jwtauth.Authenticator)
+		r.With(idempotent).Post("/imports", app.JobHandler.CreateImportJob)
+		r.With(idempotent).Post("/exports", app.JobHandler.CreateExportJob)
+		r.Get("/{id}", app.JobHandler.GetJob)
+		r.Get("/{id}/result", app.JobHandler.GetJobResult)
+		r.With(idempotent).Post("/{id}/cancel", app.JobHandler.CancelJob)
+	})
+
+	r.Route("/webhooks", func(r chi.Router) {
+		r.Use(jwtauth.Verifier(app.AuthenticationToken))
+		r.Use(jwtauth.Authenticator)
+		r.With(idempotent).Post("/", app.WebhookHandler.CreateWebhook)
+		r.Get("/", app.WebhookHandler.ListWebhooks)
+		r.Get("/{id}", app.WebhookHandler.GetWebhook)
+		r.With(idempotent).Patch("/{id}", app.WebhookHandler.UpdateWebhook)
+		r.With(idempotent).Delete("/{id}", app.WebhookHandler.DeleteWebhook)
+		r.Get("/{id}/deliveries", app.WebhookHandler.ListWebhookDeliveries)
+	})
+
+	r.Get("/tags", app.CompanyHandler.ListTags)
+
+	// Attribute definitions apply to every company, so only admins change
+	// them.
+	r.Route("/attributes", func(r chi.Router) {
+		r.Get("/", app.AttributeHandler.ListAttributes)
+		r.Get("/{name}", app.AttributeHandler.GetAttribute)
 
 		r.Group(func(r chi.Router) {
 			r.Use(jwtauth.Verifier(app.AuthenticationToken))
 			r.Use(jwtauth.Authenticator)
-			r.Post("/", app.CompanyHandler.CreateCompany)
-			r.Patch("/{id}", app.CompanyHandler.UpdateCompany)
-			r.Delete("/{id}", app.CompanyHandler.DeleteCompany)
+			r.Use(handlers.RequireAdmin)
+			r.Use(idempotent)
+			r.Post("/", app.AttributeHandler.CreateAttribute)
+			r.Patch("/{name}", app.AttributeHandler.UpdateAttribute)
+			r.Delete("/{name}", app.AttributeHandler.DeleteAttribute)
 		})
 	})
 
+	r.Group(func(r chi.Router) {
+		r.Use(jwtauth.Verifier(app.AuthenticationToken))
+		r.Use(jwtauth.Authenticator)
+		r.Use(handlers.RequireAdmin)
+		r.Method(http.MethodGet, "/debug/vars", expvar.Handler())
+	})
+
+	r.Get("/openapi.json", app.DocsHandler.OpenAPI)
+	r.Get("/docs", app.DocsHandler.SwaggerUI)
+	r.Get("/docs/*", app.DocsHandler.Assets)
+
 	err := chi.Walk(r, func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
 		fmt.Printf("[%s]: '%s' has %d middlewares\n", method, route, len(middlewares))
 		return nil
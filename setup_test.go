@@ -7,6 +7,10 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/petrostrak/xm-companies/api/openapi"
+	"github.com/petrostrak/xm-companies/internal/adapters/graph"
+	"github.com/petrostrak/xm-companies/internal/adapters/handlers"
 )
 
 func TestMain(m *testing.M) {
@@ -18,13 +22,54 @@ func TestRoutes(t *testing.T) {
 		route  string
 		method string
 	}{
+		{"/companies/", "GET"},
 		{"/companies/", "POST"},
 		{"/companies/{id}", "GET"},
 		{"/companies/{id}", "PATCH"},
 		{"/companies/{id}", "DELETE"},
+		{"/companies/{id}/restore", "POST"},
+		{"/companies/import", "POST"},
+		{"/companies/export", "GET"},
+		{"/companies/stream", "GET"},
+		{"/companies/stream/ws", "GET"},
+		{"/companies:batch", "POST"},
+		{"/companies:batch", "PATCH"},
+		{"/companies:batch", "DELETE"},
+		{"/jobs/imports", "POST"},
+		{"/jobs/exports", "POST"},
+		{"/jobs/{id}", "GET"},
+		{"/jobs/{id}/result", "GET"},
+		{"/jobs/{id}/cancel", "POST"},
+		{"/debug/vars", "GET"},
+		{"/graphql", "GET"},
+		{"/graphql", "POST"},
+		{"/webhooks/", "POST"},
+		{"/webhooks/", "GET"},
+		{"/webhooks/{id}", "GET"},
+		{"/webhooks/{id}", "PATCH"},
+		{"/webhooks/{id}", "DELETE"},
+		{"/webhooks/{id}/deliveries", "GET"},
+		{"/attributes/", "GET"},
+		{"/attributes/{name}", "GET"},
+		{"/attributes/", "POST"},
+		{"/attributes/{name}", "PATCH"},
+		{"/attributes/{name}", "DELETE"},
+		{"/openapi.json", "GET"},
+		{"/docs", "GET"},
+		{"/docs/*", "GET"},
 	}
 
-	mux := Routes()
+	app := Application{
+		CompanyHandler:      &handlers.CompanyHandler{},
+		JobHandler:          &handlers.JobHandler{},
+		StreamHandler:       &handlers.StreamHandler{},
+		GraphQLHandler:      &graph.Handler{},
+		WebhookHandler:      &handlers.WebhookHandler{},
+		AttributeHandler:    &handlers.AttributeHandler{},
+		DocsHandler:         &handlers.DocsHandler{},
+		AuthenticationToken: jwtauth.New("HS256", []byte("xm-companies"), nil),
+	}
+	mux := app.routes()
 	chiRoutes := mux.(chi.Routes)
 
 	for _, route := range registered {
@@ -34,6 +79,53 @@ func TestRoutes(t *testing.T) {
 	}
 }
 
+// TestOpenAPI checks that the specification documents every route and only
+// the routes there are.
+func TestOpenAPI(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("invalid specification: %s", err)
+	}
+
+	app := Application{
+		CompanyHandler:      &handlers.CompanyHandler{},
+		JobHandler:          &handlers.JobHandler{},
+		StreamHandler:       &handlers.StreamHandler{},
+		GraphQLHandler:      &graph.Handler{},
+		WebhookHandler:      &handlers.WebhookHandler{},
+		AttributeHandler:    &handlers.AttributeHandler{},
+		DocsHandler:         &handlers.DocsHandler{},
+		AuthenticationToken: jwtauth.New("HS256", []byte("xm-companies"), nil),
+	}
+
+	registered := make(map[string]bool)
+	_ = chi.Walk(app.routes().(chi.Routes), func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		// The assets of Swagger UI are not part of the API.
+		if route == "/docs/*" {
+			return nil
+		}
+		// Sub-routers register their root with a trailing slash, but serve
+		// it without one too.
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+
+		registered[method+" "+route] = true
+		if doc.Paths.Find(route) == nil || doc.Paths.Find(route).GetOperation(method) == nil {
+			t.Errorf("route %s %s is not documented", method, route)
+		}
+		return nil
+	})
+
+	for path, item := range doc.Paths {
+		for method := range item.Operations() {
+			if !registered[method+" "+path] {
+				t.Errorf("documented route %s %s is not registered", method, path)
+			}
+		}
+	}
+}
+
 func routeExists(testRoute, testMethod string, chiRoutes chi.Routes) bool {
 	found := false
 
@@ -1,23 +1,126 @@
 package utils
 
 import (
+	"os"
+	"path/filepath"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	PostgresHost     string `mapstructure:"POSTGRES_HOST"`
-	PostgresUser     string `mapstructure:"POSTGRES_USER"`
-	PostgresPassword string `mapstructure:"POSTGRES_PASSWORD"`
-	PostgresPort     string `mapstructure:"POSTGRES_PORT"`
-	ServerAddress    string `mapstructure:"SERVER_ADDRESS"`
+	PostgresHost            string        `mapstructure:"POSTGRES_HOST"`
+	PostgresUser            string        `mapstructure:"POSTGRES_USER"`
+	PostgresPassword        string        `mapstructure:"POSTGRES_PASSWORD"`
+	PostgresPort            int           `mapstructure:"POSTGRES_PORT"`
+	PostgresDBName          string        `mapstructure:"POSTGRES_DB_NAME"`
+	PostgresSSLMode         string        `mapstructure:"POSTGRES_SSL_MODE"`
+	PostgresMaxOpenConns    int           `mapstructure:"POSTGRES_MAX_OPEN_CONNS"`
+	PostgresMaxIdleConns    int           `mapstructure:"POSTGRES_MAX_IDLE_CONNS"`
+	PostgresConnMaxIdleTime time.Duration `mapstructure:"POSTGRES_CONN_MAX_IDLE_TIME"`
+	PostgresConnMaxLifetime time.Duration `mapstructure:"POSTGRES_CONN_MAX_LIFETIME"`
+	PostgresConnectTimeout  time.Duration `mapstructure:"POSTGRES_CONNECT_TIMEOUT"`
+	PostgresConnectRetries  int           `mapstructure:"POSTGRES_CONNECT_RETRIES"`
+	PostgresConnectBackoff  time.Duration `mapstructure:"POSTGRES_CONNECT_BACKOFF"`
+	// PostgresReplicas is a comma separated list of the connection strings
+	// of read replicas.
+	PostgresReplicas              string        `mapstructure:"POSTGRES_REPLICAS"`
+	PostgresReplicaHealthInterval time.Duration `mapstructure:"POSTGRES_REPLICA_HEALTH_INTERVAL"`
+	ReadYourWritesWindow          time.Duration `mapstructure:"READ_YOUR_WRITES_WINDOW"`
+	ServerAddress                 string        `mapstructure:"SERVER_ADDRESS"`
+	CompanyStore                  string        `mapstructure:"COMPANY_STORE"`
+	SQLitePath                    string        `mapstructure:"SQLITE_PATH"`
+	DBAutoMigrate                 bool          `mapstructure:"DB_AUTO_MIGRATE"`
+	DBReadTimeout                 time.Duration `mapstructure:"DB_READ_TIMEOUT"`
+	DBWriteTimeout                time.Duration `mapstructure:"DB_WRITE_TIMEOUT"`
+	DBBulkTimeout                 time.Duration `mapstructure:"DB_BULK_TIMEOUT"`
+	GRPCAddress                   string        `mapstructure:"GRPC_ADDRESS"`
+	PurgeRetention                time.Duration `mapstructure:"PURGE_RETENTION"`
+	PurgeInterval                 time.Duration `mapstructure:"PURGE_INTERVAL"`
+	JobsDir                       string        `mapstructure:"JOBS_DIR"`
+	JobsConcurrency               int           `mapstructure:"JOBS_CONCURRENCY"`
+	JobsPollInterval              time.Duration `mapstructure:"JOBS_POLL_INTERVAL"`
+	JobsMaxAttempts               int           `mapstructure:"JOBS_MAX_ATTEMPTS"`
+	JobsStaleAfter                time.Duration `mapstructure:"JOBS_STALE_AFTER"`
+	IdempotencyTTL                time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+	IdempotencyLease              time.Duration `mapstructure:"IDEMPOTENCY_LEASE"`
+	RateLimitStore                string        `mapstructure:"RATE_LIMIT_STORE"`
+	RateLimitDefault              string        `mapstructure:"RATE_LIMIT_DEFAULT"`
+	RateLimitRoutes               string        `mapstructure:"RATE_LIMIT_ROUTES"`
+	RedisURL                      string        `mapstructure:"REDIS_URL"`
+	CacheStore                    string        `mapstructure:"CACHE_STORE"`
+	CacheSize                     int           `mapstructure:"CACHE_SIZE"`
+	CacheTTL                      time.Duration `mapstructure:"CACHE_TTL"`
+	StreamSource                  string        `mapstructure:"STREAM_SOURCE"`
+	StreamHistory                 int           `mapstructure:"STREAM_HISTORY"`
+	GraphQLMaxDepth               int           `mapstructure:"GRAPHQL_MAX_DEPTH"`
+	GraphQLMaxComplexity          int           `mapstructure:"GRAPHQL_MAX_COMPLEXITY"`
+	WebhookConcurrency            int           `mapstructure:"WEBHOOK_CONCURRENCY"`
+	WebhookPollInterval           time.Duration `mapstructure:"WEBHOOK_POLL_INTERVAL"`
+	WebhookTimeout                time.Duration `mapstructure:"WEBHOOK_TIMEOUT"`
+	WebhookMaxAttempts            int           `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookBackoff                time.Duration `mapstructure:"WEBHOOK_BACKOFF"`
+	WebhookMaxBackoff             time.Duration `mapstructure:"WEBHOOK_MAX_BACKOFF"`
+	WebhookDisableAfter           int           `mapstructure:"WEBHOOK_DISABLE_AFTER"`
+	WebhookAllowPrivateNetworks   bool          `mapstructure:"WEBHOOK_ALLOW_PRIVATE_NETWORKS"`
+	OpenAPIValidate               bool          `mapstructure:"OPENAPI_VALIDATE"`
 }
 
 func LoadConfig(path string) (config *Config, err error) {
 	viper.AddConfigPath(path)
-	viper.SetConfigFile("app")
+	viper.SetConfigName("app")
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
 
+	viper.SetDefault("POSTGRES_PORT", 5432)
+	viper.SetDefault("POSTGRES_SSL_MODE", "disable")
+	viper.SetDefault("POSTGRES_MAX_OPEN_CONNS", 25)
+	viper.SetDefault("POSTGRES_MAX_IDLE_CONNS", 25)
+	viper.SetDefault("POSTGRES_CONN_MAX_IDLE_TIME", "15m")
+	viper.SetDefault("POSTGRES_CONN_MAX_LIFETIME", "0s")
+	viper.SetDefault("POSTGRES_CONNECT_TIMEOUT", "5s")
+	viper.SetDefault("POSTGRES_CONNECT_RETRIES", 5)
+	viper.SetDefault("POSTGRES_CONNECT_BACKOFF", "1s")
+	viper.SetDefault("POSTGRES_REPLICAS", "")
+	viper.SetDefault("POSTGRES_REPLICA_HEALTH_INTERVAL", "10s")
+	viper.SetDefault("READ_YOUR_WRITES_WINDOW", "0s")
+	viper.SetDefault("GRPC_ADDRESS", "localhost:9000")
+	viper.SetDefault("COMPANY_STORE", "postgres")
+	viper.SetDefault("SQLITE_PATH", "xm_companies.db")
+	viper.SetDefault("DB_AUTO_MIGRATE", false)
+	viper.SetDefault("DB_READ_TIMEOUT", "5s")
+	viper.SetDefault("DB_WRITE_TIMEOUT", "10s")
+	viper.SetDefault("DB_BULK_TIMEOUT", "10m")
+	viper.SetDefault("PURGE_RETENTION", "720h")
+	viper.SetDefault("PURGE_INTERVAL", "1h")
+	viper.SetDefault("JOBS_DIR", filepath.Join(os.TempDir(), "xm-companies-jobs"))
+	viper.SetDefault("JOBS_CONCURRENCY", 2)
+	viper.SetDefault("JOBS_POLL_INTERVAL", "1s")
+	viper.SetDefault("JOBS_MAX_ATTEMPTS", 3)
+	viper.SetDefault("JOBS_STALE_AFTER", "5m")
+	viper.SetDefault("IDEMPOTENCY_TTL", "24h")
+	viper.SetDefault("IDEMPOTENCY_LEASE", "1m")
+	viper.SetDefault("RATE_LIMIT_STORE", "memory")
+	viper.SetDefault("RATE_LIMIT_DEFAULT", "120/1m")
+	viper.SetDefault("RATE_LIMIT_ROUTES", "")
+	viper.SetDefault("REDIS_URL", "redis://localhost:6379/0")
+	viper.SetDefault("CACHE_STORE", "memory")
+	viper.SetDefault("CACHE_SIZE", 10000)
+	viper.SetDefault("CACHE_TTL", "5m")
+	viper.SetDefault("STREAM_SOURCE", "local")
+	viper.SetDefault("STREAM_HISTORY", 1000)
+	viper.SetDefault("GRAPHQL_MAX_DEPTH", 10)
+	viper.SetDefault("GRAPHQL_MAX_COMPLEXITY", 1000)
+	viper.SetDefault("WEBHOOK_CONCURRENCY", 2)
+	viper.SetDefault("WEBHOOK_POLL_INTERVAL", "1s")
+	viper.SetDefault("WEBHOOK_TIMEOUT", "10s")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 8)
+	viper.SetDefault("WEBHOOK_BACKOFF", "30s")
+	viper.SetDefault("WEBHOOK_MAX_BACKOFF", "1h")
+	viper.SetDefault("WEBHOOK_DISABLE_AFTER", 20)
+	viper.SetDefault("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false)
+	viper.SetDefault("OPENAPI_VALIDATE", false)
+
 	if err = viper.ReadInConfig(); err != nil {
 		return
 	}
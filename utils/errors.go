@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -14,6 +15,10 @@ var (
 	ErrSingleJSON     = errors.New("body must only contain a single JSON value")
 )
 
+// StatusClientClosedRequest is the non-standard status, borrowed from nginx,
+// of the requests whose client went away before they were processed.
+const StatusClientClosedRequest = 499
+
 func LogError(err error) {
 	logger := log.New(os.Stdout, "[ERROR] ", log.Ldate|log.Ltime)
 	logger.Println(err)
@@ -29,7 +34,18 @@ func ErrorResponse(w http.ResponseWriter, r *http.Request, status int, message a
 	}
 }
 
+// ServerErrorResponse responds with 500, unless err reports that the request
+// was cancelled or timed out, in which case it responds with 499 or 504.
 func ServerErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		ClientClosedRequestResponse(w, r)
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		TimeoutResponse(w, r)
+		return
+	}
+
 	LogError(err)
 	message := "the server encountered a problem and could not process your request"
 	ErrorResponse(w, r, http.StatusInternalServerError, message)
@@ -43,3 +59,36 @@ func NotFoundResponse(w http.ResponseWriter, r *http.Request) {
 func BadRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
 	ErrorResponse(w, r, http.StatusBadRequest, err.Error())
 }
+
+func ForbiddenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you do not have the permissions to perform this action"
+	ErrorResponse(w, r, http.StatusForbidden, message)
+}
+
+func ConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	ErrorResponse(w, r, http.StatusConflict, err.Error())
+}
+
+func FailedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+	ErrorResponse(w, r, http.StatusUnprocessableEntity, errors)
+}
+
+func UnsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the request body has an unsupported media type"
+	ErrorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
+func RateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	message := "rate limit exceeded"
+	ErrorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func ClientClosedRequestResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the request was cancelled before it could be processed"
+	ErrorResponse(w, r, StatusClientClosedRequest, message)
+}
+
+func TimeoutResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the request took too long to process"
+	ErrorResponse(w, r, http.StatusGatewayTimeout, message)
+}
@@ -1,8 +1,12 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -24,3 +28,24 @@ func Test_LogError(t *testing.T) {
 		t.Errorf("incorrect log error: expected %s but got %s", expected, string(result))
 	}
 }
+
+func Test_ServerErrorResponse(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"server error", errors.New("boom"), http.StatusInternalServerError},
+		{"cancelled", fmt.Errorf("getting company: %w", context.Canceled), StatusClientClosedRequest},
+		{"timed out", context.DeadlineExceeded, http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range testCases {
+		rr := httptest.NewRecorder()
+		ServerErrorResponse(rr, httptest.NewRequest("GET", "/", nil), tt.err)
+
+		if rr.Code != tt.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", tt.name, tt.expectedStatus, rr.Code)
+		}
+	}
+}
@@ -7,14 +7,40 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
 	"github.com/google/uuid"
 )
 
 type Envelope map[string]any
 
 func ReadIDParam(r *http.Request) uuid.UUID {
-	id := chi.URLParamFromCtx(r.Context(), "id")
-	return uuid.MustParse(id)
+	return ReadUUIDParam(r, "id")
+}
+
+// ReadUUIDParam returns the URL parameter with the given name as a UUID.
+func ReadUUIDParam(r *http.Request, name string) uuid.UUID {
+	return uuid.MustParse(chi.URLParamFromCtx(r.Context(), name))
+}
+
+// ReadSubject returns the subject of the request's JWT, if any.
+func ReadSubject(r *http.Request) string {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// IsOwner reports whether the request's JWT subject is createdBy. A request
+// without a subject owns nothing, not even what was created without one.
+func IsOwner(r *http.Request, createdBy string) bool {
+	sub := ReadSubject(r)
+	return sub != "" && sub == createdBy
+}
+
+// IsAdmin reports whether the request's JWT carries the admin role.
+func IsAdmin(r *http.Request) bool {
+	_, claims, _ := jwtauth.FromContext(r.Context())
+	role, _ := claims["role"].(string)
+	return role == "admin"
 }
 
 func WriteJSON(w http.ResponseWriter, status int, data any, headers ...http.Header) error {